@@ -0,0 +1,123 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrReject can be returned (wrapped) by a Handler to reject a message
+// without requeueing it, e.g. to dead-letter malformed messages.
+var ErrReject = errors.New("reject message")
+
+// Handler handles a delivered message. The message is acknowledged if the
+// handler returns nil, rejected if the error wraps ErrReject and requeued
+// for any other error.
+type Handler func(ctx context.Context, d amqp091.Delivery) error
+
+type consumer struct {
+	queue   string
+	handler Handler
+}
+
+// Consume registers the handler for the messages of the queue. Consumers
+// need to be registered before the Config is served.
+func (c *Config) Consume(queue string, h Handler) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.consumers = append(c.consumers, consumer{queue: queue, handler: h})
+}
+
+// serve runs the consumers on the current connection until the connection
+// is closed or the context is canceled. In-flight messages are handled
+// before it returns.
+func (c *Config) serve(ctx context.Context) error {
+	c.mtx.Lock()
+	conn, consumers := c.conn, c.consumers
+	c.mtx.Unlock()
+	if conn == nil || conn.IsClosed() {
+		return ErrNotConnected
+	}
+	closed := conn.NotifyClose(make(chan *amqp091.Error, 1))
+
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for _, cons := range consumers {
+		deliveries, err := c.subscribe(cctx, conn, cons.queue)
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				c.deliver(ctx, cons, d)
+			}
+		}()
+	}
+
+	select {
+	case err := <-closed:
+		if err == nil {
+			return amqp091.ErrClosed
+		}
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// subscribe opens a channel consuming the queue until the context is
+// canceled.
+func (c *Config) subscribe(ctx context.Context, conn *amqp091.Connection, queue string) (<-chan amqp091.Delivery, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("unable to open consumer channel: %w", err)
+	}
+	if err = ch.Qos(c.Prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("unable to set prefetch: %w", err)
+	}
+	deliveries, err := ch.ConsumeWithContext(ctx, queue, "", false, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to consume queue %s: %w", queue, err)
+	}
+	return deliveries, nil
+}
+
+// deliver passes the message to the handler and acknowledges, rejects or
+// requeues it based on the outcome.
+func (c *Config) deliver(ctx context.Context, cons consumer, d amqp091.Delivery) {
+	err := cons.handler(ctx, d)
+	switch {
+	case err == nil:
+		err = d.Ack(false)
+	case errors.Is(err, ErrReject):
+		log.Error("message rejected", err, "queue", cons.queue)
+		err = d.Reject(false)
+	default:
+		log.Error("handling message failed, requeueing", err, "queue", cons.queue)
+		err = d.Nack(false, true)
+	}
+	if err != nil {
+		log.Error("unable to acknowledge message", err, "queue", cons.queue)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrNotConfirmed is returned if the server negatively acknowledged a
+// published message.
+var ErrNotConfirmed = errors.New("amqp message not confirmed")
+
+// Publish publishes the message and waits for the server to confirm it.
+func (c *Config) Publish(ctx context.Context, exchange, key string, msg amqp091.Publishing) error {
+	c.mtx.Lock()
+	pub := c.pub
+	c.mtx.Unlock()
+	if pub == nil {
+		return ErrNotConnected
+	}
+
+	dc, err := pub.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+	if err != nil {
+		return fmt.Errorf("amqp publish failed: %w", err)
+	}
+	ok, err := dc.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotConfirmed
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package amqp
+
+import (
+	"fmt"
+
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+// Exchange holds the declaration of an exchange.
+type Exchange struct {
+	Name string
+	// Kind holds the exchange type: direct, fanout, topic or headers.
+	Kind       string
+	Durable    bool
+	AutoDelete bool
+	Args       amqp091.Table
+}
+
+// Queue holds the declaration of a queue and its bindings.
+type Queue struct {
+	Name       string
+	Durable    bool
+	AutoDelete bool
+	Exclusive  bool
+	Args       amqp091.Table
+	Bindings   []Binding
+}
+
+// Binding binds a queue to an exchange.
+type Binding struct {
+	Exchange   string
+	RoutingKey string
+	Args       amqp091.Table
+}
+
+// declare declares the configured exchanges, queues and bindings.
+func (c *Config) declare(conn *amqp091.Connection) error {
+	if len(c.Exchanges) == 0 && len(c.Queues) == 0 {
+		return nil
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("unable to open channel: %w", err)
+	}
+	defer func() { _ = ch.Close() }()
+
+	for _, e := range c.Exchanges {
+		if err = ch.ExchangeDeclare(e.Name, e.Kind, e.Durable, e.AutoDelete,
+			false, false, e.Args); err != nil {
+			return fmt.Errorf("unable to declare exchange %s: %w", e.Name, err)
+		}
+	}
+	for _, q := range c.Queues {
+		if _, err = ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete,
+			q.Exclusive, false, q.Args); err != nil {
+			return fmt.Errorf("unable to declare queue %s: %w", q.Name, err)
+		}
+		for _, b := range q.Bindings {
+			if err = ch.QueueBind(q.Name, b.RoutingKey, b.Exchange, false, b.Args); err != nil {
+				return fmt.Errorf("unable to bind queue %s to %s: %w", q.Name, b.Exchange, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package awssecrets provides a run handler resolving configuration values
+// from AWS Secrets Manager and SSM Parameter Store.
+package awssecrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("awssecrets", "AWS secrets resolver")
+
+// Supported reference schemes.
+const (
+	SchemeSecretsManager = "secretsmanager://"
+	SchemeSSM            = "ssm://"
+)
+
+// package flags.
+const (
+	defaultTimeout = 30 * time.Second
+
+	Region          = "aws-secrets-region"
+	Endpoint        = "aws-secrets-endpoint"
+	RefreshInterval = "aws-secrets-refresh-interval"
+	Timeout         = "aws-secrets-timeout"
+)
+
+var (
+	// ErrInvalidReference is returned for malformed references.
+	ErrInvalidReference = errors.New("invalid secret reference")
+	// ErrKeyNotFound is returned if a referenced JSON key is not found in
+	// the secret.
+	ErrKeyNotFound = errors.New("secret key not found")
+)
+
+// Config implements run.Config to resolve configuration values referencing
+// AWS Secrets Manager secrets or SSM parameters. Values registered with
+// Resolve are resolved in Validate, so the Config needs to be registered
+// with the run.Group before the Configs owning the values. Supported
+// references:
+//
+//	secretsmanager://<name or ARN>[#<JSON key>]
+//	ssm://<parameter name>
+//
+// If RefreshInterval is set, ServeContext periodically resolves the
+// references again and notifies watchers of changed values.
+type Config struct {
+	Prefix string
+
+	Region          string
+	Endpoint        string
+	RefreshInterval time.Duration
+	Timeout         time.Duration
+
+	sm  *secretsmanager.Client
+	ssm *ssm.Client
+
+	mtx      sync.Mutex
+	bindings []*binding
+}
+
+type binding struct {
+	dst      *string
+	ref      string
+	value    string
+	watchers []chan string
+}
+
+func (c *Config) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("aws-secrets")
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	if c.Timeout == 0 {
+		c.Timeout = defaultTimeout
+	}
+
+	flags := run.NewFlagSet("AWS secrets options")
+
+	flags.StringVar(&c.Region, c.prefix(Region),
+		c.Region, "AWS region (defaults to the AWS environment configuration)")
+
+	flags.StringVar(&c.Endpoint, c.prefix(Endpoint),
+		c.Endpoint, "custom AWS endpoint URL (e.g. for local testing)")
+
+	flags.DurationVar(&c.RefreshInterval, c.prefix(RefreshInterval),
+		c.RefreshInterval, "interval for refreshing resolved values (0 disables)")
+
+	flags.DurationVar(&c.Timeout, c.prefix(Timeout),
+		c.Timeout, "timeout for resolving values")
+
+	return flags
+}
+
+// Validate implements run.Config. It resolves the registered references.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if c.RefreshInterval < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(RefreshInterval), flag.ErrInvalidVal))
+	}
+	if c.Timeout <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Timeout), flag.ErrInvalidVal))
+	}
+	if mErr != nil {
+		return mErr
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if len(c.bindings) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	if err := c.connect(ctx); err != nil {
+		return err
+	}
+	for _, b := range c.bindings {
+		v, err := c.Lookup(ctx, b.ref)
+		if err != nil {
+			mErr = multierror.Append(mErr, err)
+			continue
+		}
+		b.value, *b.dst = v, v
+	}
+	return mErr
+}
+
+func (c *Config) connect(ctx context.Context) error {
+	if c.sm != nil {
+		return nil
+	}
+	var opts []func(*awsconfig.LoadOptions) error
+	if c.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(c.Region))
+	}
+	if c.Endpoint != "" {
+		opts = append(opts, awsconfig.WithBaseEndpoint(c.Endpoint))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("unable to load AWS configuration: %w", err)
+	}
+	c.sm = secretsmanager.NewFromConfig(cfg)
+	c.ssm = ssm.NewFromConfig(cfg)
+	return nil
+}
+
+// IsReference returns true if s holds a supported secret reference.
+func IsReference(s string) bool {
+	return strings.HasPrefix(s, SchemeSecretsManager) || strings.HasPrefix(s, SchemeSSM)
+}
+
+// Resolve registers configuration values to resolve. Values not holding a
+// reference are left untouched. Resolve needs to be called before the
+// Validate of this Config, e.g. directly after registering the Configs.
+func (c *Config) Resolve(dst ...*string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, d := range dst {
+		if IsReference(*d) {
+			c.bindings = append(c.bindings, &binding{dst: d, ref: *d})
+		}
+	}
+}
+
+// Watch returns a channel receiving the new value whenever the value
+// registered with Resolve changes during a refresh. The channel only holds
+// the latest value. Watch returns nil if dst was not registered.
+func (c *Config) Watch(dst *string) <-chan string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, b := range c.bindings {
+		if b.dst == dst {
+			ch := make(chan string, 1)
+			b.watchers = append(b.watchers, ch)
+			return ch
+		}
+	}
+	return nil
+}
+
+// Lookup resolves the reference. It can be used after Validate.
+func (c *Config) Lookup(ctx context.Context, ref string) (string, error) {
+	if name, ok := strings.CutPrefix(ref, SchemeSSM); ok && name != "" {
+		out, err := c.ssm.GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to get SSM parameter %s: %w", name, err)
+		}
+		return aws.ToString(out.Parameter.Value), nil
+	}
+
+	name, ok := strings.CutPrefix(ref, SchemeSecretsManager)
+	if !ok || name == "" {
+		return "", fmt.Errorf("%w: %s", ErrInvalidReference, ref)
+	}
+	name, key, hasKey := strings.Cut(name, "#")
+	out, err := c.sm.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to get secret %s: %w", name, err)
+	}
+	value := aws.ToString(out.SecretString)
+	if !hasKey {
+		return value, nil
+	}
+
+	var fields map[string]any
+	if err = json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", name, err)
+	}
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s#%s", ErrKeyNotFound, name, key)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, _ := json.Marshal(v)
+	return string(b), nil
+}
+
+// ServeContext implements run.ServiceContext.
+func (c *Config) ServeContext(ctx context.Context) error {
+	if c.RefreshInterval == 0 || len(c.bindings) == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(c.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.refresh(ctx)
+		}
+	}
+}
+
+// refresh resolves the registered references and notifies the watchers of
+// changed values.
+func (c *Config) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, b := range c.bindings {
+		v, err := c.Lookup(ctx, b.ref)
+		if err != nil {
+			log.Error("unable to refresh secret", err, "ref", b.ref)
+			continue
+		}
+		if v == b.value {
+			continue
+		}
+		log.Info("secret changed", "ref", b.ref)
+		b.value, *b.dst = v, v
+		for _, ch := range b.watchers {
+			// replace a pending value not yet received
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- v
+		}
+	}
+}
+
+var (
+	_ run.Config         = (*Config)(nil)
+	_ run.ServiceContext = (*Config)(nil)
+)
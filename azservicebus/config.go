@@ -0,0 +1,155 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azservicebus provides run handlers for sending to and receiving
+// from Azure Service Bus. The connection settings held by Config are shared
+// by the Sender and Receiver handlers.
+package azservicebus
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("azure-servicebus", "Azure Service Bus sender and receiver")
+
+// package flags.
+const (
+	namespaceSuffix = ".servicebus.windows.net"
+
+	ConnectionString        = "azure-servicebus-connection-string"
+	Namespace               = "azure-servicebus-namespace"
+	ManagedIdentityClientID = "azure-servicebus-managed-identity-client-id"
+)
+
+// Config implements run.Config to allow configuration of the Service Bus
+// connection settings shared by Sender and Receiver.
+//
+// Clients authenticate with the connection string if set. Otherwise they
+// connect to the Namespace using Microsoft Entra ID: with the user-assigned
+// managed identity of ManagedIdentityClientID if set, or else the
+// azidentity DefaultAzureCredential chain (environment, workload identity,
+// system-assigned managed identity, Azure CLI).
+type Config struct {
+	Prefix string
+
+	ConnectionString string
+	// Namespace holds the fully qualified namespace, e.g.
+	// "example.servicebus.windows.net". A namespace name without domain is
+	// completed with the public cloud domain.
+	Namespace               string
+	ManagedIdentityClientID string
+
+	// Credential optionally overrides the Entra ID credential.
+	Credential azcore.TokenCredential
+	// Options optionally holds the client options.
+	Options *azservicebus.ClientOptions
+}
+
+// prefix returns the name prefixed with the Config prefix. It is safe to call
+// on a nil Config, so Sender and Receiver can report a missing Config in
+// Validate.
+func (c *Config) prefix(s string) string {
+	if c != nil && c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("azure-servicebus")
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Azure Service Bus options")
+
+	if cs := os.Getenv("AZURE_SERVICEBUS_CONNECTION_STRING"); cs != "" {
+		c.ConnectionString = cs
+	}
+
+	flags.SensitiveStringVar(&c.ConnectionString, c.prefix(ConnectionString),
+		c.ConnectionString, "connection string (uses Entra ID authentication if not set)")
+
+	flags.StringVar(&c.Namespace, c.prefix(Namespace),
+		c.Namespace, "fully qualified namespace for Entra ID authentication")
+
+	flags.StringVar(&c.ManagedIdentityClientID, c.prefix(ManagedIdentityClientID),
+		c.ManagedIdentityClientID, "client ID of the user-assigned managed identity")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if c.ConnectionString == "" && c.Namespace == "" {
+		mErr = multierror.Append(mErr, flag.NewValidationError(c.prefix(Namespace),
+			flag.ValidationError("namespace or connection string is required")))
+	}
+	if c.ConnectionString != "" && c.ManagedIdentityClientID != "" {
+		mErr = multierror.Append(mErr, flag.NewValidationError(c.prefix(ManagedIdentityClientID),
+			flag.ValidationError("can't be combined with a connection string")))
+	}
+	if c.Namespace != "" && !strings.Contains(c.Namespace, ".") {
+		c.Namespace += namespaceSuffix
+	}
+
+	return mErr
+}
+
+// newClient creates a Service Bus client using the configured settings.
+func (c *Config) newClient() (*azservicebus.Client, error) {
+	if c.ConnectionString != "" {
+		client, err := azservicebus.NewClientFromConnectionString(c.ConnectionString, c.Options)
+		if err != nil {
+			return nil, fmt.Errorf("service bus client creation failed: %w", err)
+		}
+		return client, nil
+	}
+
+	cred := c.Credential
+	if cred == nil {
+		var err error
+		if c.ManagedIdentityClientID != "" {
+			cred, err = azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+				ID: azidentity.ClientID(c.ManagedIdentityClientID),
+			})
+		} else {
+			cred, err = azidentity.NewDefaultAzureCredential(nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("service bus credential creation failed: %w", err)
+		}
+	}
+	client, err := azservicebus.NewClient(c.Namespace, cred, c.Options)
+	if err != nil {
+		return nil, fmt.Errorf("service bus client creation failed: %w", err)
+	}
+	return client, nil
+}
+
+var _ run.Config = (*Config)(nil)
@@ -0,0 +1,453 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azservicebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// receiver flags.
+const (
+	defaultConcurrency        = 10
+	defaultMaxSessions        = 8
+	defaultSessionIdleTimeout = 30 * time.Second
+	defaultSettleTimeout      = 10 * time.Second
+
+	ReceiverEntity     = "azure-servicebus-receiver-entity"
+	Sessions           = "azure-servicebus-receiver-sessions"
+	Concurrency        = "azure-servicebus-receiver-concurrency"
+	Prefetch           = "azure-servicebus-receiver-prefetch"
+	MaxSessions        = "azure-servicebus-receiver-max-sessions"
+	SessionIdleTimeout = "azure-servicebus-receiver-session-idle-timeout"
+	MaxDeliveries      = "azure-servicebus-receiver-max-deliveries"
+	SettleTimeout      = "azure-servicebus-receiver-settle-timeout"
+)
+
+// Handler handles a received message. The message is completed if the
+// handler returns nil and abandoned (for redelivery) otherwise, unless the
+// error is created by DeadLetter.
+type Handler func(ctx context.Context, msg *azservicebus.ReceivedMessage) error
+
+// deadLetterError holds the reason for dead-lettering a message.
+type deadLetterError struct {
+	reason string
+	err    error
+}
+
+func (e *deadLetterError) Error() string {
+	if e.err == nil {
+		return "dead-letter: " + e.reason
+	}
+	return "dead-letter: " + e.reason + ": " + e.err.Error()
+}
+
+func (e *deadLetterError) Unwrap() error { return e.err }
+
+// DeadLetter returns an error for handlers to move the message to the dead
+// letter queue instead of abandoning it, e.g. for messages which can't be
+// processed.
+func DeadLetter(reason string, err error) error {
+	return &deadLetterError{reason: reason, err: err}
+}
+
+// receiver is implemented by azservicebus.Receiver and
+// azservicebus.SessionReceiver.
+type receiver interface {
+	ReceiveMessages(ctx context.Context, maxMessages int,
+		options *azservicebus.ReceiveMessagesOptions) ([]*azservicebus.ReceivedMessage, error)
+	CompleteMessage(ctx context.Context, msg *azservicebus.ReceivedMessage,
+		options *azservicebus.CompleteMessageOptions) error
+	AbandonMessage(ctx context.Context, msg *azservicebus.ReceivedMessage,
+		options *azservicebus.AbandonMessageOptions) error
+	DeadLetterMessage(ctx context.Context, msg *azservicebus.ReceivedMessage,
+		options *azservicebus.DeadLetterOptions) error
+	Close(ctx context.Context) error
+}
+
+// Receiver implements a run.Config and run.ServiceContext receiving the
+// messages of a Service Bus queue or topic subscription.
+//
+// Without sessions, messages are handled by Concurrency concurrent workers,
+// with Prefetch messages requested ahead (defaults to Concurrency). Note
+// that the lock of prefetched messages expires while they wait. With
+// sessions, up to MaxSessions sessions are handled concurrently, each
+// handling its messages in order; a session is released once no message
+// arrives for SessionIdleTimeout.
+//
+// Messages failing MaxDeliveries times are dead-lettered by the Receiver; if
+// 0, the max. delivery count of the entity applies.
+type Receiver struct {
+	// Config holds the shared connection settings.
+	Config *Config
+
+	// Entity holds the queue or "topic/subscription" to receive from.
+	Entity             string
+	Sessions           bool
+	Concurrency        int
+	Prefetch           int
+	MaxSessions        int
+	SessionIdleTimeout time.Duration
+	MaxDeliveries      int
+	SettleTimeout      time.Duration
+
+	// Handler handles the received messages.
+	Handler Handler
+	// OnError is called for messages the handler returned an error for. If
+	// not set, the error is logged.
+	OnError func(msg *azservicebus.ReceivedMessage, err error)
+
+	client *azservicebus.Client
+}
+
+// Name implements run.Unit.
+func (r *Receiver) Name() string {
+	return r.Config.prefix("azure-servicebus-receiver")
+}
+
+// FlagSet implements run.Config.
+func (r *Receiver) FlagSet() *run.FlagSet {
+	if r.Concurrency == 0 {
+		r.Concurrency = defaultConcurrency
+	}
+	if r.MaxSessions == 0 {
+		r.MaxSessions = defaultMaxSessions
+	}
+	if r.SessionIdleTimeout == 0 {
+		r.SessionIdleTimeout = defaultSessionIdleTimeout
+	}
+	if r.SettleTimeout == 0 {
+		r.SettleTimeout = defaultSettleTimeout
+	}
+
+	flags := run.NewFlagSet("Azure Service Bus receiver options")
+
+	flags.StringVar(&r.Entity, r.Config.prefix(ReceiverEntity),
+		r.Entity, `queue or "topic/subscription" to receive from`)
+
+	flags.BoolVar(&r.Sessions, r.Config.prefix(Sessions),
+		r.Sessions, "receive from a session enabled entity")
+
+	flags.IntVar(&r.Concurrency, r.Config.prefix(Concurrency),
+		r.Concurrency, "number of messages handled concurrently (without sessions)")
+
+	flags.IntVar(&r.Prefetch, r.Config.prefix(Prefetch),
+		r.Prefetch, "number of messages requested ahead (0 for concurrency)")
+
+	flags.IntVar(&r.MaxSessions, r.Config.prefix(MaxSessions),
+		r.MaxSessions, "number of sessions handled concurrently")
+
+	flags.DurationVar(&r.SessionIdleTimeout, r.Config.prefix(SessionIdleTimeout),
+		r.SessionIdleTimeout, "time without messages after which a session is released")
+
+	flags.IntVar(&r.MaxDeliveries, r.Config.prefix(MaxDeliveries),
+		r.MaxDeliveries, "failed deliveries after which a message is dead-lettered (0 for the entity setting)")
+
+	flags.DurationVar(&r.SettleTimeout, r.Config.prefix(SettleTimeout),
+		r.SettleTimeout, "timeout for settling a handled message")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (r *Receiver) Validate() error {
+	var mErr error
+
+	if r.Config == nil {
+		return errors.New("service bus receiver requires a connection config")
+	}
+	if r.Entity == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(r.Config.prefix(ReceiverEntity), flag.ErrRequired))
+	} else if topic, sub, ok := strings.Cut(r.Entity, "/"); ok && (topic == "" || sub == "") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(r.Config.prefix(ReceiverEntity), flag.ErrInvalidVal))
+	}
+	if r.Concurrency < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(r.Config.prefix(Concurrency), flag.ErrInvalidVal))
+	}
+	if r.Prefetch < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(r.Config.prefix(Prefetch), flag.ErrInvalidVal))
+	}
+	if r.MaxSessions < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(r.Config.prefix(MaxSessions), flag.ErrInvalidVal))
+	}
+	if r.SessionIdleTimeout <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(r.Config.prefix(SessionIdleTimeout), flag.ErrInvalidVal))
+	}
+	if r.MaxDeliveries < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(r.Config.prefix(MaxDeliveries), flag.ErrInvalidVal))
+	}
+	if r.SettleTimeout <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(r.Config.prefix(SettleTimeout), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (r *Receiver) PreRun() (err error) {
+	if r.Handler == nil {
+		return errors.New("service bus receiver has no handler")
+	}
+	r.client, err = r.Config.newClient()
+	return err
+}
+
+// ServeContext implements run.ServiceContext. It dispatches the received
+// messages to the handler until the context is canceled, after which it
+// waits for the messages being handled.
+func (r *Receiver) ServeContext(ctx context.Context) error {
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), defaultCloseTimeout)
+		defer cancel()
+		if err := r.client.Close(closeCtx); err != nil {
+			log.Error("unable to close service bus client", err)
+		}
+	}()
+
+	if r.Sessions {
+		return r.serveSessions(ctx)
+	}
+
+	var (
+		rcv receiver
+		err error
+	)
+	if topic, sub, ok := strings.Cut(r.Entity, "/"); ok {
+		rcv, err = r.client.NewReceiverForSubscription(topic, sub, nil)
+	} else {
+		rcv, err = r.client.NewReceiverForQueue(r.Entity, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("service bus receiver creation failed: %w", err)
+	}
+	defer r.close(rcv)
+
+	prefetch := r.Prefetch
+	if prefetch == 0 {
+		prefetch = r.Concurrency
+	}
+	var (
+		wg   sync.WaitGroup
+		msgs = make(chan *azservicebus.ReceivedMessage, prefetch)
+	)
+	for range r.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range msgs {
+				r.handle(ctx, rcv, msg)
+			}
+		}()
+	}
+	defer func() {
+		close(msgs)
+		wg.Wait()
+	}()
+
+	for {
+		// request no more messages than can be buffered
+		batch, err := rcv.ReceiveMessages(ctx, max(cap(msgs)-len(msgs), 1), nil)
+		if ctx.Err() != nil {
+			// abandon the messages not being handled yet
+			for _, msg := range drain(msgs, batch) {
+				r.settle(rcv, msg, nil)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("service bus receive failed: %w", err)
+		}
+		for _, msg := range batch {
+			msgs <- msg
+		}
+	}
+}
+
+// serveSessions handles up to MaxSessions sessions concurrently.
+func (r *Receiver) serveSessions(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		errc = make(chan error, r.MaxSessions)
+	)
+	for range r.MaxSessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if err := r.serveSession(ctx); err != nil {
+					errc <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errc)
+
+	return <-errc
+}
+
+// serveSession accepts the next available session and handles its messages
+// in order until it is idle.
+func (r *Receiver) serveSession(ctx context.Context) error {
+	var (
+		sr  *azservicebus.SessionReceiver
+		err error
+	)
+	if topic, sub, ok := strings.Cut(r.Entity, "/"); ok {
+		sr, err = r.client.AcceptNextSessionForSubscription(ctx, topic, sub, nil)
+	} else {
+		sr, err = r.client.AcceptNextSessionForQueue(ctx, r.Entity, nil)
+	}
+	var sbErr *azservicebus.Error
+	switch {
+	case ctx.Err() != nil:
+		return nil
+	case errors.As(err, &sbErr) && sbErr.Code == azservicebus.CodeTimeout:
+		// no session available
+		return nil
+	case err != nil:
+		return fmt.Errorf("service bus session accept failed: %w", err)
+	}
+	defer r.close(sr)
+	log.Debug("accepted service bus session", "entity", r.Entity, "session", sr.SessionID())
+
+	for {
+		idleCtx, cancel := context.WithTimeout(ctx, r.SessionIdleTimeout)
+		batch, err := sr.ReceiveMessages(idleCtx, max(r.Prefetch, 1), nil)
+		cancel()
+		if ctx.Err() != nil {
+			for _, msg := range batch {
+				r.settle(sr, msg, nil)
+			}
+			return nil
+		}
+		if len(batch) == 0 {
+			// idle session (or lost session lock), release it
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				log.Error("service bus session receive failed", err, "session", sr.SessionID())
+			}
+			return nil
+		}
+		for i, msg := range batch {
+			if ctx.Err() != nil {
+				// keep the order by abandoning the remaining messages
+				for _, msg := range batch[i:] {
+					r.settle(sr, msg, nil)
+				}
+				return nil
+			}
+			r.handle(ctx, sr, msg)
+		}
+	}
+}
+
+// handle dispatches the message to the handler and settles it.
+func (r *Receiver) handle(ctx context.Context, rcv receiver, msg *azservicebus.ReceivedMessage) {
+	err := r.Handler(ctx, msg)
+	if err != nil {
+		if r.OnError != nil {
+			r.OnError(msg, err)
+		} else {
+			log.Error("handling message failed", err,
+				"entity", r.Entity, "id", msg.MessageID, "deliveries", msg.DeliveryCount)
+		}
+		if r.MaxDeliveries > 0 && int(msg.DeliveryCount) >= r.MaxDeliveries {
+			var dlErr *deadLetterError
+			if !errors.As(err, &dlErr) {
+				err = DeadLetter("max deliveries exceeded", err)
+			}
+		}
+	}
+	r.settle(rcv, msg, err)
+}
+
+// settle completes, abandons or dead-letters the message depending on the
+// handler error. Settling uses its own timeout so handled messages are
+// settled during shutdown.
+func (r *Receiver) settle(rcv receiver, msg *azservicebus.ReceivedMessage, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.SettleTimeout)
+	defer cancel()
+
+	var (
+		dlErr     *deadLetterError
+		settleErr error
+	)
+	switch {
+	case err == nil:
+		settleErr = rcv.CompleteMessage(ctx, msg, nil)
+	case errors.As(err, &dlErr):
+		description := err.Error()
+		settleErr = rcv.DeadLetterMessage(ctx, msg, &azservicebus.DeadLetterOptions{
+			Reason:           &dlErr.reason,
+			ErrorDescription: &description,
+		})
+	default:
+		settleErr = rcv.AbandonMessage(ctx, msg, nil)
+	}
+	if settleErr != nil {
+		log.Error("unable to settle message", settleErr, "entity", r.Entity, "id", msg.MessageID)
+	}
+}
+
+func (r *Receiver) close(rcv receiver) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCloseTimeout)
+	defer cancel()
+	if err := rcv.Close(ctx); err != nil {
+		log.Error("unable to close service bus receiver", err, "entity", r.Entity)
+	}
+}
+
+// drain returns the batch with the buffered messages appended.
+func drain(msgs chan *azservicebus.ReceivedMessage,
+	batch []*azservicebus.ReceivedMessage,
+) []*azservicebus.ReceivedMessage {
+	for {
+		select {
+		case msg := <-msgs:
+			batch = append(batch, msg)
+		default:
+			return batch
+		}
+	}
+}
+
+var (
+	_ run.Config         = (*Receiver)(nil)
+	_ run.PreRunner      = (*Receiver)(nil)
+	_ run.ServiceContext = (*Receiver)(nil)
+)
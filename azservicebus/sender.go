@@ -0,0 +1,164 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azservicebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// sender flags.
+const (
+	defaultCloseTimeout = 10 * time.Second
+
+	SenderEntity = "azure-servicebus-sender-entity"
+)
+
+// Sender implements a run.Config and run.ServiceContext sending to a
+// Service Bus queue or topic. Sends are synchronous; the connection is
+// closed on shutdown.
+type Sender struct {
+	// Config holds the shared connection settings.
+	Config *Config
+
+	// Entity holds the queue or topic to send to.
+	Entity string
+
+	client *azservicebus.Client
+	sender *azservicebus.Sender
+}
+
+// Name implements run.Unit.
+func (s *Sender) Name() string {
+	return s.Config.prefix("azure-servicebus-sender")
+}
+
+// FlagSet implements run.Config.
+func (s *Sender) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Azure Service Bus sender options")
+
+	flags.StringVar(&s.Entity, s.Config.prefix(SenderEntity),
+		s.Entity, "queue or topic to send to")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Sender) Validate() error {
+	var mErr error
+
+	if s.Config == nil {
+		return errors.New("service bus sender requires a connection config")
+	}
+	if s.Entity == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.Config.prefix(SenderEntity), flag.ErrRequired))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Sender) PreRun() (err error) {
+	if s.client, err = s.Config.newClient(); err != nil {
+		return err
+	}
+	if s.sender, err = s.client.NewSender(s.Entity, nil); err != nil {
+		return fmt.Errorf("service bus sender creation failed: %w", err)
+	}
+	return nil
+}
+
+// Send sends the message.
+func (s *Sender) Send(ctx context.Context, msg *azservicebus.Message) error {
+	return s.sender.SendMessage(ctx, msg, nil)
+}
+
+// SendBatch sends the messages in as few batches as their size allows.
+func (s *Sender) SendBatch(ctx context.Context, msgs []*azservicebus.Message) error {
+	var batch *azservicebus.MessageBatch
+	for i := 0; i < len(msgs); {
+		if batch == nil {
+			var err error
+			if batch, err = s.sender.NewMessageBatch(ctx, nil); err != nil {
+				return err
+			}
+		}
+		err := batch.AddMessage(msgs[i], nil)
+		switch {
+		case err == nil:
+			i++
+			continue
+		case !errors.Is(err, azservicebus.ErrMessageTooLarge):
+			return err
+		case batch.NumMessages() == 0:
+			return fmt.Errorf("message %d: %w", i, err)
+		}
+		// batch is full, send it and retry the message with a new batch
+		if err = s.sender.SendMessageBatch(ctx, batch, nil); err != nil {
+			return err
+		}
+		batch = nil
+	}
+	if batch != nil && batch.NumMessages() > 0 {
+		return s.sender.SendMessageBatch(ctx, batch, nil)
+	}
+	return nil
+}
+
+// Schedule schedules the messages to be enqueued at the provided time and
+// returns their sequence numbers, which can be used to cancel them.
+func (s *Sender) Schedule(ctx context.Context, msgs []*azservicebus.Message, at time.Time) ([]int64, error) {
+	return s.sender.ScheduleMessages(ctx, msgs, at, nil)
+}
+
+// CancelScheduled cancels the scheduled messages.
+func (s *Sender) CancelScheduled(ctx context.Context, sequenceNumbers []int64) error {
+	return s.sender.CancelScheduledMessages(ctx, sequenceNumbers, nil)
+}
+
+// ServeContext implements run.ServiceContext. It closes the sender once the
+// context is canceled.
+func (s *Sender) ServeContext(ctx context.Context) error {
+	<-ctx.Done()
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), defaultCloseTimeout)
+	defer cancel()
+	if err := s.sender.Close(closeCtx); err != nil {
+		log.Error("unable to close service bus sender", err, "entity", s.Entity)
+	}
+	if err := s.client.Close(closeCtx); err != nil {
+		log.Error("unable to close service bus client", err)
+	}
+
+	return nil
+}
+
+// Sender returns the Service Bus sender.
+func (s *Sender) Sender() *azservicebus.Sender { return s.sender }
+
+var (
+	_ run.Config         = (*Sender)(nil)
+	_ run.PreRunner      = (*Sender)(nil)
+	_ run.ServiceContext = (*Sender)(nil)
+)
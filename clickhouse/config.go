@@ -0,0 +1,329 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clickhouse provides a run handler managing a ClickHouse connection
+// pool.
+package clickhouse
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	ch "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("clickhouse", "ClickHouse connection pool")
+
+// package flags.
+const (
+	defaultHost            = "localhost:9000"
+	defaultDatabase        = "default"
+	defaultUserName        = "default"
+	defaultProtocol        = "native"
+	defaultCompression     = "lz4"
+	defaultDialTimeout     = 10 * time.Second
+	defaultMaxOpenConns    = 10
+	defaultMaxIdleConns    = 5
+	defaultConnMaxLifetime = time.Hour
+	defaultAsyncTimeout    = 200 * time.Millisecond
+
+	Hosts              = "clickhouse-hosts"
+	Protocol           = "clickhouse-protocol"
+	Database           = "clickhouse-database"
+	UserName           = "clickhouse-username"
+	Password           = "clickhouse-password"
+	TLS                = "clickhouse-tls"
+	TLSCAFile          = "clickhouse-tls-ca-file"
+	TLSInsecure        = "clickhouse-tls-insecure"
+	Compression        = "clickhouse-compression"
+	DialTimeout        = "clickhouse-dial-timeout"
+	MaxOpenConns       = "clickhouse-max-open-conns"
+	MaxIdleConns       = "clickhouse-max-idle-conns"
+	ConnMaxLifetime    = "clickhouse-conn-max-lifetime"
+	AsyncInsert        = "clickhouse-async-insert"
+	AsyncInsertWait    = "clickhouse-async-insert-wait"
+	AsyncInsertTimeout = "clickhouse-async-insert-busy-timeout"
+)
+
+var compressions = map[string]ch.CompressionMethod{
+	"none": ch.CompressionNone,
+	"lz4":  ch.CompressionLZ4,
+	"zstd": ch.CompressionZSTD,
+	"gzip": ch.CompressionGZIP,
+}
+
+// Config implements run.Config to allow configuration of a ClickHouse
+// connection pool.
+type Config struct {
+	Prefix string
+
+	Hosts           []string
+	Protocol        string
+	Database        string
+	UserName        string
+	Password        string
+	TLS             bool
+	TLSCAFile       string
+	TLSInsecure     bool
+	Compression     string
+	DialTimeout     time.Duration
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// AsyncInsert enables server side batching of inserts.
+	AsyncInsert bool
+	// AsyncInsertWait makes inserts wait until the data is flushed.
+	AsyncInsertWait    bool
+	AsyncInsertTimeout time.Duration
+	// Settings holds additional ClickHouse settings applied to all queries.
+	Settings ch.Settings
+
+	conn driver.Conn
+}
+
+func (c *Config) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("clickhouse")
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	if len(c.Hosts) == 0 {
+		c.Hosts = []string{defaultHost}
+	}
+	if c.Protocol == "" {
+		c.Protocol = defaultProtocol
+	}
+	if c.Database == "" {
+		c.Database = defaultDatabase
+	}
+	if c.UserName == "" {
+		c.UserName = defaultUserName
+	}
+	if envPassword := os.Getenv("CLICKHOUSE_PASSWORD"); envPassword != "" {
+		c.Password = envPassword
+	}
+	if c.Compression == "" {
+		c.Compression = defaultCompression
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = defaultDialTimeout
+	}
+	if c.MaxOpenConns == 0 {
+		c.MaxOpenConns = defaultMaxOpenConns
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = defaultMaxIdleConns
+	}
+	if c.ConnMaxLifetime == 0 {
+		c.ConnMaxLifetime = defaultConnMaxLifetime
+	}
+	if c.AsyncInsertTimeout == 0 {
+		c.AsyncInsertTimeout = defaultAsyncTimeout
+	}
+
+	flags := run.NewFlagSet("ClickHouse options")
+
+	flags.StringArrayVar(&c.Hosts, c.prefix(Hosts),
+		c.Hosts, "ClickHouse host:port (can be repeated)")
+
+	flags.StringVar(&c.Protocol, c.prefix(Protocol),
+		c.Protocol, "protocol to use (native or http)")
+
+	flags.StringVar(&c.Database, c.prefix(Database),
+		c.Database, "database name")
+
+	flags.StringVar(&c.UserName, c.prefix(UserName),
+		c.UserName, "username")
+
+	flags.SensitiveStringVar(&c.Password, c.prefix(Password),
+		c.Password, "password")
+
+	flags.BoolVar(&c.TLS, c.prefix(TLS),
+		c.TLS, "connect using TLS")
+
+	flags.StringVar(&c.TLSCAFile, c.prefix(TLSCAFile),
+		c.TLSCAFile, "path to CA file for verifying the server")
+
+	flags.BoolVar(&c.TLSInsecure, c.prefix(TLSInsecure),
+		c.TLSInsecure, "skip verification of the server certificate")
+
+	flags.StringVar(&c.Compression, c.prefix(Compression),
+		c.Compression, "compression method (none, lz4, zstd or gzip)")
+
+	flags.DurationVar(&c.DialTimeout, c.prefix(DialTimeout),
+		c.DialTimeout, "dial timeout")
+
+	flags.IntVar(&c.MaxOpenConns, c.prefix(MaxOpenConns),
+		c.MaxOpenConns, "max. open connections")
+
+	flags.IntVar(&c.MaxIdleConns, c.prefix(MaxIdleConns),
+		c.MaxIdleConns, "max. idle connections")
+
+	flags.DurationVar(&c.ConnMaxLifetime, c.prefix(ConnMaxLifetime),
+		c.ConnMaxLifetime, "max. connection lifetime")
+
+	flags.BoolVar(&c.AsyncInsert, c.prefix(AsyncInsert),
+		c.AsyncInsert, "enable asynchronous inserts")
+
+	flags.BoolVar(&c.AsyncInsertWait, c.prefix(AsyncInsertWait),
+		c.AsyncInsertWait, "wait for asynchronous inserts to be flushed")
+
+	flags.DurationVar(&c.AsyncInsertTimeout, c.prefix(AsyncInsertTimeout),
+		c.AsyncInsertTimeout, "max. time to buffer asynchronous inserts")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if len(c.Hosts) == 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Hosts), flag.ErrRequired))
+	}
+	if c.Protocol != "native" && c.Protocol != "http" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Protocol), flag.ErrInvalidVal))
+	}
+	if _, ok := compressions[c.Compression]; !ok {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Compression), flag.ErrInvalidVal))
+	}
+	if c.TLSCAFile != "" {
+		if _, err := os.Stat(c.TLSCAFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(TLSCAFile), flag.ErrInvalidPath))
+		}
+	}
+	if c.MaxOpenConns < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(MaxOpenConns), flag.ErrInvalidVal))
+	}
+	if c.MaxIdleConns > c.MaxOpenConns {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(MaxIdleConns),
+				flag.ValidationError("max. idle connections can not exceed max. open connections")))
+	}
+
+	return mErr
+}
+
+func (c *Config) options() (*ch.Options, error) {
+	opts := &ch.Options{
+		Addr: c.Hosts,
+		Auth: ch.Auth{
+			Database: c.Database,
+			Username: c.UserName,
+			Password: c.Password,
+		},
+		Compression:     &ch.Compression{Method: compressions[c.Compression]},
+		DialTimeout:     c.DialTimeout,
+		MaxOpenConns:    c.MaxOpenConns,
+		MaxIdleConns:    c.MaxIdleConns,
+		ConnMaxLifetime: c.ConnMaxLifetime,
+		Settings:        ch.Settings{},
+	}
+	if c.Protocol == "http" {
+		opts.Protocol = ch.HTTP
+	}
+	for k, v := range c.Settings {
+		opts.Settings[k] = v
+	}
+	if c.AsyncInsert {
+		opts.Settings["async_insert"] = 1
+		opts.Settings["wait_for_async_insert"] = 0
+		if c.AsyncInsertWait {
+			opts.Settings["wait_for_async_insert"] = 1
+		}
+		opts.Settings["async_insert_busy_timeout_ms"] = c.AsyncInsertTimeout.Milliseconds()
+	}
+
+	if c.TLS || c.TLSCAFile != "" {
+		opts.TLS = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: c.TLSInsecure,
+		}
+		if c.TLSCAFile != "" {
+			pem, err := os.ReadFile(c.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read CA file: %w", err)
+			}
+			opts.TLS.RootCAs = x509.NewCertPool()
+			if !opts.TLS.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, errors.New("no certificates found in CA file")
+			}
+		}
+	}
+	return opts, nil
+}
+
+// PreRun implements run.PreRunner.
+func (c *Config) PreRun() error {
+	opts, err := c.options()
+	if err != nil {
+		return err
+	}
+	if c.conn, err = ch.Open(opts); err != nil {
+		return fmt.Errorf("clickhouse open failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.DialTimeout)
+	defer cancel()
+	if err = c.conn.Ping(ctx); err != nil {
+		_ = c.conn.Close()
+		return fmt.Errorf("clickhouse ping failed: %w", err)
+	}
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. It closes the connection pool
+// on shutdown.
+func (c *Config) ServeContext(ctx context.Context) error {
+	<-ctx.Done()
+	if err := c.conn.Close(); err != nil {
+		log.Error("unable to close connection pool", err)
+	}
+	return nil
+}
+
+// Conn returns the established connection pool.
+func (c *Config) Conn() driver.Conn {
+	return c.conn
+}
+
+var (
+	_ run.Config         = (*Config)(nil)
+	_ run.PreRunner      = (*Config)(nil)
+	_ run.ServiceContext = (*Config)(nil)
+)
@@ -0,0 +1,75 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// read reads and decodes the config file into a map of flag names and
+// values.
+func (l *Loader) read() (map[string]any, error) {
+	format, err := l.format()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(l.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := make(map[string]any)
+	switch format {
+	case FormatYAML:
+		err = yaml.Unmarshal(b, &doc)
+	case FormatTOML:
+		err = toml.Unmarshal(b, &doc)
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		err = dec.Decode(&doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %s: %w", l.Path, err)
+	}
+
+	values := make(map[string]any)
+	flatten(values, "", doc)
+	return values, nil
+}
+
+// flatten maps nested keys onto flag names.
+func flatten(dst map[string]any, prefix string, src map[string]any) {
+	for k, v := range src {
+		key := strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(k))
+		if prefix != "" {
+			key = prefix + "-" + key
+		}
+		switch val := v.(type) {
+		case nil:
+		case map[string]any:
+			flatten(dst, key, val)
+		default:
+			dst[key] = v
+		}
+	}
+}
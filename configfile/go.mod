@@ -0,0 +1,19 @@
+module github.com/basvanbeek/run-handlers/configfile
+
+go 1.24.2
+
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/basvanbeek/multierror v0.1.0
+	github.com/basvanbeek/run v0.2.1
+	github.com/basvanbeek/telemetry v0.2.0
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/spf13/pflag v1.0.6
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/hashicorp/errwrap v1.1.0 // indirect
+	github.com/logrusorgru/aurora/v4 v4.0.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+)
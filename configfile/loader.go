@@ -0,0 +1,327 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configfile provides a run handler loading flag values of other
+// run.Config units from a YAML, TOML or JSON file and the environment.
+package configfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("configfile", "config file loader")
+
+// package flags.
+const (
+	Path      = "config-file"
+	Format    = "config-file-format"
+	Strict    = "config-file-strict"
+	Watch     = "config-file-watch"
+	EnvPrefix = "config-env-prefix"
+)
+
+// Supported file formats.
+const (
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+	FormatJSON = "json"
+)
+
+// Loader implements run.Config and run.ServiceContext, applying values from
+// the environment and a config file to the flags of Units with the following
+// precedence: command line > environment > config file > default.
+//
+// The Loader must be registered with the run.Group before the Units it feeds,
+// as it applies the values during its Validate phase.
+//
+// Environment variables are looked up by flag name in upper case with dashes
+// replaced by underscores and optionally prefixed by EnvPrefix, e.g.
+// REDIS_PASSWORD for the redis-password flag.
+//
+// Config file keys map onto flag names. Nested keys are joined by dashes and
+// underscores and dots are treated as dashes, so the following YAML documents
+// both set the redis-password flag:
+//
+//	redis-password: secret
+//
+//	redis:
+//	  password: secret
+//
+// With Watch enabled, the config file is re-applied on change and OnChange is
+// called with the names of the flags whose value changed. Units are not
+// validated again, so OnChange should only be used for settings which can be
+// safely updated at runtime. Keys removed from the file are not reverted.
+type Loader struct {
+	Prefix string
+
+	// Path holds the config file path. It defaults to the CONFIG_FILE
+	// environment variable.
+	Path string
+	// Format holds the config file format. If empty, the format is derived
+	// from the file extension.
+	Format string
+	// Strict rejects config file keys not matching any flag.
+	Strict    bool
+	Watch     bool
+	EnvPrefix string
+
+	// Units holds the run.Config units to apply the values to.
+	Units []run.Config
+	// Args holds the command line arguments as passed to the run.Group. It
+	// defaults to os.Args[1:].
+	Args []string
+	// OnChange is called after a config file change was applied.
+	OnChange func(flags []string)
+
+	mtx     sync.Mutex
+	flags   *pflag.FlagSet
+	locked  map[string]bool
+	applied map[string]string
+}
+
+func (l *Loader) prefix(s string) string {
+	if l.Prefix != "" {
+		return l.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (l *Loader) Name() string {
+	return l.prefix("config-file")
+}
+
+// FlagSet implements run.Config.
+func (l *Loader) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Config file options")
+
+	if p := os.Getenv(envName("", l.prefix(Path))); p != "" {
+		l.Path = p
+	}
+
+	flags.StringVar(&l.Path, l.prefix(Path),
+		l.Path, "path of the config file")
+
+	flags.StringVar(&l.Format, l.prefix(Format),
+		l.Format, "config file format: yaml, toml or json (default derived from extension)")
+
+	flags.BoolVar(&l.Strict, l.prefix(Strict),
+		l.Strict, "reject config file keys not matching any flag")
+
+	flags.BoolVar(&l.Watch, l.prefix(Watch),
+		l.Watch, "re-apply the config file on change")
+
+	flags.StringVar(&l.EnvPrefix, l.prefix(EnvPrefix),
+		l.EnvPrefix, "prefix of the environment variables holding flag values")
+
+	return flags
+}
+
+// Validate implements run.Config. It applies the environment and config file
+// values to the flags of the Units.
+func (l *Loader) Validate() error {
+	var mErr error
+
+	if l.Path != "" {
+		if fi, err := os.Stat(l.Path); err != nil || fi.IsDir() {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(l.prefix(Path), flag.ErrInvalidPath))
+		}
+		if _, err := l.format(); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(l.prefix(Format), err))
+		}
+	}
+	if l.Watch && l.Path == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(l.prefix(Path), flag.ErrRequired))
+	}
+	if mErr != nil {
+		return mErr
+	}
+
+	if err := l.parseArgs(); err != nil {
+		return err
+	}
+	l.applyEnv()
+	if l.Path == "" {
+		return nil
+	}
+	if _, err := l.apply(); err != nil {
+		return flag.NewValidationError(l.prefix(Path), err)
+	}
+	return nil
+}
+
+// parseArgs collects the flags of the Units and parses the command line
+// arguments to find out which flags have been explicitly set.
+func (l *Loader) parseArgs() error {
+	l.flags = pflag.NewFlagSet(l.Name(), pflag.ContinueOnError)
+	l.flags.ParseErrorsWhitelist.UnknownFlags = true
+	l.flags.Usage = func() {}
+	for _, u := range l.Units {
+		if u == nil {
+			continue
+		}
+		fs := u.FlagSet()
+		if fs == nil {
+			continue
+		}
+		fs.VisitAll(func(f *pflag.Flag) {
+			if l.flags.Lookup(f.Name) == nil {
+				l.flags.AddFlag(f)
+			}
+		})
+	}
+
+	args := l.Args
+	if args == nil {
+		args = os.Args[1:]
+	}
+	if err := l.flags.Parse(args); err != nil && !errors.Is(err, pflag.ErrHelp) {
+		return err
+	}
+
+	l.locked = make(map[string]bool)
+	l.flags.Visit(func(f *pflag.Flag) {
+		l.locked[f.Name] = true
+	})
+	return nil
+}
+
+// applyEnv applies the environment variables to the flags not set on the
+// command line.
+func (l *Loader) applyEnv() {
+	l.flags.VisitAll(func(f *pflag.Flag) {
+		if l.locked[f.Name] {
+			return
+		}
+		v, ok := os.LookupEnv(envName(l.EnvPrefix, f.Name))
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(v); err != nil {
+			log.Error("invalid environment value", err, "flag", f.Name)
+			return
+		}
+		l.locked[f.Name] = true
+	})
+}
+
+// apply reads the config file and applies its values to the flags not set
+// on the command line or environment. It returns the names of the flags with
+// changed values.
+func (l *Loader) apply() ([]string, error) {
+	values, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.applied == nil {
+		l.applied = make(map[string]string)
+	}
+	var (
+		mErr    error
+		unknown []string
+		changed []string
+	)
+	for key, v := range values {
+		f := l.flags.Lookup(key)
+		if f == nil {
+			unknown = append(unknown, key)
+			continue
+		}
+		if l.locked[key] {
+			continue
+		}
+		str := fmt.Sprint(v)
+		if prev, ok := l.applied[key]; ok && prev == str {
+			continue
+		}
+		if err = set(f, v); err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("invalid value for %s: %w", key, err))
+			continue
+		}
+		l.applied[key] = str
+		changed = append(changed, key)
+	}
+	sort.Strings(unknown)
+	sort.Strings(changed)
+	if len(unknown) > 0 {
+		if l.Strict {
+			mErr = multierror.Append(mErr, fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", ")))
+		} else {
+			log.Info("ignoring unknown config file keys", "keys", strings.Join(unknown, ", "))
+		}
+	}
+	return changed, mErr
+}
+
+// set applies the config file value to the flag.
+func set(f *pflag.Flag, v any) error {
+	list, ok := v.([]any)
+	if !ok {
+		return f.Value.Set(fmt.Sprint(v))
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		values = append(values, fmt.Sprint(item))
+	}
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		return sv.Replace(values)
+	}
+	return f.Value.Set(strings.Join(values, ","))
+}
+
+func envName(prefix, name string) string {
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func (l *Loader) format() (string, error) {
+	format := strings.ToLower(l.Format)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(l.Path)), ".")
+	}
+	switch format {
+	case FormatYAML, "yml":
+		return FormatYAML, nil
+	case FormatTOML, FormatJSON:
+		return format, nil
+	}
+	return "", flag.ErrInvalidVal
+}
+
+var (
+	_ run.Config         = (*Loader)(nil)
+	_ run.ServiceContext = (*Loader)(nil)
+)
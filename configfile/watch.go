@@ -0,0 +1,69 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configfile
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ServeContext implements run.ServiceContext. If Watch is enabled it
+// re-applies the config file on change.
+func (l *Loader) ServeContext(ctx context.Context) error {
+	if !l.Watch {
+		<-ctx.Done()
+		return nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = w.Close() }()
+
+	// watch the directory to catch the file being replaced, e.g. by editors
+	// writing a new file and renaming it
+	path := filepath.Clean(l.Path)
+	if err = w.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err = <-w.Errors:
+			log.Error("config file watcher error", err)
+		case ev := <-w.Events:
+			if filepath.Clean(ev.Name) != path ||
+				!ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Create) {
+				continue
+			}
+			changed, err := l.apply()
+			if err != nil {
+				log.Error("unable to apply config file", err, "path", l.Path)
+			}
+			if len(changed) == 0 {
+				continue
+			}
+			log.Info("applied config file changes", "path", l.Path, "flags", changed)
+			if l.OnChange != nil {
+				l.OnChange(changed)
+			}
+		}
+	}
+}
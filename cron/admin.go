@@ -0,0 +1,121 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// runInfo holds the JSON representation of a Run.
+type runInfo struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end,omitzero"`
+	Duration string    `json:"duration"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// AdminHandler returns an http.Handler exposing job management endpoints
+// with JSON responses under the provided path prefix, to be mounted on the
+// http.Service, e.g. svc.Handle("/admin/cron/", cronSvc.AdminHandler("/admin/cron")):
+//
+//	GET  {prefix}/jobs                 lists the registered jobs
+//	GET  {prefix}/jobs/{name}          describes a job
+//	GET  {prefix}/jobs/{name}/history  lists the recent runs of a job
+//	POST {prefix}/jobs/{name}/pause    pauses a job
+//	POST {prefix}/jobs/{name}/resume   resumes a paused job
+//	POST {prefix}/jobs/{name}/run      triggers an immediate run of a job
+//
+// The handler does not authenticate requests, protect it accordingly.
+func (s *Service) AdminHandler(prefix string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+"/jobs", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, s.Jobs())
+	})
+	mux.HandleFunc("GET "+prefix+"/jobs/{name}", s.withJob(
+		func(w http.ResponseWriter, _ *http.Request, r *Reference) {
+			s.mtx.Lock()
+			info := r.info()
+			s.mtx.Unlock()
+			writeJSON(w, http.StatusOK, info)
+		}))
+	mux.HandleFunc("GET "+prefix+"/jobs/{name}/history", func(w http.ResponseWriter, req *http.Request) {
+		runs := s.History(req.PathValue("name"))
+		infos := make([]runInfo, 0, len(runs))
+		for _, run := range runs {
+			info := runInfo{
+				Start:    run.Start,
+				End:      run.End(),
+				Duration: run.Duration.String(),
+				Attempts: run.Attempts,
+			}
+			if run.Err != nil {
+				info.Error = run.Err.Error()
+			}
+			infos = append(infos, info)
+		}
+		writeJSON(w, http.StatusOK, infos)
+	})
+	mux.HandleFunc("POST "+prefix+"/jobs/{name}/pause", s.withJob(
+		func(w http.ResponseWriter, _ *http.Request, r *Reference) {
+			r.Pause()
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	mux.HandleFunc("POST "+prefix+"/jobs/{name}/resume", s.withJob(
+		func(w http.ResponseWriter, _ *http.Request, r *Reference) {
+			r.Resume()
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	mux.HandleFunc("POST "+prefix+"/jobs/{name}/run", s.withJob(
+		func(w http.ResponseWriter, _ *http.Request, r *Reference) {
+			if err := r.RunNow(); err != nil {
+				status := http.StatusServiceUnavailable
+				if errors.Is(err, ErrJobPaused) || errors.Is(err, ErrJobRunning) ||
+					errors.Is(err, ErrBlackout) {
+					status = http.StatusConflict
+				}
+				writeJSON(w, status, map[string]string{"error": err.Error()})
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		}))
+	return mux
+}
+
+// withJob resolves the job named in the request path, responding with 404
+// if it is not registered.
+func (s *Service) withJob(fn func(http.ResponseWriter, *http.Request, *Reference)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		r, ok := s.Job(req.PathValue("name"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+			return
+		}
+		fn(w, req, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("unable to write admin response", err)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// ErrBlackout is reported to OnSkipped hooks if a run was skipped because it
+// fell within a blackout window or a day excluded by the calendar.
+var ErrBlackout = errors.New("run falls within blackout")
+
+// dateLayout holds the layout of the holidays in a calendar file.
+const dateLayout = "2006-01-02"
+
+// maxBlackoutSteps limits the search for the end of overlapping blackouts.
+const maxBlackoutSteps = 1000
+
+// TimeWindow holds a period of time during which runs are skipped.
+type TimeWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Contains returns true if the time falls within the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// Calendar holds the days and windows during which scheduled runs of all jobs
+// are skipped and rescheduled to the first moment after.
+type Calendar struct {
+	// SkipWeekdays holds the days of the week without runs, e.g. weekends.
+	SkipWeekdays []time.Weekday
+	// Holidays holds the dates without runs. Only the date is used.
+	Holidays []time.Time
+	// Blackouts holds the maintenance windows without runs.
+	Blackouts []TimeWindow
+}
+
+// calendarFile holds the JSON representation of a Calendar.
+type calendarFile struct {
+	SkipWeekdays []string     `json:"skip_weekdays"`
+	Holidays     []string     `json:"holidays"`
+	Blackouts    []TimeWindow `json:"blackouts"`
+}
+
+// LoadCalendar reads a Calendar from a JSON file, e.g.:
+//
+//	{
+//	  "skip_weekdays": ["Saturday", "Sunday"],
+//	  "holidays": ["2025-12-25", "2026-01-01"],
+//	  "blackouts": [{"start": "2025-06-01T02:00:00Z", "end": "2025-06-01T04:00:00Z"}]
+//	}
+func LoadCalendar(path string) (*Calendar, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f calendarFile
+	if err = json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("invalid calendar file: %w", err)
+	}
+	c := &Calendar{Blackouts: f.Blackouts}
+	for _, s := range f.SkipWeekdays {
+		d := slices.IndexFunc(weekdays, func(d time.Weekday) bool { return d.String() == s })
+		if d < 0 {
+			return nil, fmt.Errorf("invalid weekday %q in calendar file", s)
+		}
+		c.SkipWeekdays = append(c.SkipWeekdays, weekdays[d])
+	}
+	for _, s := range f.Holidays {
+		d, err := time.ParseInLocation(dateLayout, s, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %q in calendar file: %w", s, err)
+		}
+		c.Holidays = append(c.Holidays, d)
+	}
+	for _, w := range c.Blackouts {
+		if !w.End.After(w.Start) {
+			return nil, errors.New("blackout end needs to be after its start")
+		}
+	}
+	return c, nil
+}
+
+var weekdays = []time.Weekday{
+	time.Sunday, time.Monday, time.Tuesday, time.Wednesday,
+	time.Thursday, time.Friday, time.Saturday,
+}
+
+// excludes returns the end of the exclusion if the time falls on a skipped
+// day or within a blackout window of the calendar.
+func (c *Calendar) excludes(t time.Time) (time.Time, bool) {
+	if c == nil {
+		return t, false
+	}
+	y, m, d := t.Date()
+	if slices.Contains(c.SkipWeekdays, t.Weekday()) ||
+		slices.ContainsFunc(c.Holidays, func(h time.Time) bool {
+			hy, hm, hd := h.Date()
+			return hy == y && hm == m && hd == d
+		}) {
+		return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location()), true
+	}
+	return inWindows(c.Blackouts, t)
+}
+
+// inWindows returns the end of the window the time falls within.
+func inWindows(windows []TimeWindow, t time.Time) (time.Time, bool) {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return w.End, true
+		}
+	}
+	return t, false
+}
+
+// WithBlackout skips and reschedules runs of the job falling within one of
+// the provided windows to the end of that window.
+func WithBlackout(windows ...TimeWindow) Option {
+	return func(r *Reference) error {
+		for _, w := range windows {
+			if !w.End.After(w.Start) {
+				return errors.New("blackout end needs to be after its start")
+			}
+		}
+		r.blackouts = append(r.blackouts, windows...)
+		return nil
+	}
+}
+
+// blackedOut returns the first time after the provided time that is not
+// excluded by the job's blackouts or the service calendar, and whether the
+// provided time is excluded.
+func (r *Reference) blackedOut(t time.Time) (time.Time, bool) {
+	excluded := false
+	for range maxBlackoutSteps {
+		end, ok := inWindows(r.blackouts, t)
+		if !ok {
+			end, ok = r.svc.Calendar.excludes(t)
+		}
+		if !ok {
+			return t, excluded
+		}
+		excluded = true
+		t = end
+	}
+	return t, excluded
+}
+
+// skipBlackout skips the run as it falls within a blackout, and reschedules
+// it to the end of the blackout.
+func (r *Reference) skipBlackout(now, until time.Time) {
+	log.Debug("job run falls within blackout, rescheduling", "job", r.name,
+		"until", until)
+	r.nextRun.Store(&until)
+	go r.report(r.hooks, Run{Job: r.name, Start: now, Err: ErrBlackout})
+}
@@ -0,0 +1,101 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrRunAfterOtherService is returned if a job is set to run after a job
+// registered with another Service.
+var ErrRunAfterOtherService = errors.New("job to run after is registered with another service")
+
+type (
+	resultKey   struct{}
+	upstreamKey struct{}
+)
+
+// result holds the result value a job run sets through SetResult.
+type result struct {
+	mtx   sync.Mutex
+	value any
+}
+
+// withResult returns a copy of the context holding the result of the
+// upstream job and a result for the job run to set.
+func withResult(ctx context.Context, upstream any) (context.Context, *result) {
+	res := &result{}
+	ctx = context.WithValue(ctx, resultKey{}, res)
+	if upstream != nil {
+		ctx = context.WithValue(ctx, upstreamKey{}, upstream)
+	}
+	return ctx, res
+}
+
+// SetResult sets the result value of the running job, which is passed to the
+// jobs running after it.
+func SetResult(ctx context.Context, value any) {
+	if res, ok := ctx.Value(resultKey{}).(*result); ok {
+		res.mtx.Lock()
+		res.value = value
+		res.mtx.Unlock()
+	}
+}
+
+// Result returns the result value set by the job the running job runs after.
+func Result(ctx context.Context) (any, bool) {
+	value := ctx.Value(upstreamKey{})
+	return value, value != nil
+}
+
+// WithRunAfter makes the job run each time the provided job completes
+// successfully, instead of on its own schedule. The result set by the
+// provided job through SetResult is available to the job through Result.
+func WithRunAfter(ref *Reference) Option {
+	return func(r *Reference) error {
+		if ref == nil {
+			return errors.New("job to run after cannot be nil")
+		}
+		r.after = ref
+		return nil
+	}
+}
+
+// triggerDependents runs the jobs set to run after this job.
+func (r *Reference) triggerDependents(value any) {
+	r.svc.mtx.Lock()
+	defer r.svc.mtx.Unlock()
+	if r.svc.done {
+		return
+	}
+	now := r.svc.clock().Now()
+	for _, d := range r.dependents {
+		// if the run is delayed, the scheduler picks it up on its next tick
+		d.upstream = value
+		d.nextRun.Store(&now)
+		if d.run() {
+			log.Info("job triggered", d.logDetails()...)
+		}
+	}
+}
+
+// get returns the result value.
+func (res *result) get() any {
+	res.mtx.Lock()
+	defer res.mtx.Unlock()
+	return res.value
+}
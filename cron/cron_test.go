@@ -17,64 +17,39 @@ package cron_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/basvanbeek/run-handlers/cron"
+	"github.com/basvanbeek/run-handlers/cron/crontest"
+	"github.com/basvanbeek/telemetry"
 )
 
-var (
-	mtxService = &sync.Mutex{}
-	svc        *cron.Service
-	cancel     context.CancelFunc
-)
-
-func startService(schedulerInterval time.Duration) (*cron.Service, error) {
-	mtxService.Lock()
-	defer mtxService.Unlock()
-
-	if svc != nil {
-		return svc, nil
-	}
-
-	s := &cron.Service{SchedulerInterval: schedulerInterval}
-	err := s.Validate()
-	if err != nil {
-		return nil, err
-	}
-	var ctx context.Context
-	ctx, cancel = context.WithCancel(context.Background())
-	go func() {
-		err = s.ServeContext(ctx)
-		cancel()
-	}()
-
-	return s, nil
-}
-
-func stopService() {
-	mtxService.Lock()
-	defer mtxService.Unlock()
-
-	if svc != nil {
-		cancel()
-	}
-}
-
 func TestService_TestJobs(t *testing.T) {
-	s, err := startService(time.Second)
-	if err != nil {
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, Clock: clock}
+	if err := s.Validate(); err != nil {
 		t.Fatal(err)
 	}
-	defer stopService()
 
-	now := time.Now()
-	var countA, countB, countC, countD, countE int
-	if _, err = s.AddJob(
+	// release unblocks the long-running jobs C and D at the end of the test
+	release := make(chan struct{})
+	defer close(release)
+
+	now := clock.Now()
+	var countA, countB, countC, countD, countE, callsE atomic.Int32
+	if _, err := s.AddJob(
 		func(context.Context) error {
-			countA++
+			countA.Add(1)
 			return nil
 		},
 		now,
@@ -84,9 +59,9 @@ func TestService_TestJobs(t *testing.T) {
 		t.Error("expected Job A to be created", err)
 	}
 
-	if _, err = s.AddJob(
+	if _, err := s.AddJob(
 		func(context.Context) error {
-			countB++
+			countB.Add(1)
 			return nil
 		},
 		now,
@@ -95,10 +70,10 @@ func TestService_TestJobs(t *testing.T) {
 	); err != nil {
 		t.Error("expected Job B to be created", err)
 	}
-	if _, err = s.AddJob(
+	if _, err := s.AddJob(
 		func(context.Context) error {
-			countC++
-			time.Sleep(3 * time.Second)
+			countC.Add(1)
+			<-release
 			return nil
 		},
 		now,
@@ -108,10 +83,10 @@ func TestService_TestJobs(t *testing.T) {
 	); err != nil {
 		t.Error("expected JobC to be created", err)
 	}
-	if _, err = s.AddJob(
+	if _, err := s.AddJob(
 		func(context.Context) error {
-			countD++
-			time.Sleep(3 * time.Second)
+			countD.Add(1)
+			<-release
 			return nil
 		},
 		now,
@@ -121,15 +96,17 @@ func TestService_TestJobs(t *testing.T) {
 	); err != nil {
 		t.Error("expected JobD to be created", err)
 	}
-	var failuresLeft = 2
-	if _, err = s.AddJob(
+	var failuresLeft atomic.Int32
+	failuresLeft.Store(2)
+	if _, err := s.AddJob(
 		func(context.Context) error {
-			t.Logf("Job E called, failures left: %d", failuresLeft)
-			if failuresLeft > 0 {
-				failuresLeft--
+			callsE.Add(1)
+			t.Logf("Job E called, failures left: %d", failuresLeft.Load())
+			if failuresLeft.Load() > 0 {
+				failuresLeft.Add(-1)
 				return errors.New("simulated failure")
 			}
-			countE++
+			countE.Add(1)
 			return nil
 		},
 		now,
@@ -139,22 +116,1156 @@ func TestService_TestJobs(t *testing.T) {
 		t.Error("expected JobE to be created", err)
 	}
 
-	// let's run for 3 seconds
-	time.Sleep(3 * time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.ServeContext(ctx) }()
+
+	// scheduledE returns true once the failed run of job E scheduled its retry
+	scheduledE := func() bool {
+		for _, j := range s.Jobs() {
+			if j.Name == "jobE" {
+				return !j.NextRun.IsZero()
+			}
+		}
+		return false
+	}
+	// let's run for 3 virtual seconds, waiting for the runs of each tick
+	for tick := int32(1); tick <= 3; tick++ {
+		if tick > 1 {
+			advance(t, clock, time.Second)
+		}
+		if !eventually(t, func() bool {
+			return countA.Load() == tick && callsE.Load() == tick && (tick == 3 || scheduledE())
+		}) {
+			t.Fatalf("tick %d: expected jobs A and E to run", tick)
+		}
+	}
+
+	if got := countA.Load(); got != 3 {
+		t.Errorf("expected Job A count to be 3, got %d", got)
+	}
+	if got := countB.Load(); got != 1 {
+		t.Errorf("expected Job B count to be 1, got %d", got)
+	}
+	if got := countC.Load(); got != 1 {
+		t.Errorf("expected Job C count to be 1, got %d", got)
+	}
+	// Job D runs longer than its interval, ticks during a run are skipped
+	if got := countD.Load(); got != 1 {
+		t.Errorf("expected Job D count to be 1, got %d", got)
+	}
+	if !eventually(t, func() bool { return countE.Load() == 1 }) {
+		t.Errorf("expected Job E count to be 1, got %d", countE.Load())
+	}
+}
+
+type memLocker struct {
+	clock cron.Clock
+	mtx   sync.Mutex
+	locks map[string]time.Time
+}
+
+func (l *memLocker) TryLock(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := l.clock.Now()
+	if now.Before(l.locks[key]) {
+		return false, nil
+	}
+	l.locks[key] = now.Add(ttl)
+	return true, nil
+}
+
+func TestService_DistributedLock(t *testing.T) {
+	clock := crontest.NewClock(time.Now())
+	locker := &memLocker{clock: clock, locks: make(map[string]time.Time)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var count, skipped atomic.Int32
+	now := clock.Now()
+	for range 2 {
+		s := &cron.Service{SchedulerInterval: time.Second, Clock: clock}
+		if _, err := s.AddJob(
+			func(context.Context) error {
+				count.Add(1)
+				return nil
+			},
+			now,
+			cron.WithName("locked"),
+			cron.WithDistributedLock(locker),
+			cron.WithHooks(cron.Hooks{OnSkipped: func(cron.Run) { skipped.Add(1) }}),
+		); err != nil {
+			t.Fatal(err)
+		}
+		go func() { _ = s.ServeContext(ctx) }()
+	}
+
+	if _, err := (&cron.Service{SchedulerInterval: time.Second}).AddJob(
+		func(context.Context) error { return nil }, now,
+		cron.WithDistributedLock(locker),
+	); !errors.Is(err, cron.ErrLockRequiresName) {
+		t.Errorf("expected ErrLockRequiresName, got %v", err)
+	}
+
+	for tick := int32(1); tick <= 3; tick++ {
+		if tick > 1 {
+			// wait for both schedulers to wait on the clock
+			if !eventually(t, func() bool { return clock.Waiters() == 2 }) {
+				t.Fatal("schedulers are not waiting on the clock")
+			}
+			clock.Advance(time.Second)
+		}
+		if !eventually(t, func() bool { return count.Load() == tick && skipped.Load() == tick }) {
+			t.Fatalf("tick %d: expected the job to run once per tick, got %d runs and %d skips",
+				tick, count.Load(), skipped.Load())
+		}
+	}
+}
+
+func TestService_PanicRecovery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, Clock: clock}
+
+	var panics, runs atomic.Int32
+	now := clock.Now()
+	if _, err := s.AddJob(
+		func(context.Context) error {
+			panics.Add(1)
+			panic("boom")
+		},
+		now,
+		cron.WithName("panicking"),
+		cron.WithMaxPanics(2),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddJob(
+		func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+		now,
+		cron.WithName("healthy"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	for tick := int32(1); tick <= 3; tick++ {
+		if tick > 1 {
+			advance(t, clock, time.Second)
+		}
+		if !eventually(t, func() bool { return runs.Load() == tick && panics.Load() == min(tick, 2) }) {
+			t.Fatalf("tick %d: expected the scheduler to keep running jobs, got %d runs and %d panics",
+				tick, runs.Load(), panics.Load())
+		}
+		if tick == 2 && !eventually(t, func() bool { return len(s.Jobs()) == 1 }) {
+			t.Fatal("expected the job to be canceled after 2 panics")
+		}
+	}
+
+	if got := panics.Load(); got != 2 {
+		t.Errorf("expected the job to be canceled after 2 panics, got %d", got)
+	}
+}
+
+func TestService_Retry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, Clock: clock}
+
+	var attempts atomic.Int32
+	failed := make(chan error, 1)
+	if _, err := s.AddJob(
+		func(context.Context) error {
+			attempts.Add(1)
+			return errors.New("failure")
+		},
+		clock.Now(),
+		cron.WithName("failing"),
+		cron.WithMaxRun(1),
+		cron.WithRetry(3, 10*time.Millisecond),
+		cron.WithErrorHook(func(_ string, err error) { failed <- err }),
+	); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	for backoff := 10 * time.Millisecond; backoff <= 20*time.Millisecond; backoff *= 2 {
+		// wait for both the scheduler and the retry to wait on the clock
+		if !eventually(t, func() bool { return clock.Waiters() == 2 }) {
+			t.Fatalf("expected a retry after a backoff of %s", backoff)
+		}
+		clock.Advance(backoff)
+	}
+
+	select {
+	case <-failed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the error hook to be called")
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestService_Hooks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var success, failure, skipped atomic.Int32
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{
+		SchedulerInterval: time.Second,
+		Clock:             clock,
+		Hooks: cron.Hooks{
+			OnSuccess: func(cron.Run) { success.Add(1) },
+			OnFailure: func(run cron.Run) {
+				if run.Err == nil || run.Attempts != 1 {
+					t.Errorf("unexpected failed run metadata: %+v", run)
+				}
+				failure.Add(1)
+			},
+		},
+	}
+
+	now := clock.Now()
+	locker := &memLocker{clock: clock, locks: map[string]time.Time{
+		"cron:skipped": now.Add(time.Hour),
+	}}
+	for name, job := range map[string]cron.Job{
+		"succeeding": func(context.Context) error { return nil },
+		"failing":    func(context.Context) error { return errors.New("failure") },
+		"skipped":    func(context.Context) error { return nil },
+	} {
+		if _, err := s.AddJob(job, now,
+			cron.WithName(name),
+			cron.WithMaxRun(1),
+			cron.WithDistributedLock(locker),
+			cron.WithHooks(cron.Hooks{
+				OnSkipped: func(run cron.Run) {
+					if !errors.Is(run.Err, cron.ErrLockHeld) {
+						t.Errorf("unexpected skip reason: %v", run.Err)
+					}
+					skipped.Add(1)
+				},
+			}),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	if !eventually(t, func() bool {
+		return success.Load() == 1 && failure.Load() == 1 && skipped.Load() == 1
+	}) {
+		t.Errorf("expected one call per hook, got success=%d failure=%d skipped=%d",
+			success.Load(), failure.Load(), skipped.Load())
+	}
+}
+
+type memMetric struct {
+	sink   *memSink
+	name   string
+	labels string
+}
+
+func (m *memMetric) Increment()                                 { m.Record(1) }
+func (m *memMetric) Decrement()                                 { m.Record(-1) }
+func (m *memMetric) Name() string                               { return m.name }
+func (m *memMetric) RecordContext(_ context.Context, v float64) { m.Record(v) }
+
+func (m *memMetric) Record(v float64) {
+	m.sink.mtx.Lock()
+	defer m.sink.mtx.Unlock()
+	m.sink.values[m.name+"{"+m.labels+"}"] += v
+}
+
+func (m *memMetric) With(labelValues ...telemetry.LabelValue) telemetry.Metric {
+	c := *m
+	for _, lv := range labelValues {
+		c.labels += lv.(string)
+	}
+	return &c
+}
+
+type memLabel string
+
+func (l memLabel) Insert(v string) telemetry.LabelValue { return string(l) + "=" + v }
+func (l memLabel) Update(v string) telemetry.LabelValue { return string(l) + "=" + v }
+func (l memLabel) Upsert(v string) telemetry.LabelValue { return string(l) + "=" + v }
+func (l memLabel) Delete() telemetry.LabelValue         { return "" }
+
+type memSink struct {
+	mtx    sync.Mutex
+	values map[string]float64
+}
+
+func (s *memSink) metric(name string) telemetry.Metric { return &memMetric{sink: s, name: name} }
+
+func (s *memSink) NewSum(name, _ string, _ ...telemetry.MetricOption) telemetry.Metric {
+	return s.metric(name)
+}
+
+func (s *memSink) NewGauge(name, _ string, _ ...telemetry.MetricOption) telemetry.Metric {
+	return s.metric(name)
+}
+
+func (s *memSink) NewDistribution(name, _ string, _ []float64, _ ...telemetry.MetricOption) telemetry.Metric {
+	return s.metric(name)
+}
+
+func (s *memSink) NewLabel(name string) telemetry.Label { return memLabel(name) }
+
+func (s *memSink) ContextWithLabels(ctx context.Context, _ ...telemetry.LabelValue) (context.Context, error) {
+	return ctx, nil
+}
+
+func (s *memSink) value(name string) float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.values[name]
+}
+
+func TestService_Metrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sink := &memSink{values: make(map[string]float64)}
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, MetricSink: sink, Clock: clock}
+
+	var fail atomic.Bool
+	if _, err := s.AddJob(
+		func(context.Context) error {
+			if fail.Swap(true) {
+				return errors.New("failure")
+			}
+			return nil
+		},
+		clock.Now(),
+		cron.WithName("job"),
+		cron.WithMaxRun(2),
+	); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	recorded := func(runs, failures float64) func() bool {
+		return func() bool {
+			return sink.value("cron_job_runs_total{job=job}") == runs &&
+				sink.value("cron_job_failures_total{job=job}") == failures
+		}
+	}
+	if !eventually(t, recorded(1, 0)) {
+		t.Fatal("expected the first run to be recorded")
+	}
+	advance(t, clock, time.Second)
+	if !eventually(t, recorded(2, 1)) {
+		t.Errorf("expected 2 runs and 1 failure, got %v runs and %v failures",
+			sink.value("cron_job_runs_total{job=job}"), sink.value("cron_job_failures_total{job=job}"))
+	}
+	if got := sink.value("cron_job_last_success_timestamp_seconds{job=job}"); got == 0 {
+		t.Error("expected last success timestamp to be recorded")
+	}
+}
+
+type memHistory struct {
+	mtx  sync.Mutex
+	runs []cron.Run
+}
+
+func (h *memHistory) SaveRun(_ context.Context, run cron.Run) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.runs = append(h.runs, run)
+	return nil
+}
+
+// eventually polls the condition until it holds or a second has passed.
+func eventually(t *testing.T, cond func() bool) bool {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+// advance waits for the scheduler to wait on the clock before advancing it.
+func advance(t *testing.T, clock *crontest.Clock, d time.Duration) {
+	t.Helper()
+	if !eventually(t, func() bool { return clock.Waiters() > 0 }) {
+		t.Fatal("scheduler is not waiting on the clock")
+	}
+	clock.Advance(d)
+}
+
+func TestService_History(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &memHistory{}
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{
+		SchedulerInterval: time.Second,
+		Clock:             clock,
+		HistorySize:       1,
+		HistoryStore:      store,
+	}
+
+	var count atomic.Int32
+	if _, err := s.AddJob(
+		func(context.Context) error {
+			if count.Add(1) == 2 {
+				return errors.New("failure")
+			}
+			return nil
+		},
+		clock.Now(),
+		cron.WithName("job"),
+		cron.WithMaxRun(2),
+	); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	persisted := func(n int) func() bool {
+		return func() bool {
+			store.mtx.Lock()
+			defer store.mtx.Unlock()
+			return len(store.runs) == n
+		}
+	}
+	if !eventually(t, persisted(1)) {
+		t.Fatal("expected the first run to be persisted")
+	}
+	advance(t, clock, time.Second)
+	if !eventually(t, persisted(2)) {
+		t.Fatal("expected the second run to be persisted")
+	}
+
+	runs := s.History("job")
+	if len(runs) != 1 || runs[0].Err == nil || runs[0].End().Before(runs[0].Start) {
+		t.Errorf("expected history to hold the last failed run, got %+v", runs)
+	}
+}
+
+func TestService_VirtualTime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Minute, Clock: clock}
+
+	var runs atomic.Int32
+	if _, err := s.AddJob(
+		func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+		clock.Now().Add(time.Hour),
+		cron.WithName("hourly"),
+		cron.WithInterval(time.Hour),
+	); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	for i := range 3 {
+		for range 60 {
+			advance(t, clock, time.Minute)
+		}
+		if !eventually(t, func() bool { return runs.Load() == int32(i+1) }) {
+			t.Fatalf("expected %d runs after %d virtual hours, got %d", i+1, i+1, runs.Load())
+		}
+	}
+}
+
+func TestService_Jobs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, Clock: clock}
+
+	release := make(chan struct{})
+	defer close(release)
+	if _, err := s.AddJob(
+		func(ctx context.Context) error {
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			return nil
+		},
+		clock.Now(),
+		cron.WithName("blocking"),
+		cron.WithIntervalMode(cron.IntervalModeBetweenRuns),
+	); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	var jobs []cron.JobInfo
+	if !eventually(t, func() bool {
+		jobs = s.Jobs()
+		return len(jobs) == 1 && jobs[0].State == cron.JobStateRunning
+	}) {
+		t.Fatalf("expected the job to be running, got %+v", jobs)
+	}
+	if j := jobs[0]; j.Name != "blocking" || j.RunCount != 1 || j.LastRun.IsZero() || !j.NextRun.IsZero() {
+		t.Errorf("unexpected job descriptor: %+v", j)
+	}
+}
+
+func TestService_MaxConcurrent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, MaxConcurrent: 1, Clock: clock}
+
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	defer close(release)
+	now := clock.Now()
+	for _, name := range []string{"first", "second"} {
+		if _, err := s.AddJob(
+			func(ctx context.Context) error {
+				started <- cron.JobName(ctx)
+				<-release
+				return nil
+			},
+			now,
+			cron.WithName(name),
+			cron.WithMaxRun(1),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	var first string
+	select {
+	case first = <-started:
+	case <-time.After(time.Second):
+		t.Fatal("expected a job to start")
+	}
+	// Jobs waits for the scheduler iteration that started the first job
+	for _, j := range s.Jobs() {
+		if j.Name != first && j.State == cron.JobStateRunning {
+			t.Errorf("expected at most 1 concurrently running job, %s runs next to %s", j.Name, first)
+		}
+	}
+
+	// the slot is freed once the first run has returned, after which the
+	// delayed job starts on the next tick
+	release <- struct{}{}
+	var second string
+	for tick := 0; second == "" && tick < 10; tick++ {
+		advance(t, clock, time.Second)
+		select {
+		case second = <-started:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if second == "" || second == first {
+		t.Errorf("expected the delayed job to run after %s, got %q", first, second)
+	}
+}
+
+func TestWithInterval(t *testing.T) {
+	noop := func(context.Context) error { return nil }
 
-	if countA < 2 || countA > 4 {
-		t.Errorf("expected Job A count to be around 3, got %d", countA)
+	// jobs added before flag parsing are checked against the configured
+	// scheduler interval, not the flag default
+	s := &cron.Service{}
+	s.FlagSet()
+	if _, err := s.AddJob(noop, time.Now(), cron.WithInterval(2*time.Second)); err != nil {
+		t.Errorf("expected job added before validation to be accepted: %v", err)
 	}
-	if countB != 1 {
-		t.Errorf("expected Job B count to be 1, got %d", countB)
+	s.SchedulerInterval = time.Second
+	if err := s.Validate(); err != nil {
+		t.Errorf("expected interval following the scheduler interval to be valid: %v", err)
+	}
+	if _, err := s.AddJob(noop, time.Now(),
+		cron.WithInterval(500*time.Millisecond)); !errors.Is(err, cron.ErrIntervalTooShort) {
+		t.Errorf("expected ErrIntervalTooShort after validation, got %v", err)
+	}
+
+	s = &cron.Service{}
+	s.FlagSet()
+	if _, err := s.AddJob(noop, time.Now(), cron.WithInterval(30*time.Second)); err != nil {
+		t.Errorf("expected job added before validation to be accepted: %v", err)
+	}
+	if err := s.Validate(); !errors.Is(err, cron.ErrIntervalTooShort) {
+		t.Errorf("expected ErrIntervalTooShort, got %v", err)
+	}
+}
+
+func TestService_RunAfter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, Clock: clock}
+
+	var fail atomic.Bool
+	failed := make(chan struct{})
+	extract, err := s.AddJob(
+		func(ctx context.Context) error {
+			if fail.Swap(true) {
+				return errors.New("failure")
+			}
+			cron.SetResult(ctx, "rows")
+			return nil
+		},
+		clock.Now(),
+		cron.WithName("extract"),
+		cron.WithMaxRun(2),
+		cron.WithErrorHook(func(string, error) { close(failed) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(chan any, 2)
+	if _, err = s.AddJob(
+		func(ctx context.Context) error {
+			v, _ := cron.Result(ctx)
+			results <- v
+			return nil
+		},
+		clock.Now(),
+		cron.WithName("load"),
+		cron.WithRunAfter(extract),
+	); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	var v any
+	select {
+	case v = <-results:
+	case <-time.After(time.Second):
+		t.Fatal("expected the job to run after the successful run")
+	}
+	if v != "rows" {
+		t.Errorf("expected result %q, got %v", "rows", v)
+	}
+
+	advance(t, clock, time.Second)
+	select {
+	case <-failed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the second run to fail")
+	}
+	if len(results) != 0 {
+		t.Errorf("expected the job not to run after the failed run, got %d runs", 1+len(results))
+	}
+
+	if _, err = (&cron.Service{SchedulerInterval: time.Second}).AddJob(
+		func(context.Context) error { return nil }, time.Now(),
+		cron.WithRunAfter(extract),
+	); !errors.Is(err, cron.ErrRunAfterOtherService) {
+		t.Errorf("expected ErrRunAfterOtherService, got %v", err)
+	}
+}
+
+func TestService_Blackout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, Clock: clock}
+
+	var runs, skipped atomic.Int32
+	now := clock.Now()
+	if _, err := s.AddJob(
+		func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+		now,
+		cron.WithName("blackout"),
+		cron.WithMaxRun(1),
+		cron.WithBlackout(cron.TimeWindow{Start: now.Add(-time.Hour), End: now.Add(1500 * time.Millisecond)}),
+		cron.WithHooks(cron.Hooks{OnSkipped: func(run cron.Run) {
+			if errors.Is(run.Err, cron.ErrBlackout) {
+				skipped.Add(1)
+			}
+		}}),
+	); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	if !eventually(t, func() bool { return skipped.Load() == 1 }) || runs.Load() != 0 {
+		t.Errorf("expected the run to be skipped, got runs=%d skipped=%d", runs.Load(), skipped.Load())
+	}
+	// the first tick still falls within the blackout
+	advance(t, clock, time.Second)
+	advance(t, clock, time.Second)
+	if !eventually(t, func() bool { return runs.Load() == 1 }) {
+		t.Errorf("expected the run to be rescheduled after the blackout, got %d runs", runs.Load())
+	}
+}
+
+func TestLoadCalendar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.json")
+	if err := os.WriteFile(path, []byte(`{
+		"skip_weekdays": ["Saturday", "Sunday"],
+		"holidays": ["2025-12-25"]
+	}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cron.LoadCalendar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.SkipWeekdays) != 2 || c.SkipWeekdays[0] != time.Saturday || len(c.Holidays) != 1 {
+		t.Errorf("unexpected calendar: %+v", c)
+	}
+
+	if err = os.WriteFile(path, []byte(`{"skip_weekdays": ["Caturday"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cron.LoadCalendar(path); err == nil {
+		t.Error("expected invalid weekday to be rejected")
+	}
+}
+
+func TestReference_Reschedule(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, Clock: clock}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	var runs atomic.Int32
+	ref, err := s.AddJob(
+		func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+		clock.Now().Add(time.Hour),
+		cron.WithName("rescheduled"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ref.Reschedule(clock.Now(), cron.WithInterval(500*time.Millisecond)); !errors.Is(err, cron.ErrIntervalTooShort) {
+		t.Errorf("expected ErrIntervalTooShort, got %v", err)
+	}
+	if err = ref.Reschedule(clock.Now(), cron.WithMaxRun(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	advance(t, clock, time.Second)
+	if !eventually(t, func() bool { return runs.Load() == 1 }) {
+		t.Fatalf("expected the rescheduled job to run once, got %d", runs.Load())
+	}
+	// the job is canceled on the tick after its last run
+	advance(t, clock, time.Second)
+	if !eventually(t, func() bool { return len(s.Jobs()) == 0 }) {
+		t.Fatal("expected the job to be canceled after its last run")
+	}
+	if got := runs.Load(); got != 1 {
+		t.Errorf("expected the rescheduled job to run once, got %d", got)
+	}
+	if err = ref.Reschedule(clock.Now()); err == nil {
+		t.Error("expected rescheduling a canceled job to fail")
+	}
+}
+
+func TestReference_RescheduleWhileRunning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	var finished atomic.Int32
+	done := func(cron.Run) { finished.Add(1) }
+	s := &cron.Service{
+		SchedulerInterval: time.Second,
+		Clock:             clock,
+		Hooks:             cron.Hooks{OnSuccess: done, OnFailure: done},
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first run keeps running for a while without synchronizing with the
+	// test, so the race detector catches unguarded reads of the settings
+	started := make(chan struct{})
+	var runs atomic.Int32
+	ref, err := s.AddJob(
+		func(context.Context) error {
+			if runs.Add(1) == 1 {
+				close(started)
+				time.Sleep(50 * time.Millisecond)
+				return errors.New("failure")
+			}
+			return nil
+		},
+		clock.Now(),
+		cron.WithName("running"),
+		cron.WithIntervalMode(cron.IntervalModeBetweenRuns),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+	<-started
+
+	// the settings are replaced while the job is running
+	var reported atomic.Int32
+	if err = ref.Reschedule(clock.Now(),
+		cron.WithInterval(2*time.Second),
+		cron.WithJitter(time.Millisecond),
+		cron.WithHooks(cron.Hooks{OnSuccess: func(cron.Run) { reported.Add(1) }}),
+		cron.WithErrorHook(func(string, error) {}),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if !eventually(t, func() bool { return finished.Load() == 1 }) {
+		t.Fatal("expected the active run to finish")
+	}
+
+	// the next run uses the new settings
+	advance(t, clock, 3*time.Second)
+	if !eventually(t, func() bool { return finished.Load() == 2 && reported.Load() == 1 }) {
+		t.Fatalf("expected the rescheduled job to run again, got %d runs", runs.Load())
+	}
+}
+
+func TestService_AdminHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, HistorySize: 5, Clock: clock}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	ran := make(chan struct{}, 1)
+	if _, err := s.AddJob(
+		func(context.Context) error {
+			ran <- struct{}{}
+			return nil
+		},
+		clock.Now().Add(time.Hour),
+		cron.WithName("report"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if !eventually(t, func() bool { return clock.Waiters() > 0 }) {
+		t.Fatal("expected the scheduler to be running")
+	}
+	h := s.AdminHandler("/admin/cron/")
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	var jobs []cron.JobInfo
+	rec := do(http.MethodGet, "/admin/cron/jobs")
+	if err := json.NewDecoder(rec.Body).Decode(&jobs); err != nil || len(jobs) != 1 || jobs[0].Name != "report" {
+		t.Errorf("unexpected job listing: %v %+v", err, jobs)
+	}
+	if rec = do(http.MethodGet, "/admin/cron/jobs/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown job, got %d", rec.Code)
+	}
+	if rec = do(http.MethodPost, "/admin/cron/jobs/report/pause"); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 on pause, got %d", rec.Code)
+	}
+	if rec = do(http.MethodPost, "/admin/cron/jobs/report/run"); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 running a paused job, got %d", rec.Code)
+	}
+	do(http.MethodPost, "/admin/cron/jobs/report/resume")
+	if rec = do(http.MethodPost, "/admin/cron/jobs/report/run"); rec.Code != http.StatusAccepted {
+		t.Errorf("expected 202 on run, got %d", rec.Code)
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("expected the job to run")
+	}
+	var history string
+	if !eventually(t, func() bool {
+		history = do(http.MethodGet, "/admin/cron/jobs/report/history").Body.String()
+		return strings.Contains(history, `"attempts":1`)
+	}) {
+		t.Errorf("expected run in history, got %s", history)
+	}
+}
+
+func TestReference_RunNow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, MaxConcurrent: 1, Clock: clock}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	noop := func(context.Context) error { return nil }
+	busy, err := s.AddJob(
+		func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		},
+		clock.Now(),
+		cron.WithName("busy"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle, err := s.AddJob(noop, clock.Now().Add(time.Hour), cron.WithName("idle"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+	<-started
+
+	if err = busy.RunNow(); !errors.Is(err, cron.ErrJobRunning) {
+		t.Errorf("expected ErrJobRunning, got %v", err)
+	}
+	if err = idle.RunNow(); !errors.Is(err, cron.ErrMaxConcurrent) {
+		t.Errorf("expected ErrMaxConcurrent, got %v", err)
+	}
+	close(release)
+	if !eventually(t, func() bool { return idle.RunNow() == nil }) {
+		t.Error("expected the job to run once a slot is free")
+	}
+
+	// job names identify jobs, unnamed jobs can't be looked up
+	if _, err = s.AddJob(noop, clock.Now(), cron.WithName("idle")); !errors.Is(err, cron.ErrDuplicateName) {
+		t.Errorf("expected ErrDuplicateName, got %v", err)
+	}
+	if err = busy.Reschedule(clock.Now(), cron.WithName("idle")); !errors.Is(err, cron.ErrDuplicateName) {
+		t.Errorf("expected ErrDuplicateName on reschedule, got %v", err)
+	}
+	for range 2 {
+		if _, err = s.AddJob(noop, clock.Now()); err != nil {
+			t.Errorf("expected unnamed job to be added: %v", err)
+		}
+	}
+	if _, ok := s.Job("anonymous"); ok {
+		t.Error("expected unnamed jobs not to be found by name")
+	}
+}
+
+func TestService_OverrunPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, Clock: clock}
+
+	// release ends the active runs of both jobs
+	release := make(chan struct{})
+	var queued, concurrent, peak atomic.Int32
+	now := clock.Now()
+	if _, err := s.AddJob(
+		func(context.Context) error {
+			queued.Add(1)
+			<-release
+			return nil
+		},
+		now,
+		cron.WithName("queued"),
+		cron.WithOverrunPolicy(cron.OverrunQueue),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddJob(
+		func(context.Context) error {
+			n := concurrent.Add(1)
+			defer concurrent.Add(-1)
+			if n > peak.Load() {
+				peak.Store(n)
+			}
+			<-release
+			return nil
+		},
+		now,
+		cron.WithName("concurrent"),
+		cron.WithOverrunPolicy(cron.OverrunConcurrent),
+	); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	if !eventually(t, func() bool { return queued.Load() == 1 && peak.Load() == 1 }) {
+		t.Fatal("expected both jobs to run")
+	}
+	// the next tick arrives while the first runs are still active
+	advance(t, clock, time.Second)
+	if !eventually(t, func() bool { return peak.Load() == 2 }) {
+		t.Errorf("expected concurrent runs, got peak of %d", peak.Load())
+	}
+	// Jobs waits for the scheduler iteration to handle the tick for both jobs
+	s.Jobs()
+	if got := queued.Load(); got != 1 {
+		t.Errorf("expected the run to be queued while the first run is active, got %d runs", got)
+	}
+
+	close(release)
+	if !eventually(t, func() bool { return queued.Load() == 2 }) {
+		t.Errorf("expected the queued run to start after the first run, got %d runs", queued.Load())
+	}
+}
+
+type lastRunStore struct {
+	memHistory
+	last time.Time
+}
+
+func (s *lastRunStore) LastRun(context.Context, string) (time.Time, error) {
+	return s.last, nil
+}
+
+func TestService_MisfirePolicy(t *testing.T) {
+	clock := crontest.NewClock(time.Now())
+	// three runs missed
+	store := &lastRunStore{last: clock.Now().Add(-35 * time.Second)}
+	s := &cron.Service{SchedulerInterval: time.Second, HistoryStore: store, Clock: clock}
+
+	var once, all atomic.Int32
+	next := clock.Now().Add(time.Hour)
+	for name, policy := range map[string]cron.MisfirePolicy{
+		"once": cron.MisfireFireOnce,
+		"all":  cron.MisfireFireAll,
+	} {
+		count := &once
+		if policy == cron.MisfireFireAll {
+			count = &all
+		}
+		if _, err := s.AddJob(
+			func(context.Context) error {
+				count.Add(1)
+				return nil
+			},
+			next,
+			cron.WithName(name),
+			cron.WithInterval(10*time.Second),
+			cron.WithMisfirePolicy(policy),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.AddJob(func(context.Context) error { return nil }, next,
+		cron.WithMisfirePolicy(cron.MisfireFireOnce)); !errors.Is(err, cron.ErrMisfireRequiresName) {
+		t.Errorf("expected ErrMisfireRequiresName, got %v", err)
+	}
+	if _, err := (&cron.Service{SchedulerInterval: time.Second, HistoryStore: &memHistory{}}).AddJob(
+		func(context.Context) error { return nil }, next,
+		cron.WithName("misfire"), cron.WithMisfirePolicy(cron.MisfireFireOnce),
+	); !errors.Is(err, cron.ErrMisfireRequiresStore) {
+		t.Errorf("expected ErrMisfireRequiresStore, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.ServeContext(ctx) }()
+
+	// a catch-up run is started per tick
+	for tick := int32(1); tick <= 3; tick++ {
+		if tick > 1 {
+			advance(t, clock, time.Second)
+		}
+		if !eventually(t, func() bool { return all.Load() == tick && once.Load() == 1 }) {
+			t.Fatalf("tick %d: expected a catch-up run per missed run, got %d", tick, all.Load())
+		}
+	}
+
+	if got := once.Load(); got != 1 {
+		t.Errorf("expected a single catch-up run, got %d", got)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	fast := &cron.Service{Prefix: "fast"}
+	slow := &cron.Service{Prefix: "slow"}
+	fast.Initialize()
+	slow.Initialize()
+
+	if s, ok := cron.Lookup("fast"); !ok || s != fast {
+		t.Error("expected to find the fast scheduler")
+	}
+	if s, ok := cron.Lookup("slow"); !ok || s != slow {
+		t.Error("expected to find the slow scheduler")
+	}
+	if slow.Name() != "slow-cron" || slow.FlagSet().Lookup("slow-scheduler-interval") == nil {
+		t.Error("expected the scheduler name and flags to be prefixed")
+	}
+}
+
+func TestService_Middleware(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := crontest.NewClock(time.Now())
+	s := &cron.Service{SchedulerInterval: time.Second, Clock: clock}
+	var order []string
+	var mtx sync.Mutex
+	for _, name := range []string{"outer", "inner"} {
+		s.Use(func(next cron.Job) cron.Job {
+			return func(ctx context.Context) error {
+				mtx.Lock()
+				order = append(order, name)
+				mtx.Unlock()
+				return next(ctx)
+			}
+		})
+	}
+	go func() { _ = s.ServeContext(ctx) }()
+
+	type attempt struct {
+		job, runID string
+		n          int
+	}
+	attempts := make(chan attempt, 2)
+	if _, err := s.AddJob(
+		func(ctx context.Context) error {
+			attempts <- attempt{cron.JobName(ctx), cron.RunID(ctx), cron.Attempt(ctx)}
+			if cron.Attempt(ctx) == 1 {
+				return errors.New("failure")
+			}
+			return nil
+		},
+		clock.Now(),
+		cron.WithName("wrapped"),
+		cron.WithMaxRun(1),
+		cron.WithRetry(2, 10*time.Millisecond),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func() attempt {
+		t.Helper()
+		select {
+		case a := <-attempts:
+			return a
+		case <-time.After(time.Second):
+			t.Fatal("expected the job to be called")
+			return attempt{}
+		}
 	}
-	if countC != 1 {
-		t.Errorf("expected Job C count to be 1, got %d", countA)
+	first := receive()
+	// wait for both the scheduler and the retry to wait on the clock
+	if !eventually(t, func() bool { return clock.Waiters() == 2 }) {
+		t.Fatal("expected the failed attempt to be retried")
 	}
-	if countD < 2 || countD > 4 {
-		t.Errorf("expected Job D count to be around 3, got %d", countB)
+	clock.Advance(10 * time.Millisecond)
+	second := receive()
+	if first.job != "wrapped" || first.n != 1 || second.n != 2 ||
+		first.runID == "" || first.runID != second.runID {
+		t.Errorf("unexpected context values: %+v %+v", first, second)
 	}
-	if countE != 1 {
-		t.Errorf("expected Job E count to be 1, got %d", countE)
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(order) != 4 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("unexpected middleware order: %v", order)
 	}
 }
@@ -0,0 +1,66 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// HistoryStore can be set on the Service to persist the history of job runs,
+// e.g. in a database for admin tools.
+type HistoryStore interface {
+	// SaveRun persists the run of a job.
+	SaveRun(ctx context.Context, run Run) error
+}
+
+// End returns the time the run ended.
+func (r Run) End() time.Time {
+	return r.Start.Add(r.Duration)
+}
+
+// History returns the most recent runs of the job with the provided name,
+// oldest first. Skipped runs are not included.
+func (s *Service) History(name string) []Run {
+	s.historyMtx.Lock()
+	defer s.historyMtx.Unlock()
+	return slices.Clone(s.history[name])
+}
+
+// record adds the run to the bounded history of the job and, if configured,
+// persists it to the HistoryStore.
+func (s *Service) record(ctx context.Context, run Run) {
+	if run.Attempts == 0 {
+		return
+	}
+	if s.HistorySize > 0 {
+		s.historyMtx.Lock()
+		if s.history == nil {
+			s.history = make(map[string][]Run)
+		}
+		runs := append(s.history[run.Job], run)
+		if len(runs) > s.HistorySize {
+			runs = slices.Delete(runs, 0, len(runs)-s.HistorySize)
+		}
+		s.history[run.Job] = runs
+		s.historyMtx.Unlock()
+	}
+	if s.HistoryStore != nil {
+		if err := s.HistoryStore.SaveRun(context.WithoutCancel(ctx), run); err != nil {
+			log.Error("unable to persist job run", err, "job", run.Job)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"errors"
+	"time"
+)
+
+// Run holds the metadata of a job run as passed to hooks.
+type Run struct {
+	// Job holds the name of the job.
+	Job string
+	// Start holds the time the run started.
+	Start time.Time
+	// Duration holds the duration of the run including retries. It is zero
+	// for skipped runs.
+	Duration time.Duration
+	// Attempts holds the number of times the job was called for this run.
+	Attempts int
+	// Err holds the error of a failed run or the reason a run was skipped.
+	Err error
+}
+
+// Hooks holds functions called on completion of job runs, so alerting and
+// bookkeeping can be attached without wrapping each Job. Hooks are called
+// from the job goroutine and should not block.
+type Hooks struct {
+	// OnSuccess is called after a run completed without error.
+	OnSuccess func(run Run)
+	// OnFailure is called after a run failed, after all retry attempts are
+	// exhausted.
+	OnFailure func(run Run)
+	// OnSkipped is called if a run was skipped, e.g. because the distributed
+	// lock is held by another instance.
+	OnSkipped func(run Run)
+}
+
+// call calls the hook matching the outcome of the run.
+func (h Hooks) call(run Run) {
+	var fn func(Run)
+	switch {
+	case run.Attempts == 0:
+		fn = h.OnSkipped
+	case run.Err != nil:
+		fn = h.OnFailure
+	default:
+		fn = h.OnSuccess
+	}
+	if fn != nil {
+		fn(run)
+	}
+}
+
+// WithHooks sets the hooks called on completion of the job's runs. These are
+// called before the service-level hooks.
+func WithHooks(hooks Hooks) Option {
+	return func(r *Reference) error {
+		if hooks.OnSuccess == nil && hooks.OnFailure == nil && hooks.OnSkipped == nil {
+			return errors.New("hooks cannot be empty")
+		}
+		r.hooks = hooks
+		return nil
+	}
+}
+
+// report passes the run to the provided job hooks and the service-level hooks
+// and records it in the run history.
+func (r *Reference) report(hooks Hooks, run Run) {
+	hooks.call(run)
+	r.svc.Hooks.call(run)
+	r.svc.record(r.ctx, run)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// anonymousJob holds the name of jobs added without name. Unnamed jobs can't
+// be looked up by name.
+const anonymousJob = "anonymous"
+
+var (
+	// ErrJobPaused is returned when requesting an immediate run of a paused
+	// job.
+	ErrJobPaused = errors.New("job is paused")
+	// ErrMaxConcurrent is returned when requesting an immediate run while the
+	// maximum number of concurrently running jobs is reached.
+	ErrMaxConcurrent = errors.New("maximum concurrent jobs reached")
+	// ErrDuplicateName is returned when adding a job with the name of an
+	// already registered job.
+	ErrDuplicateName = errors.New("job name already registered")
+
+	errJobInactive = errors.New("job is not active")
+)
+
+// JobState holds the state of a registered job.
+type JobState string
+
+// Job states.
+const (
+	JobStateScheduled JobState = "scheduled"
+	JobStateRunning   JobState = "running"
+	JobStatePaused    JobState = "paused"
+)
+
+// JobInfo describes a registered job.
+type JobInfo struct {
+	Name     string    `json:"name"`
+	NextRun  time.Time `json:"next_run,omitzero"`
+	LastRun  time.Time `json:"last_run,omitzero"`
+	RunCount int       `json:"run_count"`
+	State    JobState  `json:"state"`
+}
+
+// Jobs returns descriptors of the registered jobs, e.g. to expose on a
+// scheduled tasks status page. The next run is zero if the job's next run is
+// not yet known, as for interval modes relative to a running job.
+func (s *Service) Jobs() []JobInfo {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	jobs := make([]JobInfo, 0, len(s.jobs))
+	for _, r := range s.jobs {
+		jobs = append(jobs, r.info())
+	}
+	return jobs
+}
+
+// Job returns the registered job with the provided name.
+func (s *Service) Job(name string) (*Reference, bool) {
+	if name == anonymousJob {
+		return nil, false
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, r := range s.jobs {
+		if r.name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// info returns the descriptor of the job. It requires the Service mutex.
+func (r *Reference) info() JobInfo {
+	info := JobInfo{
+		Name:     r.name,
+		LastRun:  r.lastRun,
+		RunCount: r.runCount,
+		State:    JobStateScheduled,
+	}
+	if next := *r.nextRun.Load(); !next.Equal(maxTime) {
+		info.NextRun = next
+	}
+	switch {
+	case r.running.Load() > 0:
+		info.State = JobStateRunning
+	case r.paused.Load():
+		info.State = JobStatePaused
+	}
+	return info
+}
+
+// Pause stops scheduling runs of the job until resumed. An active run is not
+// interrupted.
+func (r *Reference) Pause() {
+	r.paused.Store(true)
+	log.Info("job paused", "job", r.name)
+}
+
+// Resume resumes scheduling runs of a paused job. Runs due while paused are
+// started on the next scheduler tick.
+func (r *Reference) Resume() {
+	r.paused.Store(false)
+	log.Info("job resumed", "job", r.name)
+}
+
+// RunNow triggers an immediate run of the job. For jobs running on tick, the
+// following runs are scheduled relative to this run. It returns
+// ErrJobRunning if the previous run is still active and the overrun policy
+// does not allow concurrent runs, ErrBlackout within a blackout window and
+// ErrMaxConcurrent if no slot to run the job is free.
+func (r *Reference) RunNow() error {
+	if r.paused.Load() {
+		return ErrJobPaused
+	}
+	r.svc.mtx.Lock()
+	defer r.svc.mtx.Unlock()
+	if r.svc.done || r.ctx == nil || r.ctx.Err() != nil {
+		return errJobInactive
+	}
+	now := r.svc.clock().Now()
+	if r.running.Load() > 0 && r.overrunPolicy != OverrunConcurrent {
+		return ErrJobRunning
+	}
+	if _, ok := r.blackedOut(now); ok {
+		return ErrBlackout
+	}
+	if !r.svc.available() {
+		return ErrMaxConcurrent
+	}
+	r.nextRun.Store(&now)
+	if !r.run() {
+		// the job reached its maximum runs or stop time
+		return errJobInactive
+	}
+	log.Info("job triggered", r.logDetails()...)
+	return nil
+}
+
+// checkName returns ErrDuplicateName if a job other than r is registered
+// with the provided name. The caller holds the Service mutex.
+func (s *Service) checkName(r *Reference, name string) error {
+	if name == anonymousJob {
+		return nil
+	}
+	for _, j := range s.jobs {
+		if j != r && j.name == name {
+			return fmt.Errorf("%w: %s", ErrDuplicateName, name)
+		}
+	}
+	return nil
+}
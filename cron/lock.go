@@ -0,0 +1,62 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// lockPrefix holds the prefix of the distributed lock keys of jobs.
+const lockPrefix = "cron:"
+
+// ErrLockRequiresName is returned if a job with a distributed lock has no
+// name to derive the lock key from.
+var ErrLockRequiresName = errors.New("distributed lock requires a job name")
+
+// ErrLockHeld is reported to OnSkipped hooks if a run was skipped because the
+// distributed lock is held by another instance.
+var ErrLockHeld = errors.New("job lock held by another instance")
+
+// Locker coordinates job runs between instances, e.g. backed by the redis or
+// postgresql handlers.
+type Locker interface {
+	// TryLock obtains the lock for the key for the provided time to live. It
+	// returns false if the lock is held by another instance. The lock is not
+	// released before the time to live expires.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// lock returns nil if no distributed lock is configured or the lock for this
+// run is obtained. The lock is held for most of the interval, so the job
+// fires on exactly one instance per tick, even if the scheduler ticks of the
+// instances are not aligned.
+func (r *Reference) lock(cfg *settings) error {
+	if cfg.locker == nil {
+		return nil
+	}
+	ok, err := cfg.locker.TryLock(r.ctx, lockPrefix+cfg.name, cfg.interval-cfg.interval/10)
+	if err != nil {
+		log.Error("unable to obtain job lock", err, "job", cfg.name)
+		return fmt.Errorf("unable to obtain job lock: %w", err)
+	}
+	if !ok {
+		log.Debug("job lock held by another instance", "job", cfg.name)
+		return ErrLockHeld
+	}
+	return nil
+}
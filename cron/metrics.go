@@ -0,0 +1,84 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"github.com/basvanbeek/telemetry"
+)
+
+// metrics holds the per-job metrics of the cron service.
+type metrics struct {
+	job         telemetry.Label
+	runs        telemetry.Metric
+	failures    telemetry.Metric
+	duration    telemetry.Metric
+	lastSuccess telemetry.Metric
+	running     telemetry.Metric
+}
+
+func newMetrics(sink telemetry.MetricSink) *metrics {
+	job := sink.NewLabel("job")
+	return &metrics{
+		job: job,
+		runs: sink.NewSum("cron_job_runs_total",
+			"Total number of job runs.", telemetry.WithLabels(job)),
+		failures: sink.NewSum("cron_job_failures_total",
+			"Total number of failed job runs.", telemetry.WithLabels(job)),
+		duration: sink.NewGauge("cron_job_last_duration_seconds",
+			"Duration of the last job run.", telemetry.WithLabels(job),
+			telemetry.WithUnit(telemetry.Seconds)),
+		lastSuccess: sink.NewGauge("cron_job_last_success_timestamp_seconds",
+			"Unix timestamp of the last successful job run.",
+			telemetry.WithLabels(job), telemetry.WithUnit(telemetry.Seconds)),
+		running: sink.NewGauge("cron_job_running",
+			"Number of currently running job runs.", telemetry.WithLabels(job)),
+	}
+}
+
+// setupMetrics creates the metrics using the configured MetricSink or, if not
+// set, the global MetricSink as soon as it is registered.
+func (s *Service) setupMetrics() {
+	if s.MetricSink != nil {
+		s.metrics.Store(newMetrics(s.MetricSink))
+		return
+	}
+	telemetry.ToGlobalMetricSink(func(sink telemetry.MetricSink) {
+		s.metrics.Store(newMetrics(sink))
+	})
+}
+
+// started records the start of a run of the job.
+func (m *metrics) started(r *Reference, run Run) {
+	if m == nil {
+		return
+	}
+	m.running.With(m.job.Upsert(run.Job)).Record(float64(r.running.Load()))
+}
+
+// finished records the outcome of a run of the job.
+func (m *metrics) finished(r *Reference, run Run) {
+	if m == nil {
+		return
+	}
+	job := m.job.Upsert(run.Job)
+	m.running.With(job).Record(float64(r.running.Load()))
+	m.runs.With(job).Increment()
+	m.duration.With(job).Record(run.Duration.Seconds())
+	if run.Err != nil {
+		m.failures.With(job).Increment()
+		return
+	}
+	m.lastSuccess.With(job).Record(float64(run.Start.Add(run.Duration).Unix()))
+}
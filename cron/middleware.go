@@ -0,0 +1,87 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/basvanbeek/telemetry"
+)
+
+// Middleware wraps a Job, e.g. to add tracing to every job uniformly.
+type Middleware func(Job) Job
+
+type runMetaKey struct{}
+
+// runMeta holds the baseline context values of a job run attempt.
+type runMeta struct {
+	job     string
+	runID   string
+	attempt int
+}
+
+// Use adds middleware wrapping all jobs of the Service. Middleware is applied
+// in the order provided, the first being the outermost.
+func (s *Service) Use(mw ...Middleware) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.middleware = append(s.middleware, mw...)
+}
+
+// wrap returns the job wrapped by the Service middleware.
+func (s *Service) wrap(job Job) Job {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		job = s.middleware[i](job)
+	}
+	return job
+}
+
+// JobName returns the name of the job running with the provided context.
+func JobName(ctx context.Context) string {
+	m, _ := ctx.Value(runMetaKey{}).(runMeta)
+	return m.job
+}
+
+// RunID returns the unique ID of the job run, shared by its retry attempts.
+func RunID(ctx context.Context) string {
+	m, _ := ctx.Value(runMetaKey{}).(runMeta)
+	return m.runID
+}
+
+// Attempt returns the attempt number of the job run, starting at 1.
+func Attempt(ctx context.Context) int {
+	m, _ := ctx.Value(runMetaKey{}).(runMeta)
+	return m.attempt
+}
+
+// withRunMeta returns a copy of the context holding the baseline values of
+// the run attempt, also added to the telemetry key/value pairs so loggers
+// using the context include them.
+func withRunMeta(ctx context.Context, m runMeta) context.Context {
+	ctx = context.WithValue(ctx, runMetaKey{}, m)
+	return telemetry.KeyValuesToContext(ctx,
+		"job", m.job, "run_id", m.runID, "attempt", m.attempt)
+}
+
+// newRunID returns a random run ID.
+func newRunID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
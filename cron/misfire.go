@@ -0,0 +1,100 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrMisfireRequiresName is returned if a job with a misfire policy has
+	// no name to look up its last run.
+	ErrMisfireRequiresName = errors.New("misfire policy requires a job name")
+	// ErrMisfireRequiresStore is returned if a job with a misfire policy is
+	// added to a Service without HistoryStore implementing LastRunStore.
+	ErrMisfireRequiresStore = errors.New("misfire policy requires a history store implementing LastRunStore")
+)
+
+// maxMissedRuns limits the number of missed runs fired by MisfireFireAll.
+const maxMissedRuns = 100
+
+// MisfirePolicy determines how a job handles runs missed while the process
+// was down.
+type MisfirePolicy int
+
+const (
+	// MisfireSkip skips the missed runs.
+	MisfireSkip MisfirePolicy = iota
+	// MisfireFireOnce runs the job once immediately if runs were missed.
+	MisfireFireOnce
+	// MisfireFireAll runs the job once for each missed run, up to 100, one
+	// per scheduler tick. It only applies to jobs running on tick.
+	MisfireFireAll
+)
+
+// LastRunStore can be implemented by a HistoryStore to look up the last
+// persisted run of a job, enabling misfire handling.
+type LastRunStore interface {
+	// LastRun returns the start of the last run of the job, or the zero time
+	// if the job did not run before.
+	LastRun(ctx context.Context, job string) (time.Time, error)
+}
+
+// WithMisfirePolicy sets how the job handles runs missed while the process
+// was down. It requires the Service HistoryStore to implement LastRunStore
+// and the job to have a unique name. The default is MisfireSkip.
+func WithMisfirePolicy(policy MisfirePolicy) Option {
+	return func(r *Reference) error {
+		if policy < MisfireSkip || policy > MisfireFireAll {
+			return errors.New("invalid misfire policy")
+		}
+		r.misfirePolicy = policy
+		return nil
+	}
+}
+
+// catchUp applies the misfire policy of the job based on its last persisted
+// run.
+func (s *Service) catchUp(ctx context.Context, r *Reference) {
+	if r.misfirePolicy == MisfireSkip || r.interval <= 0 || r.after != nil {
+		return
+	}
+	store, ok := s.HistoryStore.(LastRunStore)
+	if !ok {
+		log.Info("history store does not support looking up last runs, misfires not handled",
+			"job", r.name)
+		return
+	}
+	last, err := store.LastRun(ctx, r.name)
+	if err != nil {
+		log.Error("unable to look up last job run", err, "job", r.name)
+		return
+	}
+	missed := int(s.since(last) / r.interval)
+	if last.IsZero() || missed < 1 {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	log.Info("job missed runs", "job", r.name, "last_run", last, "missed", missed)
+	now := s.clock().Now()
+	r.nextRun.Store(&now)
+	if r.misfirePolicy == MisfireFireAll && r.mode == IntervalModeOnTick {
+		r.missed = min(missed, maxMissedRuns) - 1
+	}
+}
@@ -21,7 +21,7 @@ import (
 )
 
 var (
-	ErrIntervalTooShort = errors.New("interval needs to the same or larger than the scheduler interval")
+	ErrIntervalTooShort = errors.New("interval needs to be the same or larger than the scheduler interval")
 )
 
 type Option func(r *Reference) error
@@ -34,11 +34,15 @@ func WithMaxRun(maxRun int) Option {
 	}
 }
 
-// WithInterval sets the interval between runs of the job.
+// WithInterval sets the interval between runs of the job. The interval needs
+// to be at least the scheduler interval of the Service the job is added to,
+// so second-granularity jobs are supported with a one second scheduler
+// interval. Jobs added before the Service is validated are checked against
+// the configured scheduler interval by Validate.
 func WithInterval(interval time.Duration) Option {
 	return func(r *Reference) error {
-		if interval < time.Second {
-			return ErrIntervalTooShort
+		if interval <= 0 {
+			return errors.New("interval needs to be positive")
 		}
 		r.interval = interval
 		return nil
@@ -74,3 +78,67 @@ func WithName(name string) Option {
 		return nil
 	}
 }
+
+// WithDistributedLock makes the job obtain a distributed lock before each
+// run, so in multi-replica deployments it fires on one instance per tick.
+// The job requires a unique name to derive the lock key from.
+func WithDistributedLock(locker Locker) Option {
+	return func(r *Reference) error {
+		if locker == nil {
+			return errors.New("locker cannot be nil")
+		}
+		r.locker = locker
+		return nil
+	}
+}
+
+// WithMaxPanics cancels the job after the provided number of consecutive
+// runs panicked. Panics are always recovered and logged as failed runs.
+func WithMaxPanics(maxPanics int) Option {
+	return func(r *Reference) error {
+		if maxPanics < 0 {
+			return errors.New("maxPanics cannot be negative")
+		}
+		r.maxPanics = maxPanics
+		return nil
+	}
+}
+
+// WithRetry retries a failed run up to maxAttempts times in total before
+// waiting for the next scheduled tick. The backoff between attempts starts at
+// the provided duration and doubles after each failed attempt.
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(r *Reference) error {
+		if maxAttempts < 1 {
+			return errors.New("maxAttempts needs to be at least 1")
+		}
+		if backoff < 0 {
+			return errors.New("backoff cannot be negative")
+		}
+		r.attempts = maxAttempts
+		r.backoff = backoff
+		return nil
+	}
+}
+
+// WithErrorHook sets a function called with the final error of a failed run,
+// after all retry attempts are exhausted.
+func WithErrorHook(hook func(name string, err error)) Option {
+	return func(r *Reference) error {
+		r.onError = hook
+		return nil
+	}
+}
+
+// WithJitter randomizes each scheduled next run within ±jitter, so fleets of
+// replicas running the same job don't hit shared dependencies at the same
+// second.
+func WithJitter(jitter time.Duration) Option {
+	return func(r *Reference) error {
+		if jitter < 0 {
+			return errors.New("jitter cannot be negative")
+		}
+		r.jitter = jitter
+		return nil
+	}
+}
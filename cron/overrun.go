@@ -0,0 +1,107 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrJobRunning is reported to OnSkipped hooks if a tick was skipped because
+// the previous run of the job is still active.
+var ErrJobRunning = errors.New("job still running")
+
+// OverrunPolicy determines how a job handles a tick arriving while its
+// previous run is still active.
+type OverrunPolicy int
+
+const (
+	// OverrunSkip skips the tick.
+	OverrunSkip OverrunPolicy = iota
+	// OverrunQueue queues a single run, started as soon as the active run
+	// completes. Further ticks are skipped while a run is queued.
+	OverrunQueue
+	// OverrunConcurrent starts the run concurrently to the active run.
+	OverrunConcurrent
+)
+
+// WithOverrunPolicy sets how the job handles a tick arriving while its
+// previous run is still active. The default is OverrunSkip.
+func WithOverrunPolicy(policy OverrunPolicy) Option {
+	return func(r *Reference) error {
+		if policy < OverrunSkip || policy > OverrunConcurrent {
+			return errors.New("invalid overrun policy")
+		}
+		r.overrunPolicy = policy
+		return nil
+	}
+}
+
+// overrun applies the overrun policy to a tick arriving while the previous
+// run is still active, returning true if the run should start.
+func (r *Reference) overrun(now time.Time) bool {
+	switch r.overrunPolicy {
+	case OverrunConcurrent:
+		return true
+	case OverrunQueue:
+		if !r.queued {
+			log.Debug("job still running, queueing run", "job", r.name)
+			r.queued = true
+			r.scheduleNextTick(now)
+			return false
+		}
+	case OverrunSkip:
+	}
+	r.skipTick(now)
+	return false
+}
+
+// skipTick skips the scheduled run as the previous run of the job is still
+// active, and schedules the next tick.
+func (r *Reference) skipTick(now time.Time) {
+	log.Debug("job still running, skipping tick", "job", r.name)
+	r.scheduleNextTick(now)
+	go r.report(r.hooks, Run{Job: r.name, Start: now, Err: ErrJobRunning})
+}
+
+// scheduleNextTick schedules the next run one interval after the provided
+// time, unless the job only runs after another job or catches up on missed
+// runs.
+func (r *Reference) scheduleNextTick(now time.Time) {
+	nextRun := r.jittered(now.Add(r.interval))
+	switch {
+	case r.missed > 0:
+		nextRun = now
+	case r.after != nil:
+		nextRun = maxTime
+	}
+	r.nextRun.Store(&nextRun)
+}
+
+// runQueued starts the run queued while the previous run was active. If it
+// cannot start right away, it is picked up on the next scheduler tick.
+func (r *Reference) runQueued() {
+	r.svc.mtx.Lock()
+	defer r.svc.mtx.Unlock()
+	if !r.queued || r.svc.done {
+		return
+	}
+	r.queued = false
+	now := r.svc.clock().Now()
+	r.nextRun.Store(&now)
+	if r.run() {
+		log.Info("job triggered", r.logDetails()...)
+	}
+}
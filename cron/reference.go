@@ -16,6 +16,11 @@ package cron
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime/debug"
+	"slices"
 	"sync/atomic"
 	"time"
 )
@@ -23,17 +28,16 @@ import (
 // maxTime is the maximum time that can be represented by a time.Time.
 var maxTime = time.Unix(1<<63-62135596801, 999999999)
 
+// ErrJobPanicked is returned for a job run which panicked.
+var ErrJobPanicked = errors.New("job panicked")
+
 // Job holds a function which can be scheduled to run through the cron.Service.
 type Job func(ctx context.Context) error
 
 // Reference holds the pointer to a scheduled Job. It can be used to cancel
 // a job when no longer needed.
 type Reference struct {
-	name      string
-	interval  time.Duration
-	mode      IntervalMode
-	maxRun    int
-	stopAfter time.Time
+	settings
 
 	svc      *Service
 	job      Job
@@ -42,6 +46,34 @@ type Reference struct {
 	lastRun  time.Time
 	nextRun  atomic.Pointer[time.Time]
 	runCount int
+	panics   atomic.Int32
+	running  atomic.Int32
+	paused   atomic.Bool
+	queued   bool // guarded by the Service mutex
+	missed   int  // guarded by the Service mutex
+	// dependents and upstream are guarded by the Service mutex
+	dependents []*Reference
+	upstream   any
+}
+
+// settings holds the configuration of a job as set through Options.
+type settings struct {
+	name          string
+	interval      time.Duration
+	mode          IntervalMode
+	maxRun        int
+	stopAfter     time.Time
+	locker        Locker
+	maxPanics     int
+	attempts      int
+	backoff       time.Duration
+	jitter        time.Duration
+	onError       func(name string, err error)
+	hooks         Hooks
+	after         *Reference
+	blackouts     []TimeWindow
+	overrunPolicy OverrunPolicy
+	misfirePolicy MisfirePolicy
 }
 
 type IntervalMode int
@@ -59,7 +91,10 @@ func (r *Reference) run() bool {
 		go r.svc.cancelJob(r) // cancel the job in goroutine to avoid deadlock
 		return false
 	}
-	now := time.Now()
+	if r.paused.Load() {
+		return false
+	}
+	now := r.svc.clock().Now()
 	if r.nextRun.Load().After(now) {
 		// next run is still in the future
 		return false
@@ -74,12 +109,34 @@ func (r *Reference) run() bool {
 		// job has been canceled
 		return false
 	}
+	// skip and reschedule runs falling within a blackout
+	if until, ok := r.blackedOut(now); ok {
+		r.skipBlackout(now, until)
+		return false
+	}
+	// handle ticks arriving while the previous run is still active
+	if r.running.Load() > 0 && !r.overrun(now) {
+		return false
+	}
+	// respect the maximum number of concurrently running jobs
+	if !r.svc.acquire() {
+		log.Debug("maximum concurrent jobs reached, delaying run", "job", r.name)
+		return false
+	}
 	// time to run the job
+	r.running.Add(1)
 	r.runCount++
 	r.lastRun = now
-	if r.interval > 0 {
+	if r.missed > 0 {
+		// catch up on runs missed while the process was down
+		r.missed--
+		r.nextRun.Store(&now)
+	} else if r.after != nil {
+		// jobs running after another job are only triggered by that job
+		r.nextRun.Store(&maxTime)
+	} else if r.interval > 0 {
 		if r.mode == IntervalModeOnTick {
-			nextRun := r.lastRun.Add(r.interval)
+			nextRun := r.jittered(r.lastRun.Add(r.interval))
 			r.nextRun.Store(&nextRun)
 		} else {
 			// we need to move nextRun sufficiently beyond the possible run time
@@ -87,19 +144,101 @@ func (r *Reference) run() bool {
 			r.nextRun.Store(&maxTime)
 		}
 	}
-	go func() {
-		if err := r.job(r.ctx); err != nil {
-			log.Error("job failed", err, "job", r.name)
-		} else if r.mode == IntervalUntilDone {
-			// if the job is done, we can cancel it
-			go r.svc.cancelJob(r)
+	upstream := r.upstream
+	r.upstream = nil
+	go r.exec(r.settings, upstream)
+	return true
+}
+
+// exec runs the job, if it obtains the distributed lock when configured, and
+// schedules the next run for the interval modes relative to the run. The
+// result of the job it runs after, if any, is passed through the context.
+// The run uses the settings of the job at the time it was started, so they
+// can be replaced by Reschedule while the job is running.
+func (r *Reference) exec(cfg settings, upstream any) {
+	defer r.svc.release()
+	run := Run{Job: cfg.name, Start: r.svc.clock().Now()}
+	if err := r.lock(&cfg); err != nil {
+		r.running.Add(-1)
+		run.Err = err
+		r.report(cfg.hooks, run)
+	} else {
+		m := r.svc.metrics.Load()
+		m.started(r, run)
+		ctx, res := withResult(r.ctx, upstream)
+		run.Attempts, run.Err = r.callWithRetry(ctx, &cfg)
+		run.Duration = r.svc.since(run.Start)
+		r.running.Add(-1)
+		m.finished(r, run)
+		if run.Err != nil {
+			log.Error("job failed", run.Err, "job", cfg.name)
+			if cfg.onError != nil {
+				cfg.onError(cfg.name, run.Err)
+			}
+		} else {
+			r.triggerDependents(res.get())
+			if cfg.mode == IntervalUntilDone {
+				// if the job is done, we can cancel it
+				go r.svc.cancelJob(r)
+			}
 		}
-		if r.interval > 0 && (r.mode == IntervalModeBetweenRuns || r.mode == IntervalUntilDone) {
-			nextRun := time.Now().Add(r.interval)
-			r.nextRun.Store(&nextRun)
+		r.report(cfg.hooks, run)
+	}
+	if cfg.after == nil && cfg.interval > 0 &&
+		(cfg.mode == IntervalModeBetweenRuns || cfg.mode == IntervalUntilDone) {
+		nextRun := cfg.jittered(r.svc.clock().Now().Add(cfg.interval))
+		r.nextRun.Store(&nextRun)
+	}
+	r.runQueued()
+}
+
+// jittered returns the provided time randomly offset within the configured
+// jitter, so replicas running the same job don't fire at the same moment.
+func (cfg *settings) jittered(t time.Time) time.Time {
+	if cfg.jitter <= 0 {
+		return t
+	}
+	return t.Add(rand.N(2*cfg.jitter+1) - cfg.jitter)
+}
+
+// Reschedule sets the next run of the job and applies the provided Options in
+// place, e.g. to adjust schedules on configuration reloads without canceling
+// and re-adding the job. Changes apply to runs started after Reschedule
+// returns. The next run of jobs running after another job is not changed.
+func (r *Reference) Reschedule(at time.Time, opts ...Option) error {
+	tmp := &Reference{settings: r.settings, svc: r.svc}
+	tmp.blackouts = slices.Clone(r.blackouts)
+	for _, opt := range opts {
+		if err := opt(tmp); err != nil {
+			return err
 		}
-	}()
-	return true
+	}
+	if tmp.after != r.after {
+		return errors.New("the job to run after cannot be changed")
+	}
+	if err := r.svc.validateJob(tmp); err != nil {
+		return err
+	}
+
+	r.svc.mtx.Lock()
+	defer r.svc.mtx.Unlock()
+	if r.svc.done || (r.ctx != nil && r.ctx.Err() != nil) {
+		return errors.New("job has been canceled")
+	}
+	if err := r.svc.checkName(r, tmp.name); err != nil {
+		return err
+	}
+	if r.svc.validated || r.svc.ctx != nil {
+		if err := r.svc.checkInterval(tmp); err != nil {
+			return err
+		}
+	}
+	r.settings = tmp.settings
+	if r.after == nil {
+		r.nextRun.Store(&at)
+	}
+	log.Info("job rescheduled", r.logDetails()...)
+	return nil
 }
 
 func (r *Reference) Cancel() {
@@ -122,3 +261,46 @@ func (r *Reference) logDetails() []any {
 	}
 	return ss
 }
+
+// call runs the job, recovering a panic into a failed run. The job is
+// canceled after maxPanics consecutive panics if set.
+func (r *Reference) call(ctx context.Context, cfg *settings) (err error) {
+	defer func() {
+		p := recover()
+		if p == nil {
+			r.panics.Store(0)
+			return
+		}
+		err = fmt.Errorf("%w: %v", ErrJobPanicked, p)
+		log.Error("job panicked", err, "job", cfg.name, "stack", string(debug.Stack()))
+		if n := int(r.panics.Add(1)); cfg.maxPanics > 0 && n >= cfg.maxPanics {
+			log.Error("job canceled after consecutive panics", err,
+				"job", cfg.name, "panics", n)
+			go r.svc.cancelJob(r)
+		}
+	}()
+	return r.svc.wrap(r.job)(ctx)
+}
+
+// callWithRetry calls the job, retrying failed runs with exponential backoff
+// until the configured number of attempts is reached or the job is canceled.
+// It returns the number of attempts made and the error of the last attempt.
+func (r *Reference) callWithRetry(ctx context.Context, cfg *settings) (int, error) {
+	backoff := cfg.backoff
+	meta := runMeta{job: cfg.name, runID: newRunID()}
+	for attempt := 1; ; attempt++ {
+		meta.attempt = attempt
+		err := r.call(withRunMeta(ctx, meta), cfg)
+		if err == nil || attempt >= cfg.attempts || r.ctx.Err() != nil {
+			return attempt, err
+		}
+		log.Debug("job failed, retrying", "job", cfg.name, "attempt", attempt,
+			"backoff", backoff, "error", err.Error())
+		select {
+		case <-r.ctx.Done():
+			return attempt, err
+		case <-r.svc.clock().After(backoff):
+		}
+		backoff *= 2
+	}
+}
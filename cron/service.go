@@ -19,48 +19,111 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/telemetry"
 	"github.com/basvanbeek/telemetry/scope"
 )
 
 var (
-	log       = scope.Register("cron", "cron service")
-	mtx       sync.Mutex
-	scheduler *Service
+	log        = scope.Register("cron", "cron service")
+	mtx        sync.Mutex
+	schedulers = make(map[string]*Service)
 )
 
 const (
 	flagSchedulerInterval = "scheduler-interval"
+	flagHistorySize       = "history-size"
+	flagMaxConcurrent     = "max-concurrent-jobs"
+	flagCalendarFile      = "calendar-file"
 
 	defaultSchedulerInterval = 1 * time.Minute
+	defaultHistorySize       = 10
 )
 
 type Service struct {
+	// Prefix names the scheduler, so multiple independent schedulers can be
+	// registered in the same run group. It prefixes the unit name and flags.
+	// The scheduler without prefix is used by the package level AddJob.
+	Prefix string
+
 	SchedulerInterval time.Duration
+	// Hooks holds the hooks called on completion of the runs of all jobs.
+	Hooks Hooks
+	// MetricSink holds the sink used for the per-job metrics. If not set, the
+	// global telemetry MetricSink is used once registered.
+	MetricSink telemetry.MetricSink
+	// MaxConcurrent holds the maximum number of concurrently running jobs.
+	// Due jobs exceeding the limit are delayed until a slot frees up. Zero
+	// means unlimited.
+	MaxConcurrent int
+	// CalendarFile holds the path to a JSON file holding the Calendar.
+	CalendarFile string
+	// Calendar holds the days and windows during which scheduled runs of all
+	// jobs are skipped. If not set, it is loaded from CalendarFile.
+	Calendar *Calendar
+	// Clock provides the time to the scheduler. If not set, the real time is
+	// used. Tests can use crontest.Clock to advance virtual time.
+	Clock Clock
+	// HistorySize holds the number of recent runs kept in memory per job.
+	HistorySize int
+	// HistoryStore can optionally be set to persist all job runs.
+	HistoryStore HistoryStore
 
-	ctx  context.Context
-	done bool
-	mtx  sync.Mutex
-	jobs []*Reference
+	ctx        context.Context
+	done       bool
+	validated  bool
+	mtx        sync.Mutex
+	jobs       []*Reference
+	metrics    atomic.Pointer[metrics]
+	sem        chan struct{}
+	middleware []Middleware
+	historyMtx sync.Mutex
+	history    map[string][]Run
 }
 
+// Initialize implements run.Initializer. It registers the scheduler by its
+// prefix, so it can be found through Lookup.
 func (s *Service) Initialize() {
 	mtx.Lock()
 	defer mtx.Unlock()
-	if scheduler != nil {
+	if _, ok := schedulers[s.Prefix]; ok {
 		return
 	}
-	scheduler = s
+	schedulers[s.Prefix] = s
+}
+
+// Lookup returns the registered scheduler with the provided prefix. The
+// scheduler without prefix is found with an empty name.
+func Lookup(name string) (*Service, bool) {
+	mtx.Lock()
+	defer mtx.Unlock()
+	s, ok := schedulers[name]
+	return s, ok
+}
+
+func (s *Service) prefix(name string) string {
+	if s.Prefix != "" {
+		return s.Prefix + "-" + name
+	}
+	return name
 }
 
 func (s *Service) FlagSet() *run.FlagSet {
 	fs := run.NewFlagSet(s.Name())
 
-	fs.DurationVar(&s.SchedulerInterval, flagSchedulerInterval,
+	fs.DurationVar(&s.SchedulerInterval, s.prefix(flagSchedulerInterval),
 		defaultSchedulerInterval, "interval between scheduler runs")
+	fs.IntVar(&s.MaxConcurrent, s.prefix(flagMaxConcurrent),
+		0, "maximum number of concurrently running jobs (0 is unlimited)")
+	fs.StringVar(&s.CalendarFile, s.prefix(flagCalendarFile),
+		"", "path to a JSON file with days and windows without job runs")
+	fs.IntVar(&s.HistorySize, s.prefix(flagHistorySize),
+		defaultHistorySize, "number of recent runs kept in memory per job")
 
 	return fs
 }
@@ -69,20 +132,51 @@ func (s *Service) Validate() error {
 	if s.SchedulerInterval < time.Second {
 		return errors.New("scheduler interval needs to be at least one second")
 	}
+	if s.MaxConcurrent < 0 {
+		return errors.New("max concurrent jobs cannot be negative")
+	}
+	if s.HistorySize < 0 {
+		return errors.New("history size cannot be negative")
+	}
+
+	// the scheduler interval is final, check the jobs added before
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.validated = true
+	for _, r := range s.jobs {
+		if err := s.checkInterval(r); err != nil {
+			return fmt.Errorf("job %s: %w", r.name, err)
+		}
+	}
 
 	return nil
 }
 
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() error {
+	if s.Calendar != nil || s.CalendarFile == "" {
+		return nil
+	}
+	c, err := LoadCalendar(s.CalendarFile)
+	if err != nil {
+		return fmt.Errorf("unable to load calendar: %w", err)
+	}
+	s.Calendar = c
+	return nil
+}
+
 func (s *Service) Name() string {
-	return "cron"
+	return s.prefix("cron")
 }
 
 func (s *Service) AddJob(job Job, at time.Time, opts ...Option) (*Reference, error) {
 	r := &Reference{
-		svc:      s,
-		job:      job,
-		interval: s.SchedulerInterval,
-		mode:     IntervalModeOnTick,
+		settings: settings{
+			interval: s.SchedulerInterval,
+			mode:     IntervalModeOnTick,
+		},
+		svc: s,
+		job: job,
 	}
 	r.nextRun.Store(&at)
 
@@ -91,29 +185,75 @@ func (s *Service) AddJob(job Job, at time.Time, opts ...Option) (*Reference, err
 			return nil, err
 		}
 	}
-	if r.interval < s.SchedulerInterval {
-		return nil, fmt.Errorf("%w (%s)", ErrIntervalTooShort,
-			s.SchedulerInterval.String())
+	if err := s.validateJob(r); err != nil {
+		return nil, err
 	}
-
-	if r.name == "" {
-		r.name = "anonymous"
+	if r.after != nil {
+		r.nextRun.Store(&maxTime)
 	}
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 	if s.done {
 		return nil, errors.New("service has already shut down")
 	}
+	if err := s.checkName(r, r.name); err != nil {
+		return nil, err
+	}
+	if s.validated || s.ctx != nil {
+		if err := s.checkInterval(r); err != nil {
+			return nil, err
+		}
+	}
 	if s.ctx != nil {
 		r.ctx, r.cancel = context.WithCancel(s.ctx)
 	}
 	log.Info("job added", r.logDetails()...)
 
 	s.jobs = append(s.jobs, r)
+	if r.after != nil {
+		r.after.dependents = append(r.after.dependents, r)
+	}
+	if s.ctx != nil {
+		go s.catchUp(s.ctx, r)
+	}
 
 	return r, nil
 }
 
+// checkInterval checks the interval of the job against the scheduler
+// interval. As the scheduler interval is only final once the flags are
+// parsed, jobs added before are checked by Validate. The caller holds the
+// Service mutex.
+func (s *Service) checkInterval(r *Reference) error {
+	if r.interval < s.SchedulerInterval {
+		return fmt.Errorf("%w (%s)", ErrIntervalTooShort,
+			s.SchedulerInterval.String())
+	}
+	return nil
+}
+
+// validateJob checks the settings of the job and defaults its name.
+func (s *Service) validateJob(r *Reference) error {
+	if r.name == "" {
+		if r.locker != nil {
+			return ErrLockRequiresName
+		}
+		if r.misfirePolicy != MisfireSkip {
+			return ErrMisfireRequiresName
+		}
+		r.name = anonymousJob
+	}
+	if r.misfirePolicy != MisfireSkip {
+		if _, ok := s.HistoryStore.(LastRunStore); !ok {
+			return ErrMisfireRequiresStore
+		}
+	}
+	if r.after != nil && r.after.svc != s {
+		return ErrRunAfterOtherService
+	}
+	return nil
+}
+
 func (s *Service) cancelJob(r *Reference) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
@@ -134,16 +274,24 @@ func (s *Service) cancelJob(r *Reference) {
 }
 
 func (s *Service) ServeContext(ctx context.Context) error {
+	s.setupMetrics()
 	s.mtx.Lock()
 	s.ctx = ctx
+	if s.MaxConcurrent > 0 {
+		s.sem = make(chan struct{}, s.MaxConcurrent)
+	}
 	for i := 0; i < len(s.jobs); i++ {
 		s.jobs[i].ctx, s.jobs[i].cancel = context.WithCancel(ctx)
 	}
+	jobs := slices.Clone(s.jobs)
 	s.mtx.Unlock()
+	for _, r := range jobs {
+		s.catchUp(ctx, r)
+	}
 	for {
 		// set timer so we don't get back here within that time period.
-		timer, cancel := context.WithTimeout(ctx, s.SchedulerInterval)
-		now := time.Now()
+		timer := s.clock().After(s.SchedulerInterval)
+		now := s.clock().Now()
 		log.Debug("cron start iteration")
 		// iterate over registered jobs
 		s.mtx.Lock()
@@ -154,14 +302,12 @@ func (s *Service) ServeContext(ctx context.Context) error {
 			}
 		}
 		s.mtx.Unlock()
-		log.Debug("cron end iteration", "duration", time.Since(now))
+		log.Debug("cron end iteration", "duration", s.since(now))
 
 		// wait until application context is canceled or trigger timer is done.
 		select {
 		case <-ctx.Done():
 			log.Info("cron service shutting down")
-			// cancel our timer
-			cancel()
 			// remove all jobs
 			s.mtx.Lock()
 			s.done = true
@@ -173,18 +319,45 @@ func (s *Service) ServeContext(ctx context.Context) error {
 			s.mtx.Unlock()
 			// we can now safely exit
 			return nil
-		case <-timer.Done():
+		case <-timer:
 			// trigger when timer is done
 			continue
 		}
 	}
 }
 
+// acquire obtains a slot for running a job, returning false if the maximum
+// number of concurrently running jobs is reached.
+func (s *Service) acquire() bool {
+	if s.sem == nil {
+		return true
+	}
+	select {
+	case s.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// available returns true if a slot for running a job is free. As slots are
+// only obtained while holding the Service mutex, acquire succeeds if
+// available returned true under the same lock.
+func (s *Service) available() bool {
+	return s.sem == nil || len(s.sem) < cap(s.sem)
+}
+
+// release frees the slot obtained by acquire.
+func (s *Service) release() {
+	if s.sem != nil {
+		<-s.sem
+	}
+}
+
+// AddJob adds the job to the scheduler without prefix.
 func AddJob(job Job, at time.Time, opts ...Option) (*Reference, error) {
-	mtx.Lock()
-	s := scheduler
-	mtx.Unlock()
-	if s == nil {
+	s, ok := Lookup("")
+	if !ok {
 		return nil, errors.New("cron service not initialized")
 	}
 	return s.AddJob(job, at, opts...)
@@ -193,5 +366,6 @@ func AddJob(job Job, at time.Time, opts ...Option) (*Reference, error) {
 var (
 	_ run.Initializer    = (*Service)(nil)
 	_ run.Config         = (*Service)(nil)
+	_ run.PreRunner      = (*Service)(nil)
 	_ run.ServiceContext = (*Service)(nil)
 )
@@ -0,0 +1,131 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Capture errors.
+var (
+	ErrNoProfileDir   = errors.New("no profile directory configured")
+	ErrCPUProfileBusy = errors.New("CPU profile capture already in progress")
+)
+
+var cpuMtx sync.Mutex
+
+// CaptureHeap writes a heap profile to the profile directory and returns
+// the path of the written file.
+func (s *Service) CaptureHeap() (string, error) {
+	f, err := s.create("heap")
+	if err != nil {
+		return "", err
+	}
+	runtime.GC()
+	if err = pprof.WriteHeapProfile(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// CaptureCPU records a CPU profile for the provided duration, capped at
+// MaxCPUProfile, and writes it to the profile directory. It returns the path
+// of the written file. Only one CPU profile can be captured at a time.
+func (s *Service) CaptureCPU(ctx context.Context, d time.Duration) (string, error) {
+	if s.ProfileDir == "" {
+		return "", ErrNoProfileDir
+	}
+	if !cpuMtx.TryLock() {
+		return "", ErrCPUProfileBusy
+	}
+	defer cpuMtx.Unlock()
+
+	if d <= 0 || d > s.MaxCPUProfile {
+		d = s.MaxCPUProfile
+	}
+	f, err := s.create("cpu")
+	if err != nil {
+		return "", err
+	}
+	if err = pprof.StartCPUProfile(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+
+	t := time.NewTimer(d)
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		t.Stop()
+	}
+	pprof.StopCPUProfile()
+	return f.Name(), f.Close()
+}
+
+func (s *Service) create(kind string) (*os.File, error) {
+	if s.ProfileDir == "" {
+		return nil, ErrNoProfileDir
+	}
+	name := fmt.Sprintf("%s-%s.pprof", kind, time.Now().UTC().Format("20060102T150405.000"))
+	return os.Create(filepath.Join(s.ProfileDir, name))
+}
+
+func (s *Service) handleCaptureHeap(w http.ResponseWriter, _ *http.Request) {
+	path, err := s.CaptureHeap()
+	if err != nil {
+		log.Error("unable to capture heap profile", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Info("captured heap profile", "file", path)
+	writeJSON(w, http.StatusOK, map[string]string{"file": path})
+}
+
+func (s *Service) handleCaptureCPU(w http.ResponseWriter, r *http.Request) {
+	var d time.Duration
+	if v := r.URL.Query().Get("seconds"); v != "" {
+		sec, err := strconv.Atoi(v)
+		if err != nil || sec <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid seconds"})
+			return
+		}
+		d = time.Duration(sec) * time.Second
+	}
+	path, err := s.CaptureCPU(r.Context(), d)
+	switch {
+	case errors.Is(err, ErrCPUProfileBusy):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		log.Error("unable to capture CPU profile", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Info("captured CPU profile", "file", path)
+	writeJSON(w, http.StatusOK, map[string]string{"file": path})
+}
@@ -0,0 +1,15 @@
+module github.com/basvanbeek/run-handlers/debug
+
+go 1.24.2
+
+require (
+	github.com/basvanbeek/multierror v0.1.0
+	github.com/basvanbeek/run v0.2.1
+	github.com/basvanbeek/telemetry v0.2.0
+)
+
+require (
+	github.com/hashicorp/errwrap v1.1.0 // indirect
+	github.com/logrusorgru/aurora/v4 v4.0.0 // indirect
+	github.com/spf13/pflag v1.0.6 // indirect
+)
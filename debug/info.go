@@ -0,0 +1,113 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/basvanbeek/run/pkg/version"
+)
+
+var startTime = time.Now()
+
+// BuildInfo holds the build and version information of the binary.
+type BuildInfo struct {
+	Version   string            `json:"version"`
+	GoVersion string            `json:"goVersion"`
+	Path      string            `json:"path,omitempty"`
+	Settings  map[string]string `json:"settings,omitempty"`
+	Deps      map[string]string `json:"deps,omitempty"`
+}
+
+// RuntimeStats holds a summary of the runtime statistics.
+type RuntimeStats struct {
+	Uptime       string `json:"uptime"`
+	Goroutines   int    `json:"goroutines"`
+	GOMAXPROCS   int    `json:"gomaxprocs"`
+	NumCPU       int    `json:"numCPU"`
+	HeapAlloc    uint64 `json:"heapAlloc"`
+	HeapInuse    uint64 `json:"heapInuse"`
+	HeapObjects  uint64 `json:"heapObjects"`
+	Sys          uint64 `json:"sys"`
+	TotalAlloc   uint64 `json:"totalAlloc"`
+	NumGC        uint32 `json:"numGC"`
+	PauseTotalNs uint64 `json:"pauseTotalNs"`
+	LastGC       string `json:"lastGC,omitempty"`
+}
+
+// Build returns the build and version information of the binary.
+func Build() BuildInfo {
+	info := BuildInfo{
+		Version:   version.Parse(),
+		GoVersion: runtime.Version(),
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Path = bi.Path
+	info.Settings = make(map[string]string, len(bi.Settings))
+	for _, s := range bi.Settings {
+		info.Settings[s.Key] = s.Value
+	}
+	info.Deps = make(map[string]string, len(bi.Deps))
+	for _, d := range bi.Deps {
+		info.Deps[d.Path] = d.Version
+	}
+	return info
+}
+
+// Runtime returns a summary of the runtime statistics.
+func Runtime() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	st := RuntimeStats{
+		Uptime:       time.Since(startTime).Round(time.Second).String(),
+		Goroutines:   runtime.NumGoroutine(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		NumCPU:       runtime.NumCPU(),
+		HeapAlloc:    m.HeapAlloc,
+		HeapInuse:    m.HeapInuse,
+		HeapObjects:  m.HeapObjects,
+		Sys:          m.Sys,
+		TotalAlloc:   m.TotalAlloc,
+		NumGC:        m.NumGC,
+		PauseTotalNs: m.PauseTotalNs,
+	}
+	if m.LastGC > 0 {
+		st.LastGC = time.Unix(0, int64(m.LastGC)).UTC().Format(time.RFC3339)
+	}
+	return st
+}
+
+func (s *Service) handleBuild(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, Build())
+}
+
+func (s *Service) handleRuntime(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, Runtime())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,203 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package debug provides a run handler serving pprof, expvar, build and
+// runtime information on a private listener, independent of the main HTTP
+// server.
+package debug
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("debug", "debug server")
+
+// package flags.
+const (
+	defaultListenAddress   = "127.0.0.1:6060"
+	defaultMaxCPUProfile   = 60 * time.Second
+	defaultShutdownTimeout = 5 * time.Second
+
+	ListenAddress   = "debug-listen-address"
+	ProfileDir      = "debug-profile-dir"
+	MaxCPUProfile   = "debug-max-cpu-profile"
+	ShutdownTimeout = "debug-shutdown-timeout"
+)
+
+// Service implements run.Config and run.ServiceContext for a debug server.
+// It serves:
+//
+//	/debug/pprof/         pprof index and profiles
+//	/debug/vars           expvar
+//	/debug/build          build and version information
+//	/debug/runtime        runtime statistics
+//	/debug/capture/heap   capture a heap profile to ProfileDir (POST)
+//	/debug/capture/cpu    capture a CPU profile to ProfileDir (POST, ?seconds=N)
+//
+// The capture endpoints are only available if ProfileDir is set. As the
+// endpoints expose sensitive process internals, the listener should be
+// bound to a private address.
+type Service struct {
+	Prefix string
+
+	ListenAddress   string
+	ProfileDir      string
+	MaxCPUProfile   time.Duration
+	ShutdownTimeout time.Duration
+
+	server   *http.Server
+	listener net.Listener
+}
+
+func (s *Service) prefix(v string) string {
+	if s.Prefix != "" {
+		return s.Prefix + "-" + v
+	}
+	return v
+}
+
+// Name implements run.Unit.
+func (s *Service) Name() string {
+	return s.prefix("debug")
+}
+
+// Initialize implements run.Initializer.
+func (s *Service) Initialize() {
+	s.ListenAddress = defaultListenAddress
+	s.MaxCPUProfile = defaultMaxCPUProfile
+	s.ShutdownTimeout = defaultShutdownTimeout
+}
+
+// FlagSet implements run.Config.
+func (s *Service) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Debug server options")
+
+	flags.StringVar(&s.ListenAddress, s.prefix(ListenAddress),
+		s.ListenAddress, "listen address of the debug server (empty disables the server)")
+
+	flags.StringVar(&s.ProfileDir, s.prefix(ProfileDir),
+		s.ProfileDir, "directory to write captured profiles to (empty disables capturing)")
+
+	flags.DurationVar(&s.MaxCPUProfile, s.prefix(MaxCPUProfile),
+		s.MaxCPUProfile, "max. duration of a captured CPU profile")
+
+	flags.DurationVar(&s.ShutdownTimeout, s.prefix(ShutdownTimeout),
+		s.ShutdownTimeout, "timeout for graceful shutdown of the debug server")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	if s.ListenAddress != "" {
+		if _, _, err := net.SplitHostPort(s.ListenAddress); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(ListenAddress), flag.ErrInvalidVal))
+		}
+	}
+	if s.ProfileDir != "" {
+		if fi, err := os.Stat(s.ProfileDir); err != nil || !fi.IsDir() {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(ProfileDir), flag.ErrInvalidPath))
+		}
+	}
+	if s.MaxCPUProfile <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(MaxCPUProfile), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// Handler returns the http.Handler serving the debug endpoints, e.g. for
+// mounting on a server of choice.
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/build", s.handleBuild)
+	mux.HandleFunc("/debug/runtime", s.handleRuntime)
+	if s.ProfileDir != "" {
+		mux.HandleFunc("POST /debug/capture/heap", s.handleCaptureHeap)
+		mux.HandleFunc("POST /debug/capture/cpu", s.handleCaptureCPU)
+	}
+	return mux
+}
+
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() error {
+	if s.ListenAddress == "" {
+		return nil
+	}
+	s.server = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	var err error
+	if s.listener, err = net.Listen("tcp", s.ListenAddress); err != nil {
+		return err
+	}
+	log.Info("debug server listening", "address", s.listener.Addr().String())
+	return nil
+}
+
+// ServeContext implements run.ServiceContext.
+func (s *Service) ServeContext(ctx context.Context) error {
+	if s.server == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.server.Serve(s.listener)
+	}()
+
+	select {
+	case err := <-errc:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		sctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+		defer cancel()
+		return s.server.Shutdown(sctx)
+	}
+}
+
+var (
+	_ run.Initializer    = (*Service)(nil)
+	_ run.Config         = (*Service)(nil)
+	_ run.PreRunner      = (*Service)(nil)
+	_ run.ServiceContext = (*Service)(nil)
+)
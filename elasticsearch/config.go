@@ -0,0 +1,329 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package elasticsearch provides a run handler managing an Elasticsearch
+// client.
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	es "github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/healthstatus"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("elasticsearch", "Elasticsearch client")
+
+// package flags.
+const (
+	defaultAddress         = "http://localhost:9200"
+	defaultMaxRetries      = 3
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultMaxRetryBackoff = 5 * time.Second
+	defaultHealthInterval  = 10 * time.Second
+	defaultHealthTimeout   = 5 * time.Second
+	defaultMinHealthStatus = "yellow"
+
+	Addresses             = "elasticsearch-addresses"
+	UserName              = "elasticsearch-username"
+	Password              = "elasticsearch-password"
+	APIKey                = "elasticsearch-api-key"
+	CloudID               = "elasticsearch-cloud-id"
+	CAFile                = "elasticsearch-ca-file"
+	CertFingerprint       = "elasticsearch-cert-fingerprint"
+	MaxRetries            = "elasticsearch-max-retries"
+	RetryOnStatus         = "elasticsearch-retry-on-status"
+	RetryBackoff          = "elasticsearch-retry-backoff"
+	MaxRetryBackoff       = "elasticsearch-max-retry-backoff"
+	DiscoverNodesOnStart  = "elasticsearch-discover-nodes-on-start"
+	DiscoverNodesInterval = "elasticsearch-discover-nodes-interval"
+	HealthInterval        = "elasticsearch-health-interval"
+	HealthTimeout         = "elasticsearch-health-timeout"
+	MinHealthStatus       = "elasticsearch-min-health-status"
+)
+
+var healthLevels = map[string]int{"red": 0, "yellow": 1, "green": 2}
+
+// Config implements run.Config to allow configuration of a typed
+// Elasticsearch client. The cluster health is checked in PreRun and
+// periodically in ServeContext to feed Healthy.
+//
+// The client verifies the server is Elasticsearch; OpenSearch clusters are
+// not supported by the underlying client.
+type Config struct {
+	Prefix string
+
+	Addresses       []string
+	UserName        string
+	Password        string
+	APIKey          string
+	CloudID         string
+	CAFile          string
+	CertFingerprint string
+	MaxRetries      int
+	RetryOnStatus   []int
+	// RetryBackoff holds the initial backoff between retries, which doubles
+	// on each retry up to MaxRetryBackoff.
+	RetryBackoff          time.Duration
+	MaxRetryBackoff       time.Duration
+	DiscoverNodesOnStart  bool
+	DiscoverNodesInterval time.Duration
+	HealthInterval        time.Duration
+	HealthTimeout         time.Duration
+	// MinHealthStatus holds the minimum cluster health status (red, yellow or
+	// green) considered healthy.
+	MinHealthStatus string
+
+	client  *es.TypedClient
+	healthy atomic.Bool
+}
+
+func (c *Config) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("elasticsearch")
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	if len(c.Addresses) == 0 {
+		c.Addresses = []string{defaultAddress}
+	}
+	if envPassword := os.Getenv("ELASTICSEARCH_PASSWORD"); envPassword != "" {
+		c.Password = envPassword
+	}
+	if envAPIKey := os.Getenv("ELASTICSEARCH_API_KEY"); envAPIKey != "" {
+		c.APIKey = envAPIKey
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = defaultMaxRetries
+	}
+	if len(c.RetryOnStatus) == 0 {
+		c.RetryOnStatus = []int{502, 503, 504, 429}
+	}
+	if c.RetryBackoff == 0 {
+		c.RetryBackoff = defaultRetryBackoff
+	}
+	if c.MaxRetryBackoff == 0 {
+		c.MaxRetryBackoff = defaultMaxRetryBackoff
+	}
+	if c.HealthInterval == 0 {
+		c.HealthInterval = defaultHealthInterval
+	}
+	if c.HealthTimeout == 0 {
+		c.HealthTimeout = defaultHealthTimeout
+	}
+	if c.MinHealthStatus == "" {
+		c.MinHealthStatus = defaultMinHealthStatus
+	}
+
+	flags := run.NewFlagSet("Elasticsearch options")
+
+	flags.StringArrayVar(&c.Addresses, c.prefix(Addresses),
+		c.Addresses, "Elasticsearch node URL (can be repeated)")
+
+	flags.StringVar(&c.UserName, c.prefix(UserName),
+		c.UserName, "username for basic authentication")
+
+	flags.SensitiveStringVar(&c.Password, c.prefix(Password),
+		c.Password, "password for basic authentication")
+
+	flags.SensitiveStringVar(&c.APIKey, c.prefix(APIKey),
+		c.APIKey, "base64 encoded API key")
+
+	flags.StringVar(&c.CloudID, c.prefix(CloudID),
+		c.CloudID, "Elastic Cloud ID (overrides the addresses)")
+
+	flags.StringVar(&c.CAFile, c.prefix(CAFile),
+		c.CAFile, "path to CA file for verifying the cluster")
+
+	flags.StringVar(&c.CertFingerprint, c.prefix(CertFingerprint),
+		c.CertFingerprint, "SHA256 hex fingerprint of the cluster certificate")
+
+	flags.IntVar(&c.MaxRetries, c.prefix(MaxRetries),
+		c.MaxRetries, "max. retries of a request")
+
+	flags.IntSliceVar(&c.RetryOnStatus, c.prefix(RetryOnStatus),
+		c.RetryOnStatus, "HTTP status codes to retry")
+
+	flags.DurationVar(&c.RetryBackoff, c.prefix(RetryBackoff),
+		c.RetryBackoff, "initial backoff between retries")
+
+	flags.DurationVar(&c.MaxRetryBackoff, c.prefix(MaxRetryBackoff),
+		c.MaxRetryBackoff, "max. backoff between retries")
+
+	flags.BoolVar(&c.DiscoverNodesOnStart, c.prefix(DiscoverNodesOnStart),
+		c.DiscoverNodesOnStart, "discover cluster nodes (sniffing) on start")
+
+	flags.DurationVar(&c.DiscoverNodesInterval, c.prefix(DiscoverNodesInterval),
+		c.DiscoverNodesInterval, "interval for discovering cluster nodes (0 disables)")
+
+	flags.DurationVar(&c.HealthInterval, c.prefix(HealthInterval),
+		c.HealthInterval, "interval for checking the cluster health")
+
+	flags.DurationVar(&c.HealthTimeout, c.prefix(HealthTimeout),
+		c.HealthTimeout, "timeout for checking the cluster health")
+
+	flags.StringVar(&c.MinHealthStatus, c.prefix(MinHealthStatus),
+		c.MinHealthStatus, "min. cluster health status considered healthy (red, yellow or green)")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if len(c.Addresses) == 0 && c.CloudID == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Addresses), flag.ErrRequired))
+	}
+	if c.CAFile != "" {
+		if _, err := os.Stat(c.CAFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(CAFile), flag.ErrInvalidPath))
+		}
+	}
+	if c.MaxRetries < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(MaxRetries), flag.ErrInvalidVal))
+	}
+	if c.HealthInterval <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(HealthInterval), flag.ErrInvalidVal))
+	}
+	if _, ok := healthLevels[c.MinHealthStatus]; !ok {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(MinHealthStatus), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (c *Config) PreRun() error {
+	cfg := es.Config{
+		Addresses:              c.Addresses,
+		Username:               c.UserName,
+		Password:               c.Password,
+		APIKey:                 c.APIKey,
+		CloudID:                c.CloudID,
+		CertificateFingerprint: c.CertFingerprint,
+		RetryOnStatus:          c.RetryOnStatus,
+		MaxRetries:             c.MaxRetries,
+		DisableRetry:           c.MaxRetries == 0,
+		RetryBackoff:           c.backoff,
+		DiscoverNodesOnStart:   c.DiscoverNodesOnStart,
+		DiscoverNodesInterval:  c.DiscoverNodesInterval,
+	}
+	if c.CloudID != "" {
+		cfg.Addresses = nil
+	}
+	if c.CAFile != "" {
+		var err error
+		if cfg.CACert, err = os.ReadFile(c.CAFile); err != nil {
+			return fmt.Errorf("unable to read CA file: %w", err)
+		}
+	}
+
+	var err error
+	if c.client, err = es.NewTypedClient(cfg); err != nil {
+		return fmt.Errorf("elasticsearch client creation failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.HealthTimeout)
+	defer cancel()
+	if err = c.checkHealth(ctx); err != nil {
+		return fmt.Errorf("elasticsearch health check failed: %w", err)
+	}
+	return nil
+}
+
+// backoff returns the exponential backoff for the retry attempt.
+func (c *Config) backoff(attempt int) time.Duration {
+	d := c.RetryBackoff
+	for i := 1; i < attempt && d < c.MaxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, c.MaxRetryBackoff)
+}
+
+// checkHealth retrieves the cluster health and updates the healthy state.
+func (c *Config) checkHealth(ctx context.Context) error {
+	res, err := c.client.Cluster.Health().Do(ctx)
+	if err != nil {
+		c.healthy.Store(false)
+		return err
+	}
+	healthy := healthLevels[res.Status.String()] >= healthLevels[c.MinHealthStatus]
+	if c.healthy.Swap(healthy) != healthy {
+		log.Info("cluster health changed", "status", res.Status.String(), "healthy", healthy)
+	}
+	if !healthy && res.Status == healthstatus.Red {
+		return fmt.Errorf("cluster %s status is red", res.ClusterName)
+	}
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. It periodically checks the
+// cluster health.
+func (c *Config) ServeContext(ctx context.Context) error {
+	ticker := time.NewTicker(c.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			hctx, cancel := context.WithTimeout(ctx, c.HealthTimeout)
+			if err := c.checkHealth(hctx); err != nil {
+				log.Error("cluster health check failed", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Healthy returns true if the last cluster health check succeeded with at
+// least the configured minimum health status. It can be used for readiness
+// checks.
+func (c *Config) Healthy() bool {
+	return c.healthy.Load()
+}
+
+// Client returns the typed Elasticsearch client.
+func (c *Config) Client() *es.TypedClient {
+	return c.client
+}
+
+var (
+	_ run.Config         = (*Config)(nil)
+	_ run.PreRunner      = (*Config)(nil)
+	_ run.ServiceContext = (*Config)(nil)
+)
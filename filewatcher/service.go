@@ -38,6 +38,7 @@ type fileReg struct {
 	name            string
 	defaultFilePath string
 	ch              chan []byte
+	removals        bool
 }
 
 type Service struct {
@@ -53,7 +54,19 @@ func (s *Service) Name() string {
 	return "file-watcher"
 }
 
+// AddWatcher registers the file and returns a channel receiving the contents
+// of the file each time it is created or written.
 func (s *Service) AddWatcher(name, fqn string) (<-chan []byte, error) {
+	return s.addWatcher(name, fqn, false)
+}
+
+// AddWatcherWithRemoval registers the file like AddWatcher, additionally
+// sending nil on the channel once the file is removed or renamed.
+func (s *Service) AddWatcherWithRemoval(name, fqn string) (<-chan []byte, error) {
+	return s.addWatcher(name, fqn, true)
+}
+
+func (s *Service) addWatcher(name, fqn string, removals bool) (<-chan []byte, error) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
@@ -85,6 +98,7 @@ func (s *Service) AddWatcher(name, fqn string) (<-chan []byte, error) {
 			name:            name,
 			defaultFilePath: fqn,
 			ch:              ch,
+			removals:        removals,
 		})
 		if s.p[fp] < 2 {
 			// new patch to watch
@@ -104,6 +118,7 @@ func (s *Service) AddWatcher(name, fqn string) (<-chan []byte, error) {
 		name:            name,
 		defaultFilePath: fqn,
 		ch:              ch,
+		removals:        removals,
 	})
 
 	return ch, nil
@@ -220,8 +235,9 @@ forLoop:
 			}
 			log.Debug("file watcher event",
 				"name", event.Name, "op", event.Op)
-			if event.Op&fsnotify.Write != fsnotify.Write && event.Op&fsnotify.Create != fsnotify.Create {
-				// file not modified or created
+			removed := event.Op.Has(fsnotify.Remove) || event.Op.Has(fsnotify.Rename)
+			if event.Op&fsnotify.Write != fsnotify.Write && event.Op&fsnotify.Create != fsnotify.Create && !removed {
+				// file not modified, created or removed
 				continue
 			}
 
@@ -255,6 +271,13 @@ forLoop:
 				log.Debug("file watcher event",
 					"name", reg.name, "event", event.Name,
 					"op", event.Op)
+				if removed {
+					if reg.removals {
+						// signal the removal of the file
+						reg.ch <- nil
+					}
+					continue
+				}
 				// try to load the file
 				var b []byte
 				b, err = os.ReadFile(event.Name)
@@ -145,3 +145,35 @@ func TestServeContextHandlesWatcherErrors(t *testing.T) {
 	svc.w.Errors <- errors.New("simulated error")
 	time.Sleep(100 * time.Millisecond)
 }
+
+func TestServeContextSignalsRemovedFiles(t *testing.T) {
+	svc := initializeService(t)
+	tempDir := t.TempDir()
+	plainFile := createTempFile(t, tempDir, "initial content 5")
+	tempFile := createTempFile(t, tempDir, "initial content 6")
+
+	_, err := svc.AddWatcher("plain-file", plainFile)
+	require.NoError(t, err)
+	ch, err := svc.AddWatcherWithRemoval("test-file", tempFile)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- svc.ServeContext(ctx) }()
+
+	// events are handled in order, so a notification for the plain file
+	// would block the removal notification of the other file
+	removeTempFile(t, plainFile)
+	removeTempFile(t, tempFile)
+
+	select {
+	case data, ok := <-ch:
+		require.True(t, ok)
+		require.Nil(t, data)
+	case <-time.After(1 * time.Second):
+		t.Fatal("file removal event not received")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,133 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcppubsub provides run handlers for publishing to and receiving
+// from Google Cloud Pub/Sub. The connection settings held by Config are
+// shared by the Publisher and Subscriber handlers.
+package gcppubsub
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/option"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("gcp-pubsub", "Google Cloud Pub/Sub publisher and subscriber")
+
+// package flags.
+const (
+	Project         = "gcp-pubsub-project"
+	CredentialsFile = "gcp-pubsub-credentials-file"
+	Endpoint        = "gcp-pubsub-endpoint"
+)
+
+// Config implements run.Config to allow configuration of the Pub/Sub
+// connection settings shared by Publisher and Subscriber. Without
+// credentials file, Application Default Credentials are used. The Pub/Sub
+// emulator is used if the PUBSUB_EMULATOR_HOST environment variable is set.
+type Config struct {
+	Prefix string
+
+	Project         string
+	CredentialsFile string
+	// Endpoint optionally overrides the Pub/Sub API endpoint, e.g. a
+	// regional endpoint.
+	Endpoint string
+
+	// Options holds additional client options applied after the flag based
+	// options.
+	Options []option.ClientOption
+}
+
+// prefix returns the name prefixed with the Config prefix. It is safe to call
+// on a nil Config, so Publisher and Subscriber can report a missing Config in
+// Validate.
+func (c *Config) prefix(s string) string {
+	if c != nil && c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("gcp-pubsub")
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Google Cloud Pub/Sub options")
+
+	if project := os.Getenv("GOOGLE_CLOUD_PROJECT"); project != "" && c.Project == "" {
+		c.Project = project
+	}
+	if file := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); file != "" && c.CredentialsFile == "" {
+		c.CredentialsFile = file
+	}
+
+	flags.StringVar(&c.Project, c.prefix(Project),
+		c.Project, "Google Cloud project ID")
+
+	flags.StringVar(&c.CredentialsFile, c.prefix(CredentialsFile),
+		c.CredentialsFile, "path to service account credentials file (defaults to ADC)")
+
+	flags.StringVar(&c.Endpoint, c.prefix(Endpoint),
+		c.Endpoint, "Pub/Sub API endpoint override")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if c.Project == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Project), flag.ErrRequired))
+	}
+	if c.CredentialsFile != "" {
+		if _, err := os.Stat(c.CredentialsFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(CredentialsFile), flag.ErrInvalidPath))
+		}
+	}
+
+	return mErr
+}
+
+// newClient creates a Pub/Sub client using the configured settings.
+func (c *Config) newClient(ctx context.Context) (*pubsub.Client, error) {
+	var opts []option.ClientOption
+	if c.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(c.CredentialsFile))
+	}
+	if c.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(c.Endpoint))
+	}
+	client, err := pubsub.NewClient(ctx, c.Project, append(opts, c.Options...)...)
+	if err != nil {
+		return nil, fmt.Errorf("pubsub client creation failed: %w", err)
+	}
+	return client, nil
+}
+
+var _ run.Config = (*Config)(nil)
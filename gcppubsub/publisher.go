@@ -0,0 +1,187 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcppubsub
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// publisher flags.
+const (
+	defaultDelayThreshold = 10 * time.Millisecond
+	defaultCountThreshold = 100
+	defaultFlushTimeout   = 10 * time.Second
+
+	Topic          = "gcp-pubsub-topic"
+	EnableOrdering = "gcp-pubsub-ordering"
+	DelayThreshold = "gcp-pubsub-publish-delay"
+	CountThreshold = "gcp-pubsub-publish-count"
+	FlushTimeout   = "gcp-pubsub-flush-timeout"
+)
+
+// Publisher implements a run.Config and run.ServiceContext publishing to a
+// Pub/Sub topic. Messages are batched by the client and flushed on shutdown.
+//
+// If EnableOrdering is set, messages with the same ordering key are
+// delivered in publish order. A failed publish pauses its ordering key; the
+// Publisher resumes it so subsequent messages are published again.
+type Publisher struct {
+	// Config holds the shared connection settings.
+	Config *Config
+
+	Topic          string
+	EnableOrdering bool
+	// DelayThreshold and CountThreshold hold the max. delay and number of
+	// messages of a batch before it is sent.
+	DelayThreshold time.Duration
+	CountThreshold int
+	FlushTimeout   time.Duration
+
+	client *pubsub.Client
+	topic  *pubsub.Topic
+}
+
+// Name implements run.Unit.
+func (p *Publisher) Name() string {
+	return p.Config.prefix("gcp-pubsub-publisher")
+}
+
+// FlagSet implements run.Config.
+func (p *Publisher) FlagSet() *run.FlagSet {
+	if p.DelayThreshold == 0 {
+		p.DelayThreshold = defaultDelayThreshold
+	}
+	if p.CountThreshold == 0 {
+		p.CountThreshold = defaultCountThreshold
+	}
+	if p.FlushTimeout == 0 {
+		p.FlushTimeout = defaultFlushTimeout
+	}
+
+	flags := run.NewFlagSet("Google Cloud Pub/Sub publisher options")
+
+	flags.StringVar(&p.Topic, p.Config.prefix(Topic),
+		p.Topic, "topic ID to publish to")
+
+	flags.BoolVar(&p.EnableOrdering, p.Config.prefix(EnableOrdering),
+		p.EnableOrdering, "deliver messages with the same ordering key in order")
+
+	flags.DurationVar(&p.DelayThreshold, p.Config.prefix(DelayThreshold),
+		p.DelayThreshold, "max. time to wait for batches to fill before sending")
+
+	flags.IntVar(&p.CountThreshold, p.Config.prefix(CountThreshold),
+		p.CountThreshold, "max. number of messages in a batch")
+
+	flags.DurationVar(&p.FlushTimeout, p.Config.prefix(FlushTimeout),
+		p.FlushTimeout, "max. time to flush pending messages on shutdown")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (p *Publisher) Validate() error {
+	var mErr error
+
+	if p.Config == nil {
+		return errors.New("pubsub publisher requires a connection config")
+	}
+	if p.Topic == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(p.Config.prefix(Topic), flag.ErrRequired))
+	}
+	if p.DelayThreshold <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(p.Config.prefix(DelayThreshold), flag.ErrInvalidVal))
+	}
+	if p.CountThreshold < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(p.Config.prefix(CountThreshold), flag.ErrInvalidVal))
+	}
+	if p.FlushTimeout <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(p.Config.prefix(FlushTimeout), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (p *Publisher) PreRun() (err error) {
+	if p.client, err = p.Config.newClient(context.Background()); err != nil {
+		return err
+	}
+	p.topic = p.client.Topic(p.Topic)
+	p.topic.EnableMessageOrdering = p.EnableOrdering
+	p.topic.PublishSettings.DelayThreshold = p.DelayThreshold
+	p.topic.PublishSettings.CountThreshold = p.CountThreshold
+
+	return nil
+}
+
+// Publish publishes the message and waits for the server assigned message
+// ID.
+func (p *Publisher) Publish(ctx context.Context, msg *pubsub.Message) (string, error) {
+	id, err := p.PublishAsync(ctx, msg).Get(ctx)
+	if err != nil && msg.OrderingKey != "" {
+		// resume the ordering key paused by the failure, leaving it to the
+		// caller to republish
+		p.topic.ResumePublish(msg.OrderingKey)
+	}
+	return id, err
+}
+
+// PublishAsync publishes the message without waiting for the result.
+func (p *Publisher) PublishAsync(ctx context.Context, msg *pubsub.Message) *pubsub.PublishResult {
+	return p.topic.Publish(ctx, msg)
+}
+
+// ServeContext implements run.ServiceContext. It flushes the pending
+// messages once the context is canceled.
+func (p *Publisher) ServeContext(ctx context.Context) error {
+	<-ctx.Done()
+
+	done := make(chan struct{})
+	go func() {
+		p.topic.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(p.FlushTimeout):
+		log.Error("unable to flush pending messages", context.DeadlineExceeded, "topic", p.Topic)
+	}
+	if err := p.client.Close(); err != nil {
+		log.Error("unable to close pubsub client", err)
+	}
+
+	return nil
+}
+
+// Client returns the Pub/Sub client of the publisher.
+func (p *Publisher) Client() *pubsub.Client { return p.client }
+
+var (
+	_ run.Config         = (*Publisher)(nil)
+	_ run.PreRunner      = (*Publisher)(nil)
+	_ run.ServiceContext = (*Publisher)(nil)
+)
@@ -0,0 +1,248 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcppubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// subscriber flags.
+const (
+	defaultMaxOutstanding = 100
+	defaultGoroutines     = 1
+	defaultMaxExtension   = 10 * time.Minute
+
+	Subscriptions      = "gcp-pubsub-subscriptions"
+	MaxOutstanding     = "gcp-pubsub-max-outstanding-messages"
+	Goroutines         = "gcp-pubsub-receive-goroutines"
+	MaxExtension       = "gcp-pubsub-max-extension"
+	MaxExtensionPeriod = "gcp-pubsub-max-extension-period"
+)
+
+// Handler handles a received message. The message is acknowledged if the
+// handler returns nil and negatively acknowledged (for redelivery)
+// otherwise.
+type Handler func(ctx context.Context, msg *pubsub.Message) error
+
+// Subscriber implements a run.Config and run.ServiceContext receiving the
+// messages of the subscriptions of the registered handlers.
+//
+// Handlers are registered by subscription name, which is used as
+// subscription ID unless mapped to another ID by the Subscriptions flag
+// ("name=subscription-id" pairs), e.g. for environment specific
+// subscriptions. The ack deadline of messages being handled is extended
+// automatically for up to MaxExtension. MaxOutstanding limits the number of
+// messages handled concurrently per subscription.
+type Subscriber struct {
+	// Config holds the shared connection settings.
+	Config *Config
+
+	Subscriptions  map[string]string
+	MaxOutstanding int
+	// Goroutines holds the number of goroutines pulling messages per
+	// subscription.
+	Goroutines int
+	// MaxExtension holds the max. time the ack deadline of a message is
+	// extended, MaxExtensionPeriod the max. duration of a single extension
+	// (0 for the client default).
+	MaxExtension       time.Duration
+	MaxExtensionPeriod time.Duration
+	// OnError is called for messages a handler returned an error for. If not
+	// set, the error is logged.
+	OnError func(msg *pubsub.Message, err error)
+
+	mtx      sync.Mutex
+	handlers map[string]Handler
+	client   *pubsub.Client
+}
+
+// Name implements run.Unit.
+func (s *Subscriber) Name() string {
+	return s.Config.prefix("gcp-pubsub-subscriber")
+}
+
+// Handle registers the handler for the messages of the named subscription.
+// Handlers need to be registered before the Subscriber is started.
+func (s *Subscriber) Handle(subscription string, h Handler) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.handlers == nil {
+		s.handlers = make(map[string]Handler)
+	}
+	s.handlers[subscription] = h
+}
+
+// FlagSet implements run.Config.
+func (s *Subscriber) FlagSet() *run.FlagSet {
+	if s.MaxOutstanding == 0 {
+		s.MaxOutstanding = defaultMaxOutstanding
+	}
+	if s.Goroutines == 0 {
+		s.Goroutines = defaultGoroutines
+	}
+	if s.MaxExtension == 0 {
+		s.MaxExtension = defaultMaxExtension
+	}
+
+	flags := run.NewFlagSet("Google Cloud Pub/Sub subscriber options")
+
+	flags.StringToStringVar(&s.Subscriptions, s.Config.prefix(Subscriptions),
+		s.Subscriptions, `subscription IDs of the handlers as "name=subscription-id" pairs`)
+
+	flags.IntVar(&s.MaxOutstanding, s.Config.prefix(MaxOutstanding),
+		s.MaxOutstanding, "max. number of messages handled concurrently per subscription")
+
+	flags.IntVar(&s.Goroutines, s.Config.prefix(Goroutines),
+		s.Goroutines, "number of goroutines pulling messages per subscription")
+
+	flags.DurationVar(&s.MaxExtension, s.Config.prefix(MaxExtension),
+		s.MaxExtension, "max. time the ack deadline of a message is extended")
+
+	flags.DurationVar(&s.MaxExtensionPeriod, s.Config.prefix(MaxExtensionPeriod),
+		s.MaxExtensionPeriod, "max. duration of a single ack deadline extension (0 for default)")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Subscriber) Validate() error {
+	var mErr error
+
+	if s.Config == nil {
+		return errors.New("pubsub subscriber requires a connection config")
+	}
+	if s.MaxOutstanding < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.Config.prefix(MaxOutstanding), flag.ErrInvalidVal))
+	}
+	if s.Goroutines < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.Config.prefix(Goroutines), flag.ErrInvalidVal))
+	}
+	if s.MaxExtension <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.Config.prefix(MaxExtension), flag.ErrInvalidVal))
+	}
+	if s.MaxExtensionPeriod < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.Config.prefix(MaxExtensionPeriod), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Subscriber) PreRun() (err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if len(s.handlers) == 0 {
+		return errors.New("pubsub subscriber has no handlers registered")
+	}
+	s.client, err = s.Config.newClient(context.Background())
+	return err
+}
+
+// subscription returns the subscription of the named handler.
+func (s *Subscriber) subscription(name string) *pubsub.Subscription {
+	id := name
+	if mapped, ok := s.Subscriptions[name]; ok {
+		id = mapped
+	}
+	sub := s.client.Subscription(id)
+	sub.ReceiveSettings.MaxOutstandingMessages = s.MaxOutstanding
+	sub.ReceiveSettings.NumGoroutines = s.Goroutines
+	sub.ReceiveSettings.MaxExtension = s.MaxExtension
+	sub.ReceiveSettings.MaxExtensionPeriod = s.MaxExtensionPeriod
+	return sub
+}
+
+// ServeContext implements run.ServiceContext. It dispatches the received
+// messages to the registered handlers until the context is canceled, after
+// which it waits for the messages being handled. A subscription failing to
+// receive (e.g. as it doesn't exist) stops the Subscriber.
+func (s *Subscriber) ServeContext(ctx context.Context) error {
+	defer func() {
+		if err := s.client.Close(); err != nil {
+			log.Error("unable to close pubsub client", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		errc = make(chan error, len(s.handlers))
+	)
+	for name, h := range s.handlers {
+		sub := s.subscription(name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+				s.handle(ctx, sub, h, msg)
+			})
+			if err != nil && ctx.Err() == nil {
+				errc <- fmt.Errorf("pubsub subscription %s: %w", sub.ID(), err)
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errc)
+
+	return <-errc
+}
+
+// handle dispatches the message to the handler and acknowledges it.
+func (s *Subscriber) handle(ctx context.Context, sub *pubsub.Subscription, h Handler, msg *pubsub.Message) {
+	if err := h(ctx, msg); err != nil {
+		msg.Nack()
+		if s.OnError != nil {
+			s.OnError(msg, err)
+			return
+		}
+		log.Error("handling message failed", err,
+			"subscription", sub.ID(), "id", msg.ID, "attempt", deliveryAttempt(msg))
+		return
+	}
+	msg.Ack()
+}
+
+func deliveryAttempt(msg *pubsub.Message) int {
+	if msg.DeliveryAttempt == nil {
+		return 0
+	}
+	return *msg.DeliveryAttempt
+}
+
+// Client returns the Pub/Sub client of the subscriber.
+func (s *Subscriber) Client() *pubsub.Client { return s.client }
+
+var (
+	_ run.Config         = (*Subscriber)(nil)
+	_ run.PreRunner      = (*Subscriber)(nil)
+	_ run.ServiceContext = (*Subscriber)(nil)
+)
@@ -0,0 +1,107 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geoip
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Record holds the GeoIP information of an IP address. Fields are empty if
+// the address is not found or not covered by the loaded databases.
+type Record struct {
+	IP          netip.Addr
+	Country     string // ISO 3166-1 alpha-2 country code
+	CountryName string // English country name
+	Continent   string // continent code
+	City        string // English city name
+	// ASN holds the autonomous system number and ASOrganization its
+	// organization.
+	ASN            uint
+	ASOrganization string
+}
+
+// Lookup returns the GeoIP information of the IP address.
+func (s *Service) Lookup(ip netip.Addr) (*Record, error) {
+	db := s.db.Load()
+	if db == nil {
+		return nil, ErrNotLoaded
+	}
+
+	ip = ip.Unmap()
+	rec := &Record{IP: ip}
+	if err := lookup(db, net.IP(ip.AsSlice()), rec); err != nil {
+		return nil, err
+	}
+	if asn := s.asn.Load(); asn != nil {
+		if err := lookup(asn, net.IP(ip.AsSlice()), rec); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+// Country returns the ISO country code of the IP address.
+func (s *Service) Country(ip netip.Addr) (string, error) {
+	rec, err := s.Lookup(ip)
+	if err != nil {
+		return "", err
+	}
+	return rec.Country, nil
+}
+
+// ASN returns the autonomous system number and organization of the IP
+// address.
+func (s *Service) ASN(ip netip.Addr) (uint, string, error) {
+	rec, err := s.Lookup(ip)
+	if err != nil {
+		return 0, "", err
+	}
+	return rec.ASN, rec.ASOrganization, nil
+}
+
+// lookup fills the record with the fields provided by the database type.
+func lookup(r *geoip2.Reader, ip net.IP, rec *Record) error {
+	switch dbType := r.Metadata().DatabaseType; {
+	case isASN(dbType):
+		asn, err := r.ASN(ip)
+		if err != nil {
+			return err
+		}
+		rec.ASN = asn.AutonomousSystemNumber
+		rec.ASOrganization = asn.AutonomousSystemOrganization
+	case strings.Contains(dbType, "City"), strings.Contains(dbType, "Enterprise"):
+		city, err := r.City(ip)
+		if err != nil {
+			return err
+		}
+		rec.Country = city.Country.IsoCode
+		rec.CountryName = city.Country.Names["en"]
+		rec.Continent = city.Continent.Code
+		rec.City = city.City.Names["en"]
+	default:
+		country, err := r.Country(ip)
+		if err != nil {
+			return err
+		}
+		rec.Country = country.Country.IsoCode
+		rec.CountryName = country.Country.Names["en"]
+		rec.Continent = country.Continent.Code
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geoip
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	"github.com/basvanbeek/telemetry"
+)
+
+type recordKey struct{}
+
+// RecordFromContext returns the GeoIP record attached by Middleware.
+func RecordFromContext(ctx context.Context) (*Record, bool) {
+	rec, ok := ctx.Value(recordKey{}).(*Record)
+	return rec, ok
+}
+
+// Middleware annotates requests with the GeoIP record of the client IP. The
+// record is stored in the request context and its country and ASN are added
+// to the telemetry key/value pairs so loggers using the request context
+// include them. Requests which can't be resolved are passed on unchanged.
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ip netip.Addr
+		if s.ClientIP != nil {
+			ip = s.ClientIP(r)
+		} else {
+			ip = remoteIP(r.RemoteAddr)
+		}
+		if !ip.IsValid() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec, err := s.Lookup(ip)
+		if err != nil {
+			log.Debug("geoip lookup failed", "ip", ip.String(), "error", err.Error())
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), recordKey{}, rec)
+		if rec.Country != "" {
+			ctx = telemetry.KeyValuesToContext(ctx, "geo_country", rec.Country)
+		}
+		if rec.ASN != 0 {
+			ctx = telemetry.KeyValuesToContext(ctx, "geo_asn", strconv.FormatUint(uint64(rec.ASN), 10))
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func remoteIP(addr string) netip.Addr {
+	if ap, err := netip.ParseAddrPort(addr); err == nil {
+		return ap.Addr().Unmap()
+	}
+	ip, _ := netip.ParseAddr(addr)
+	return ip.Unmap()
+}
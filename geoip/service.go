@@ -0,0 +1,195 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package geoip provides GeoIP lookups using MaxMind databases for use with
+// the run package. Databases are reloaded on change when a file watcher is
+// configured.
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+	"github.com/oschwald/geoip2-golang"
+)
+
+var log = scope.Register("geoip", "GeoIP database")
+
+// package flags.
+const (
+	Database    = "geoip-database"
+	ASNDatabase = "geoip-asn-database"
+)
+
+// ErrNotLoaded is returned for lookups before the database has been loaded.
+var ErrNotLoaded = errors.New("geoip database not loaded")
+
+// FileWatcher is implemented by the filewatcher.Service and provides
+// notifications of file changes.
+type FileWatcher interface {
+	AddWatcher(name, fqn string) (<-chan []byte, error)
+}
+
+// Service implements run.Config and run.PreRunner for a MaxMind (mmdb)
+// GeoIP database. Database holds a City or Country database, ASNDatabase an
+// optional ASN (or ISP) database for the autonomous system lookups.
+type Service struct {
+	Prefix      string
+	Database    string
+	ASNDatabase string
+
+	// Watcher optionally holds the file watcher (e.g. filewatcher.Service)
+	// used to reload the databases on change.
+	Watcher FileWatcher
+	// ClientIP optionally resolves the client IP of requests passing the
+	// Middleware, e.g. the ClientIP func of the http package when running
+	// behind proxies. If not set, the IP of the remote address is used.
+	ClientIP func(r *http.Request) netip.Addr
+
+	db  atomic.Pointer[geoip2.Reader]
+	asn atomic.Pointer[geoip2.Reader]
+}
+
+func (s *Service) prefix(v string) string {
+	if s.Prefix != "" {
+		return s.Prefix + "-" + v
+	}
+	return v
+}
+
+// Name implements run.Unit.
+func (s *Service) Name() string {
+	return s.prefix("geoip")
+}
+
+// FlagSet implements run.Config.
+func (s *Service) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("GeoIP options")
+
+	flags.StringVar(&s.Database, s.prefix(Database), s.Database,
+		"path to the MaxMind City or Country database (mmdb)")
+
+	flags.StringVar(&s.ASNDatabase, s.prefix(ASNDatabase), s.ASNDatabase,
+		"path to the optional MaxMind ASN database (mmdb)")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	if s.Database == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(Database), flag.ErrRequired))
+	}
+	for name, path := range map[string]string{
+		Database:    s.Database,
+		ASNDatabase: s.ASNDatabase,
+	} {
+		if path == "" {
+			continue
+		}
+		if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(name), flag.ErrInvalidPath))
+		}
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() error {
+	dbs := []struct {
+		name, path string
+		reader     *atomic.Pointer[geoip2.Reader]
+		asn        bool
+	}{
+		{s.prefix(Database), s.Database, &s.db, false},
+		{s.prefix(ASNDatabase), s.ASNDatabase, &s.asn, true},
+	}
+
+	for _, db := range dbs {
+		if db.path == "" {
+			continue
+		}
+		b, err := os.ReadFile(db.path)
+		if err != nil {
+			return fmt.Errorf("unable to read geoip database %s: %w", db.path, err)
+		}
+		if err = load(db.reader, b, db.asn); err != nil {
+			return fmt.Errorf("unable to load geoip database %s: %w", db.path, err)
+		}
+		log.Info("loaded geoip database", "path", db.path,
+			"type", db.reader.Load().Metadata().DatabaseType)
+
+		if s.Watcher == nil {
+			continue
+		}
+		ch, err := s.Watcher.AddWatcher(db.name, db.path)
+		if err != nil {
+			return fmt.Errorf("unable to watch %s: %w", db.path, err)
+		}
+		go func() {
+			// the channel is closed once the file watcher stops; a failed
+			// reload (e.g. of a partially written file) keeps the previous
+			// database in use
+			for b := range ch {
+				if err := load(db.reader, b, db.asn); err != nil {
+					log.Error("unable to reload geoip database", err, "path", db.path)
+					continue
+				}
+				log.Info("reloaded geoip database", "path", db.path)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// load opens the database from memory rather than memory mapping the file,
+// so replaced readers can be left to the garbage collector while lookups
+// may still be using them.
+func load(reader *atomic.Pointer[geoip2.Reader], b []byte, asn bool) error {
+	r, err := geoip2.FromBytes(b)
+	if err != nil {
+		return err
+	}
+	if isASN(r.Metadata().DatabaseType) != asn {
+		return fmt.Errorf("unexpected database type %s", r.Metadata().DatabaseType)
+	}
+	reader.Store(r)
+	return nil
+}
+
+// isASN returns true for database types holding autonomous system rather
+// than location information.
+func isASN(dbType string) bool {
+	return strings.Contains(dbType, "ASN") || strings.Contains(dbType, "ISP")
+}
+
+var (
+	_ run.Config    = (*Service)(nil)
+	_ run.PreRunner = (*Service)(nil)
+)
@@ -0,0 +1,158 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/basvanbeek/telemetry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// accessLog holds the request logging interceptors.
+type accessLog struct {
+	level telemetry.Level
+	skip  map[string]bool
+}
+
+func newAccessLog(level telemetry.Level, skip []string) *accessLog {
+	a := &accessLog{level: level, skip: make(map[string]bool, len(skip))}
+	for _, method := range skip {
+		a.skip[method] = true
+	}
+	return a
+}
+
+// skipped returns true if the full method ("/package.Service/Method") or its
+// service ("/package.Service" or "package.Service") is on the skip-list.
+func (a *accessLog) skipped(fullMethod string) bool {
+	if a.level == telemetry.LevelNone || a.skip[fullMethod] {
+		return true
+	}
+	svc, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return a.skip[svc] || a.skip["/"+svc]
+}
+
+func (a *accessLog) log(
+	ctx context.Context, kind, fullMethod string, start time.Time,
+	recvSize, sendSize int64, err error,
+) {
+	code := status.Code(err)
+	kvs := []any{
+		"method", fullMethod,
+		"type", kind,
+		"code", code.String(),
+		"duration", time.Since(start).String(),
+		"recv_bytes", recvSize,
+		"send_bytes", sendSize,
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		kvs = append(kvs, "peer", p.Addr.String())
+	}
+
+	l := log.Context(ctx)
+	switch {
+	case code != codes.OK && a.level >= telemetry.LevelError:
+		l.Error("gRPC request failed", err, kvs...)
+	case a.level >= telemetry.LevelDebug:
+		l.Debug("gRPC request", kvs...)
+	case a.level >= telemetry.LevelInfo:
+		l.Info("gRPC request", kvs...)
+	}
+}
+
+// UnaryServerInterceptor returns the unary request logging interceptor.
+func (a *accessLog) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if a.skipped(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		a.log(ctx, "unary", info.FullMethod, start,
+			messageSize(req), messageSize(resp), err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns the stream request logging interceptor.
+func (a *accessLog) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if a.skipped(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		start := time.Now()
+		cs := &countingStream{ServerStream: ss}
+		err := handler(srv, cs)
+		a.log(ss.Context(), "stream", info.FullMethod, start,
+			cs.recv.Load(), cs.sent.Load(), err)
+		return err
+	}
+}
+
+// countingStream wraps a grpc.ServerStream to total the message sizes.
+type countingStream struct {
+	grpc.ServerStream
+	recv, sent atomic.Int64
+}
+
+func (c *countingStream) SendMsg(m any) error {
+	err := c.ServerStream.SendMsg(m)
+	if err == nil {
+		c.sent.Add(messageSize(m))
+	}
+	return err
+}
+
+func (c *countingStream) RecvMsg(m any) error {
+	err := c.ServerStream.RecvMsg(m)
+	if err == nil {
+		c.recv.Add(messageSize(m))
+	}
+	return err
+}
+
+// messageSize returns the encoded size of protobuf messages and 0 otherwise.
+func messageSize(m any) int64 {
+	if pm, ok := m.(proto.Message); ok {
+		return int64(proto.Size(pm))
+	}
+	return 0
+}
+
+// addAccessLog registers the request logging interceptors as the outermost
+// interceptors, so recovered panics are logged with their resulting code.
+func (s *Service) addAccessLog() {
+	level, _ := telemetry.FromLevel(s.AccessLogLevel)
+	if level == telemetry.LevelNone {
+		return
+	}
+	a := newAccessLog(level, s.AccessLogSkip)
+	s.i.AddUnaryServerPhase(PhaseObservability, a.UnaryServerInterceptor())
+	s.i.AddStreamServerPhase(PhaseObservability, a.StreamServerInterceptor())
+}
@@ -0,0 +1,211 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// parseCIDRs parses a list of CIDR ranges and IP addresses.
+func parseCIDRs(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if strings.Contains(c, "/") {
+			prefix, err := netip.ParsePrefix(c)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", c, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP %q: %w", c, err)
+		}
+		ip = ip.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// validateACL checks the peer IP allow and deny lists.
+func (s *Service) validateACL() error {
+	var mErr error
+
+	if _, err := parseCIDRs(s.AllowCIDRs); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(AllowCIDRs, err))
+	}
+	if _, err := parseCIDRs(s.DenyCIDRs); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(DenyCIDRs, err))
+	}
+	if len(s.ACLMethods) > 0 && len(s.AllowCIDRs) == 0 && len(s.DenyCIDRs) == 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(ACLMethods,
+				flag.ValidationError("no allow or deny list configured")))
+	}
+
+	return mErr
+}
+
+type aclKey struct{}
+
+// acl implements peer IP allow and deny lists. The peer of a connection is
+// checked once in TagConn, its RPCs are enforced by the interceptors.
+type acl struct {
+	allow   []netip.Prefix
+	deny    []netip.Prefix
+	methods map[string]bool
+}
+
+// permitted returns true if the peer address is not denied and, if an allow
+// list is configured, allowed. Peers without IP address, like unix domain
+// socket peers, are local and always permitted.
+func (a *acl) permitted(addr net.Addr) bool {
+	if addr == nil {
+		return false
+	}
+	if _, ok := addr.(*net.UnixAddr); ok {
+		return true
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := ap.Addr().Unmap()
+	for _, p := range a.deny {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	if len(a.allow) == 0 {
+		return true
+	}
+	for _, p := range a.allow {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// applies returns true if the ACL is enforced for the method.
+func (a *acl) applies(fullMethod string) bool {
+	if len(a.methods) == 0 {
+		return true
+	}
+	method := strings.TrimPrefix(fullMethod, "/")
+	svc, _, _ := strings.Cut(method, "/")
+	return a.methods[method] || a.methods[svc]
+}
+
+// check returns a PermissionDenied error if the peer of the RPC is not
+// permitted to call the method.
+func (a *acl) check(ctx context.Context, fullMethod string) error {
+	if !a.applies(fullMethod) {
+		return nil
+	}
+	ok, tagged := ctx.Value(aclKey{}).(bool)
+	if !tagged {
+		// connections served through the HTTP service are not tagged
+		var addr net.Addr
+		if p, found := peer.FromContext(ctx); found {
+			addr = p.Addr
+		}
+		ok = a.permitted(addr)
+	}
+	if !ok {
+		log.Context(ctx).Debug("peer denied", "method", fullMethod,
+			"peer", peerHost(ctx))
+		return status.Error(codes.PermissionDenied, "peer not permitted")
+	}
+	return nil
+}
+
+// TagConn implements stats.Handler.
+func (a *acl) TagConn(ctx context.Context, cti *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, aclKey{}, a.permitted(cti.RemoteAddr))
+}
+
+// HandleConn implements stats.Handler.
+func (a *acl) HandleConn(context.Context, stats.ConnStats) {}
+
+// TagRPC implements stats.Handler.
+func (a *acl) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC implements stats.Handler.
+func (a *acl) HandleRPC(context.Context, stats.RPCStats) {}
+
+// UnaryServerInterceptor returns the unary ACL interceptor.
+func (a *acl) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := a.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the stream ACL interceptor.
+func (a *acl) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := a.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// addACL registers the peer IP ACL if an allow or deny list is configured.
+func (s *Service) addACL() {
+	allow, _ := parseCIDRs(s.AllowCIDRs)
+	deny, _ := parseCIDRs(s.DenyCIDRs)
+	if len(allow) == 0 && len(deny) == 0 {
+		return
+	}
+	a := &acl{allow: allow, deny: deny, methods: make(map[string]bool)}
+	for _, m := range s.ACLMethods {
+		a.methods[strings.TrimPrefix(strings.TrimSpace(m), "/")] = true
+	}
+	s.i.AddStatsHandler(a)
+	s.i.AddUnaryServerPhase(PhaseLimits, a.UnaryServerInterceptor())
+	s.i.AddStreamServerPhase(PhaseLimits, a.StreamServerInterceptor())
+}
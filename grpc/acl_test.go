@@ -0,0 +1,79 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+func TestACLPermitted(t *testing.T) {
+	allow, _ := parseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+	deny, _ := parseCIDRs([]string{"10.1.0.0/16"})
+	a := &acl{allow: allow, deny: deny}
+
+	for addr, want := range map[net.Addr]bool{
+		&net.TCPAddr{IP: net.ParseIP("10.2.3.4"), Port: 1}:    true,
+		&net.TCPAddr{IP: net.ParseIP("10.1.3.4"), Port: 1}:    false,
+		&net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 1}: true,
+		&net.TCPAddr{IP: net.ParseIP("192.168.1.2"), Port: 1}: false,
+		// IPv4-mapped IPv6 addresses match the IPv4 ranges
+		&net.TCPAddr{IP: net.ParseIP("::ffff:10.2.3.4"), Port: 1}: true,
+		&net.UnixAddr{Name: "/run/grpc.sock", Net: "unix"}:        true,
+	} {
+		if got := a.permitted(addr); got != want {
+			t.Errorf("%s: expected %v, got %v", addr, want, got)
+		}
+	}
+}
+
+func TestACLMethods(t *testing.T) {
+	deny, _ := parseCIDRs([]string{"0.0.0.0/0"})
+	a := &acl{deny: deny, methods: map[string]bool{
+		"admin.Service":     true,
+		"app.Service/Purge": true,
+	}}
+
+	for method, want := range map[string]bool{
+		"/admin.Service/Anything": true,
+		"/app.Service/Purge":      true,
+		"/app.Service/Get":        false,
+	} {
+		if got := a.applies(method); got != want {
+			t.Errorf("%s: expected %v, got %v", method, want, got)
+		}
+	}
+
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1}
+	for name, ctx := range map[string]context.Context{
+		"tagged connection": a.TagConn(context.Background(),
+			&stats.ConnTagInfo{RemoteAddr: addr}),
+		// connections served through the HTTP service are not tagged
+		"untagged peer": peer.NewContext(context.Background(), &peer.Peer{Addr: addr}),
+	} {
+		if code := status.Code(a.check(ctx, "/admin.Service/Anything")); code != codes.PermissionDenied {
+			t.Errorf("%s: expected %v, got %v", name, codes.PermissionDenied, code)
+		}
+		if err := a.check(ctx, "/app.Service/Get"); err != nil {
+			t.Errorf("%s: expected method without ACL to be permitted, got %v", name, err)
+		}
+	}
+}
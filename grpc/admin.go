@@ -0,0 +1,66 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"errors"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/admin"
+)
+
+// setupAdmin registers the admin services (channelz and, if the xDS packages
+// are linked in, CSDS). If an admin address is configured they are served by
+// a separate internal server, otherwise by the main server.
+func (s *Service) setupAdmin() error {
+	if s.AdminAddress == "" {
+		cleanup, err := admin.Register(s.srv)
+		s.adminCleanup = cleanup
+		return err
+	}
+
+	srv := grpc.NewServer()
+	cleanup, err := admin.Register(srv)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", s.AdminAddress)
+	if err != nil {
+		cleanup()
+		return err
+	}
+	s.admin, s.adminCleanup = srv, cleanup
+
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Error("admin server failed", err, "address", s.AdminAddress)
+		}
+	}()
+	log.Info("serving gRPC admin services", "address", s.AdminAddress)
+	return nil
+}
+
+// stopAdmin stops the internal admin server, if any, and releases the
+// resources held by the admin services.
+func (s *Service) stopAdmin() {
+	if s.admin != nil {
+		// admin streams can be long-lived, don't wait for them
+		s.admin.Stop()
+	}
+	if s.adminCleanup != nil {
+		s.adminCleanup()
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var auditLog = scope.Register("grpc-audit", "gRPC audit events")
+
+// redacted replaces the values of sensitive fields in audit events.
+const redacted = "[REDACTED]"
+
+// phaseAudit places the audit interceptors after authentication, so the
+// principal is known.
+const phaseAudit = PhaseAuth + 50
+
+// AuditEvent describes an audited RPC.
+type AuditEvent struct {
+	Time      time.Time
+	Method    string
+	Principal string
+	Peer      string
+	Code      codes.Code
+	Duration  time.Duration
+	// Request holds the selected request fields by path, with the values of
+	// sensitive fields redacted. Streaming RPCs hold no request fields.
+	Request map[string]any
+}
+
+// AuditSink receives the audit events, e.g. to store them in an audit trail.
+type AuditSink interface {
+	Audit(ctx context.Context, e *AuditEvent)
+}
+
+// AuditSinkFunc implements an AuditSink using a function.
+type AuditSinkFunc func(ctx context.Context, e *AuditEvent)
+
+// Audit implements AuditSink.
+func (f AuditSinkFunc) Audit(ctx context.Context, e *AuditEvent) {
+	f(ctx, e)
+}
+
+// logSink implements the default AuditSink, logging the events to the
+// "grpc-audit" scope.
+type logSink struct{}
+
+// Audit implements AuditSink.
+func (logSink) Audit(ctx context.Context, e *AuditEvent) {
+	kvs := []any{
+		"method", e.Method,
+		"principal", e.Principal,
+		"peer", e.Peer,
+		"code", e.Code.String(),
+		"duration", e.Duration.String(),
+	}
+	if len(e.Request) > 0 {
+		kvs = append(kvs, "request", e.Request)
+	}
+	auditLog.Context(ctx).Info("gRPC audit", kvs...)
+}
+
+// tlsPrincipal returns the common name of the verified client certificate of
+// the RPC, if any.
+func tlsPrincipal(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName
+}
+
+// validateAudit checks the audit settings.
+func (s *Service) validateAudit() error {
+	if s.EnableAudit {
+		return nil
+	}
+
+	var mErr error
+
+	for _, f := range []struct {
+		flag   string
+		values []string
+	}{
+		{AuditMethods, s.AuditMethods},
+		{AuditFields, s.AuditFields},
+		{AuditRedact, s.AuditRedact},
+	} {
+		if len(f.values) > 0 {
+			mErr = multierror.Append(mErr, flag.NewValidationError(f.flag,
+				flag.ValidationError("audit logging is disabled")))
+		}
+	}
+
+	return mErr
+}
+
+// auditor holds the audit interceptors.
+type auditor struct {
+	sink      AuditSink
+	principal func(context.Context) string
+	methods   map[string]bool
+	fields    []string
+	redact    map[string]bool
+}
+
+// audited returns true if the method is audited.
+func (a *auditor) audited(fullMethod string) bool {
+	if len(a.methods) == 0 {
+		return true
+	}
+	method := strings.TrimPrefix(fullMethod, "/")
+	svc, _, _ := strings.Cut(method, "/")
+	return a.methods[method] || a.methods[svc]
+}
+
+func (a *auditor) audit(
+	ctx context.Context, fullMethod string, req any, start time.Time, err error,
+) {
+	e := &AuditEvent{
+		Time:      start,
+		Method:    fullMethod,
+		Principal: a.principal(ctx),
+		Peer:      peerHost(ctx),
+		Code:      status.Code(err),
+		Duration:  time.Since(start),
+	}
+	if m, ok := req.(proto.Message); ok && len(a.fields) > 0 {
+		e.Request = make(map[string]any, len(a.fields))
+		for _, path := range a.fields {
+			if v, found := a.lookup(m.ProtoReflect(), strings.Split(path, ".")); found {
+				e.Request[path] = v
+			}
+		}
+	}
+	a.sink.Audit(ctx, e)
+}
+
+// sensitive returns true if the field is annotated with debug_redact or
+// configured to be redacted.
+func (a *auditor) sensitive(fd protoreflect.FieldDescriptor) bool {
+	opts, _ := fd.Options().(*descriptorpb.FieldOptions)
+	return opts.GetDebugRedact() || a.redact[string(fd.Name())]
+}
+
+// lookup returns the redacted value of the field found at the path.
+func (a *auditor) lookup(m protoreflect.Message, path []string) (any, bool) {
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	switch {
+	case fd == nil:
+		return nil, false
+	case a.sensitive(fd):
+		return redacted, true
+	case len(path) == 1:
+		return a.value(fd, m.Get(fd)), true
+	case fd.Message() == nil || fd.IsList() || fd.IsMap():
+		return nil, false
+	}
+	return a.lookup(m.Get(fd).Message(), path[1:])
+}
+
+// value returns the redacted value of a field.
+func (a *auditor) value(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch {
+	case fd.IsList():
+		l := v.List()
+		values := make([]any, 0, l.Len())
+		for i := range l.Len() {
+			values = append(values, a.single(fd, l.Get(i)))
+		}
+		return values
+	case fd.IsMap():
+		values := make(map[string]any)
+		v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+			values[k.String()] = a.single(fd.MapValue(), mv)
+			return true
+		})
+		return values
+	}
+	return a.single(fd, v)
+}
+
+// single returns the redacted value of a non repeated field value.
+func (a *auditor) single(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		values := make(map[string]any)
+		v.Message().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			if a.sensitive(fd) {
+				values[string(fd.Name())] = redacted
+			} else {
+				values[string(fd.Name())] = a.value(fd, v)
+			}
+			return true
+		})
+		return values
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	default:
+		return v.Interface()
+	}
+}
+
+// UnaryServerInterceptor returns the unary audit interceptor.
+func (a *auditor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if !a.audited(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		a.audit(ctx, info.FullMethod, req, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns the stream audit interceptor.
+func (a *auditor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if !a.audited(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		start := time.Now()
+		err := handler(srv, ss)
+		a.audit(ss.Context(), info.FullMethod, nil, start, err)
+		return err
+	}
+}
+
+// addAudit registers the audit interceptors if enabled.
+func (s *Service) addAudit() {
+	if !s.EnableAudit {
+		return
+	}
+	a := &auditor{
+		sink:      s.AuditSink,
+		principal: s.AuditPrincipal,
+		methods:   make(map[string]bool),
+		fields:    s.AuditFields,
+		redact:    make(map[string]bool),
+	}
+	if a.sink == nil {
+		a.sink = logSink{}
+	}
+	if a.principal == nil {
+		a.principal = tlsPrincipal
+	}
+	for _, m := range s.AuditMethods {
+		a.methods[strings.TrimPrefix(strings.TrimSpace(m), "/")] = true
+	}
+	for _, f := range s.AuditRedact {
+		a.redact[strings.TrimSpace(f)] = true
+	}
+	s.i.AddUnaryServerPhase(phaseAudit, a.UnaryServerInterceptor())
+	s.i.AddStreamServerPhase(phaseAudit, a.StreamServerInterceptor())
+}
@@ -0,0 +1,129 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync"
+)
+
+// FileWatcher is implemented by the filewatcher.Service and provides
+// notifications of file changes.
+type FileWatcher interface {
+	AddWatcher(name, fqn string) (<-chan []byte, error)
+}
+
+// certReloader holds the server certificate and client CA pool, reloading
+// them once their files change.
+type certReloader struct {
+	certFile, keyFile, caFile string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	pool *x509.CertPool
+}
+
+func newCertReloader(certFile, keyFile, caFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.reloadCert(); err != nil {
+		return nil, err
+	}
+	if err := r.reloadPool(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) reloadCert() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load server certificate: %w", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) reloadPool() error {
+	if r.caFile == "" {
+		return nil
+	}
+	pool, err := loadCertPool(r.caFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.pool = pool
+	r.mu.Unlock()
+	return nil
+}
+
+// getCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// configForClient returns the TLS config to use for a connection, holding
+// the current client CA pool.
+func (r *certReloader) configForClient(base *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.ClientCAs = r.pool
+		return cfg, nil
+	}
+}
+
+// watch registers the certificate files with the file watcher and reloads
+// them on change. A failed reload (e.g. when the certificate has been
+// replaced but the key not yet) keeps the previous certificate in use.
+func (r *certReloader) watch(fw FileWatcher) error {
+	files := []struct {
+		name, path string
+		reload     func() error
+	}{
+		{"grpc-tls-cert", r.certFile, r.reloadCert},
+		{"grpc-tls-key", r.keyFile, r.reloadCert},
+		{"grpc-tls-client-ca", r.caFile, r.reloadPool},
+	}
+
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		ch, err := fw.AddWatcher(f.name, f.path)
+		if err != nil {
+			return fmt.Errorf("unable to watch %s: %w", f.path, err)
+		}
+		go func() {
+			// the channel is closed once the file watcher stops
+			for range ch {
+				if err := f.reload(); err != nil {
+					log.Error("unable to reload TLS credentials", err, "file", f.path)
+					continue
+				}
+				log.Info("reloaded TLS credentials", "file", f.path)
+			}
+		}()
+	}
+	return nil
+}
@@ -0,0 +1,503 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// client flags.
+const (
+	ClientTarget            = "grpc-client-target"
+	ClientAuthority         = "grpc-client-authority"
+	ClientTLS               = "grpc-client-tls"
+	ClientTLSCAFile         = "grpc-client-tls-ca-file"
+	ClientTLSCertFile       = "grpc-client-tls-cert-file"
+	ClientTLSKeyFile        = "grpc-client-tls-key-file"
+	ClientTLSServerName     = "grpc-client-tls-server-name"
+	ClientKeepaliveTime     = "grpc-client-keepalive-time"
+	ClientKeepaliveTimeout  = "grpc-client-keepalive-timeout"
+	ClientKeepaliveNoStream = "grpc-client-keepalive-permit-without-stream"
+	ClientMaxMsgSize        = "grpc-client-max-msg-size"
+	ClientMaxAttempts       = "grpc-client-max-attempts"
+	ClientRetryCodes        = "grpc-client-retry-codes"
+	ClientDisableRetry      = "grpc-client-disable-retry"
+
+	ClientRetryInitialBackoff    = "grpc-client-retry-initial-backoff"
+	ClientRetryMaxBackoff        = "grpc-client-retry-max-backoff"
+	ClientRetryBackoffMultiplier = "grpc-client-retry-backoff-multiplier"
+	ClientHedging                = "grpc-client-hedging"
+	ClientHedgingDelay           = "grpc-client-hedging-delay"
+
+	ClientServiceConfig       = "grpc-client-service-config"
+	ClientWaitForReady        = "grpc-client-wait-for-ready"
+	ClientWaitForReadyTimeout = "grpc-client-wait-for-ready-timeout"
+	ClientLBPolicy            = "grpc-client-lb-policy"
+	ClientEndpoints           = "grpc-client-endpoints"
+	ClientDNSServer           = "grpc-client-dns-server"
+	ClientMode                = "grpc-client-mode"
+)
+
+const (
+	defaultClientWaitForReady      = 10 * time.Second
+	defaultClientMaxAttempts       = 1
+	defaultClientRetryCodes        = "UNAVAILABLE"
+	defaultClientInitialBackoff    = 100 * time.Millisecond
+	defaultClientMaxBackoff        = time.Second
+	defaultClientBackoffMultiplier = 2
+)
+
+// Client implements a run.Group compatible gRPC client connection. The
+// connection is created in PreRun and closed on shutdown. Set a Prefix to
+// manage multiple named clients in one run.Group.
+type Client struct {
+	Prefix    string
+	Target    string
+	Authority string
+
+	// LBPolicy holds the load balancing policy ("pick_first" or
+	// "round_robin") to spread calls over the resolved addresses.
+	LBPolicy string
+	// Endpoints optionally holds a static list of server addresses to use
+	// instead of resolving the Target.
+	Endpoints []string
+	// DNSServer optionally holds the DNS server ("host:port") to resolve
+	// the Target with instead of the system resolver.
+	DNSServer string
+	// Mode holds the client mode: "default" or "xds". In xDS mode the
+	// target is resolved and balanced by the control plane found in the
+	// xDS bootstrap config.
+	Mode string
+
+	TLS           bool
+	TLSCAFile     string
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSServerName string
+
+	KeepaliveTime                time.Duration
+	KeepaliveTimeout             time.Duration
+	KeepalivePermitWithoutStream bool
+
+	MaxMsgSize int
+	// MaxAttempts holds the max. number of attempts of a call including the
+	// original one. Calls failing with one of the RetryCodes are retried
+	// with exponential backoff.
+	MaxAttempts            int
+	RetryCodes             []string
+	RetryInitialBackoff    time.Duration
+	RetryMaxBackoff        time.Duration
+	RetryBackoffMultiplier float64
+	// Hedging sends up to MaxAttempts copies of a unary call, HedgingDelay apart,
+	// instead of retrying it. The RetryCodes then hold the codes which do
+	// not cancel the outstanding copies.
+	Hedging      bool
+	HedgingDelay time.Duration
+	// DisableRetry disables all retries, including the transparent retries
+	// of calls which never left the client.
+	DisableRetry bool
+	// ServiceConfig optionally holds a JSON service config, overriding the
+	// one derived from the retry settings.
+	ServiceConfig string
+
+	// WaitForReady makes PreRun wait for the connection to become ready.
+	WaitForReady        bool
+	WaitForReadyTimeout time.Duration
+
+	DialOptions []grpc.DialOption
+
+	i  Interceptors
+	cc *grpc.ClientConn
+}
+
+func (c *Client) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Client) Name() string {
+	return c.prefix("grpc-client")
+}
+
+// FlagSet implements run.Config.
+func (c *Client) FlagSet() *run.FlagSet {
+	if c.MaxMsgSize == 0 {
+		c.MaxMsgSize = defaultMaxGRPCStreamMsgSize
+	}
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = defaultClientMaxAttempts
+	}
+	if len(c.RetryCodes) == 0 {
+		c.RetryCodes = []string{defaultClientRetryCodes}
+	}
+	if c.RetryInitialBackoff == 0 {
+		c.RetryInitialBackoff = defaultClientInitialBackoff
+	}
+	if c.RetryMaxBackoff == 0 {
+		c.RetryMaxBackoff = defaultClientMaxBackoff
+	}
+	if c.RetryBackoffMultiplier == 0 {
+		c.RetryBackoffMultiplier = defaultClientBackoffMultiplier
+	}
+	if c.WaitForReadyTimeout == 0 {
+		c.WaitForReadyTimeout = defaultClientWaitForReady
+	}
+
+	flags := run.NewFlagSet("gRPC client options")
+
+	flags.StringVar(&c.Target, c.prefix(ClientTarget), c.Target,
+		`Target of the gRPC server, e.g. "dns:///orders:9080" or "unix:///run/grpc.sock"`)
+
+	flags.StringVar(&c.Mode, c.prefix(ClientMode), c.Mode,
+		`Client mode: "default" or "xds" (proxyless service mesh using the xDS bootstrap config)`)
+
+	flags.StringVar(&c.LBPolicy, c.prefix(ClientLBPolicy), c.LBPolicy,
+		`Load balancing policy: "pick_first" (default) or "round_robin"`)
+
+	flags.StringSliceVar(&c.Endpoints, c.prefix(ClientEndpoints), c.Endpoints,
+		"Static list of server addresses (host:port) to balance over instead of resolving the target")
+
+	flags.StringVar(&c.DNSServer, c.prefix(ClientDNSServer), c.DNSServer,
+		`DNS server to resolve the target with, e.g. "10.0.0.2:53" (system resolver if empty)`)
+
+	flags.StringVar(&c.Authority, c.prefix(ClientAuthority), c.Authority,
+		"Authority (:authority header) to use instead of the one derived from the target")
+
+	flags.BoolVar(&c.TLS, c.prefix(ClientTLS), c.TLS,
+		"Connect using TLS (implied if any TLS file is provided)")
+
+	flags.StringVar(&c.TLSCAFile, c.prefix(ClientTLSCAFile), c.TLSCAFile,
+		"CA bundle to verify the server certificate with (system roots if empty)")
+
+	flags.StringVar(&c.TLSCertFile, c.prefix(ClientTLSCertFile), c.TLSCertFile,
+		"Client certificate file for mutual TLS")
+
+	flags.StringVar(&c.TLSKeyFile, c.prefix(ClientTLSKeyFile), c.TLSKeyFile,
+		"Client certificate key file for mutual TLS")
+
+	flags.StringVar(&c.TLSServerName, c.prefix(ClientTLSServerName), c.TLSServerName,
+		"Server name to verify the server certificate against")
+
+	flags.DurationVar(&c.KeepaliveTime, c.prefix(ClientKeepaliveTime), c.KeepaliveTime,
+		"Idle time after which the client pings the server (0 to disable)")
+
+	flags.DurationVar(&c.KeepaliveTimeout, c.prefix(ClientKeepaliveTimeout), c.KeepaliveTimeout,
+		"Time to wait for a ping response before closing the connection (0 for the default of 20s)")
+
+	flags.BoolVar(&c.KeepalivePermitWithoutStream, c.prefix(ClientKeepaliveNoStream),
+		c.KeepalivePermitWithoutStream, "Send pings on connections without active streams")
+
+	flags.IntVar(&c.MaxMsgSize, c.prefix(ClientMaxMsgSize), c.MaxMsgSize,
+		"Max. size of messages sent and received in bytes")
+
+	flags.IntVar(&c.MaxAttempts, c.prefix(ClientMaxAttempts), c.MaxAttempts,
+		"Max. attempts of a call including the original one (1 disables retries)")
+
+	flags.StringSliceVar(&c.RetryCodes, c.prefix(ClientRetryCodes), c.RetryCodes,
+		"Status codes on which calls are retried")
+
+	flags.DurationVar(&c.RetryInitialBackoff, c.prefix(ClientRetryInitialBackoff),
+		c.RetryInitialBackoff, "Max. backoff before the first retry")
+
+	flags.DurationVar(&c.RetryMaxBackoff, c.prefix(ClientRetryMaxBackoff),
+		c.RetryMaxBackoff, "Upper bound of the backoff between retries")
+
+	flags.Float64Var(&c.RetryBackoffMultiplier, c.prefix(ClientRetryBackoffMultiplier),
+		c.RetryBackoffMultiplier, "Multiplier applied to the backoff after each retry")
+
+	flags.BoolVar(&c.Hedging, c.prefix(ClientHedging), c.Hedging,
+		"Hedge unary calls by sending up to max. attempts copies instead of retrying")
+
+	flags.DurationVar(&c.HedgingDelay, c.prefix(ClientHedgingDelay), c.HedgingDelay,
+		"Delay between hedged copies of a call (0 sends all at once)")
+
+	flags.BoolVar(&c.DisableRetry, c.prefix(ClientDisableRetry), c.DisableRetry,
+		"Disable all retries, including transparent retries")
+
+	flags.StringVar(&c.ServiceConfig, c.prefix(ClientServiceConfig), c.ServiceConfig,
+		"JSON service config (overrides the retry settings)")
+
+	flags.BoolVar(&c.WaitForReady, c.prefix(ClientWaitForReady), c.WaitForReady,
+		"Wait for the connection to become ready on startup")
+
+	flags.DurationVar(&c.WaitForReadyTimeout, c.prefix(ClientWaitForReadyTimeout),
+		c.WaitForReadyTimeout, "Max. time to wait for the connection to become ready")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Client) Validate() error {
+	var mErr error
+
+	if c.Target == "" && len(c.Endpoints) == 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientTarget), flag.ErrRequired))
+	}
+
+	if err := c.validateResolver(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientTLSKeyFile),
+				flag.ValidationError("client certificate and key must be provided together")))
+	}
+
+	if c.TLSCAFile != "" {
+		if _, err := loadCertPool(c.TLSCAFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(ClientTLSCAFile), err))
+		}
+	}
+
+	for _, t := range []struct {
+		flag string
+		d    time.Duration
+	}{
+		{ClientKeepaliveTime, c.KeepaliveTime},
+		{ClientKeepaliveTimeout, c.KeepaliveTimeout},
+		{ClientWaitForReadyTimeout, c.WaitForReadyTimeout},
+	} {
+		if t.d < 0 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(t.flag), flag.ErrInvalidVal))
+		}
+	}
+
+	if c.MaxMsgSize <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientMaxMsgSize), flag.ErrInvalidVal))
+	}
+
+	if err := c.validateRetry(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if c.ServiceConfig != "" && !json.Valid([]byte(c.ServiceConfig)) {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientServiceConfig),
+				flag.ValidationError("invalid JSON")))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (c *Client) PreRun() error {
+	opts, err := c.dialOptions()
+	if err != nil {
+		return err
+	}
+
+	target, resolverOpts := c.target()
+	opts = append(opts, resolverOpts...)
+	if c.cc, err = grpc.NewClient(target, opts...); err != nil {
+		return fmt.Errorf("unable to create gRPC client for %q: %w", target, err)
+	}
+
+	if c.WaitForReady {
+		ctx, cancel := context.WithTimeout(context.Background(), c.WaitForReadyTimeout)
+		defer cancel()
+		if err = waitForReady(ctx, c.cc); err != nil {
+			_ = c.cc.Close()
+			return fmt.Errorf("gRPC connection to %q not ready: %w", c.Target, err)
+		}
+	}
+
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. It closes the connection once
+// the context is canceled.
+func (c *Client) ServeContext(ctx context.Context) error {
+	<-ctx.Done()
+	return c.cc.Close()
+}
+
+// Conn returns the client connection.
+func (c *Client) Conn() *grpc.ClientConn {
+	return c.cc
+}
+
+// Interceptors returns the Interceptors handler for this gRPC Client. Add
+// interceptors before PreRun.
+func (c *Client) Interceptors() *Interceptors {
+	return &c.i
+}
+
+func (c *Client) dialOptions() ([]grpc.DialOption, error) {
+	creds := insecure.NewCredentials()
+	if c.TLS || c.TLSCAFile != "" || c.TLSCertFile != "" {
+		cfg, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(cfg)
+	}
+	if c.Mode == ModeXDS {
+		var err error
+		if creds, err = xdsClientCreds(creds); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(c.MaxMsgSize),
+			grpc.MaxCallSendMsgSize(c.MaxMsgSize),
+		),
+	}
+	if c.Authority != "" {
+		opts = append(opts, grpc.WithAuthority(c.Authority))
+	}
+	if c.DisableRetry {
+		opts = append(opts, grpc.WithDisableRetry())
+	}
+	if c.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                c.KeepaliveTime,
+			Timeout:             c.KeepaliveTimeout,
+			PermitWithoutStream: c.KeepalivePermitWithoutStream,
+		}))
+	}
+
+	sc, err := c.serviceConfig()
+	if err != nil {
+		return nil, err
+	}
+	if sc != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(sc))
+	}
+
+	opts = append(opts, c.i.GetDialOptions()...)
+	if c.Hedging && c.MaxAttempts > 1 && !c.DisableRetry {
+		// chained after the Interceptors, which see a hedged call once
+		h, err := c.hedgingInterceptor()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithChainUnaryInterceptor(h))
+	}
+	return append(opts, c.DialOptions...), nil
+}
+
+func (c *Client) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName: c.TLSServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if c.TLSCAFile != "" {
+		pool, err := loadCertPool(c.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if c.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// serviceConfig returns the configured service config or the one holding
+// the load balancing and retry policies if set.
+func (c *Client) serviceConfig() (string, error) {
+	if c.ServiceConfig != "" {
+		return c.ServiceConfig, nil
+	}
+
+	sc := make(map[string]any)
+	if c.LBPolicy != "" {
+		sc["loadBalancingConfig"] = []any{map[string]any{c.LBPolicy: map[string]any{}}}
+	}
+	mc, err := c.methodConfig()
+	if err != nil {
+		return "", err
+	}
+	if mc != nil {
+		sc["methodConfig"] = []any{mc}
+	}
+	if len(sc) == 0 {
+		return "", nil
+	}
+
+	b, err := json.Marshal(sc)
+	return string(b), err
+}
+
+// parseCodes normalizes a list of status code names, e.g. "unavailable" or
+// "RESOURCE_EXHAUSTED", to their service config representation.
+func parseCodes(names []string) ([]string, error) {
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		var code codes.Code
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if err := code.UnmarshalJSON([]byte(`"` + name + `"`)); err != nil {
+			return nil, fmt.Errorf("unknown status code %q", name)
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+// waitForReady blocks until the connection is ready or the context expires.
+func waitForReady(ctx context.Context, cc *grpc.ClientConn) error {
+	cc.Connect()
+	for {
+		state := cc.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if state == connectivity.Shutdown {
+			return errors.New("connection is shut down")
+		}
+		if !cc.WaitForStateChange(ctx, state) {
+			return ctx.Err()
+		}
+	}
+}
+
+var (
+	_ run.Config         = (*Client)(nil)
+	_ run.PreRunner      = (*Client)(nil)
+	_ run.ServiceContext = (*Client)(nil)
+)
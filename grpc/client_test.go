@@ -0,0 +1,142 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyService returns a test service failing with codes.Unavailable until
+// it has been called n times.
+func flakyService(n int32, calls *atomic.Int32) *Service {
+	s := &Service{}
+	s.Attach(echoService(func(context.Context) error {
+		if calls.Add(1) < n {
+			return status.Error(codes.Unavailable, "try again")
+		}
+		return nil
+	}))
+	return s
+}
+
+func newClient(t *testing.T, c *Client) {
+	c.FlagSet()
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		_ = c.ServeContext(ctx)
+	})
+}
+
+func TestClientRetry(t *testing.T) {
+	var calls atomic.Int32
+	s := flakyService(3, &calls)
+	serve(t, s)
+	target, _ := s.GetGrpcAddress()
+
+	c := &Client{
+		Target:              target,
+		MaxAttempts:         3,
+		RetryInitialBackoff: time.Millisecond,
+		RetryMaxBackoff:     time.Millisecond,
+	}
+	newClient(t, c)
+
+	if err := call(context.Background(), c.Conn()); err != nil {
+		t.Fatalf("expected call to succeed after retries, got %v", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+}
+
+func TestClientHedging(t *testing.T) {
+	var calls atomic.Int32
+	s := flakyService(2, &calls)
+	serve(t, s)
+	target, _ := s.GetGrpcAddress()
+
+	c := &Client{
+		Target:       target,
+		MaxAttempts:  2,
+		Hedging:      true,
+		HedgingDelay: time.Second,
+	}
+	newClient(t, c)
+
+	if err := call(context.Background(), c.Conn()); err != nil {
+		t.Fatalf("expected hedged call to succeed, got %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected 2 attempts, got %d", n)
+	}
+}
+
+func TestClientStaticEndpoints(t *testing.T) {
+	c := &Client{
+		Target:    "backend",
+		Endpoints: []string{"10.0.0.1:9080", "10.0.0.2:9080"},
+		LBPolicy:  LBPolicyRoundRobin,
+	}
+	c.FlagSet()
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	target, opts := c.target()
+	if target != staticScheme+":///backend" || len(opts) != 1 {
+		t.Errorf("expected static resolver target, got %q", target)
+	}
+}
+
+func TestClientValidate(t *testing.T) {
+	for name, test := range map[string]struct {
+		c    *Client
+		flag string
+	}{
+		"no target":          {&Client{}, ClientTarget},
+		"invalid retry code": {&Client{Target: "x:1", RetryCodes: []string{"NOPE"}}, ClientRetryCodes},
+		"retry disabled": {
+			&Client{Target: "x:1", MaxAttempts: 3, DisableRetry: true},
+			ClientDisableRetry,
+		},
+		"unknown LB policy": {&Client{Target: "x:1", LBPolicy: "random"}, ClientLBPolicy},
+		"invalid endpoint":  {&Client{Target: "x", Endpoints: []string{"x"}}, ClientEndpoints},
+		"DNS server with endpoints": {
+			&Client{Target: "x", Endpoints: []string{"x:1"}, DNSServer: "8.8.8.8:53"},
+			ClientDNSServer,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			test.c.FlagSet()
+			err := test.c.Validate()
+			if err == nil || !strings.Contains(err.Error(), "--"+test.flag+" error") {
+				t.Errorf("expected error for %s, got %v", test.flag, err)
+			}
+		})
+	}
+}
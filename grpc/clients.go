@@ -0,0 +1,381 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// clients flags.
+const (
+	ClientsTargets             = "grpc-clients"
+	ClientsTLS                 = "grpc-clients-tls"
+	ClientsTLSCAFile           = "grpc-clients-tls-ca-file"
+	ClientsTLSCertFile         = "grpc-clients-tls-cert-file"
+	ClientsTLSKeyFile          = "grpc-clients-tls-key-file"
+	ClientsKeepaliveTime       = "grpc-clients-keepalive-time"
+	ClientsKeepaliveTimeout    = "grpc-clients-keepalive-timeout"
+	ClientsMaxMsgSize          = "grpc-clients-max-msg-size"
+	ClientsMaxAttempts         = "grpc-clients-max-attempts"
+	ClientsRetryCodes          = "grpc-clients-retry-codes"
+	ClientsLBPolicy            = "grpc-clients-lb-policy"
+	ClientsWaitForReady        = "grpc-clients-wait-for-ready"
+	ClientsWaitForReadyTimeout = "grpc-clients-wait-for-ready-timeout"
+)
+
+// Clients implements a run.Group compatible registry of named gRPC client
+// connections sharing TLS, keepalive, retry and interceptor settings. The
+// connections are created in PreRun, monitored for connectivity changes and
+// closed on shutdown.
+//
+// Targets are declared as name=target pairs, e.g.:
+//
+//	--grpc-clients orders=dns:///orders:9080,users=dns:///users:9080
+type Clients struct {
+	Prefix string
+	// Targets holds the targets by client name.
+	Targets map[string]string
+
+	TLS         bool
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+
+	MaxMsgSize  int
+	MaxAttempts int
+	RetryCodes  []string
+	LBPolicy    string
+
+	// WaitForReady makes PreRun wait for all connections to become ready.
+	WaitForReady        bool
+	WaitForReadyTimeout time.Duration
+
+	DialOptions []grpc.DialOption
+	// Configure is optionally called for each Client before validation to
+	// adjust settings of individual targets, e.g. the Authority.
+	Configure func(name string, c *Client)
+
+	i       Interceptors
+	mu      sync.RWMutex
+	clients map[string]*Client
+}
+
+func (c *Clients) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Clients) Name() string {
+	return c.prefix("grpc-clients")
+}
+
+// FlagSet implements run.Config.
+func (c *Clients) FlagSet() *run.FlagSet {
+	if c.MaxMsgSize == 0 {
+		c.MaxMsgSize = defaultMaxGRPCStreamMsgSize
+	}
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = defaultClientMaxAttempts
+	}
+	if len(c.RetryCodes) == 0 {
+		c.RetryCodes = []string{defaultClientRetryCodes}
+	}
+	if c.WaitForReadyTimeout == 0 {
+		c.WaitForReadyTimeout = defaultClientWaitForReady
+	}
+
+	flags := run.NewFlagSet("gRPC clients options")
+
+	flags.StringToStringVar(&c.Targets, c.prefix(ClientsTargets), c.Targets,
+		`Named gRPC client targets, e.g. "orders=dns:///orders:9080"`)
+
+	flags.StringVar(&c.LBPolicy, c.prefix(ClientsLBPolicy), c.LBPolicy,
+		`Load balancing policy: "pick_first" (default) or "round_robin"`)
+
+	flags.BoolVar(&c.TLS, c.prefix(ClientsTLS), c.TLS,
+		"Connect using TLS (implied if any TLS file is provided)")
+
+	flags.StringVar(&c.TLSCAFile, c.prefix(ClientsTLSCAFile), c.TLSCAFile,
+		"CA bundle to verify the server certificates with (system roots if empty)")
+
+	flags.StringVar(&c.TLSCertFile, c.prefix(ClientsTLSCertFile), c.TLSCertFile,
+		"Client certificate file for mutual TLS")
+
+	flags.StringVar(&c.TLSKeyFile, c.prefix(ClientsTLSKeyFile), c.TLSKeyFile,
+		"Client certificate key file for mutual TLS")
+
+	flags.DurationVar(&c.KeepaliveTime, c.prefix(ClientsKeepaliveTime), c.KeepaliveTime,
+		"Idle time after which the clients ping the server (0 to disable)")
+
+	flags.DurationVar(&c.KeepaliveTimeout, c.prefix(ClientsKeepaliveTimeout), c.KeepaliveTimeout,
+		"Time to wait for a ping response before closing the connection (0 for the default of 20s)")
+
+	flags.IntVar(&c.MaxMsgSize, c.prefix(ClientsMaxMsgSize), c.MaxMsgSize,
+		"Max. size of messages sent and received in bytes")
+
+	flags.IntVar(&c.MaxAttempts, c.prefix(ClientsMaxAttempts), c.MaxAttempts,
+		"Max. attempts of a call including the original one (1 disables retries)")
+
+	flags.StringSliceVar(&c.RetryCodes, c.prefix(ClientsRetryCodes), c.RetryCodes,
+		"Status codes on which calls are retried")
+
+	flags.BoolVar(&c.WaitForReady, c.prefix(ClientsWaitForReady), c.WaitForReady,
+		"Wait for the connections to become ready on startup")
+
+	flags.DurationVar(&c.WaitForReadyTimeout, c.prefix(ClientsWaitForReadyTimeout),
+		c.WaitForReadyTimeout, "Max. time to wait for the connections to become ready")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Clients) Validate() error {
+	var mErr error
+
+	for name, target := range c.Targets {
+		if name == "" || target == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(ClientsTargets),
+					flag.ValidationError(`expected "name=target" pairs`)))
+			break
+		}
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientsTLSKeyFile),
+				flag.ValidationError("client certificate and key must be provided together")))
+	}
+	if c.TLSCAFile != "" {
+		if _, err := loadCertPool(c.TLSCAFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(ClientsTLSCAFile), err))
+		}
+	}
+
+	for _, t := range []struct {
+		flag string
+		d    time.Duration
+	}{
+		{ClientsKeepaliveTime, c.KeepaliveTime},
+		{ClientsKeepaliveTimeout, c.KeepaliveTimeout},
+		{ClientsWaitForReadyTimeout, c.WaitForReadyTimeout},
+	} {
+		if t.d < 0 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(t.flag), flag.ErrInvalidVal))
+		}
+	}
+
+	if c.MaxMsgSize <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientsMaxMsgSize), flag.ErrInvalidVal))
+	}
+	if c.MaxAttempts < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientsMaxAttempts), flag.ErrInvalidVal))
+	}
+	if _, err := parseCodes(c.RetryCodes); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientsRetryCodes), err))
+	}
+	if !validLBPolicy(c.LBPolicy) {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientsLBPolicy), flag.ErrInvalidVal))
+	}
+
+	if mErr != nil {
+		return mErr
+	}
+
+	// validate the individual clients, which may have been adjusted by
+	// Configure
+	clients := make(map[string]*Client, len(c.Targets))
+	for name, target := range c.Targets {
+		cl := c.newClient(name, target)
+		if err := cl.Validate(); err != nil {
+			mErr = multierror.Append(mErr, err)
+		}
+		clients[name] = cl
+	}
+
+	c.mu.Lock()
+	c.clients = clients
+	c.mu.Unlock()
+
+	return mErr
+}
+
+// newClient returns a Client for the target holding the shared settings.
+func (c *Clients) newClient(name, target string) *Client {
+	cl := &Client{
+		Prefix:              c.prefix(name),
+		Target:              target,
+		LBPolicy:            c.LBPolicy,
+		TLS:                 c.TLS,
+		TLSCAFile:           c.TLSCAFile,
+		TLSCertFile:         c.TLSCertFile,
+		TLSKeyFile:          c.TLSKeyFile,
+		KeepaliveTime:       c.KeepaliveTime,
+		KeepaliveTimeout:    c.KeepaliveTimeout,
+		MaxMsgSize:          c.MaxMsgSize,
+		MaxAttempts:         c.MaxAttempts,
+		RetryCodes:          c.RetryCodes,
+		WaitForReady:        c.WaitForReady,
+		WaitForReadyTimeout: c.WaitForReadyTimeout,
+		DialOptions:         c.DialOptions,
+
+		RetryInitialBackoff:    defaultClientInitialBackoff,
+		RetryMaxBackoff:        defaultClientMaxBackoff,
+		RetryBackoffMultiplier: defaultClientBackoffMultiplier,
+	}
+	if c.Configure != nil {
+		c.Configure(name, cl)
+	}
+	return cl
+}
+
+// PreRun implements run.PreRunner.
+func (c *Clients) PreRun() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := c.names()
+	for idx, name := range names {
+		cl := c.clients[name]
+		cl.i = c.i
+		if err := cl.PreRun(); err != nil {
+			for _, opened := range names[:idx] {
+				_ = c.clients[opened].cc.Close()
+			}
+			return fmt.Errorf("gRPC client %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. It logs connectivity changes
+// of the connections and closes them once the context is canceled.
+func (c *Clients) ServeContext(ctx context.Context) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for name, cl := range c.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			monitor(ctx, name, cl.cc)
+		}()
+	}
+	<-ctx.Done()
+	wg.Wait()
+
+	var mErr error
+	for _, cl := range c.clients {
+		if err := cl.cc.Close(); err != nil {
+			mErr = multierror.Append(mErr, err)
+		}
+	}
+	return mErr
+}
+
+// monitor logs the connectivity state changes of the connection until the
+// context is canceled.
+func monitor(ctx context.Context, name string, cc *grpc.ClientConn) {
+	state := cc.GetState()
+	for cc.WaitForStateChange(ctx, state) {
+		prev := state
+		state = cc.GetState()
+		switch state {
+		case connectivity.TransientFailure:
+			log.Info("gRPC client connection failing", "client", name, "target", cc.Target())
+		case connectivity.Ready:
+			log.Info("gRPC client connection ready", "client", name, "target", cc.Target())
+		default:
+			log.Debug("gRPC client connection state changed", "client", name,
+				"from", prev.String(), "to", state.String())
+		}
+	}
+}
+
+// Get returns the client connection by name, or nil if not found.
+func (c *Clients) Get(name string) *grpc.ClientConn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if cl, ok := c.clients[name]; ok {
+		return cl.cc
+	}
+	return nil
+}
+
+// Client returns the managed Client by name, or nil if not found. It can be
+// used with the DependencyWatcher.
+func (c *Clients) Client(name string) *Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.clients[name]
+}
+
+// State returns the connectivity state of the client connections by name.
+func (c *Clients) State() map[string]connectivity.State {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	states := make(map[string]connectivity.State, len(c.clients))
+	for name, cl := range c.clients {
+		if cl.cc != nil {
+			states[name] = cl.cc.GetState()
+		}
+	}
+	return states
+}
+
+// Interceptors returns the Interceptors handler shared by all clients. Add
+// interceptors before PreRun.
+func (c *Clients) Interceptors() *Interceptors {
+	return &c.i
+}
+
+func (c *Clients) names() []string {
+	names := make([]string, 0, len(c.clients))
+	for name := range c.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	_ run.Config         = (*Clients)(nil)
+	_ run.PreRunner      = (*Clients)(nil)
+	_ run.ServiceContext = (*Clients)(nil)
+)
@@ -0,0 +1,123 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// Supported compressors.
+const (
+	CompressionNone = "none"
+	CompressionGzip = gzip.Name
+	CompressionZstd = "zstd"
+)
+
+var registerZstd sync.Once
+
+// registerCompressors makes the supported compressors available to servers
+// and clients. Servers respond with the compressor used by the client.
+func registerCompressors() {
+	// gzip is registered by importing its package
+	registerZstd.Do(func() {
+		encoding.RegisterCompressor(&zstdCompressor{})
+	})
+}
+
+// validCompression returns true if name holds a supported compressor.
+func validCompression(name string) bool {
+	switch name {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+		return true
+	}
+	return false
+}
+
+// zstdCompressor implements encoding.Compressor using pooled zstd encoders
+// and decoders.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+// Name implements encoding.Compressor.
+func (z *zstdCompressor) Name() string {
+	return CompressionZstd
+}
+
+// Compress implements encoding.Compressor.
+func (z *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	enc, ok := z.encoders.Get().(*zstd.Encoder)
+	if !ok {
+		var err error
+		if enc, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	}
+	enc.Reset(w)
+	return &zstdWriter{Encoder: enc, pool: &z.encoders}, nil
+}
+
+// Decompress implements encoding.Compressor.
+func (z *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, ok := z.decoders.Get().(*zstd.Decoder)
+	if !ok {
+		var err error
+		if dec, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	}
+	if err := dec.Reset(r); err != nil {
+		z.decoders.Put(dec)
+		return nil, err
+	}
+	return &zstdReader{Decoder: dec, pool: &z.decoders}, nil
+}
+
+// zstdWriter returns its encoder to the pool once closed.
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+	return err
+}
+
+// zstdReader returns its decoder to the pool once fully read.
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.Decoder == nil {
+		return 0, io.EOF
+	}
+	n, err := r.Decoder.Read(p)
+	if errors.Is(err, io.EOF) {
+		r.pool.Put(r.Decoder)
+		r.Decoder = nil
+	}
+	return n, err
+}
@@ -0,0 +1,73 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestZstdCompressor(t *testing.T) {
+	var (
+		z    zstdCompressor
+		buf  bytes.Buffer
+		data = bytes.Repeat([]byte("run-handlers "), 1024)
+	)
+	w, err := z.Compress(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() >= len(data) {
+		t.Errorf("expected compressed size below %d, got %d", len(data), buf.Len())
+	}
+
+	r, err := z.Decompress(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("expected decompressed data to match")
+	}
+}
+
+func TestCompressedCalls(t *testing.T) {
+	for _, name := range []string{CompressionGzip, CompressionZstd} {
+		t.Run(name, func(t *testing.T) {
+			s := &Service{Compression: name}
+			s.Attach(echoService(func(context.Context) error { return nil }))
+			conn := serve(t, s)
+
+			if err := conn.Invoke(context.Background(), testMethod, new(emptypb.Empty), new(emptypb.Empty),
+				grpc.UseCompressor(name)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
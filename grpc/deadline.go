@@ -0,0 +1,157 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// parseMethodTimeouts parses a list of "method=timeout" pairs where method
+// holds a full method ("/package.Service/Method") or service
+// ("package.Service").
+func parseMethodTimeouts(pairs []string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(pairs))
+	for _, pair := range pairs {
+		method, timeout, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || method == "" {
+			return nil, errors.New(`expected "method=timeout" pairs`)
+		}
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return nil, errors.New("invalid timeout for " + method)
+		}
+		timeouts[strings.TrimPrefix(method, "/")] = d
+	}
+	return timeouts, nil
+}
+
+// validateDeadlines checks the deadline enforcement settings.
+func (s *Service) validateDeadlines() error {
+	var mErr error
+
+	if s.DefaultTimeout < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(DefaultTimeout, flag.ErrInvalidVal))
+	}
+	if s.MaxTimeout < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(MaxTimeout, flag.ErrInvalidVal))
+	}
+	if _, err := parseMethodTimeouts(s.MethodTimeouts); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(MethodTimeouts, err))
+	}
+
+	return mErr
+}
+
+// deadlines holds the deadline enforcement interceptors.
+type deadlines struct {
+	defaultTimeout time.Duration
+	methods        map[string]time.Duration
+	maxTimeout     time.Duration
+}
+
+// timeout returns the timeout to apply to calls without deadline.
+func (d *deadlines) timeout(fullMethod string) time.Duration {
+	method := strings.TrimPrefix(fullMethod, "/")
+	if t, ok := d.methods[method]; ok {
+		return t
+	}
+	svc, _, _ := strings.Cut(method, "/")
+	if t, ok := d.methods[svc]; ok {
+		return t
+	}
+	return d.defaultTimeout
+}
+
+// context returns the context of the call with the default timeout applied
+// if the client did not send a deadline. Deadlines exceeding the max.
+// timeout are rejected.
+func (d *deadlines) context(ctx context.Context, fullMethod string) (context.Context, context.CancelFunc, error) {
+	deadline, ok := ctx.Deadline()
+	if ok {
+		if d.maxTimeout > 0 && time.Until(deadline) > d.maxTimeout {
+			return nil, nil, status.Errorf(codes.InvalidArgument,
+				"deadline exceeds the max. allowed timeout of %s", d.maxTimeout)
+		}
+		return ctx, func() {}, nil
+	}
+	if t := d.timeout(fullMethod); t > 0 {
+		ctx, cancel := context.WithTimeout(ctx, t)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// UnaryServerInterceptor returns the unary deadline enforcement interceptor.
+func (d *deadlines) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, cancel, err := d.context(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the stream deadline enforcement
+// interceptor.
+func (d *deadlines) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, cancel, err := d.context(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		ws := grpcmiddleware.WrapServerStream(ss)
+		ws.WrappedContext = ctx
+		return handler(srv, ws)
+	}
+}
+
+// addDeadlines registers the deadline enforcement interceptors if any
+// timeout is configured.
+func (s *Service) addDeadlines() {
+	methods, _ := parseMethodTimeouts(s.MethodTimeouts)
+	if s.DefaultTimeout <= 0 && s.MaxTimeout <= 0 && len(methods) == 0 {
+		return
+	}
+	d := &deadlines{
+		defaultTimeout: s.DefaultTimeout,
+		methods:        methods,
+		maxTimeout:     s.MaxTimeout,
+	}
+	s.i.AddUnaryServerPhase(PhaseLimits, d.UnaryServerInterceptor())
+	s.i.AddStreamServerPhase(PhaseLimits, d.StreamServerInterceptor())
+}
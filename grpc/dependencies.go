@@ -0,0 +1,241 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/basvanbeek/run"
+)
+
+// dependencyRetryInterval holds the time to wait before restarting a failed
+// health watch.
+const dependencyRetryInterval = time.Second
+
+// Dependency describes a critical gRPC dependency watched by the
+// DependencyWatcher.
+type Dependency struct {
+	// Client holds the managed client connection of the dependency.
+	Client *Client
+	// HealthCheck additionally watches the grpc.health.v1.Health status of
+	// HealthService (empty for the server as a whole) on the dependency.
+	HealthCheck   bool
+	HealthService string
+}
+
+// DependencyWatcher implements a run.Group compatible unit watching the
+// connectivity state and optionally the health status of critical gRPC
+// dependencies. The Service is ready once all dependencies are healthy.
+type DependencyWatcher struct {
+	Dependencies []Dependency
+	// Service optionally holds the gRPC Service whose overall health status
+	// reflects the readiness.
+	Service *Service
+	// OnChange is optionally called when the readiness changes.
+	OnChange func(ready bool)
+
+	mu      sync.RWMutex
+	healthy map[string]bool
+	ready   bool
+}
+
+type dependencyState struct {
+	name    string
+	healthy bool
+}
+
+// Name implements run.Unit.
+func (w *DependencyWatcher) Name() string {
+	return "grpc-dependencies"
+}
+
+// Validate implements run.Config.
+func (w *DependencyWatcher) Validate() error {
+	for _, d := range w.Dependencies {
+		if d.Client == nil {
+			return errors.New("dependency without client")
+		}
+	}
+	return nil
+}
+
+// FlagSet implements run.Config.
+func (w *DependencyWatcher) FlagSet() *run.FlagSet {
+	return nil
+}
+
+// Ready returns true if all dependencies are healthy.
+func (w *DependencyWatcher) Ready() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.ready
+}
+
+// Status returns the health of the dependencies by name.
+func (w *DependencyWatcher) Status() map[string]bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	status := make(map[string]bool, len(w.healthy))
+	for name, healthy := range w.healthy {
+		status[name] = healthy
+	}
+	return status
+}
+
+// ServeContext implements run.ServiceContext.
+func (w *DependencyWatcher) ServeContext(ctx context.Context) error {
+	w.mu.Lock()
+	w.healthy = make(map[string]bool, len(w.Dependencies))
+	for _, d := range w.Dependencies {
+		w.healthy[d.Client.Name()] = false
+	}
+	w.mu.Unlock()
+	w.update(nil)
+
+	states := make(chan dependencyState)
+	for _, d := range w.Dependencies {
+		go d.watch(ctx, states)
+	}
+
+	for {
+		select {
+		case s := <-states:
+			w.update(&s)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// update records the dependency state and reports readiness changes.
+func (w *DependencyWatcher) update(s *dependencyState) {
+	w.mu.Lock()
+	if s != nil {
+		if w.healthy[s.name] != s.healthy {
+			log.Info("gRPC dependency health changed",
+				"dependency", s.name, "healthy", s.healthy)
+		}
+		w.healthy[s.name] = s.healthy
+	}
+	ready := true
+	for _, healthy := range w.healthy {
+		ready = ready && healthy
+	}
+	changed := ready != w.ready || s == nil
+	w.ready = ready
+	w.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if w.Service != nil {
+		w.Service.SetServing("", ready)
+	}
+	if w.OnChange != nil {
+		w.OnChange(ready)
+	}
+}
+
+// watch reports the health of the dependency until the context is canceled.
+// Without health check it is healthy while the connection is ready.
+func (d Dependency) watch(ctx context.Context, states chan<- dependencyState) {
+	var (
+		mu            sync.Mutex
+		connReady     bool
+		serving       = !d.HealthCheck
+		name          = d.Client.Name()
+		reportHealthy = func() {
+			mu.Lock()
+			healthy := connReady && serving
+			mu.Unlock()
+			select {
+			case states <- dependencyState{name: name, healthy: healthy}:
+			case <-ctx.Done():
+			}
+		}
+	)
+
+	if d.HealthCheck {
+		go d.watchHealth(ctx, func(ok bool) {
+			mu.Lock()
+			serving = ok
+			mu.Unlock()
+			reportHealthy()
+		})
+	}
+
+	cc := d.Client.Conn()
+	for {
+		state := cc.GetState()
+		if state == connectivity.Idle {
+			// keep the connection to critical dependencies up
+			cc.Connect()
+		}
+		mu.Lock()
+		connReady = state == connectivity.Ready
+		mu.Unlock()
+		reportHealthy()
+		if !cc.WaitForStateChange(ctx, state) {
+			return
+		}
+	}
+}
+
+// watchHealth streams the health status of the dependency, restarting the
+// watch if it fails.
+func (d Dependency) watchHealth(ctx context.Context, report func(serving bool)) {
+	client := healthpb.NewHealthClient(d.Client.Conn())
+	for {
+		err := d.streamHealth(ctx, client, report)
+		if ctx.Err() != nil {
+			return
+		}
+		report(false)
+		log.Debug("gRPC dependency health watch failed",
+			"dependency", d.Client.Name(), "error", err.Error())
+		select {
+		case <-time.After(dependencyRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d Dependency) streamHealth(
+	ctx context.Context, client healthpb.HealthClient, report func(bool),
+) error {
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: d.HealthService})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		report(resp.GetStatus() == healthpb.HealthCheckResponse_SERVING)
+	}
+}
+
+var (
+	_ run.Config         = (*DependencyWatcher)(nil)
+	_ run.ServiceContext = (*DependencyWatcher)(nil)
+)
@@ -0,0 +1,123 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/stats"
+)
+
+// muxDrainInterval holds the interval at which active RPCs are checked while
+// shutting down the gRPC server serving on the HTTP listener.
+const muxDrainInterval = 10 * time.Millisecond
+
+// activeRPCs implements a stats.Handler counting the RPCs being handled, so
+// unfinished RPCs can be reported on shutdown.
+type activeRPCs struct {
+	n atomic.Int64
+}
+
+// TagRPC implements stats.Handler.
+func (a *activeRPCs) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC implements stats.Handler.
+func (a *activeRPCs) HandleRPC(_ context.Context, rs stats.RPCStats) {
+	switch rs.(type) {
+	case *stats.Begin:
+		a.n.Add(1)
+	case *stats.End:
+		a.n.Add(-1)
+	}
+}
+
+// TagConn implements stats.Handler.
+func (a *activeRPCs) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn implements stats.Handler.
+func (a *activeRPCs) HandleConn(context.Context, stats.ConnStats) {}
+
+// ActiveRPCs returns the number of RPCs currently being handled.
+func (s *Service) ActiveRPCs() int64 {
+	return s.active.n.Load()
+}
+
+// shutdown gracefully stops the server, waiting for active RPCs to finish
+// until the shutdown timeout expires. RPCs still active at that point are
+// reported and forcefully cancelled.
+func (s *Service) shutdown() {
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-done:
+		log.Info("graceful shutdown completed")
+	case <-t.C:
+		log.Error("graceful shutdown did not complete", context.DeadlineExceeded,
+			"timeout", timeout, "active_rpcs", s.active.n.Load())
+		s.srv.Stop()
+		<-done
+	}
+}
+
+// shutdownMux stops the gRPC server serving on the HTTP listener. Its
+// connections belong to the HTTP server and can't be drained by gRPC (the
+// handler transport panics on drain), so new RPCs are rejected while the
+// active RPCs are waited for until the shutdown timeout expires. The
+// connections themselves are drained by the HTTP server's shutdown.
+func (s *Service) shutdownMux() {
+	srv := s.mux.Swap(nil)
+	if srv == nil {
+		return
+	}
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	tick := time.NewTicker(muxDrainInterval)
+	defer tick.Stop()
+	for s.active.n.Load() > 0 {
+		select {
+		case <-tick.C:
+		case <-t.C:
+			log.Error("graceful shutdown did not complete", context.DeadlineExceeded,
+				"timeout", timeout, "active_rpcs", s.active.n.Load())
+			srv.Stop()
+			return
+		}
+	}
+	log.Info("graceful shutdown completed")
+	srv.Stop()
+}
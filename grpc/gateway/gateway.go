@@ -0,0 +1,287 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpcgateway holds a run.Group compatible grpc-gateway service,
+// exposing gRPC services as REST/JSON APIs.
+package grpcgateway
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+
+	rungrpc "github.com/basvanbeek/run-handlers/grpc"
+)
+
+// package flags.
+const (
+	Endpoint       = "grpc-gateway-endpoint"
+	ListenAddress  = "grpc-gateway-listen-address"
+	Prefix         = "grpc-gateway-prefix"
+	ForwardHeaders = "grpc-gateway-forward-headers"
+)
+
+const (
+	defaultPrefix          = "/"
+	defaultShutdownTimeout = 5 * time.Second
+)
+
+// Handler registers the REST/JSON handlers of a gRPC service with the
+// gateway mux. The generated RegisterXHandlerFromEndpoint functions
+// implement this signature.
+type Handler func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error
+
+// Mounter is implemented by the http.Service (and http.ServeMux) to mount
+// the gateway on.
+type Mounter interface {
+	Handle(pattern string, h http.Handler)
+}
+
+// Service implements a run.Group compatible grpc-gateway, proxying REST/JSON
+// requests to the gRPC endpoint. The gateway is mounted on the provided HTTP
+// Mounter or, if not provided, served on its own listener.
+type Service struct {
+	// GRPC optionally holds the gRPC Service to proxy to, used to derive the
+	// endpoint and transport security if not explicitly configured.
+	GRPC     *rungrpc.Service
+	Endpoint string
+	// HTTP optionally holds the HTTP service to mount the gateway on.
+	HTTP    Mounter
+	Address string
+	Prefix  string
+	// ForwardHeaders holds the HTTP request headers forwarded as gRPC
+	// metadata, in addition to the headers forwarded by default.
+	ForwardHeaders []string
+	// DialOptions optionally holds the options to connect to the gRPC
+	// endpoint with. If not set, a plaintext connection is used unless the
+	// GRPC Service has TLS enabled, in which case the server certificate is
+	// verified against the system roots.
+	DialOptions []grpc.DialOption
+	MuxOptions  []runtime.ServeMuxOption
+
+	handlers []Handler
+	mux      *runtime.ServeMux
+	srv      *http.Server
+	cancel   context.CancelFunc
+	done     chan struct{}
+	once     sync.Once
+}
+
+// Name implements run.Unit.
+func (s *Service) Name() string {
+	return "grpc-gateway"
+}
+
+// Register adds gRPC service handlers to the gateway. It must be called
+// before the PreRun stage.
+func (s *Service) Register(h ...Handler) {
+	s.handlers = append(s.handlers, h...)
+}
+
+// FlagSet implements run.Config.
+func (s *Service) FlagSet() *run.FlagSet {
+	if s.Prefix == "" {
+		s.Prefix = defaultPrefix
+	}
+	if s.ForwardHeaders == nil {
+		s.ForwardHeaders = []string{"X-Request-ID"}
+	}
+
+	flags := run.NewFlagSet("gRPC gateway options")
+
+	flags.StringVar(
+		&s.Endpoint,
+		Endpoint,
+		s.Endpoint,
+		`gRPC endpoint to proxy to, e.g. "localhost:9080" (defaults to the gRPC service address)`)
+
+	flags.StringVar(
+		&s.Address,
+		ListenAddress,
+		s.Address,
+		`Listen address of the gateway if not mounted on the HTTP service, e.g. ":8080"`)
+
+	flags.StringVar(
+		&s.Prefix,
+		Prefix,
+		s.Prefix,
+		`URL path prefix to serve the gateway on, e.g. "/api"`)
+
+	flags.StringSliceVar(
+		&s.ForwardHeaders,
+		ForwardHeaders,
+		s.ForwardHeaders,
+		"HTTP request headers forwarded as gRPC metadata")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	if s.Endpoint == "" && s.GRPC == nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(Endpoint, flag.ErrRequired))
+	}
+
+	if s.HTTP == nil {
+		if s.Address == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(ListenAddress, flag.ErrRequired))
+		} else if _, _, err := net.SplitHostPort(s.Address); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(ListenAddress, err))
+		}
+	}
+
+	if !strings.HasPrefix(s.Prefix, "/") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(Prefix, flag.ErrInvalidPath))
+	}
+
+	if len(s.handlers) == 0 {
+		mErr = multierror.Append(mErr, errors.New("no gateway handlers registered"))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() error {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		var err error
+		if endpoint, err = s.GRPC.GetGrpcAddress(); err != nil {
+			return err
+		}
+	}
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.done = make(chan struct{})
+
+	s.mux = runtime.NewServeMux(append([]runtime.ServeMuxOption{
+		runtime.WithIncomingHeaderMatcher(s.headerMatcher()),
+	}, s.MuxOptions...)...)
+
+	opts := s.dialOptions()
+	for _, h := range s.handlers {
+		if err := h(ctx, s.mux, endpoint, opts); err != nil {
+			s.cancel()
+			return fmt.Errorf("unable to register gateway handler: %w", err)
+		}
+	}
+
+	pattern := strings.TrimSuffix(s.Prefix, "/") + "/"
+	var h http.Handler = s.mux
+	if pattern != "/" {
+		h = http.StripPrefix(strings.TrimSuffix(pattern, "/"), h)
+	}
+	if s.HTTP != nil {
+		s.HTTP.Handle(pattern, h)
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(pattern, h)
+	s.srv = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 60 * time.Second,
+	}
+	return nil
+}
+
+// Serve implements run.Service.
+func (s *Service) Serve() error {
+	if s.srv == nil {
+		// mounted on the HTTP service, wait until we're stopped
+		<-s.done
+		return nil
+	}
+	l, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	if err = s.srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// GracefulStop implements run.Service.
+func (s *Service) GracefulStop() {
+	if s.srv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+		defer cancel()
+		if err := s.srv.Shutdown(ctx); err != nil {
+			_ = s.srv.Close()
+		}
+	}
+	if s.cancel != nil {
+		// closes the connections to the gRPC endpoint
+		s.cancel()
+	}
+	if s.done != nil {
+		s.once.Do(func() { close(s.done) })
+	}
+}
+
+// headerMatcher returns the matcher forwarding the default and configured
+// HTTP headers as gRPC metadata.
+func (s *Service) headerMatcher() runtime.HeaderMatcherFunc {
+	forward := make(map[string]struct{}, len(s.ForwardHeaders))
+	for _, h := range s.ForwardHeaders {
+		forward[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(h))] = struct{}{}
+	}
+	return func(key string) (string, bool) {
+		if _, ok := forward[textproto.CanonicalMIMEHeaderKey(key)]; ok {
+			return strings.ToLower(key), true
+		}
+		return runtime.DefaultHeaderMatcher(key)
+	}
+}
+
+func (s *Service) dialOptions() []grpc.DialOption {
+	if len(s.DialOptions) > 0 {
+		return s.DialOptions
+	}
+	if s.GRPC != nil && s.GRPC.TLSCertFile != "" {
+		return []grpc.DialOption{grpc.WithTransportCredentials(
+			credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}))}
+	}
+	return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+}
+
+var (
+	_ run.Config    = (*Service)(nil)
+	_ run.PreRunner = (*Service)(nil)
+	_ run.Service   = (*Service)(nil)
+)
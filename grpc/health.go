@@ -0,0 +1,51 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthServer returns the health server, creating it if needed.
+func (s *Service) healthServer() *health.Server {
+	s.healthOnce.Do(func() {
+		s.health = health.NewServer()
+	})
+	return s.health
+}
+
+// SetServing sets the serving status of a service as reported by the
+// grpc.health.v1.Health service. The empty service name holds the status of
+// the server as a whole. It has no effect if the health service is disabled.
+func (s *Service) SetServing(service string, ok bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if ok {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	s.healthServer().SetServingStatus(service, status)
+}
+
+// registerHealth registers the health service and marks all registered
+// services as serving.
+func (s *Service) registerHealth() {
+	hs := s.healthServer()
+	healthpb.RegisterHealthServer(s.srv, hs)
+	for name := range s.srv.GetServiceInfo() {
+		if name != healthpb.Health_ServiceDesc.ServiceName {
+			hs.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+		}
+	}
+}
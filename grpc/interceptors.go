@@ -15,20 +15,73 @@
 package grpc //nolint:golint // see doc.go
 
 import (
+	"cmp"
+	"slices"
+
 	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/stats"
 )
 
+// Phase determines the placement of an interceptor in the chain. Interceptors
+// of a lower phase wrap the ones of a higher phase, interceptors within the
+// same phase are chained in registration order.
+type Phase int
+
+// Interceptor phases, outermost first. Custom phases can be placed in between.
+const (
+	PhaseObservability Phase = 100
+	PhaseRecovery      Phase = 200
+	PhaseLimits        Phase = 300
+	PhaseAuth          Phase = 400
+	PhaseValidation    Phase = 500
+	PhaseBusiness      Phase = 600
+)
+
+type phased[T any] struct {
+	phase Phase
+	v     T
+}
+
+// withPhase returns the provided values tagged with the provided phase.
+func withPhase[T any](phase Phase, vs []T) []phased[T] {
+	p := make([]phased[T], 0, len(vs))
+	for _, v := range vs {
+		p = append(p, phased[T]{phase: phase, v: v})
+	}
+	return p
+}
+
+// ordered returns the values ordered by phase, retaining registration order
+// within a phase.
+func ordered[T any](p []phased[T]) []T {
+	p = slices.Clone(p)
+	slices.SortStableFunc(p, func(a, b phased[T]) int {
+		return cmp.Compare(a.phase, b.phase)
+	})
+	vs := make([]T, 0, len(p))
+	for _, e := range p {
+		vs = append(vs, e.v)
+	}
+	return vs
+}
+
 // Interceptors holds collections of gRPC server and client interceptors which
 // can be added to and allows them to be chained in a gRPC server or client.
+// Interceptors are chained by Phase, so units can register independently
+// while still producing a correct chain.
 type Interceptors struct {
 	sh []stats.Handler
-	us []grpc.UnaryServerInterceptor
-	uc []grpc.UnaryClientInterceptor
-	ss []grpc.StreamServerInterceptor
-	sc []grpc.StreamClientInterceptor
+	us []phased[grpc.UnaryServerInterceptor]
+	uc []phased[grpc.UnaryClientInterceptor]
+	ss []phased[grpc.StreamServerInterceptor]
+	sc []phased[grpc.StreamClientInterceptor]
 	so []grpc.ServerOption
+
+	otel       bool
+	otelOpts   []otelgrpc.Option
+	compressor string
 }
 
 // AddStatsHandler allows one or more stats.Handlers to be registered.
@@ -36,24 +89,52 @@ func (i *Interceptors) AddStatsHandler(handlers ...stats.Handler) {
 	i.sh = append(i.sh, handlers...)
 }
 
-// AddUnaryServer allows one or more UnaryServerInterceptors to be registered.
+// AddUnaryServer allows one or more UnaryServerInterceptors to be registered
+// in PhaseBusiness.
 func (i *Interceptors) AddUnaryServer(us ...grpc.UnaryServerInterceptor) {
-	i.us = append(i.us, us...)
+	i.AddUnaryServerPhase(PhaseBusiness, us...)
+}
+
+// AddUnaryServerPhase allows one or more UnaryServerInterceptors to be
+// registered in the provided phase.
+func (i *Interceptors) AddUnaryServerPhase(phase Phase, us ...grpc.UnaryServerInterceptor) {
+	i.us = append(i.us, withPhase(phase, us)...)
 }
 
-// AddUnaryClient allows one or more UnaryClientInterceptors to be registered.
+// AddUnaryClient allows one or more UnaryClientInterceptors to be registered
+// in PhaseBusiness.
 func (i *Interceptors) AddUnaryClient(uc ...grpc.UnaryClientInterceptor) {
-	i.uc = append(i.uc, uc...)
+	i.AddUnaryClientPhase(PhaseBusiness, uc...)
+}
+
+// AddUnaryClientPhase allows one or more UnaryClientInterceptors to be
+// registered in the provided phase.
+func (i *Interceptors) AddUnaryClientPhase(phase Phase, uc ...grpc.UnaryClientInterceptor) {
+	i.uc = append(i.uc, withPhase(phase, uc)...)
 }
 
-// AddStreamServer allows one or more StreamServerInterceptor to be registered.
+// AddStreamServer allows one or more StreamServerInterceptor to be registered
+// in PhaseBusiness.
 func (i *Interceptors) AddStreamServer(ss ...grpc.StreamServerInterceptor) {
-	i.ss = append(i.ss, ss...)
+	i.AddStreamServerPhase(PhaseBusiness, ss...)
 }
 
-// AddStreamClient allows one or more StreamClientInterceptor to be registered.
+// AddStreamServerPhase allows one or more StreamServerInterceptor to be
+// registered in the provided phase.
+func (i *Interceptors) AddStreamServerPhase(phase Phase, ss ...grpc.StreamServerInterceptor) {
+	i.ss = append(i.ss, withPhase(phase, ss)...)
+}
+
+// AddStreamClient allows one or more StreamClientInterceptor to be registered
+// in PhaseBusiness.
 func (i *Interceptors) AddStreamClient(sc ...grpc.StreamClientInterceptor) {
-	i.sc = append(i.sc, sc...)
+	i.AddStreamClientPhase(PhaseBusiness, sc...)
+}
+
+// AddStreamClientPhase allows one or more StreamClientInterceptor to be
+// registered in the provided phase.
+func (i *Interceptors) AddStreamClientPhase(phase Phase, sc ...grpc.StreamClientInterceptor) {
+	i.sc = append(i.sc, withPhase(phase, sc)...)
 }
 
 // AddServerOption allows to add custom server options to a gRPC server.
@@ -61,11 +142,36 @@ func (i *Interceptors) AddServerOption(so ...grpc.ServerOption) {
 	i.so = append(i.so, so...)
 }
 
+// EnableOpenTelemetry adds the OpenTelemetry (otelgrpc) stats handlers to the
+// server and dial options, so traces and metrics are recorded and the trace
+// context is propagated. The global tracer and meter providers are used
+// unless overridden by the provided options.
+func (i *Interceptors) EnableOpenTelemetry(opts ...otelgrpc.Option) {
+	i.otel = true
+	i.otelOpts = append(i.otelOpts, opts...)
+}
+
+// UseCompressor sets the compressor ("gzip" or "zstd") clients use by default
+// to compress their requests. Servers respond with the compressor used by the
+// client. Use "none" or an empty name to disable compression.
+func (i *Interceptors) UseCompressor(name string) {
+	registerCompressors()
+	i.compressor = name
+	if name == CompressionNone {
+		i.compressor = ""
+	}
+}
+
 // GetServerOptions returns an array of grpc.ServerOptions composed of the
 // registered chained ServerInterceptors.
 func (i *Interceptors) GetServerOptions() []grpc.ServerOption {
 	var so []grpc.ServerOption
 
+	if i.otel {
+		so = append(so, grpc.StatsHandler(
+			otelgrpc.NewServerHandler(i.otelOpts...),
+		))
+	}
 	if len(i.sh) > 0 {
 		so = append(so, grpc.StatsHandler(
 			NewStatsHandler(i.sh...),
@@ -73,12 +179,12 @@ func (i *Interceptors) GetServerOptions() []grpc.ServerOption {
 	}
 	if len(i.us) > 0 {
 		so = append(so, grpc.UnaryInterceptor(
-			grpcmiddleware.ChainUnaryServer(i.us...),
+			grpcmiddleware.ChainUnaryServer(ordered(i.us)...),
 		))
 	}
 	if len(i.ss) > 0 {
 		so = append(so, grpc.StreamInterceptor(
-			grpcmiddleware.ChainStreamServer(i.ss...),
+			grpcmiddleware.ChainStreamServer(ordered(i.ss)...),
 		))
 	}
 	if len(i.so) > 0 {
@@ -93,6 +199,11 @@ func (i *Interceptors) GetServerOptions() []grpc.ServerOption {
 func (i *Interceptors) GetDialOptions() []grpc.DialOption {
 	var do []grpc.DialOption
 
+	if i.otel {
+		do = append(do, grpc.WithStatsHandler(
+			otelgrpc.NewClientHandler(i.otelOpts...),
+		))
+	}
 	if len(i.sh) > 0 {
 		do = append(do, grpc.WithStatsHandler(
 			NewStatsHandler(i.sh...),
@@ -100,13 +211,18 @@ func (i *Interceptors) GetDialOptions() []grpc.DialOption {
 	}
 	if len(i.uc) > 0 {
 		do = append(do, grpc.WithUnaryInterceptor(
-			grpcmiddleware.ChainUnaryClient(i.uc...),
+			grpcmiddleware.ChainUnaryClient(ordered(i.uc)...),
 		))
 	}
 
 	if len(i.sc) > 0 {
 		do = append(do, grpc.WithStreamInterceptor(
-			grpcmiddleware.ChainStreamClient(i.sc...),
+			grpcmiddleware.ChainStreamClient(ordered(i.sc)...),
+		))
+	}
+	if i.compressor != "" {
+		do = append(do, grpc.WithDefaultCallOptions(
+			grpc.UseCompressor(i.compressor),
 		))
 	}
 
@@ -0,0 +1,47 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// keepaliveOptions returns the keepalive server parameters and enforcement
+// policy options. Unset (zero) values keep the gRPC defaults.
+func (s *Service) keepaliveOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+
+	params := keepalive.ServerParameters{
+		MaxConnectionIdle:     s.KeepaliveMaxConnectionIdle,
+		MaxConnectionAge:      s.KeepaliveMaxConnectionAge,
+		MaxConnectionAgeGrace: s.KeepaliveMaxConnectionAgeGrace,
+		Time:                  s.KeepaliveTime,
+		Timeout:               s.KeepaliveTimeout,
+	}
+	if params != (keepalive.ServerParameters{}) {
+		opts = append(opts, grpc.KeepaliveParams(params))
+	}
+
+	if s.KeepaliveMinPingInterval > 0 || s.KeepalivePermitWithoutStream {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(
+			keepalive.EnforcementPolicy{
+				MinTime:             s.KeepaliveMinPingInterval,
+				PermitWithoutStream: s.KeepalivePermitWithoutStream,
+			}))
+	}
+
+	return opts
+}
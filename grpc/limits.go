@@ -0,0 +1,80 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"math"
+
+	"google.golang.org/grpc"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// minWindowSize holds the smallest window size accepted by gRPC, smaller
+// values are ignored.
+const minWindowSize = 64 * 1024
+
+// limitOptions returns the resource limit server options. Unset (zero)
+// values keep the gRPC defaults.
+func (s *Service) limitOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+
+	if s.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(uint32(s.MaxConcurrentStreams)))
+	}
+	if s.ConnectionTimeout > 0 {
+		opts = append(opts, grpc.ConnectionTimeout(s.ConnectionTimeout))
+	}
+	if s.InitialWindowSize > 0 {
+		opts = append(opts, grpc.InitialWindowSize(int32(s.InitialWindowSize)))
+	}
+	if s.InitialConnWindowSize > 0 {
+		opts = append(opts, grpc.InitialConnWindowSize(int32(s.InitialConnWindowSize)))
+	}
+	if s.MaxHeaderListSize > 0 {
+		opts = append(opts, grpc.MaxHeaderListSize(uint32(s.MaxHeaderListSize)))
+	}
+
+	return opts
+}
+
+// validateLimits checks the resource limit settings.
+func (s *Service) validateLimits() error {
+	var mErr error
+
+	for _, l := range []struct {
+		flag  string
+		valid bool
+	}{
+		{MaxConcurrentStreams, s.MaxConcurrentStreams >= 0 && s.MaxConcurrentStreams <= math.MaxUint32},
+		{MaxHeaderListSize, s.MaxHeaderListSize >= 0 && s.MaxHeaderListSize <= math.MaxUint32},
+		{InitialWindowSize, validWindowSize(s.InitialWindowSize)},
+		{InitialConnWindowSize, validWindowSize(s.InitialConnWindowSize)},
+	} {
+		if !l.valid {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(l.flag, flag.ErrInvalidVal))
+		}
+	}
+
+	return mErr
+}
+
+// validWindowSize returns true if the window size is unset or within the
+// range accepted by gRPC.
+func validWindowSize(size int) bool {
+	return size == 0 || (size >= minWindowSize && size <= math.MaxInt32)
+}
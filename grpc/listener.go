@@ -0,0 +1,139 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc"
+)
+
+const unixPrefix = "unix://"
+
+// unixSocketPath returns the socket path if the address uses the unix:// scheme.
+func unixSocketPath(address string) (string, bool) {
+	if !strings.HasPrefix(address, unixPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(address, unixPrefix), true
+}
+
+// parseFileMode parses an octal file mode like "0660".
+func parseFileMode(mode string) (fs.FileMode, error) {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid octal file mode %q", mode)
+	}
+	return fs.FileMode(m), nil
+}
+
+// listenUnix creates a unix domain socket listener at the provided path. A
+// stale socket file left behind by a previous process is removed first. A
+// socket still accepting connections is left alone.
+func listenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("unable to check socket: %w", err)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, fmt.Errorf("unable to remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// the socket file is removed by the listener on Close.
+	l.(*net.UnixListener).SetUnlinkOnClose(true)
+
+	if err = os.Chmod(path, mode); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("unable to set socket file mode: %w", err)
+	}
+	return l, nil
+}
+
+// addresses returns the configured comma separated listen addresses.
+func (s *Service) addresses() []string {
+	var addresses []string
+	for _, address := range strings.Split(s.Address, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// listen creates the listener for the provided TCP or unix domain socket
+// address.
+func (s *Service) listen(address string) (net.Listener, error) {
+	path, ok := unixSocketPath(address)
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+	mode, err := parseFileMode(s.UnixSocketMode)
+	if err != nil {
+		return nil, err
+	}
+	// the socket file is removed once the server closes the listener
+	return listenUnix(path, mode)
+}
+
+// serveListeners serves the gRPC server on all listeners. It returns once
+// serving on all listeners stopped, or on the first listener failing.
+func (s *Service) serveListeners() error {
+	errs := make(chan error, len(s.ls))
+	for _, l := range s.ls {
+		go func() {
+			err := s.srv.Serve(l)
+			if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				errs <- fmt.Errorf("serving on %s: %w", l.Addr(), err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+	for range s.ls {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeListeners closes all listeners.
+func (s *Service) closeListeners() {
+	for _, l := range s.ls {
+		_ = l.Close()
+	}
+}
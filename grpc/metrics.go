@@ -0,0 +1,227 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMetrics holds the RPC metrics collectors of the gRPC server, following
+// the naming of go-grpc-prometheus.
+type grpcMetrics struct {
+	started  *prometheus.CounterVec
+	handled  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	msgRecv  *prometheus.CounterVec
+	msgSent  *prometheus.CounterVec
+	reqSize  *prometheus.HistogramVec
+	respSize *prometheus.HistogramVec
+	panics   prometheus.CounterFunc
+}
+
+func newGRPCMetrics(reg prometheus.Registerer, panics func() float64) (*grpcMetrics, error) {
+	labels := []string{"grpc_type", "grpc_service", "grpc_method"}
+	codeLabels := append(labels[:len(labels):len(labels)], "grpc_code")
+	sizeBuckets := prometheus.ExponentialBuckets(64, 4, 10)
+
+	m := &grpcMetrics{
+		started: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_started_total",
+			Help: "Total number of RPCs started on the server.",
+		}, labels),
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Total number of RPCs completed on the server, regardless of success or failure.",
+		}, codeLabels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Duration of RPCs handled by the server.",
+			Buckets: prometheus.DefBuckets,
+		}, codeLabels),
+		msgRecv: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_msg_received_total",
+			Help: "Total number of RPC stream messages received on the server.",
+		}, labels),
+		msgSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_msg_sent_total",
+			Help: "Total number of RPC stream messages sent by the server.",
+		}, labels),
+		reqSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_request_size_bytes",
+			Help:    "Total size of the messages received per RPC.",
+			Buckets: sizeBuckets,
+		}, codeLabels),
+		respSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_response_size_bytes",
+			Help:    "Total size of the messages sent per RPC.",
+			Buckets: sizeBuckets,
+		}, codeLabels),
+		panics: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "grpc_server_panics_total",
+			Help: "Number of RPC handler panics recovered.",
+		}, panics),
+	}
+
+	var err error
+	for _, c := range []**prometheus.CounterVec{&m.started, &m.handled, &m.msgRecv, &m.msgSent} {
+		if *c, err = register(reg, *c); err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range []**prometheus.HistogramVec{&m.duration, &m.reqSize, &m.respSize} {
+		if *h, err = register(reg, *h); err != nil {
+			return nil, err
+		}
+	}
+	if m.panics, err = register(reg, m.panics); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// register registers the collector, reusing an identical collector if it was
+// registered before.
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// splitMethod splits a full method into its service and method name.
+func splitMethod(fullMethod string) (string, string) {
+	svc, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", "unknown"
+	}
+	return svc, method
+}
+
+func (m *grpcMetrics) observe(
+	kind, fullMethod string, start time.Time, recv, sent int64, err error,
+) {
+	svc, method := splitMethod(fullMethod)
+	lv := []string{kind, svc, method, status.Code(err).String()}
+	m.handled.WithLabelValues(lv...).Inc()
+	m.duration.WithLabelValues(lv...).Observe(time.Since(start).Seconds())
+	m.reqSize.WithLabelValues(lv...).Observe(float64(recv))
+	m.respSize.WithLabelValues(lv...).Observe(float64(sent))
+}
+
+// UnaryServerInterceptor returns the unary metrics interceptor.
+func (m *grpcMetrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		svc, method := splitMethod(info.FullMethod)
+		m.started.WithLabelValues("unary", svc, method).Inc()
+		m.msgRecv.WithLabelValues("unary", svc, method).Inc()
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		if err == nil {
+			m.msgSent.WithLabelValues("unary", svc, method).Inc()
+		}
+		m.observe("unary", info.FullMethod, start,
+			messageSize(req), messageSize(resp), err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns the stream metrics interceptor.
+func (m *grpcMetrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		kind := streamType(info)
+		svc, method := splitMethod(info.FullMethod)
+		m.started.WithLabelValues(kind, svc, method).Inc()
+
+		start := time.Now()
+		ms := &metricsStream{
+			countingStream: countingStream{ServerStream: ss},
+			recvMsgs:       m.msgRecv.WithLabelValues(kind, svc, method),
+			sentMsgs:       m.msgSent.WithLabelValues(kind, svc, method),
+		}
+		err := handler(srv, ms)
+		m.observe(kind, info.FullMethod, start,
+			ms.recv.Load(), ms.sent.Load(), err)
+		return err
+	}
+}
+
+func streamType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return "bidi_stream"
+	case info.IsClientStream:
+		return "client_stream"
+	default:
+		return "server_stream"
+	}
+}
+
+// metricsStream wraps a grpc.ServerStream to count messages and their sizes.
+type metricsStream struct {
+	countingStream
+	recvMsgs, sentMsgs prometheus.Counter
+}
+
+func (s *metricsStream) SendMsg(msg any) error {
+	err := s.countingStream.SendMsg(msg)
+	if err == nil {
+		s.sentMsgs.Inc()
+	}
+	return err
+}
+
+func (s *metricsStream) RecvMsg(msg any) error {
+	err := s.countingStream.RecvMsg(msg)
+	if err == nil {
+		s.recvMsgs.Inc()
+	}
+	return err
+}
+
+// addMetrics registers the metrics interceptors outside of the recovery
+// interceptors, so recovered panics are recorded with their resulting code.
+func (s *Service) addMetrics() error {
+	reg := prometheus.DefaultRegisterer
+	if s.Registry != nil {
+		reg = s.Registry
+	}
+	m, err := newGRPCMetrics(reg, func() float64 { return float64(s.Panics()) })
+	if err != nil {
+		return err
+	}
+	s.i.AddUnaryServerPhase(PhaseObservability, m.UnaryServerInterceptor())
+	s.i.AddStreamServerPhase(PhaseObservability, m.StreamServerInterceptor())
+	return nil
+}
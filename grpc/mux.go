@@ -0,0 +1,96 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// Multiplexer is implemented by the http.Service, allowing the gRPC Service
+// to serve its RPCs on the HTTP listener.
+type Multiplexer interface {
+	Use(mw ...func(http.Handler) http.Handler)
+}
+
+// validateMux checks the settings for serving on the HTTP listener.
+func (s *Service) validateMux() error {
+	if s.HTTP == nil {
+		return nil
+	}
+
+	var mErr error
+
+	if s.Mode == ModeXDS {
+		mErr = multierror.Append(mErr, flag.NewValidationError(Mode,
+			flag.ValidationError("xDS mode requires a dedicated listener")))
+	}
+	if s.tlsEnabled() {
+		mErr = multierror.Append(mErr, flag.NewValidationError(TLSCertFile,
+			flag.ValidationError("TLS is terminated by the HTTP service")))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() error {
+	if s.HTTP != nil {
+		s.stop = make(chan struct{})
+		s.HTTP.Use(s.muxHandler)
+	}
+	return nil
+}
+
+// muxHandler holds a middleware routing gRPC requests to the gRPC server.
+// Other requests are passed through.
+func (s *Service) muxHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isGRPCRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		srv := s.mux.Load()
+		if srv == nil {
+			// translated to codes.Unavailable by gRPC clients
+			http.Error(w, "gRPC server not available",
+				http.StatusServiceUnavailable)
+			return
+		}
+		srv.ServeHTTP(w, r)
+	})
+}
+
+// isGRPCRequest returns true for HTTP/2 requests with a gRPC content type.
+func isGRPCRequest(r *http.Request) bool {
+	if r.ProtoMajor != 2 {
+		return false
+	}
+	ct := r.Header.Get("Content-Type")
+	return ct == "application/grpc" ||
+		strings.HasPrefix(ct, "application/grpc+") ||
+		strings.HasPrefix(ct, "application/grpc;")
+}
+
+// serveMux makes the gRPC server available to the HTTP listener and blocks
+// until the Service is stopped.
+func (s *Service) serveMux() error {
+	s.mux.Store(s.Server)
+	<-s.stop
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// blocker holds a test service handler blocking until released or
+// cancelled.
+type blocker struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlocker() *blocker {
+	return &blocker{started: make(chan struct{}, 10), release: make(chan struct{})}
+}
+
+func (b *blocker) register(s *grpc.Server) {
+	echoService(func(ctx context.Context) error {
+		b.started <- struct{}{}
+		select {
+		case <-b.release:
+			return nil
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	})(s)
+}
+
+// testMux implements Multiplexer on an h2c test server.
+type testMux struct {
+	mw []func(http.Handler) http.Handler
+}
+
+func (m *testMux) Use(mw ...func(http.Handler) http.Handler) {
+	m.mw = append(m.mw, mw...)
+}
+
+func (m *testMux) start(t *testing.T) *httptest.Server {
+	var h http.Handler = http.NotFoundHandler()
+	for i := len(m.mw) - 1; i >= 0; i-- {
+		h = m.mw[i](h)
+	}
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// serveMux starts the Service on a test HTTP server and returns a client
+// connection to it.
+func serveMux(t *testing.T, s *Service) *grpc.ClientConn {
+	m := &testMux{}
+	s.HTTP = m
+	s.FlagSet()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.Serve() }()
+	t.Cleanup(func() {
+		if err := <-errc; err != nil {
+			t.Error(err)
+		}
+	})
+	for s.mux.Load() == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	srv := m.start(t)
+	conn, err := grpc.NewClient("passthrough:///"+strings.TrimPrefix(srv.URL, "http://"),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func invoke(ctx context.Context, conn *grpc.ClientConn) <-chan error {
+	errc := make(chan error, 1)
+	go func() { errc <- call(ctx, conn) }()
+	return errc
+}
+
+func TestMuxGracefulStopWaitsForActiveRPCs(t *testing.T) {
+	b := newBlocker()
+	s := &Service{ShutdownTimeout: 10 * time.Second}
+	s.Attach(b.register)
+	conn := serveMux(t, s)
+
+	rpc := invoke(context.Background(), conn)
+	<-b.started
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	// new RPCs are rejected while the active one is still being handled
+	for s.mux.Load() != nil {
+		time.Sleep(time.Millisecond)
+	}
+	err := <-invoke(context.Background(), conn)
+	if code := status.Code(err); code != codes.Unavailable {
+		t.Errorf("expected %v for RPC during shutdown, got %v", codes.Unavailable, err)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("expected shutdown to wait for the active RPC")
+	default:
+	}
+
+	close(b.release)
+	if err := <-rpc; err != nil {
+		t.Errorf("expected active RPC to complete, got %v", err)
+	}
+	<-stopped
+}
+
+func TestMuxGracefulStopTimeout(t *testing.T) {
+	b := newBlocker()
+	s := &Service{ShutdownTimeout: 50 * time.Millisecond}
+	s.Attach(b.register)
+	conn := serveMux(t, s)
+
+	rpc := invoke(context.Background(), conn)
+	<-b.started
+
+	s.GracefulStop()
+	if err := <-rpc; err == nil {
+		t.Error("expected active RPC to be cancelled after the shutdown timeout")
+	}
+	if n := s.ActiveRPCs(); n != 0 {
+		t.Errorf("expected no active RPCs, got %d", n)
+	}
+}
+
+func TestIsGRPCRequest(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"application/grpc":       true,
+		"application/grpc+proto": true,
+		"application/grpc; x=y":  true,
+		"application/grpc-web":   false,
+		"application/json":       false,
+		"":                       false,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/svc/Method", nil)
+		r.ProtoMajor = 2
+		r.Header.Set("Content-Type", ct)
+		if got := isGRPCRequest(r); got != want {
+			t.Errorf("%q: expected %v, got %v", ct, want, got)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// Limiter decides if a request identified by key is allowed under the
+// provided limit of requests per second with the provided burst. If not, it
+// returns the time after which the request can be retried.
+//
+// The default Limiter holds in-memory token buckets, limiting each server
+// instance on its own. A Limiter backed by a shared store (e.g. the redis
+// handler) can be used to apply the limits across instances.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit float64, burst int) (ok bool, retryAfter time.Duration, err error)
+}
+
+// parseRateLimits parses a list of "method=limit" pairs where method holds a
+// full method ("/package.Service/Method") or service ("package.Service").
+func parseRateLimits(pairs []string) (map[string]float64, error) {
+	limits := make(map[string]float64, len(pairs))
+	for _, pair := range pairs {
+		method, limit, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || method == "" {
+			return nil, errors.New(`expected "method=limit" pairs`)
+		}
+		l, err := strconv.ParseFloat(limit, 64)
+		if err != nil || l <= 0 {
+			return nil, errors.New("invalid limit for " + method)
+		}
+		limits[strings.TrimPrefix(method, "/")] = l
+	}
+	return limits, nil
+}
+
+// validateRateLimits checks the rate limit settings.
+func (s *Service) validateRateLimits() error {
+	var mErr error
+
+	if _, err := parseRateLimits(s.RateLimitMethods); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(RateLimitMethods, err))
+	}
+	if s.RateLimitPeer < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(RateLimitPeer, flag.ErrInvalidVal))
+	}
+	if s.RateLimitBurst < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(RateLimitBurst, flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// rateLimit holds the rate limiting interceptors.
+type rateLimit struct {
+	limiter Limiter
+	methods map[string]float64
+	peer    float64
+	burst   int
+}
+
+// methodLimit returns the limit configured for the full method or its
+// service.
+func (r *rateLimit) methodLimit(fullMethod string) (string, float64) {
+	method := strings.TrimPrefix(fullMethod, "/")
+	if l, ok := r.methods[method]; ok {
+		return method, l
+	}
+	svc, _, _ := strings.Cut(method, "/")
+	if l, ok := r.methods[svc]; ok {
+		// the limit applies to the service as a whole
+		return svc, l
+	}
+	return "", 0
+}
+
+func (r *rateLimit) burstFor(limit float64) int {
+	if r.burst > 0 {
+		return r.burst
+	}
+	return int(math.Max(1, math.Ceil(limit)))
+}
+
+func (r *rateLimit) allow(ctx context.Context, fullMethod string) error {
+	type check struct {
+		key   string
+		limit float64
+	}
+	var checks []check
+	if key, limit := r.methodLimit(fullMethod); limit > 0 {
+		checks = append(checks, check{"method:" + key, limit})
+	}
+	if r.peer > 0 {
+		checks = append(checks, check{"peer:" + peerHost(ctx), r.peer})
+	}
+
+	for _, c := range checks {
+		ok, retryAfter, err := r.limiter.Allow(ctx, c.key, c.limit, r.burstFor(c.limit))
+		if err != nil {
+			// don't turn limiter failures into outages
+			log.Context(ctx).Error("rate limiter failed", err, "key", c.key)
+			continue
+		}
+		if !ok {
+			st, _ := status.New(codes.ResourceExhausted, "rate limit exceeded").
+				WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+			return st.Err()
+		}
+	}
+	return nil
+}
+
+// UnaryServerInterceptor returns the unary rate limiting interceptor.
+func (r *rateLimit) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := r.allow(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the stream rate limiting interceptor. The
+// limits apply to the creation of streams, not their messages.
+func (r *rateLimit) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := r.allow(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// peerHost returns the host of the peer found in the context.
+func peerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// localLimiter implements an in-memory token bucket Limiter. Buckets which
+// are full are considered idle and periodically removed.
+type localLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+const limiterSweepInterval = time.Minute
+
+// Allow implements Limiter.
+func (l *localLimiter) Allow(_ context.Context, key string, limit float64, burst int) (bool, time.Duration, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*rate.Limiter)
+	}
+	if now.Sub(l.lastSweep) > limiterSweepInterval {
+		for k, b := range l.buckets {
+			if b.TokensAt(now) >= float64(b.Burst()) {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = rate.NewLimiter(rate.Limit(limit), burst)
+		l.buckets[key] = b
+	}
+	l.mu.Unlock()
+
+	res := b.ReserveN(now, 1)
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return false, delay, nil
+	}
+	return true, 0, nil
+}
+
+// addRateLimit registers the rate limiting interceptors if limits are
+// configured.
+func (s *Service) addRateLimit() {
+	methods, _ := parseRateLimits(s.RateLimitMethods)
+	if len(methods) == 0 && s.RateLimitPeer <= 0 {
+		return
+	}
+	r := &rateLimit{
+		limiter: s.RateLimiter,
+		methods: methods,
+		peer:    s.RateLimitPeer,
+		burst:   s.RateLimitBurst,
+	}
+	if r.limiter == nil {
+		r.limiter = &localLimiter{}
+	}
+	s.i.AddUnaryServerPhase(PhaseLimits, r.UnaryServerInterceptor())
+	s.i.AddStreamServerPhase(PhaseLimits, r.StreamServerInterceptor())
+}
@@ -0,0 +1,77 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recovery holds the panic recovery interceptors. Panics are logged with
+// their stack trace, counted and returned to the client as codes.Internal.
+type recovery struct {
+	n atomic.Uint64
+}
+
+func (r *recovery) recover(ctx context.Context, method string, err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	r.n.Add(1)
+	log.Context(ctx).Error("panic while handling RPC", fmt.Errorf("%v", v),
+		"method", method, "stack", string(debug.Stack()))
+	*err = status.Error(codes.Internal, "internal error")
+}
+
+// UnaryServerInterceptor returns the unary panic recovery interceptor.
+func (r *recovery) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (_ any, err error) {
+		defer r.recover(ctx, info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the stream panic recovery interceptor.
+func (r *recovery) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) (err error) {
+		defer r.recover(ss.Context(), info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+}
+
+// addRecovery registers the panic recovery interceptors in PhaseRecovery, so
+// panics in interceptors of later phases are recovered as well.
+func (s *Service) addRecovery() {
+	s.i.AddUnaryServerPhase(PhaseRecovery, s.recovery.UnaryServerInterceptor())
+	s.i.AddStreamServerPhase(PhaseRecovery, s.recovery.StreamServerInterceptor())
+}
+
+// Panics returns the number of recovered RPC handler panics.
+func (s *Service) Panics() uint64 {
+	return s.recovery.n.Load()
+}
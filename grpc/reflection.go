@@ -0,0 +1,198 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	v1reflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	v1alphareflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// registerReflection registers the reflection service. If an allow-list of
+// services is configured, only those services are advertised and resolvable
+// through reflection. As reflection serves whole file descriptors, other
+// services are removed from the files returned, but the message types they
+// use remain resolvable.
+func (s *Service) registerReflection() {
+	if len(s.ReflectionServices) == 0 {
+		reflection.Register(s.srv)
+		return
+	}
+
+	allowed := make(map[string]bool, len(s.ReflectionServices))
+	for _, name := range s.ReflectionServices {
+		allowed[name] = true
+	}
+	opts := reflection.ServerOptions{
+		Services:           &allowedServices{s: s.srv, allowed: allowed},
+		DescriptorResolver: &allowedDescriptors{allowed: allowed},
+	}
+	// like reflection.Register, serve both the v1 and v1alpha versions
+	v1alphareflectiongrpc.RegisterServerReflectionServer(s.srv, reflection.NewServer(opts))
+	v1reflectiongrpc.RegisterServerReflectionServer(s.srv, reflection.NewServerV1(opts))
+}
+
+// allowedServices advertises the allow-listed services of the server.
+type allowedServices struct {
+	s       reflection.ServiceInfoProvider
+	allowed map[string]bool
+}
+
+func (a *allowedServices) GetServiceInfo() map[string]grpc.ServiceInfo {
+	info := make(map[string]grpc.ServiceInfo)
+	for name, si := range a.s.GetServiceInfo() {
+		if a.allowed[name] {
+			info[name] = si
+		}
+	}
+	return info
+}
+
+// allowedDescriptors resolves descriptors from the global registry, hiding
+// services (and their methods) which are not allow-listed, including from
+// the file descriptors returned.
+type allowedDescriptors struct {
+	allowed map[string]bool
+}
+
+func (a *allowedDescriptors) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return a.file(fd), nil
+}
+
+func (a *allowedDescriptors) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, err
+	}
+	svc := d
+	if md, ok := d.(protoreflect.MethodDescriptor); ok {
+		svc = md.Parent()
+	}
+	if _, ok := svc.(protoreflect.ServiceDescriptor); ok && !a.allowed[string(svc.FullName())] {
+		return nil, protoregistry.NotFound
+	}
+	// reflection serves the file holding the descriptor
+	return &allowedDescriptor{Descriptor: d, file: a.file(d.ParentFile())}, nil
+}
+
+// file returns the file descriptor without the services which are not
+// allow-listed.
+func (a *allowedDescriptors) file(fd protoreflect.FileDescriptor) protoreflect.FileDescriptor {
+	f := &allowedFile{FileDescriptor: fd, a: a}
+	for i, services := 0, fd.Services(); i < services.Len(); i++ {
+		if sd := services.Get(i); a.allowed[string(sd.FullName())] {
+			f.services = append(f.services, sd)
+		}
+	}
+	return f
+}
+
+// allowedDescriptor holds a descriptor of a file without hidden services.
+type allowedDescriptor struct {
+	protoreflect.Descriptor
+	file protoreflect.FileDescriptor
+}
+
+func (d *allowedDescriptor) ParentFile() protoreflect.FileDescriptor {
+	return d.file
+}
+
+// allowedFile holds a file descriptor without hidden services, which also
+// hides the services of the files it imports.
+type allowedFile struct {
+	protoreflect.FileDescriptor
+	a        *allowedDescriptors
+	services []protoreflect.ServiceDescriptor
+}
+
+func (f *allowedFile) Imports() protoreflect.FileImports {
+	return &allowedImports{FileImports: f.FileDescriptor.Imports(), a: f.a}
+}
+
+func (f *allowedFile) Services() protoreflect.ServiceDescriptors {
+	return &allowedServiceDescriptors{ServiceDescriptors: f.FileDescriptor.Services(), services: f.services}
+}
+
+func (f *allowedFile) SourceLocations() protoreflect.SourceLocations {
+	if len(f.services) == f.FileDescriptor.Services().Len() {
+		return f.FileDescriptor.SourceLocations()
+	}
+	// the locations refer to the services by index and hold their comments
+	return noSourceLocations{f.FileDescriptor.SourceLocations()}
+}
+
+// Edition is used by protodesc to serialize files using editions.
+func (f *allowedFile) Edition() int32 {
+	if e, ok := f.FileDescriptor.(interface{ Edition() int32 }); ok {
+		return e.Edition()
+	}
+	return 0
+}
+
+type allowedImports struct {
+	protoreflect.FileImports
+	a *allowedDescriptors
+}
+
+func (i *allowedImports) Get(n int) protoreflect.FileImport {
+	imp := i.FileImports.Get(n)
+	imp.FileDescriptor = i.a.file(imp.FileDescriptor)
+	return imp
+}
+
+type allowedServiceDescriptors struct {
+	protoreflect.ServiceDescriptors
+	services []protoreflect.ServiceDescriptor
+}
+
+func (s *allowedServiceDescriptors) Len() int {
+	return len(s.services)
+}
+
+func (s *allowedServiceDescriptors) Get(i int) protoreflect.ServiceDescriptor {
+	return s.services[i]
+}
+
+func (s *allowedServiceDescriptors) ByName(name protoreflect.Name) protoreflect.ServiceDescriptor {
+	for _, sd := range s.services {
+		if sd.Name() == name {
+			return sd
+		}
+	}
+	return nil
+}
+
+type noSourceLocations struct {
+	protoreflect.SourceLocations
+}
+
+func (noSourceLocations) Len() int { return 0 }
+
+func (noSourceLocations) Get(int) protoreflect.SourceLocation { return protoreflect.SourceLocation{} }
+
+func (noSourceLocations) ByPath(protoreflect.SourcePath) protoreflect.SourceLocation {
+	return protoreflect.SourceLocation{}
+}
+
+func (noSourceLocations) ByDescriptor(protoreflect.Descriptor) protoreflect.SourceLocation {
+	return protoreflect.SourceLocation{}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// supported load balancing policies.
+const (
+	LBPolicyPickFirst  = "pick_first"
+	LBPolicyRoundRobin = "round_robin"
+)
+
+// staticScheme holds the resolver scheme of clients with static endpoints.
+const staticScheme = "static"
+
+func validLBPolicy(policy string) bool {
+	switch policy {
+	case "", LBPolicyPickFirst, LBPolicyRoundRobin:
+		return true
+	}
+	return false
+}
+
+// validEndpoints returns true if all endpoints hold a host and port.
+func validEndpoints(endpoints []string) bool {
+	for _, e := range endpoints {
+		if _, _, err := net.SplitHostPort(e); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// validateResolver checks the load balancing and resolver settings.
+func (c *Client) validateResolver() error {
+	var mErr error
+
+	if !validMode(c.Mode) {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientMode), flag.ErrInvalidVal))
+	}
+	if c.Mode == ModeXDS {
+		if !xdsBootstrapFound() {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(ClientMode), errXDSBootstrap))
+		}
+		if c.LBPolicy != "" || len(c.Endpoints) > 0 || c.DNSServer != "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(ClientMode),
+					flag.ValidationError("resolving and balancing is done by the control plane")))
+		}
+	}
+
+	if !validLBPolicy(c.LBPolicy) {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientLBPolicy), flag.ErrInvalidVal))
+	}
+	if c.LBPolicy != "" && c.ServiceConfig != "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientLBPolicy),
+				flag.ValidationError("set the policy in the service config instead")))
+	}
+
+	if !validEndpoints(c.Endpoints) {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientEndpoints),
+				flag.ValidationError("expected host:port addresses")))
+	}
+
+	if c.DNSServer != "" {
+		if _, _, err := net.SplitHostPort(c.DNSServer); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(ClientDNSServer), err))
+		} else if len(c.Endpoints) > 0 ||
+			(strings.Contains(c.Target, "://") && !strings.HasPrefix(c.Target, "dns:///")) {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(ClientDNSServer),
+					flag.ValidationError("only supported for DNS targets")))
+		}
+	}
+
+	return mErr
+}
+
+// target returns the target to dial and the resolver dial option it needs,
+// if any. Static endpoints are served by a manual resolver, a DNS server
+// results in a target using that server as DNS authority.
+func (c *Client) target() (string, []grpc.DialOption) {
+	if c.Mode == ModeXDS {
+		return xdsTarget(c.Target), nil
+	}
+
+	if len(c.Endpoints) > 0 {
+		addrs := make([]resolver.Address, 0, len(c.Endpoints))
+		for _, e := range c.Endpoints {
+			addrs = append(addrs, resolver.Address{Addr: e})
+		}
+		r := manual.NewBuilderWithScheme(staticScheme)
+		r.InitialState(resolver.State{Addresses: addrs})
+
+		// the endpoint part of the target is used as the default authority
+		name := c.Target
+		if name == "" {
+			name = c.Endpoints[0]
+		}
+		return staticScheme + ":///" + name, []grpc.DialOption{grpc.WithResolvers(r)}
+	}
+
+	if c.DNSServer != "" {
+		return "dns://" + c.DNSServer + "/" + strings.TrimPrefix(c.Target, "dns:///"), nil
+	}
+	return c.Target, nil
+}
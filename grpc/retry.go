@@ -0,0 +1,174 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// validateRetry checks the retry and hedging settings.
+func (c *Client) validateRetry() error {
+	var mErr error
+
+	if c.MaxAttempts < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientMaxAttempts), flag.ErrInvalidVal))
+	}
+
+	if _, err := parseCodes(c.RetryCodes); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientRetryCodes), err))
+	}
+
+	for _, t := range []struct {
+		flag string
+		d    time.Duration
+	}{
+		{ClientRetryInitialBackoff, c.RetryInitialBackoff},
+		{ClientRetryMaxBackoff, c.RetryMaxBackoff},
+	} {
+		if t.d <= 0 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(t.flag), flag.ErrInvalidVal))
+		}
+	}
+
+	if c.RetryBackoffMultiplier <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientRetryBackoffMultiplier), flag.ErrInvalidVal))
+	}
+
+	if c.HedgingDelay < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientHedgingDelay), flag.ErrInvalidVal))
+	}
+
+	if c.DisableRetry && (c.MaxAttempts > 1 || c.Hedging) {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientDisableRetry),
+				flag.ValidationError("retries and hedging require retry support")))
+	}
+
+	return mErr
+}
+
+// methodConfig returns the default method config holding the retry policy,
+// or nil if calls are attempted only once or hedged.
+func (c *Client) methodConfig() (map[string]any, error) {
+	if c.MaxAttempts <= 1 || c.DisableRetry || c.Hedging {
+		return nil, nil
+	}
+
+	retryCodes, err := parseCodes(c.RetryCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"name": []any{map[string]any{}},
+		"retryPolicy": map[string]any{
+			"maxAttempts":          c.MaxAttempts,
+			"initialBackoff":       durationString(c.RetryInitialBackoff),
+			"maxBackoff":           durationString(c.RetryMaxBackoff),
+			"backoffMultiplier":    c.RetryBackoffMultiplier,
+			"retryableStatusCodes": retryCodes,
+		},
+	}, nil
+}
+
+// hedgingInterceptor returns a unary client interceptor hedging calls, as
+// grpc-go does not implement the hedging policy of the service config. Up
+// to MaxAttempts copies of a call are sent HedgingDelay apart, the first
+// successful response or fatal error is returned and the outstanding copies
+// are cancelled. A non-fatal error triggers the next copy right away.
+// Streaming calls are not hedged.
+func (c *Client) hedgingInterceptor() (grpc.UnaryClientInterceptor, error) {
+	names, err := parseCodes(c.RetryCodes)
+	if err != nil {
+		return nil, err
+	}
+	nonFatal := make(map[codes.Code]bool, len(names))
+	for _, name := range names {
+		var code codes.Code
+		_ = code.UnmarshalJSON([]byte(`"` + name + `"`))
+		nonFatal[code] = true
+	}
+
+	return func(
+		ctx context.Context, method string, req, reply any,
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		msg, ok := reply.(proto.Message)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type result struct {
+			reply proto.Message
+			err   error
+		}
+		results := make(chan result, c.MaxAttempts)
+		next := time.NewTimer(0)
+		defer next.Stop()
+
+		var sent, received int
+		for {
+			select {
+			case <-next.C:
+				sent++
+				go func() {
+					r := msg.ProtoReflect().New().Interface()
+					results <- result{reply: r, err: invoker(ctx, method, req, r, cc, opts...)}
+				}()
+				if sent < c.MaxAttempts {
+					next.Reset(c.HedgingDelay)
+				}
+			case r := <-results:
+				received++
+				if r.err == nil {
+					proto.Reset(msg)
+					proto.Merge(msg, r.reply)
+					return nil
+				}
+				if !nonFatal[status.Code(r.err)] || received == c.MaxAttempts {
+					return r.err
+				}
+				if sent < c.MaxAttempts {
+					next.Reset(0)
+				}
+			case <-ctx.Done():
+				return status.FromContextError(ctx.Err()).Err()
+			}
+		}
+	}, nil
+}
+
+// durationString returns the duration in the service config JSON format.
+func durationString(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
@@ -15,41 +15,226 @@
 package grpc //nolint:golint // see doc.go
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
 
 	"github.com/basvanbeek/multierror"
 	"github.com/basvanbeek/run"
 	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry"
+	"github.com/basvanbeek/telemetry/scope"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // package flags.
 const (
 	ServerListenAddress  = "grpc-listen-address"
 	MaxGRPCStreamMsgSize = "max-grpc-stream-msg-size"
+	TLSCertFile          = "grpc-tls-cert-file"
+	TLSKeyFile           = "grpc-tls-key-file"
+	TLSClientCAFile      = "grpc-tls-client-ca-file"
+	TLSClientAuth        = "grpc-tls-client-auth"
+	EnableHealth         = "grpc-enable-health"
+	ShutdownTimeout      = "grpc-shutdown-timeout"
+	UnixSocketMode       = "grpc-unix-socket-mode"
+	DisableReflection    = "grpc-disable-reflection"
+	ReflectionServices   = "grpc-reflection-services"
+	DisablePanicRecovery = "grpc-disable-panic-recovery"
+	AccessLogLevel       = "grpc-access-log-level"
+	AccessLogSkip        = "grpc-access-log-skip"
+	RateLimitMethods     = "grpc-rate-limit-methods"
+	RateLimitPeer        = "grpc-rate-limit-peer"
+	RateLimitBurst       = "grpc-rate-limit-burst"
+	AllowCIDRs           = "grpc-allow-cidrs"
+	DenyCIDRs            = "grpc-deny-cidrs"
+	ACLMethods           = "grpc-acl-methods"
+	EnableAudit          = "grpc-enable-audit"
+	AuditMethods         = "grpc-audit-methods"
+	AuditFields          = "grpc-audit-fields"
+	AuditRedact          = "grpc-audit-redact"
+	EnableMetrics        = "grpc-enable-metrics"
+	EnableOpenTelemetry  = "grpc-enable-otel"
+	EnableAdmin          = "grpc-enable-admin"
+	AdminAddress         = "grpc-admin-address"
+	Compression          = "grpc-compression"
+	Mode                 = "grpc-mode"
+	DefaultTimeout       = "grpc-default-timeout"
+	MethodTimeouts       = "grpc-method-timeouts"
+	MaxTimeout           = "grpc-max-timeout"
+
+	KeepaliveMaxConnectionIdle     = "grpc-keepalive-max-connection-idle"
+	KeepaliveMaxConnectionAge      = "grpc-keepalive-max-connection-age"
+	KeepaliveMaxConnectionAgeGrace = "grpc-keepalive-max-connection-age-grace"
+	KeepaliveTime                  = "grpc-keepalive-time"
+	KeepaliveTimeout               = "grpc-keepalive-timeout"
+	KeepaliveMinPingInterval       = "grpc-keepalive-min-ping-interval"
+	KeepalivePermitWithoutStream   = "grpc-keepalive-permit-without-stream"
+
+	MaxConcurrentStreams  = "grpc-max-concurrent-streams"
+	ConnectionTimeout     = "grpc-connection-timeout"
+	InitialWindowSize     = "grpc-initial-window-size"
+	InitialConnWindowSize = "grpc-initial-conn-window-size"
+	MaxHeaderListSize     = "grpc-max-header-list-size"
 )
 
 // default configuration values.
 const (
 	defaultGRPCAddress          = ":9080"
 	defaultMaxGRPCStreamMsgSize = 20 * 1024 * 1024 // 20MB
+	defaultShutdownTimeout      = 5 * time.Second
+	defaultUnixSocketMode       = "0660"
+	defaultAccessLogLevel       = "info"
 )
 
+var log = scope.Register("grpc", "gRPC server")
+
 // Service implements a run.Group compatible gRPC server.
 type Service struct {
+	// Address holds one or more comma separated TCP or unix:// listen
+	// addresses, all served by the same server.
 	Address              string
+	UnixSocketMode       string
 	MaxGRPCStreamMsgSize int
-	Options              []grpc.ServerOption
-
-	i Interceptors
+	TLSCertFile          string
+	TLSKeyFile           string
+	TLSClientCAFile      string
+	TLSClientAuth        string
+	// CertWatcher optionally holds the file watcher (e.g. filewatcher.Service)
+	// used to reload the TLS credentials on change.
+	CertWatcher  FileWatcher
+	EnableHealth bool
+	// DisableReflection disables the server reflection service, which is
+	// registered by default.
+	DisableReflection bool
+	// ReflectionServices optionally restricts the services exposed through
+	// server reflection. Other services are removed from the file
+	// descriptors served, but the message types they use remain resolvable.
+	ReflectionServices []string
+	// DisablePanicRecovery disables the panic recovery interceptors, which
+	// are registered by default.
+	DisablePanicRecovery bool
+	// AccessLogLevel holds the level ("none", "error", "info" or "debug") to
+	// log handled RPCs at. With "error" only failed RPCs are logged.
+	AccessLogLevel string
+	// AccessLogSkip holds the full methods or services to exclude from the
+	// access log, e.g. "/grpc.health.v1.Health/Check".
+	AccessLogSkip []string
+	// RateLimitMethods holds "method=limit" pairs limiting the requests per
+	// second of a full method or service across all peers.
+	RateLimitMethods []string
+	// RateLimitPeer holds the max. requests per second of a single peer IP
+	// (0 for unlimited).
+	RateLimitPeer float64
+	// RateLimitBurst holds the burst allowed on top of the rate limits
+	// (0 to derive it from the limit).
+	RateLimitBurst int
+	// RateLimiter optionally overrides the in-memory token bucket Limiter,
+	// e.g. to share the limits between server instances.
+	RateLimiter Limiter
+	// AllowCIDRs and DenyCIDRs hold the CIDR ranges or IP addresses of the
+	// peers allowed or denied to call the ACLMethods (full methods or
+	// services, all methods if empty). Denied ranges take precedence.
+	AllowCIDRs []string
+	DenyCIDRs  []string
+	ACLMethods []string
+	// EnableAudit enables audit logging of the AuditMethods (full methods or
+	// services, all methods if empty), including the AuditFields request
+	// fields (dot separated proto field paths). Fields annotated with
+	// debug_redact or named in AuditRedact are redacted.
+	EnableAudit  bool
+	AuditMethods []string
+	AuditFields  []string
+	AuditRedact  []string
+	// AuditSink optionally overrides the sink receiving the audit events,
+	// which logs them to the "grpc-audit" scope by default.
+	AuditSink AuditSink
+	// AuditPrincipal optionally overrides how the principal of an RPC is
+	// obtained, which defaults to the common name of the verified client
+	// certificate.
+	AuditPrincipal func(ctx context.Context) string
+	// DefaultTimeout holds the timeout applied to calls without deadline
+	// (0 for none). MethodTimeouts holds "method=timeout" pairs overriding
+	// it for a full method or service.
+	DefaultTimeout time.Duration
+	MethodTimeouts []string
+	// MaxTimeout holds the max. deadline accepted from clients (0 for
+	// unlimited).
+	MaxTimeout time.Duration
+	// EnableMetrics enables the Prometheus RPC metrics.
+	EnableMetrics bool
+	// Registry optionally holds the Prometheus registry to register the RPC
+	// metrics with. If nil, the Prometheus default registry is used. Share
+	// the registry with the http handler to export the metrics on its
+	// metrics endpoint.
+	Registry *prometheus.Registry
+	// EnableOpenTelemetry enables the OpenTelemetry stats handlers, see
+	// Interceptors.EnableOpenTelemetry.
+	EnableOpenTelemetry bool
+	// EnableAdmin registers the gRPC admin services, like channelz.
+	EnableAdmin bool
+	// AdminAddress optionally holds the address of a separate internal
+	// listener to serve the admin services on.
+	AdminAddress string
+	// Compression holds the compressor ("none", "gzip" or "zstd") clients
+	// obtaining their dial options through Interceptors use by default. The
+	// server supports all compressors and responds with the one the client
+	// used.
+	Compression string
+	// Mode holds the server mode: "default" or "xds". In xDS mode the
+	// listener and security configuration are obtained from the control
+	// plane found in the xDS bootstrap config.
+	Mode string
+	// HTTP optionally holds the HTTP service to serve the RPCs on, for
+	// platforms exposing a single port per workload. The gRPC requests are
+	// routed through its middleware chain, which requires it to accept
+	// HTTP/2 (TLS or h2c) and to not time out long-lived streams. Address is
+	// not listened on in this mode.
+	HTTP Multiplexer
+	// ShutdownTimeout holds the max. time to wait for active RPCs to finish
+	// on shutdown before they are cancelled.
+	ShutdownTimeout time.Duration
+
+	KeepaliveMaxConnectionIdle     time.Duration
+	KeepaliveMaxConnectionAge      time.Duration
+	KeepaliveMaxConnectionAgeGrace time.Duration
+	KeepaliveTime                  time.Duration
+	KeepaliveTimeout               time.Duration
+	KeepaliveMinPingInterval       time.Duration
+	KeepalivePermitWithoutStream   bool
+
+	MaxConcurrentStreams  int
+	ConnectionTimeout     time.Duration
+	InitialWindowSize     int
+	InitialConnWindowSize int
+	MaxHeaderListSize     int
+
+	Options []grpc.ServerOption
+
+	i            Interceptors
+	health       *health.Server
+	healthOnce   sync.Once
+	active       activeRPCs
+	recovery     recovery
+	admin        *grpc.Server
+	adminCleanup func()
 	*grpc.Server
-	l net.Listener
-	f []func(*grpc.Server)
+	srv  server
+	ls   []net.Listener
+	mux  atomic.Pointer[grpc.Server]
+	stop chan struct{}
+	f    []func(*grpc.Server)
+	r    []func(grpc.ServiceRegistrar)
 }
 
 // Name implements run.Unit.
@@ -63,17 +248,36 @@ func (s *Service) FlagSet() *run.FlagSet {
 		s.Address = defaultGRPCAddress
 	}
 
+	if s.UnixSocketMode == "" {
+		s.UnixSocketMode = defaultUnixSocketMode
+	}
+
+	if s.AccessLogLevel == "" {
+		s.AccessLogLevel = defaultAccessLogLevel
+	}
+
 	if s.MaxGRPCStreamMsgSize == 0 {
 		s.MaxGRPCStreamMsgSize = defaultMaxGRPCStreamMsgSize
 	}
 
+	if s.ShutdownTimeout == 0 {
+		s.ShutdownTimeout = defaultShutdownTimeout
+	}
+
 	flags := run.NewFlagSet("gRPC server options")
 
 	flags.StringVarP(
 		&s.Address,
 		ServerListenAddress, "l",
 		s.Address,
-		`gRPC server listen address, e.g. ":9080" or "localhost:9000"`)
+		`gRPC server listen address, e.g. ":9080", "localhost:9000" or "unix:///run/grpc.sock". `+
+			`Multiple addresses can be provided comma separated`)
+
+	flags.StringVar(
+		&s.UnixSocketMode,
+		UnixSocketMode,
+		s.UnixSocketMode,
+		"File mode (octal) of the unix domain socket if listening on a unix:// address")
 
 	flags.IntVar(
 		&s.MaxGRPCStreamMsgSize,
@@ -81,21 +285,303 @@ func (s *Service) FlagSet() *run.FlagSet {
 		defaultMaxGRPCStreamMsgSize,
 		"Max size in bytes of the message sent or received via the stream. Default is 20MB")
 
+	flags.StringVar(
+		&s.TLSCertFile,
+		TLSCertFile,
+		s.TLSCertFile,
+		"PEM encoded server certificate (enables TLS)")
+
+	flags.StringVar(
+		&s.TLSKeyFile,
+		TLSKeyFile,
+		s.TLSKeyFile,
+		"PEM encoded server private key")
+
+	flags.StringVar(
+		&s.TLSClientCAFile,
+		TLSClientCAFile,
+		s.TLSClientCAFile,
+		"CA bundle (PEM) used to verify client certificates (enables mTLS)")
+
+	flags.StringVar(
+		&s.TLSClientAuth,
+		TLSClientAuth,
+		s.TLSClientAuth,
+		`Client certificate mode: "none", "request", "require", `+
+			`"verify-if-given" or "require-and-verify"`)
+
+	flags.BoolVar(
+		&s.EnableHealth,
+		EnableHealth,
+		s.EnableHealth,
+		"Enable the grpc.health.v1.Health service")
+
+	flags.BoolVar(
+		&s.DisableReflection,
+		DisableReflection,
+		s.DisableReflection,
+		"Disable the gRPC server reflection service")
+
+	flags.StringSliceVar(
+		&s.ReflectionServices,
+		ReflectionServices,
+		s.ReflectionServices,
+		"Fully qualified names of the services to expose through server reflection (empty for all)")
+
+	flags.BoolVar(
+		&s.DisablePanicRecovery,
+		DisablePanicRecovery,
+		s.DisablePanicRecovery,
+		"Disable recovering from panics in RPC handlers")
+
+	flags.StringVar(
+		&s.AccessLogLevel,
+		AccessLogLevel,
+		s.AccessLogLevel,
+		`Level to log handled RPCs at: "none", "error" (failed RPCs only), "info" or "debug"`)
+
+	flags.StringSliceVar(
+		&s.AccessLogSkip,
+		AccessLogSkip,
+		s.AccessLogSkip,
+		`Full methods or services to exclude from the access log, e.g. "/grpc.health.v1.Health/Check"`)
+
+	flags.StringSliceVar(
+		&s.RateLimitMethods,
+		RateLimitMethods,
+		s.RateLimitMethods,
+		`Requests per second per full method or service, e.g. "/pkg.Service/Method=100" or "pkg.Service=50"`)
+
+	flags.Float64Var(
+		&s.RateLimitPeer,
+		RateLimitPeer,
+		s.RateLimitPeer,
+		"Max. requests per second per peer IP (0 for unlimited)")
+
+	flags.IntVar(
+		&s.RateLimitBurst,
+		RateLimitBurst,
+		s.RateLimitBurst,
+		"Requests allowed to exceed the rate limits in bursts (0 to derive from the limit)")
+
+	flags.StringSliceVar(
+		&s.AllowCIDRs,
+		AllowCIDRs,
+		s.AllowCIDRs,
+		"CIDR ranges or IP addresses of the peers allowed to call the ACL methods (empty allows all)")
+
+	flags.StringSliceVar(
+		&s.DenyCIDRs,
+		DenyCIDRs,
+		s.DenyCIDRs,
+		"CIDR ranges or IP addresses of the peers denied to call the ACL methods")
+
+	flags.StringSliceVar(
+		&s.ACLMethods,
+		ACLMethods,
+		s.ACLMethods,
+		`Full methods or services the peer allow and deny lists apply to, e.g. "pkg.Admin" (empty for all)`)
+
+	flags.BoolVar(
+		&s.EnableAudit,
+		EnableAudit,
+		s.EnableAudit,
+		"Enable audit logging of RPCs")
+
+	flags.StringSliceVar(
+		&s.AuditMethods,
+		AuditMethods,
+		s.AuditMethods,
+		"Full methods or services to audit (empty for all)")
+
+	flags.StringSliceVar(
+		&s.AuditFields,
+		AuditFields,
+		s.AuditFields,
+		`Request fields to include in audit events, e.g. "user.id"`)
+
+	flags.StringSliceVar(
+		&s.AuditRedact,
+		AuditRedact,
+		s.AuditRedact,
+		"Request field names to redact in addition to fields annotated with debug_redact")
+
+	flags.DurationVar(
+		&s.DefaultTimeout,
+		DefaultTimeout,
+		s.DefaultTimeout,
+		"Timeout applied to calls without deadline (0 for none)")
+
+	flags.StringSliceVar(
+		&s.MethodTimeouts,
+		MethodTimeouts,
+		s.MethodTimeouts,
+		`Timeouts per full method or service overriding the default, e.g. "/pkg.Service/Method=30s"`)
+
+	flags.DurationVar(
+		&s.MaxTimeout,
+		MaxTimeout,
+		s.MaxTimeout,
+		"Max. deadline accepted from clients, longer ones are rejected (0 for unlimited)")
+
+	flags.BoolVar(
+		&s.EnableMetrics,
+		EnableMetrics,
+		s.EnableMetrics,
+		"Enable Prometheus RPC metrics")
+
+	flags.BoolVar(
+		&s.EnableOpenTelemetry,
+		EnableOpenTelemetry,
+		s.EnableOpenTelemetry,
+		"Enable OpenTelemetry tracing and metrics of RPCs")
+
+	flags.BoolVar(
+		&s.EnableAdmin,
+		EnableAdmin,
+		s.EnableAdmin,
+		"Enable the gRPC admin services (channelz)")
+
+	flags.StringVar(
+		&s.AdminAddress,
+		AdminAddress,
+		s.AdminAddress,
+		`Optional internal listen address for the admin services, e.g. "localhost:9081"`)
+
+	flags.StringVar(
+		&s.Compression,
+		Compression,
+		s.Compression,
+		`Default compression of gRPC clients using the server's interceptors: "none", "gzip" or "zstd"`)
+
+	flags.StringVar(
+		&s.Mode,
+		Mode,
+		s.Mode,
+		`Server mode: "default" or "xds" (proxyless service mesh using the xDS bootstrap config)`)
+
+	flags.DurationVar(
+		&s.ShutdownTimeout,
+		ShutdownTimeout,
+		s.ShutdownTimeout,
+		"Max. time to wait for active RPCs to finish on shutdown")
+
+	flags.DurationVar(
+		&s.KeepaliveMaxConnectionIdle,
+		KeepaliveMaxConnectionIdle,
+		s.KeepaliveMaxConnectionIdle,
+		"Time after which an idle connection is closed (0 for infinity)")
+
+	flags.DurationVar(
+		&s.KeepaliveMaxConnectionAge,
+		KeepaliveMaxConnectionAge,
+		s.KeepaliveMaxConnectionAge,
+		"Max. connection age after which clients are asked to reconnect, "+
+			"e.g. to rebalance behind a load balancer (0 for infinity)")
+
+	flags.DurationVar(
+		&s.KeepaliveMaxConnectionAgeGrace,
+		KeepaliveMaxConnectionAgeGrace,
+		s.KeepaliveMaxConnectionAgeGrace,
+		"Time to let active RPCs finish after the max. connection age is reached (0 for infinity)")
+
+	flags.DurationVar(
+		&s.KeepaliveTime,
+		KeepaliveTime,
+		s.KeepaliveTime,
+		"Idle time after which the server pings the client (0 for the default of 2h)")
+
+	flags.DurationVar(
+		&s.KeepaliveTimeout,
+		KeepaliveTimeout,
+		s.KeepaliveTimeout,
+		"Time to wait for a ping response before closing the connection (0 for the default of 20s)")
+
+	flags.DurationVar(
+		&s.KeepaliveMinPingInterval,
+		KeepaliveMinPingInterval,
+		s.KeepaliveMinPingInterval,
+		"Min. interval clients are allowed to ping at (0 for the default of 5m)")
+
+	flags.BoolVar(
+		&s.KeepalivePermitWithoutStream,
+		KeepalivePermitWithoutStream,
+		s.KeepalivePermitWithoutStream,
+		"Allow client pings on connections without active streams")
+
+	flags.IntVar(
+		&s.MaxConcurrentStreams,
+		MaxConcurrentStreams,
+		s.MaxConcurrentStreams,
+		"Max. concurrent streams per client connection (0 for unlimited)")
+
+	flags.DurationVar(
+		&s.ConnectionTimeout,
+		ConnectionTimeout,
+		s.ConnectionTimeout,
+		"Max. time to establish a connection, including the TLS handshake (0 for the default of 120s)")
+
+	flags.IntVar(
+		&s.InitialWindowSize,
+		InitialWindowSize,
+		s.InitialWindowSize,
+		"Initial flow control window size of a stream in bytes, min. 65536 (0 for the default)")
+
+	flags.IntVar(
+		&s.InitialConnWindowSize,
+		InitialConnWindowSize,
+		s.InitialConnWindowSize,
+		"Initial flow control window size of a connection in bytes, min. 65536 (0 for the default)")
+
+	flags.IntVar(
+		&s.MaxHeaderListSize,
+		MaxHeaderListSize,
+		s.MaxHeaderListSize,
+		"Max. size of the header list accepted in bytes (0 for the default)")
+
 	return flags
 }
 
-// Validate implements run.Config.
-func (s *Service) Validate() error {
+// validateListenAddress checks the TCP and unix domain socket listen
+// addresses.
+func (s *Service) validateListenAddress() error {
 	var mErr error
 
-	if s.Address != "" {
-		if _, _, err := net.SplitHostPort(s.Address); err != nil {
+	addresses := s.addresses()
+	if len(addresses) == 0 {
+		return flag.NewValidationError(ServerListenAddress, flag.ErrRequired)
+	}
+
+	var unix bool
+	for _, address := range addresses {
+		if path, ok := unixSocketPath(address); ok {
+			unix = true
+			if path == "" {
+				mErr = multierror.Append(mErr,
+					flag.NewValidationError(ServerListenAddress, flag.ErrInvalidPath))
+			}
+		} else if _, _, err := net.SplitHostPort(address); err != nil {
 			mErr = multierror.Append(mErr,
 				flag.NewValidationError(ServerListenAddress, err))
 		}
-	} else {
-		mErr = multierror.Append(mErr,
-			flag.NewValidationError(ServerListenAddress, flag.ErrRequired))
+	}
+
+	if unix {
+		if _, err := parseFileMode(s.UnixSocketMode); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(UnixSocketMode, err))
+		}
+	}
+
+	return mErr
+}
+
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	if err := s.validateListenAddress(); err != nil {
+		mErr = multierror.Append(mErr, err)
 	}
 
 	if s.MaxGRPCStreamMsgSize < 4*1024*1024 {
@@ -103,44 +589,238 @@ func (s *Service) Validate() error {
 			flag.NewValidationError(MaxGRPCStreamMsgSize, flag.ValidationError("must be at least 4MB")))
 	}
 
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(TLSCertFile,
+				flag.ValidationError("certificate and key must be provided together")))
+	} else if s.TLSCertFile != "" {
+		if _, err := tls.LoadX509KeyPair(s.TLSCertFile, s.TLSKeyFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(TLSCertFile, err))
+		}
+	}
+
+	clientAuth, err := parseClientAuth(s.TLSClientAuth)
+	if err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(TLSClientAuth, err))
+	} else if clientAuth >= tls.VerifyClientCertIfGiven && s.TLSClientCAFile == "" {
+		// don't verify client certificates against the system roots
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(TLSClientCAFile,
+				flag.ValidationError("required to verify client certificates")))
+	}
+
+	if s.TLSClientCAFile != "" {
+		if _, err = loadCertPool(s.TLSClientCAFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(TLSClientCAFile, err))
+		}
+	}
+
+	for _, t := range []struct {
+		flag string
+		d    time.Duration
+	}{
+		{ShutdownTimeout, s.ShutdownTimeout},
+		{KeepaliveMaxConnectionIdle, s.KeepaliveMaxConnectionIdle},
+		{KeepaliveMaxConnectionAge, s.KeepaliveMaxConnectionAge},
+		{KeepaliveMaxConnectionAgeGrace, s.KeepaliveMaxConnectionAgeGrace},
+		{KeepaliveTime, s.KeepaliveTime},
+		{KeepaliveTimeout, s.KeepaliveTimeout},
+		{KeepaliveMinPingInterval, s.KeepaliveMinPingInterval},
+		{ConnectionTimeout, s.ConnectionTimeout},
+	} {
+		if t.d < 0 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(t.flag, flag.ErrInvalidVal))
+		}
+	}
+
+	if !s.tlsEnabled() && (s.TLSClientCAFile != "" || clientAuth != tls.NoClientCert) {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(TLSClientAuth,
+				flag.ValidationError("client certificates require a server certificate")))
+	}
+
+	if err := s.validateLimits(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if _, ok := telemetry.FromLevel(s.AccessLogLevel); !ok {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(AccessLogLevel, flag.ErrInvalidVal))
+	}
+
+	if err := s.validateRateLimits(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if err := s.validateDeadlines(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if err := s.validateACL(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if err := s.validateAudit(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if s.AdminAddress != "" {
+		if !s.EnableAdmin {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(AdminAddress,
+					flag.ValidationError("admin services are disabled")))
+		} else if _, _, err := net.SplitHostPort(s.AdminAddress); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(AdminAddress, err))
+		}
+	}
+
+	if err := s.validateMode(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if err := s.validateMux(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if !validCompression(s.Compression) {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(Compression, flag.ErrInvalidVal))
+	}
+
+	if s.DisableReflection && len(s.ReflectionServices) > 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(ReflectionServices,
+				flag.ValidationError("reflection is disabled")))
+	}
+
 	return mErr
 }
 
 // Serve implements run.Service.
 func (s *Service) Serve() error {
-	s.Options = append([]grpc.ServerOption{
+	opts := []grpc.ServerOption{
 		grpc.MaxRecvMsgSize(s.MaxGRPCStreamMsgSize),
 		grpc.MaxSendMsgSize(s.MaxGRPCStreamMsgSize),
-	}, s.Options...)
+		grpc.StatsHandler(&s.active),
+	}
+	opts = append(opts, s.keepaliveOptions()...)
+	opts = append(opts, s.limitOptions()...)
+	var creds credentials.TransportCredentials
+	if s.tlsEnabled() {
+		cfg, err := s.tlsConfig()
+		if err != nil {
+			return err
+		}
+		creds = credentials.NewTLS(cfg)
+	}
+	if creds != nil && s.Mode != ModeXDS {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	s.Options = append(opts, s.Options...)
 
+	// built-in interceptors sharing a phase are chained in this order
+	s.addAccessLog()
+	if s.EnableMetrics {
+		if err := s.addMetrics(); err != nil {
+			return err
+		}
+	}
+	if !s.DisablePanicRecovery {
+		s.addRecovery()
+	}
+	s.addACL()
+	s.addRateLimit()
+	s.addDeadlines()
+	s.addAudit()
+	if s.EnableOpenTelemetry {
+		s.i.EnableOpenTelemetry()
+	}
+	s.i.UseCompressor(s.Compression)
 	s.Options = append(s.Options, s.i.GetServerOptions()...)
 
-	s.Server = grpc.NewServer(s.Options...)
+	if err := s.newServer(creds); err != nil {
+		return err
+	}
 
-	// now that we have the internal grpc.Server object, run all callbacks
-	// provided with AttachToServer to register the gRPC services to handle.
-	for _, f := range s.f {
-		f(s.Server)
+	if s.EnableHealth {
+		s.registerHealth()
+	}
+	if !s.DisableReflection {
+		s.registerReflection()
+	}
+	if s.EnableAdmin {
+		if err := s.setupAdmin(); err != nil {
+			return err
+		}
 	}
 
-	reflection.Register(s.Server)
+	if s.HTTP != nil {
+		return s.serveMux()
+	}
 
 	// listen and serve time
-	var err error
-	s.l, err = net.Listen("tcp", s.Address)
-	if err != nil {
-		return err
+	for _, address := range s.addresses() {
+		l, err := s.listen(address)
+		if err != nil {
+			s.closeListeners()
+			return err
+		}
+		s.ls = append(s.ls, l)
+	}
+
+	return s.serveListeners()
+}
+
+// newServer creates the gRPC server for the configured mode and registers
+// the attached services.
+func (s *Service) newServer(creds credentials.TransportCredentials) error {
+	if s.Mode == ModeXDS {
+		if len(s.f) > 0 {
+			return errors.New("services registered with Attach are not " +
+				"supported in xDS mode, use AttachRegistrar instead")
+		}
+		x, err := s.newXDSServer(creds)
+		if err != nil {
+			return err
+		}
+		s.srv = x
+	} else {
+		s.Server = grpc.NewServer(s.Options...)
+		s.srv = s.Server
+
+		// now that we have the internal grpc.Server object, run all callbacks
+		// provided with Attach to register the gRPC services to handle.
+		for _, f := range s.f {
+			f(s.Server)
+		}
 	}
 
-	return s.Server.Serve(s.l)
+	for _, f := range s.r {
+		f(s.srv)
+	}
+	return nil
 }
 
 // GracefulStop implements run.Service.
 func (s *Service) GracefulStop() {
-	if s.l != nil {
-		s.Stop()
-		_ = s.l.Close()
+	if s.health != nil {
+		// let clients and probes know we're going away
+		s.health.Shutdown()
+	}
+	if len(s.ls) > 0 {
+		s.shutdown()
+		s.closeListeners()
 	}
+	if s.stop != nil {
+		s.shutdownMux()
+		close(s.stop)
+	}
+	s.stopAdmin()
 }
 
 // Attach allows one to register gRPC services to this server. Once the actual
@@ -150,19 +830,32 @@ func (s *Service) Attach(fn func(*grpc.Server)) {
 	s.f = append(s.f, fn)
 }
 
+// AttachRegistrar allows one to register gRPC services to this server like
+// Attach, but is supported in all modes including xDS.
+func (s *Service) AttachRegistrar(fn func(grpc.ServiceRegistrar)) {
+	s.r = append(s.r, fn)
+}
+
 // Interceptors returns the Interceptors handler for this gRPC Service.
 func (s *Service) Interceptors() *Interceptors {
 	return &s.i
 }
 
 // GetGrpcAddress returns the grpc address assigned to the server instance.
-// If the address is not configured, an error is returned.
+// If multiple addresses are configured, the first one is returned. If the
+// address is not configured, an error is returned.
 func (s *Service) GetGrpcAddress() (string, error) {
-	if s.Address == "" {
+	addresses := s.addresses()
+	if len(addresses) == 0 {
 		return "", errors.New("s.Address is not set")
 	}
+	address := addresses[0]
+	if path, ok := unixSocketPath(address); ok {
+		// gRPC target syntax for absolute socket paths
+		return "unix://" + path, nil
+	}
 	// we need an address we can use in a client. the listener address might not be directly suitable
-	host, port, err := net.SplitHostPort(s.Address)
+	host, port, err := net.SplitHostPort(address)
 	if err != nil {
 		return "", fmt.Errorf("s.GrpcAddress is invalid: %w", err)
 	}
@@ -175,6 +868,7 @@ func (s *Service) GetGrpcAddress() (string, error) {
 }
 
 var (
-	_ run.Config  = (*Service)(nil)
-	_ run.Service = (*Service)(nil)
+	_ run.Config    = (*Service)(nil)
+	_ run.PreRunner = (*Service)(nil)
+	_ run.Service   = (*Service)(nil)
 )
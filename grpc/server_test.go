@@ -0,0 +1,389 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	_ "google.golang.org/grpc/interop/grpc_testing" // test.proto holds several services
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	testService = "test.Echo"
+	testMethod  = "/" + testService + "/Call"
+)
+
+// echoService returns the registration of a unary test service calling fn
+// for each RPC.
+func echoService(fn func(ctx context.Context) error) func(*grpc.Server) {
+	return func(s *grpc.Server) {
+		s.RegisterService(&grpc.ServiceDesc{
+			ServiceName: testService,
+			HandlerType: (*any)(nil),
+			Methods: []grpc.MethodDesc{{
+				MethodName: "Call",
+				Handler: func(_ any, ctx context.Context, dec func(any) error,
+					interceptor grpc.UnaryServerInterceptor,
+				) (any, error) {
+					if err := dec(new(emptypb.Empty)); err != nil {
+						return nil, err
+					}
+					handler := func(ctx context.Context, _ any) (any, error) {
+						return new(emptypb.Empty), fn(ctx)
+					}
+					if interceptor == nil {
+						return handler(ctx, nil)
+					}
+					return interceptor(ctx, nil,
+						&grpc.UnaryServerInfo{FullMethod: testMethod}, handler)
+				},
+			}},
+		}, nil)
+	}
+}
+
+func call(ctx context.Context, conn *grpc.ClientConn) error {
+	return conn.Invoke(ctx, testMethod, new(emptypb.Empty), new(emptypb.Empty))
+}
+
+// serve starts the Service on a unix domain socket and returns a client
+// connection to it.
+func serve(t *testing.T, s *Service) *grpc.ClientConn {
+	path := filepath.Join(t.TempDir(), "grpc.sock")
+	s.Address = unixPrefix + path
+	s.FlagSet()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.Serve() }()
+	t.Cleanup(func() {
+		s.GracefulStop()
+		if err := <-errc; err != nil {
+			t.Error(err)
+		}
+	})
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	target, err := s.GetGrpcAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestValidate(t *testing.T) {
+	for name, test := range map[string]struct {
+		s    *Service
+		flag string
+	}{
+		"unix socket without path":  {&Service{Address: "unix://"}, ServerListenAddress},
+		"certificate without key":   {&Service{TLSCertFile: "cert.pem"}, TLSCertFile},
+		"client auth without TLS":   {&Service{TLSClientAuth: "require"}, TLSClientAuth},
+		"verify without client CA":  {&Service{TLSClientAuth: "verify-if-given"}, TLSClientCAFile},
+		"negative shutdown timeout": {&Service{ShutdownTimeout: -time.Second}, ShutdownTimeout},
+		"negative keepalive time":   {&Service{KeepaliveTime: -time.Second}, KeepaliveTime},
+		"small window size":         {&Service{InitialWindowSize: 1024}, InitialWindowSize},
+		"negative streams":          {&Service{MaxConcurrentStreams: -1}, MaxConcurrentStreams},
+		"invalid rate limit":        {&Service{RateLimitMethods: []string{"svc=x"}}, RateLimitMethods},
+		"invalid method timeout":    {&Service{MethodTimeouts: []string{"svc"}}, MethodTimeouts},
+		"invalid CIDR":              {&Service{AllowCIDRs: []string{"10.0.0.0/33"}}, AllowCIDRs},
+		"ACL methods without lists": {&Service{ACLMethods: []string{"svc"}}, ACLMethods},
+		"unknown compression":       {&Service{Compression: "brotli"}, Compression},
+		"admin address disabled":    {&Service{AdminAddress: ":9091"}, AdminAddress},
+		"reflection disabled": {
+			&Service{DisableReflection: true, ReflectionServices: []string{"svc"}},
+			ReflectionServices,
+		},
+		"mux with TLS": {
+			&Service{HTTP: &testMux{}, TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"},
+			TLSCertFile,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := test.s
+			s.FlagSet()
+			err := s.Validate()
+			if err == nil {
+				t.Fatal("expected validation error")
+			}
+			if !strings.Contains(err.Error(), "--"+test.flag+" error") {
+				t.Errorf("expected error for %s, got %v", test.flag, err)
+			}
+		})
+	}
+
+	s := &Service{}
+	s.FlagSet()
+	if err := s.Validate(); err != nil {
+		t.Errorf("expected defaults to be valid, got %v", err)
+	}
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	s := &Service{UnixSocketMode: "0600"}
+	s.Attach(echoService(func(context.Context) error { return nil }))
+	conn := serve(t, s)
+
+	if err := call(context.Background(), conn); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := unixSocketPath(s.Address)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != fs.FileMode(0o600) {
+		t.Errorf("expected socket mode 0600, got %o", mode)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	s := &Service{}
+	s.Attach(echoService(func(context.Context) error { panic("boom") }))
+	conn := serve(t, s)
+
+	err := call(context.Background(), conn)
+	if code := status.Code(err); code != codes.Internal {
+		t.Errorf("expected %v, got %v", codes.Internal, err)
+	}
+	if n := s.Panics(); n != 1 {
+		t.Errorf("expected 1 recovered panic, got %d", n)
+	}
+}
+
+func TestDeadlines(t *testing.T) {
+	s := &Service{
+		DefaultTimeout: time.Minute,
+		MaxTimeout:     time.Hour,
+		MethodTimeouts: []string{testService + "=10s"},
+	}
+	var remaining time.Duration
+	s.Attach(echoService(func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return errors.New("expected deadline")
+		}
+		remaining = time.Until(deadline)
+		return nil
+	}))
+	conn := serve(t, s)
+
+	if err := call(context.Background(), conn); err != nil {
+		t.Fatal(err)
+	}
+	if remaining <= 0 || remaining > 10*time.Second {
+		t.Errorf("expected the method timeout to apply, got %v", remaining)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+	if code := status.Code(call(ctx, conn)); code != codes.InvalidArgument {
+		t.Errorf("expected %v for deadline beyond max. timeout, got %v",
+			codes.InvalidArgument, code)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := &Service{RateLimitMethods: []string{testService + "=0.001"}}
+	s.Attach(echoService(func(context.Context) error { return nil }))
+	conn := serve(t, s)
+
+	if err := call(context.Background(), conn); err != nil {
+		t.Fatal(err)
+	}
+	err := call(context.Background(), conn)
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("expected %v, got %v", codes.ResourceExhausted, err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	s := &Service{EnableHealth: true}
+	s.Attach(echoService(func(context.Context) error { return nil }))
+	conn := serve(t, s)
+	client := healthpb.NewHealthClient(conn)
+
+	check := func() healthpb.HealthCheckResponse_ServingStatus {
+		res, err := client.Check(context.Background(),
+			&healthpb.HealthCheckRequest{Service: testService})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.GetStatus()
+	}
+	if st := check(); st != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected registered service to be serving, got %v", st)
+	}
+	s.SetServing(testService, false)
+	if st := check(); st != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected service to not be serving, got %v", st)
+	}
+}
+
+func TestReflectionServices(t *testing.T) {
+	s := &Service{
+		EnableHealth:       true,
+		ReflectionServices: []string{healthpb.Health_ServiceDesc.ServiceName},
+	}
+	s.Attach(echoService(func(context.Context) error { return nil }))
+	conn := serve(t, s)
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).
+		ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = stream.CloseSend() }()
+
+	if err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var services []string
+	for _, svc := range res.GetListServicesResponse().GetService() {
+		services = append(services, svc.GetName())
+	}
+	if !slices.Equal(services, s.ReflectionServices) {
+		t.Errorf("expected services %v, got %v", s.ReflectionServices, services)
+	}
+}
+
+func TestReflectionHiddenServices(t *testing.T) {
+	s := &Service{ReflectionServices: []string{"grpc.testing.TestService"}}
+	conn := serve(t, s)
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).
+		ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = stream.CloseSend() }()
+
+	tests := []struct {
+		name string
+		req  *reflectionpb.ServerReflectionRequest
+	}{
+		{"service", &reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{
+				FileContainingSymbol: "grpc.testing.TestService",
+			},
+		}},
+		{"file", &reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{
+				FileByFilename: "grpc/testing/test.proto",
+			},
+		}},
+		{"hidden service", &reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{
+				FileContainingSymbol: "grpc.testing.UnimplementedService",
+			},
+		}},
+	}
+	for _, tt := range tests {
+		if err = stream.Send(tt.req); err != nil {
+			t.Fatal(err)
+		}
+		res, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var services []string
+		for _, b := range res.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			var fd descriptorpb.FileDescriptorProto
+			if err = proto.Unmarshal(b, &fd); err != nil {
+				t.Fatal(err)
+			}
+			for _, svc := range fd.GetService() {
+				services = append(services, fd.GetPackage()+"."+svc.GetName())
+			}
+		}
+		want := []string{"grpc.testing.TestService"}
+		if tt.name == "hidden service" {
+			want = nil
+		}
+		if !slices.Equal(services, want) {
+			t.Errorf("%s: expected services %v, got %v", tt.name, want, services)
+		}
+	}
+}
+
+func TestInterceptorPhases(t *testing.T) {
+	var (
+		order []string
+		i     Interceptors
+	)
+	record := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (any, error) {
+			order = append(order, name)
+			return handler(ctx, req)
+		}
+	}
+	i.AddUnaryServer(record("business"))
+	i.AddUnaryServerPhase(PhaseAuth, record("auth"))
+	i.AddUnaryServerPhase(PhaseObservability, record("observability-1"))
+	i.AddUnaryServerPhase(PhaseObservability, record("observability-2"))
+
+	s := &Service{}
+	s.i = i
+	s.Attach(echoService(func(context.Context) error { return nil }))
+	conn := serve(t, s)
+	if err := call(context.Background(), conn); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"observability-1", "observability-2", "auth", "business"}
+	if !slices.Equal(order, want) {
+		t.Errorf("expected %v, got %v", want, order)
+	}
+}
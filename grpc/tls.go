@@ -0,0 +1,101 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// supported client certificate verification modes.
+const (
+	ClientAuthNone             = "none"
+	ClientAuthRequest          = "request"
+	ClientAuthRequire          = "require"
+	ClientAuthVerifyIfGiven    = "verify-if-given"
+	ClientAuthRequireAndVerify = "require-and-verify"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	ClientAuthNone:             tls.NoClientCert,
+	ClientAuthRequest:          tls.RequestClientCert,
+	ClientAuthRequire:          tls.RequireAnyClientCert,
+	ClientAuthVerifyIfGiven:    tls.VerifyClientCertIfGiven,
+	ClientAuthRequireAndVerify: tls.RequireAndVerifyClientCert,
+}
+
+func parseClientAuth(mode string) (tls.ClientAuthType, error) {
+	if mode == "" {
+		return tls.NoClientCert, nil
+	}
+	ca, ok := clientAuthTypes[strings.ToLower(mode)]
+	if !ok {
+		return tls.NoClientCert, fmt.Errorf("unknown client auth mode %q", mode)
+	}
+	return ca, nil
+}
+
+func loadCertPool(fileName string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("no valid certificates found in CA bundle")
+	}
+	return pool, nil
+}
+
+// tlsEnabled returns true if a server certificate is configured.
+func (s *Service) tlsEnabled() bool {
+	return s.TLSCertFile != ""
+}
+
+// tlsConfig returns the server TLS config based on the configured server
+// certificate and client certificate verification settings. If a FileWatcher
+// is configured, the certificate and client CA bundle are reloaded on change.
+func (s *Service) tlsConfig() (*tls.Config, error) {
+	r, err := newCertReloader(s.TLSCertFile, s.TLSKeyFile, s.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		GetCertificate: r.getCertificate,
+		ClientCAs:      r.pool,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if cfg.ClientAuth, err = parseClientAuth(s.TLSClientAuth); err != nil {
+		return nil, err
+	}
+	if s.TLSClientCAFile != "" && cfg.ClientAuth == tls.NoClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if s.CertWatcher != nil {
+		if s.TLSClientCAFile != "" {
+			cfg.GetConfigForClient = r.configForClient(cfg)
+		}
+		if err = r.watch(s.CertWatcher); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
@@ -12,29 +12,137 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package grpcvalidator holds a protoc-gen-validate gRPC server interceptor middleware.
+// Package grpcvalidator holds a request validating gRPC server interceptor
+// middleware supporting protoc-gen-validate and protovalidate messages.
 package grpcvalidator
 
 import (
 	"context"
+	"errors"
 
+	"buf.build/go/protovalidate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
+// validatorAll is implemented by protoc-gen-validate messages and returns all
+// violations instead of only the first.
+type validatorAll interface {
+	ValidateAll() error
+}
+
 type validator interface {
 	Validate() error
 }
 
+// validate validates the message using the most complete validation it
+// supports: protoc-gen-validate's ValidateAll, its Validate or protovalidate
+// rules found in the message descriptor.
+func validate(m interface{}) error {
+	var err error
+	switch v := m.(type) {
+	case validatorAll:
+		err = v.ValidateAll()
+	case validator:
+		err = v.Validate()
+	case proto.Message:
+		err = protovalidate.Validate(v)
+		var vErr *protovalidate.ValidationError
+		if err != nil && !errors.As(err, &vErr) {
+			// compilation or runtime errors of the rules are not caused by
+			// the request payload
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	if err != nil {
+		return invalidArgument(err)
+	}
+	return nil
+}
+
+// fieldError is implemented by the validation errors of protoc-gen-validate
+// messages.
+type fieldError interface {
+	Field() string
+	Reason() string
+	Cause() error
+}
+
+// multiError is implemented by the errors returned from protoc-gen-validate's
+// ValidateAll.
+type multiError interface {
+	AllErrors() []error
+}
+
+// invalidArgument returns an InvalidArgument status holding the violations
+// found in err as errdetails.BadRequest field violations.
+func invalidArgument(err error) error {
+	st, dErr := status.New(codes.InvalidArgument, "request validation failed").
+		WithDetails(&errdetails.BadRequest{FieldViolations: fieldViolations("", err)})
+	if dErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return st.Err()
+}
+
+// fieldViolations flattens the violations found in err, prefixing field paths
+// with the provided parent field.
+func fieldViolations(parent string, err error) []*errdetails.BadRequest_FieldViolation {
+	var (
+		vErr *protovalidate.ValidationError
+		fErr fieldError
+		mErr multiError
+	)
+	switch {
+	case errors.As(err, &vErr):
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(vErr.Violations))
+		for _, v := range vErr.Violations {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       joinField(parent, protovalidate.FieldPathString(v.Proto.GetField())),
+				Description: v.Proto.GetMessage(),
+			})
+		}
+		return violations
+	case errors.As(err, &mErr):
+		var violations []*errdetails.BadRequest_FieldViolation
+		for _, e := range mErr.AllErrors() {
+			violations = append(violations, fieldViolations(parent, e)...)
+		}
+		return violations
+	case errors.As(err, &fErr):
+		field := joinField(parent, fErr.Field())
+		if cause := fErr.Cause(); cause != nil &&
+			(errors.As(cause, &fErr) || errors.As(cause, &mErr)) {
+			// embedded message failed validation, report its violations
+			return fieldViolations(field, cause)
+		}
+		return []*errdetails.BadRequest_FieldViolation{{
+			Field:       field,
+			Description: fErr.Reason(),
+		}}
+	}
+	return []*errdetails.BadRequest_FieldViolation{{
+		Field:       parent,
+		Description: err.Error(),
+	}}
+}
+
+func joinField(parent, field string) string {
+	if parent == "" || field == "" {
+		return parent + field
+	}
+	return parent + "." + field
+}
+
 // UnaryServerInterceptor returns a grpc.UnaryServerInterceptor to validate
 // the incoming request payload prior to handing over to the business logic.
 func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		if v, ok := req.(validator); ok {
-			if err := v.Validate(); err != nil {
-				return nil, status.Error(codes.InvalidArgument, err.Error())
-			}
+		if err := validate(req); err != nil {
+			return nil, err
 		}
 		return handler(ctx, req)
 	}
@@ -56,10 +164,5 @@ func (w *wrapper) RecvMsg(m interface{}) error {
 	if err := w.ServerStream.RecvMsg(m); err != nil {
 		return err
 	}
-	if v, ok := m.(validator); ok {
-		if err := v.Validate(); err != nil {
-			return status.Error(codes.InvalidArgument, err.Error())
-		}
-	}
-	return nil
+	return validate(m)
 }
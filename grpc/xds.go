@@ -0,0 +1,136 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc //nolint:golint // see doc.go
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	xdscreds "google.golang.org/grpc/credentials/xds"
+	"google.golang.org/grpc/xds"
+
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// supported server and client modes.
+const (
+	ModeDefault = "default"
+	// ModeXDS obtains the listener, route and security configuration of
+	// servers and the endpoints of client targets from an xDS control
+	// plane, e.g. Istio or Traffic Director (proxyless gRPC).
+	ModeXDS = "xds"
+)
+
+// xDS bootstrap config environment variables read by gRPC.
+const (
+	xdsBootstrapFileEnv   = "GRPC_XDS_BOOTSTRAP"
+	xdsBootstrapConfigEnv = "GRPC_XDS_BOOTSTRAP_CONFIG"
+	xdsScheme             = "xds:///"
+)
+
+// server holds the methods shared by grpc.Server and xds.GRPCServer.
+type server interface {
+	grpc.ServiceRegistrar
+	GetServiceInfo() map[string]grpc.ServiceInfo
+	Serve(l net.Listener) error
+	Stop()
+	GracefulStop()
+}
+
+var (
+	_ server = (*grpc.Server)(nil)
+	_ server = (*xds.GRPCServer)(nil)
+)
+
+var errXDSBootstrap = errors.New("xDS bootstrap config not found, set " +
+	xdsBootstrapFileEnv + " or " + xdsBootstrapConfigEnv)
+
+func validMode(mode string) bool {
+	return mode == "" || mode == ModeDefault || mode == ModeXDS
+}
+
+// validateMode checks the server mode and its requirements.
+func (s *Service) validateMode() error {
+	switch {
+	case !validMode(s.Mode):
+		return flag.NewValidationError(Mode, flag.ErrInvalidVal)
+	case s.Mode != ModeXDS:
+		return nil
+	case !xdsBootstrapFound():
+		return flag.NewValidationError(Mode, errXDSBootstrap)
+	}
+	for _, address := range s.addresses() {
+		if _, ok := unixSocketPath(address); ok {
+			return flag.NewValidationError(Mode,
+				flag.ValidationError("not supported on unix domain sockets"))
+		}
+	}
+	return nil
+}
+
+// xdsBootstrapFound returns true if gRPC can discover an xDS bootstrap
+// config.
+func xdsBootstrapFound() bool {
+	return os.Getenv(xdsBootstrapFileEnv) != "" || os.Getenv(xdsBootstrapConfigEnv) != ""
+}
+
+// fallbackCreds returns the provided credentials or insecure ones if nil.
+func fallbackCreds(creds credentials.TransportCredentials) credentials.TransportCredentials {
+	if creds == nil {
+		return insecure.NewCredentials()
+	}
+	return creds
+}
+
+// newXDSServer creates a server using the xDS control plane for its
+// configuration. The provided credentials are used if the control plane
+// does not provide security configuration.
+func (s *Service) newXDSServer(creds credentials.TransportCredentials) (*xds.GRPCServer, error) {
+	xc, err := xdscreds.NewServerCredentials(xdscreds.ServerOptions{
+		FallbackCreds: fallbackCreds(creds),
+	})
+	if err != nil {
+		return nil, err
+	}
+	opts := append([]grpc.ServerOption{
+		grpc.Creds(xc),
+		xds.ServingModeCallback(func(addr net.Addr, args xds.ServingModeChangeArgs) {
+			log.Info("xDS serving mode changed", "address", addr.String(),
+				"mode", args.Mode.String(), "error", args.Err)
+		}),
+	}, s.Options...)
+	return xds.NewGRPCServer(opts...)
+}
+
+// xdsTarget returns the target with the xds scheme.
+func xdsTarget(target string) string {
+	if strings.HasPrefix(target, xdsScheme) {
+		return target
+	}
+	return xdsScheme + target
+}
+
+// xdsClientCreds returns the client credentials using the xDS control plane
+// for security configuration, falling back to the provided credentials.
+func xdsClientCreds(creds credentials.TransportCredentials) (credentials.TransportCredentials, error) {
+	return xdscreds.NewClientCredentials(xdscreds.ClientOptions{
+		FallbackCreds: fallbackCreds(creds),
+	})
+}
@@ -0,0 +1,83 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// isDebugEndpoint returns true for the paths of the pprof and expvar
+// endpoints.
+func isDebugEndpoint(path string) bool {
+	return path == "/debug/vars" || strings.HasPrefix(path, "/debug/pprof/")
+}
+
+// AdminHandle registers the handler for the given pattern on the admin
+// listener. It has no effect if no admin listener address is configured.
+func (s *Service) AdminHandle(pattern string, h http.Handler) {
+	s.adminMux().Handle(pattern, h)
+}
+
+// adminMux returns the mux of the admin listener, creating it if needed.
+func (s *Service) adminMux() *http.ServeMux {
+	if s.admin == nil {
+		s.admin = &http.Server{
+			Handler:           http.NewServeMux(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+	}
+	return s.admin.Handler.(*http.ServeMux)
+}
+
+// setupAdmin creates the admin listener, exposing the pprof, expvar and build
+// info debug endpoints as well as the maintenance mode toggle, and registers
+// it to be served alongside the main listener.
+func (s *Service) setupAdmin() error {
+	mux := s.adminMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/buildinfo", buildInfoHandler)
+	mux.Handle("/maintenance", s.maintenanceAdminHandler())
+
+	l, err := net.Listen("tcp", s.AdminAddress)
+	if err != nil {
+		return err
+	}
+	s.addAux("admin", s.admin, func() error {
+		return s.admin.Serve(l)
+	})
+	return nil
+}
+
+func buildInfoHandler(w http.ResponseWriter, _ *http.Request) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		http.Error(w, "build info not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bi)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugEndpointsOnlyOnAdminListener(t *testing.T) {
+	if expvar.Get("admin_test_var") == nil {
+		expvar.NewString("admin_test_var").Set("published")
+	}
+	s := &Service{AdminAddress: "127.0.0.1:0"}
+	s.FlagSet()
+	if err := s.setupAdmin(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.admin.Close() })
+
+	// without a Handler the main listener falls back to http.DefaultServeMux
+	main := s.buildHandler()
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/vars"} {
+		rec := httptest.NewRecorder()
+		main.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("main %s: expected 404, got %d", path, rec.Code)
+		}
+	}
+
+	for path, want := range map[string]string{
+		"/debug/pprof/":                  "goroutine",
+		"/debug/pprof/goroutine?debug=1": "goroutine profile",
+		"/debug/pprof/cmdline":           "",
+		"/debug/vars":                    `"admin_test_var": "published"`,
+	} {
+		rec := httptest.NewRecorder()
+		s.admin.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("admin %s: expected 200, got %d", path, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("admin %s: expected body to contain %q", path, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.admin.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown profile, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry"
+)
+
+// authentication errors.
+var (
+	// ErrNoCredentials is returned by an Authenticator if the request does not
+	// hold credentials it can handle, so the next Authenticator can be tried.
+	ErrNoCredentials = errors.New("no credentials provided")
+	// ErrInvalidCredentials is returned by an Authenticator if the request
+	// holds credentials it handles, but they are not valid.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal holds the authenticated identity of a request.
+type Principal struct {
+	Subject string
+	Method  string
+	Claims  map[string]any
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the authenticated Principal found in the
+// provided context, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// ContextWithPrincipal returns a copy of the provided context holding the
+// Principal. The principal's subject is added to the telemetry key/value
+// pairs so loggers using the context include it.
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	ctx = context.WithValue(ctx, principalKey{}, p)
+	return telemetry.KeyValuesToContext(ctx, "principal", p.Subject)
+}
+
+// Authenticator authenticates an incoming request.
+type Authenticator interface {
+	// Authenticate returns the Principal of the request, ErrNoCredentials if
+	// the request does not hold credentials for this Authenticator or any
+	// other error if authentication failed.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Challenger can be implemented by an Authenticator to return a
+// WWW-Authenticate challenge on unauthorized requests.
+type Challenger interface {
+	Challenge() string
+}
+
+// BasicAuth implements an Authenticator for HTTP basic authentication.
+type BasicAuth struct {
+	Realm string
+	// Users holds the passwords by username.
+	Users map[string]string
+}
+
+// Authenticate implements Authenticator.
+func (b *BasicAuth) Authenticate(r *http.Request) (*Principal, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	expected, found := b.Users[user]
+	// always compare to avoid leaking valid usernames through timing
+	if !secureCompare(pass, expected) || !found {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{Subject: user, Method: "basic"}, nil
+}
+
+// Challenge implements Challenger.
+func (b *BasicAuth) Challenge() string {
+	realm := b.Realm
+	if realm == "" {
+		realm = "restricted"
+	}
+	return fmt.Sprintf("Basic realm=%q", realm)
+}
+
+// APIKeys implements an Authenticator for static API keys.
+type APIKeys struct {
+	// Header holds the request header containing the API key.
+	Header string
+	// Keys holds the subjects by API key.
+	Keys map[string]string
+}
+
+// Authenticate implements Authenticator.
+func (a *APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(a.Header)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	for k, subject := range a.Keys {
+		if secureCompare(key, k) {
+			return &Principal{Subject: subject, Method: "api-key"}, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// TokenValidator validates bearer tokens, e.g. JWTs or opaque tokens checked
+// through token introspection. A token without Principal is rejected.
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (*Principal, error)
+}
+
+// BearerAuth implements an Authenticator for bearer tokens.
+type BearerAuth struct {
+	Validator TokenValidator
+}
+
+// Authenticate implements Authenticator.
+func (b *BearerAuth) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, ErrNoCredentials
+	}
+	p, err := b.Validator.ValidateToken(r.Context(), token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	if p == nil {
+		return nil, fmt.Errorf("%w: no principal for token", ErrInvalidCredentials)
+	}
+	if p.Method == "" {
+		p.Method = "bearer"
+	}
+	return p, nil
+}
+
+// Challenge implements Challenger.
+func (b *BearerAuth) Challenge() string {
+	return "Bearer"
+}
+
+// AuthHandler returns a middleware which requires requests with a path
+// matching one of the provided prefixes to be authenticated by one of the
+// provided Authenticators. The authenticated Principal is stored in the
+// request context.
+func AuthHandler(prefixes []string, authenticators ...Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasPathPrefix(r.URL.Path, prefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			for _, a := range authenticators {
+				p, err := a.Authenticate(r)
+				if errors.Is(err, ErrNoCredentials) {
+					continue
+				}
+				if err == nil && p == nil {
+					err = ErrInvalidCredentials
+				}
+				if err != nil {
+					log.Context(r.Context()).Debug("authentication failed",
+						"path", r.URL.Path, "error", err.Error())
+					break
+				}
+				next.ServeHTTP(w, r.WithContext(ContextWithPrincipal(r.Context(), p)))
+				return
+			}
+			for _, a := range authenticators {
+				if c, ok := a.(Challenger); ok {
+					w.Header().Add("WWW-Authenticate", c.Challenge())
+				}
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized),
+				http.StatusUnauthorized)
+		})
+	}
+}
+
+// hasPathPrefix returns true if the path matches one of the provided prefixes
+// on a path segment boundary.
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if prefix == "/" || path == prefix ||
+			strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func secureCompare(given, expected string) bool {
+	g := sha256.Sum256([]byte(given))
+	e := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(g[:], e[:]) == 1
+}
+
+// parseCredentials parses a comma separated list of "name:secret" pairs.
+func parseCredentials(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, secret, ok := strings.Cut(pair, ":")
+		if !ok || name == "" || secret == "" {
+			return nil, errors.New(`expected comma separated "name:secret" pairs`)
+		}
+		m[name] = secret
+	}
+	return m, nil
+}
+
+// parseAPIKeys parses a comma separated list of "subject:key" pairs into the
+// subjects by API key. A subject can hold multiple keys, a key can only be
+// assigned to a single subject.
+func parseAPIKeys(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		subject, key, ok := strings.Cut(pair, ":")
+		if !ok || subject == "" || key == "" {
+			return nil, errors.New(`expected comma separated "subject:key" pairs`)
+		}
+		if _, ok = m[key]; ok {
+			return nil, fmt.Errorf("duplicate API key for subject %q", subject)
+		}
+		m[key] = subject
+	}
+	return m, nil
+}
+
+// authenticators returns the Authenticators configured for the Service.
+func (s *Service) authenticators() []Authenticator {
+	var as []Authenticator
+	if users, _ := parseCredentials(s.AuthBasicUsers); len(users) > 0 {
+		as = append(as, &BasicAuth{Users: users})
+	}
+	if keys, _ := parseAPIKeys(s.AuthAPIKeys); len(keys) > 0 {
+		as = append(as, &APIKeys{Header: s.AuthAPIKeyHeader, Keys: keys})
+	}
+	if s.TokenValidator != nil {
+		as = append(as, &BearerAuth{Validator: s.TokenValidator})
+	}
+	return append(as, s.Authenticators...)
+}
+
+// validateAuth checks the authentication settings.
+func (s *Service) validateAuth() error {
+	var mErr error
+
+	if _, err := parseCredentials(s.AuthBasicUsers); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagAuthBasicUsers, err))
+	}
+
+	if _, err := parseAPIKeys(s.AuthAPIKeys); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagAuthAPIKeys, err))
+	}
+
+	for _, prefix := range s.AuthPrefixes {
+		if !strings.HasPrefix(prefix, "/") {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagAuthPrefixes, flag.ErrInvalidPath))
+			break
+		}
+	}
+
+	if len(s.AuthPrefixes) > 0 && len(s.authenticators()) == 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagAuthPrefixes,
+				flag.ValidationError("no authentication methods configured")))
+	}
+
+	return mErr
+}
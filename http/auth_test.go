@@ -0,0 +1,118 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// tokenValidator implements TokenValidator, accepting the tokens holding a
+// Principal.
+type tokenValidator map[string]*Principal
+
+func (v tokenValidator) ValidateToken(_ context.Context, token string) (*Principal, error) {
+	p, ok := v[token]
+	if !ok {
+		return nil, errors.New("unknown token")
+	}
+	return p, nil
+}
+
+func TestAuthHandler(t *testing.T) {
+	h := AuthHandler([]string{"/api"},
+		&BasicAuth{Users: map[string]string{"alice": "secret"}},
+		&APIKeys{Header: defaultAPIKeyHeader, Keys: map[string]string{"k3y": "svc"}},
+		&BearerAuth{Validator: tokenValidator{"t0ken": {Subject: "bob"}, "nil": nil}},
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject := "anonymous"
+		if p, ok := PrincipalFromContext(r.Context()); ok {
+			subject = p.Subject
+		}
+		_, _ = w.Write([]byte(subject))
+	}))
+
+	tests := []struct {
+		name   string
+		path   string
+		setup  func(r *http.Request)
+		status int
+		body   string
+	}{
+		{"public", "/apis", func(*http.Request) {}, http.StatusOK, "anonymous"},
+		{"missing", "/api/x", func(*http.Request) {}, http.StatusUnauthorized, ""},
+		{"basic", "/api/x", func(r *http.Request) { r.SetBasicAuth("alice", "secret") },
+			http.StatusOK, "alice"},
+		{"basic invalid", "/api", func(r *http.Request) { r.SetBasicAuth("alice", "nope") },
+			http.StatusUnauthorized, ""},
+		{"api key", "/api/x", func(r *http.Request) { r.Header.Set(defaultAPIKeyHeader, "k3y") },
+			http.StatusOK, "svc"},
+		{"bearer", "/api/x", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") },
+			http.StatusOK, "bob"},
+		{"bearer invalid", "/api/x", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") },
+			http.StatusUnauthorized, ""},
+		{"bearer without principal", "/api/x", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nil") },
+			http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		tt.setup(req)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rec.Code)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: expected body %q, got %q", tt.name, tt.body, rec.Body.String())
+		}
+	}
+}
+
+func TestAPIKeysFlag(t *testing.T) {
+	s := &Service{AuthAPIKeys: "svc:k1, svc:k2, other:k3"}
+	s.FlagSet()
+	if err := s.validateAuth(); err != nil {
+		t.Fatal(err)
+	}
+	var keys *APIKeys
+	for _, a := range s.authenticators() {
+		if k, ok := a.(*APIKeys); ok {
+			keys = k
+		}
+	}
+	if keys == nil {
+		t.Fatal("expected API key authenticator")
+	}
+	for key, subject := range map[string]string{"k1": "svc", "k2": "svc", "k3": "other"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(keys.Header, key)
+		p, err := keys.Authenticate(req)
+		if err != nil {
+			t.Errorf("%s: %v", key, err)
+			continue
+		}
+		if p.Subject != subject {
+			t.Errorf("%s: expected subject %q, got %q", key, subject, p.Subject)
+		}
+	}
+
+	s.AuthAPIKeys = "svc:k1,other:k1"
+	if err := s.validateAuth(); err == nil {
+		t.Error("expected duplicate API key to be rejected")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// server is implemented by both http.Server and http3.Server.
+type server interface {
+	Shutdown(ctx context.Context) error
+	Close() error
+}
+
+// auxServer holds an additional listener which is served alongside the main
+// listener of the Service.
+type auxServer struct {
+	name  string
+	srv   server
+	serve func() error
+}
+
+// addAux registers an auxiliary server to be served alongside the main
+// listener.
+func (s *Service) addAux(name string, srv server, serve func() error) {
+	s.aux = append(s.aux, auxServer{name: name, srv: srv, serve: serve})
+}
+
+// serveWithAux serves the main listener and all auxiliary servers. If one of
+// them fails, all others are closed as well.
+func (s *Service) serveWithAux(serveMain func() error) error {
+	errc := make(chan error, len(s.aux))
+	for _, a := range s.aux {
+		go func() {
+			err := a.serve()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				// take down the main listener as well
+				_ = s.Server.Close()
+				errc <- fmt.Errorf("%s: %w", a.name, err)
+				return
+			}
+			errc <- nil
+		}()
+	}
+
+	err := serveMain()
+	for _, a := range s.aux {
+		_ = a.srv.Close()
+	}
+	for range s.aux {
+		if auxErr := <-errc; auxErr != nil &&
+			(err == nil || errors.Is(err, http.ErrServerClosed)) {
+			err = auxErr
+		}
+	}
+	return err
+}
+
+// shutdownAux gracefully shuts down all auxiliary servers concurrently,
+// closing the ones that did not finish before the context expired. The
+// returned function waits for all of them to be done.
+func (s *Service) shutdownAux(ctx context.Context) (wait func()) {
+	var wg sync.WaitGroup
+	for _, a := range s.aux {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.srv.Shutdown(ctx); err != nil {
+				log.Error("graceful shutdown did not complete", err, "listener", a.name)
+				_ = a.srv.Close()
+			}
+		}()
+	}
+	return wg.Wait
+}
@@ -0,0 +1,98 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BodyLimitHandler returns a middleware limiting the size of request bodies.
+// The limit of the longest matching path prefix in prefixLimits is used,
+// falling back to defaultLimit. A limit of 0 means unlimited.
+// Requests announcing a larger Content-Length are rejected with a 413 status.
+// Reading beyond the limit from a streamed body results in a
+// *http.MaxBytesError which handlers can translate to a 413 status.
+func BodyLimitHandler(defaultLimit int64, prefixLimits map[string]int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := defaultLimit
+			matched := -1
+			for prefix, l := range prefixLimits {
+				if len(prefix) > matched && hasPathPrefix(r.URL.Path, []string{prefix}) {
+					limit, matched = l, len(prefix)
+				}
+			}
+			if limit > 0 && r.Body != nil && r.Body != http.NoBody {
+				if r.ContentLength > limit {
+					http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge),
+						http.StatusRequestEntityTooLarge)
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// parseByteSize parses sizes like "512", "64K", "10M" or "1G" (binary units).
+func parseByteSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	if s == "" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	if s == "" {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	mult := int64(1)
+	switch s[len(s)-1] {
+	case 'K':
+		mult = 1 << 10
+	case 'M':
+		mult = 1 << 20
+	case 'G':
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/mult {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n * mult, nil
+}
+
+// parsePrefixLimits parses a list of "prefix=size" values.
+func parsePrefixLimits(values []string) (map[string]int64, error) {
+	m := make(map[string]int64, len(values))
+	for _, v := range values {
+		prefix, size, ok := strings.Cut(v, "=")
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf(`expected "prefix=size", got %q`, v)
+		}
+		n, err := parseByteSize(size)
+		if err != nil {
+			return nil, err
+		}
+		m[prefix] = n
+	}
+	return m, nil
+}
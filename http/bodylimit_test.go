@@ -0,0 +1,39 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	for in, want := range map[string]int64{
+		"":      0,
+		"512":   512,
+		"64K":   64 << 10,
+		"64kb":  64 << 10,
+		"10MiB": 10 << 20,
+		" 1G ":  1 << 30,
+	} {
+		got, err := parseByteSize(in)
+		if err != nil || got != want {
+			t.Errorf("%q: expected %d, got %d (%v)", in, want, got, err)
+		}
+	}
+
+	for _, in := range []string{"B", "b", "iB", "IB", "K", "-1", "1T", "x", "9223372036854775807K"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+// inFlightHandler holds a middleware tracking the number of requests being
+// handled, so unfinished requests can be reported on shutdown.
+func (s *Service) inFlightHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlightRequests returns the number of requests currently being handled.
+func (s *Service) InFlightRequests() int64 {
+	return s.inFlight.Load()
+}
+
+// shutdown gracefully shuts down the server and its auxiliary servers,
+// waiting for active requests to finish until the shutdown timeout expires.
+// Requests still active at that point are reported and their connections
+// forcefully closed.
+func (s *Service) shutdown() {
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// the auxiliary servers share the deadline of the main listener
+	wait := s.shutdownAux(ctx)
+	defer wait()
+
+	if err := s.Shutdown(ctx); err != nil {
+		active := s.inFlight.Load()
+		log.Error("graceful shutdown did not complete", err,
+			"timeout", timeout, "active_requests", active)
+		if s.metrics != nil {
+			s.metrics.abortedOnShutdown.Add(float64(active))
+		}
+		_ = s.Close()
+		return
+	}
+	log.Info("graceful shutdown completed")
+}
@@ -0,0 +1,48 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+)
+
+// configureHTTP2 sets up the HTTP/2 protocol support and tuning of the
+// underlying http.Server.
+func (s *Service) configureHTTP2() {
+	if s.EnableH2C {
+		// keep HTTP/1.1 and HTTP/2 over TLS while adding HTTP/2 cleartext
+		// (prior knowledge) support.
+		s.Protocols = new(http.Protocols)
+		s.Protocols.SetHTTP1(true)
+		s.Protocols.SetHTTP2(true)
+		s.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	if s.HTTP2 == nil {
+		s.HTTP2 = &http.HTTP2Config{}
+	}
+	if s.HTTP2MaxConcurrentStreams > 0 {
+		s.HTTP2.MaxConcurrentStreams = s.HTTP2MaxConcurrentStreams
+	}
+	if s.HTTP2MaxReadFrameSize > 0 {
+		s.HTTP2.MaxReadFrameSize = s.HTTP2MaxReadFrameSize
+	}
+	if s.HTTP2ReadIdleTimeout > 0 {
+		s.HTTP2.SendPingTimeout = s.HTTP2ReadIdleTimeout
+	}
+	if s.HTTP2PingTimeout > 0 {
+		s.HTTP2.PingTimeout = s.HTTP2PingTimeout
+	}
+}
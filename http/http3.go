@@ -0,0 +1,58 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// altSvcHandler holds a middleware advertising the HTTP/3 listener to
+// clients connecting over TCP.
+func (s *Service) altSvcHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			_ = s.h3.SetQUICHeaders(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setupH3 creates the HTTP/3 (QUIC) listener and registers it to be served
+// alongside the TCP listener.
+func (s *Service) setupH3() error {
+	pc, err := net.ListenPacket("udp", s.Address)
+	if err != nil {
+		return err
+	}
+	s.h3 = s.newH3Server()
+	s.addAux("http3", s.h3, func() error {
+		defer func() { _ = pc.Close() }()
+		return s.h3.Serve(pc)
+	})
+	return nil
+}
+
+func (s *Service) newH3Server() *http3.Server {
+	return &http3.Server{
+		Addr:           s.Address,
+		Handler:        s.Handler,
+		TLSConfig:      s.TLSConfig,
+		MaxHeaderBytes: s.MaxHeaderBytes,
+		IdleTimeout:    s.IdleTimeout,
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "http.sock")
+	l, err := listenUnix(path, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = listenUnix(path, 0o600); err == nil {
+		t.Fatal("expected socket in use not to be replaced")
+	}
+
+	// leave a stale socket file behind
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = l.Close()
+	if l, err = listenUnix(path, 0o600); err != nil {
+		t.Fatalf("expected stale socket to be replaced: %v", err)
+	}
+	_ = l.Close()
+}
@@ -0,0 +1,146 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+const defaultMaintenanceMessage = "Service is undergoing maintenance, please try again later."
+
+// FileWatcher is implemented by the filewatcher.Service and provides
+// notifications of file changes and removals.
+type FileWatcher interface {
+	AddWatcherWithRemoval(name, fqn string) (<-chan []byte, error)
+}
+
+// SetMaintenance enables or disables maintenance mode at runtime. While the
+// maintenance file exists, maintenance mode stays enabled regardless.
+func (s *Service) SetMaintenance(enabled bool) {
+	if s.maintenance.Swap(enabled) != enabled {
+		log.Info("maintenance mode changed", "enabled", enabled)
+	}
+}
+
+// InMaintenance returns true if maintenance mode is enabled.
+func (s *Service) InMaintenance() bool {
+	return s.maintenance.Load() || s.maintenanceFile.Load()
+}
+
+// maintenanceHandler holds a middleware answering all requests with 503
+// Service Unavailable while in maintenance mode, except for the allow-listed
+// path prefixes.
+func (s *Service) maintenanceHandler(next http.Handler) http.Handler {
+	retryAfter := ""
+	if s.MaintenanceRetryAfter > 0 {
+		retryAfter = strconv.Itoa(int(s.MaintenanceRetryAfter.Round(time.Second).Seconds()))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.InMaintenance() || hasPathPrefix(r.URL.Path, s.MaintenanceAllow) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, s.MaintenanceMessage, http.StatusServiceUnavailable)
+	})
+}
+
+// maintenanceAdminHandler returns the admin endpoint to inspect (GET), enable
+// (PUT) and disable (DELETE) maintenance mode.
+func (s *Service) maintenanceAdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost:
+			s.SetMaintenance(true)
+		case http.MethodDelete:
+			s.SetMaintenance(false)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+				http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Enabled bool `json:"enabled"`
+			File    bool `json:"file"`
+		}{s.InMaintenance(), s.maintenanceFile.Load()})
+	})
+}
+
+// watchMaintenanceFile enables maintenance mode while the maintenance file
+// exists, checking the file each time the file watcher reports a change.
+func (s *Service) watchMaintenanceFile() error {
+	check := func() {
+		_, err := os.Stat(s.MaintenanceFile)
+		exists := err == nil
+		if s.maintenanceFile.Swap(exists) != exists {
+			log.Info("maintenance file changed",
+				"file", s.MaintenanceFile, "enabled", exists)
+		}
+	}
+	check()
+
+	// registrations need a unique name per Service sharing the file watcher
+	ch, err := s.Watcher.AddWatcherWithRemoval(flagMaintenanceFile+"-"+s.Address, s.MaintenanceFile)
+	if err != nil {
+		return fmt.Errorf("unable to watch %s: %w", s.MaintenanceFile, err)
+	}
+	go func() {
+		// the channel is closed once the file watcher stops
+		for range ch {
+			check()
+		}
+	}()
+	return nil
+}
+
+// validateMaintenance checks the maintenance mode settings.
+func (s *Service) validateMaintenance() error {
+	var mErr error
+
+	if s.MaintenanceFile != "" && s.Watcher == nil {
+		mErr = multierror.Append(mErr, flag.NewValidationError(flagMaintenanceFile,
+			flag.ValidationError("maintenance file requires a file watcher")))
+	}
+
+	if s.MaintenanceRetryAfter < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagMaintenanceRetryAfter, flag.ErrInvalidVal))
+	}
+
+	for _, prefix := range s.MaintenanceAllow {
+		if !strings.HasPrefix(prefix, "/") {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagMaintenanceAllow, flag.ErrInvalidPath))
+			break
+		}
+	}
+
+	return mErr
+}
@@ -0,0 +1,185 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const routeOther = "other"
+
+type routeKey struct{}
+
+// withRoute returns a request with a placeholder for the matched route
+// pattern, which is filled in once the request has been routed.
+func withRoute(r *http.Request) (*http.Request, *string) {
+	if p, ok := r.Context().Value(routeKey{}).(*string); ok {
+		return r, p
+	}
+	route := routeOther
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, &route)), &route
+}
+
+// setRoute records the matched route pattern of the request.
+func setRoute(r *http.Request, pattern string) {
+	if p, ok := r.Context().Value(routeKey{}).(*string); ok && pattern != "" {
+		*p = pattern
+	}
+}
+
+// httpMetrics holds the RED metrics collectors of the HTTP server.
+type httpMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	reqSize  *prometheus.HistogramVec
+	respSize *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+
+	abortedOnShutdown prometheus.Counter
+	panics            prometheus.Counter
+}
+
+func newHTTPMetrics(reg prometheus.Registerer) (*httpMetrics, error) {
+	labels := []string{"method", "route", "code"}
+	sizeBuckets := prometheus.ExponentialBuckets(64, 4, 10)
+
+	m := &httpMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_server_requests_total",
+			Help: "Total number of HTTP requests handled.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_server_request_duration_seconds",
+			Help:    "Duration of HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+		reqSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_server_request_size_bytes",
+			Help:    "Size of HTTP request bodies.",
+			Buckets: sizeBuckets,
+		}, labels),
+		respSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_server_response_size_bytes",
+			Help:    "Size of HTTP response bodies.",
+			Buckets: sizeBuckets,
+		}, labels),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_server_requests_in_flight",
+			Help: "Number of HTTP requests currently being handled.",
+		}),
+		abortedOnShutdown: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "http_server_shutdown_aborted_requests_total",
+			Help: "Number of HTTP requests still active when the shutdown timeout expired.",
+		}),
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "http_server_panics_total",
+			Help: "Number of HTTP handler panics recovered.",
+		}),
+	}
+
+	var err error
+	if m.requests, err = register(reg, m.requests); err != nil {
+		return nil, err
+	}
+	if m.duration, err = register(reg, m.duration); err != nil {
+		return nil, err
+	}
+	if m.reqSize, err = register(reg, m.reqSize); err != nil {
+		return nil, err
+	}
+	if m.respSize, err = register(reg, m.respSize); err != nil {
+		return nil, err
+	}
+	if m.inFlight, err = register(reg, m.inFlight); err != nil {
+		return nil, err
+	}
+	if m.abortedOnShutdown, err = register(reg, m.abortedOnShutdown); err != nil {
+		return nil, err
+	}
+	if m.panics, err = register(reg, m.panics); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// register registers the collector, reusing an identical collector if it was
+// registered before.
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// handler holds a middleware recording request count, duration and sizes.
+func (m *httpMetrics) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		r, route := withRoute(r)
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		lv := []string{r.Method, *route, strconv.Itoa(rec.status)}
+		m.requests.WithLabelValues(lv...).Inc()
+		m.duration.WithLabelValues(lv...).Observe(time.Since(start).Seconds())
+		if r.ContentLength > 0 {
+			m.reqSize.WithLabelValues(lv...).Observe(float64(r.ContentLength))
+		}
+		m.respSize.WithLabelValues(lv...).Observe(float64(rec.size))
+	})
+}
+
+// registries returns the Prometheus registerer and gatherer to use.
+func (s *Service) registries() (prometheus.Registerer, prometheus.Gatherer) {
+	if s.Registry != nil {
+		return s.Registry, s.Registry
+	}
+	return prometheus.DefaultRegisterer, prometheus.DefaultGatherer
+}
+
+// setupMetrics installs the metrics middleware and exposes the metrics
+// endpoint on the admin listener if configured, otherwise on the main one.
+func (s *Service) setupMetrics() error {
+	reg, gatherer := s.registries()
+	m, err := newHTTPMetrics(reg)
+	if err != nil {
+		return err
+	}
+	s.metrics = m
+
+	h := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+	if s.AdminAddress != "" {
+		s.adminMux().Handle(s.MetricsPath, h)
+	} else {
+		s.Handle(s.MetricsPath, h)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+// wrapHandler builds the handler chain of the Service. Middleware added last
+// sees the request first.
+func (s *Service) wrapHandler() error {
+	var err error
+	if s.trusted, err = parseTrustedProxies(s.TrustedProxies); err != nil {
+		return err
+	}
+	if s.EnableMetrics {
+		if err := s.setupMetrics(); err != nil {
+			return err
+		}
+	}
+	s.Handler = s.buildHandler()
+	for i := len(s.mw) - 1; i >= 0; i-- {
+		s.Handler = s.mw[i](s.Handler)
+	}
+	s.Handler = s.inFlightHandler(s.Handler)
+	if len(s.AuthPrefixes) > 0 {
+		s.Handler = AuthHandler(s.AuthPrefixes, s.authenticators()...)(s.Handler)
+	}
+	if s.MaxBodySize != "" || len(s.MaxBodySizePrefixes) > 0 {
+		limit, err := parseByteSize(s.MaxBodySize)
+		if err != nil {
+			return err
+		}
+		prefixes, err := parsePrefixLimits(s.MaxBodySizePrefixes)
+		if err != nil {
+			return err
+		}
+		s.Handler = BodyLimitHandler(limit, prefixes)(s.Handler)
+	}
+	s.maintenance.Store(s.Maintenance)
+	s.Handler = s.maintenanceHandler(s.Handler)
+	if s.SecureHeaders {
+		s.Handler = SecurityHandler(s.Handler)
+	}
+	if s.clientAuthEnabled() {
+		s.Handler = PeerIdentityHandler(s.Handler)
+	}
+	if s.EnableH3 {
+		s.Handler = s.altSvcHandler(s.Handler)
+	}
+	if !s.DisablePanicRecovery {
+		s.Handler = s.recoveryHandler(s.Handler)
+	}
+	if s.metrics != nil {
+		s.Handler = s.metrics.handler(s.Handler)
+	}
+	s.Handler = RealIPHandler(s.trusted)(s.Handler)
+	if s.RequestIDHeader != "" {
+		s.Handler = RequestIDHandler(s.RequestIDHeader)(s.Handler)
+	}
+	return nil
+}
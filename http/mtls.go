@@ -0,0 +1,130 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// supported client certificate verification modes.
+const (
+	ClientAuthNone             = "none"
+	ClientAuthRequest          = "request"
+	ClientAuthRequire          = "require"
+	ClientAuthVerifyIfGiven    = "verify-if-given"
+	ClientAuthRequireAndVerify = "require-and-verify"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	ClientAuthNone:             tls.NoClientCert,
+	ClientAuthRequest:          tls.RequestClientCert,
+	ClientAuthRequire:          tls.RequireAnyClientCert,
+	ClientAuthVerifyIfGiven:    tls.VerifyClientCertIfGiven,
+	ClientAuthRequireAndVerify: tls.RequireAndVerifyClientCert,
+}
+
+type peerIdentityKey struct{}
+
+// PeerIdentity holds the identity of a client as presented by its verified
+// TLS client certificate.
+type PeerIdentity struct {
+	Subject     pkix.Name
+	DNSNames    []string
+	URIs        []*url.URL
+	Certificate *x509.Certificate
+}
+
+// PeerIdentityFromContext returns the verified client certificate identity
+// found in the provided context, if any.
+func PeerIdentityFromContext(ctx context.Context) (*PeerIdentity, bool) {
+	p, ok := ctx.Value(peerIdentityKey{}).(*PeerIdentity)
+	return p, ok
+}
+
+// PeerIdentityHandler holds a middleware which stores the identity of a
+// verified TLS client certificate in the request context.
+func PeerIdentityHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 &&
+			len(r.TLS.VerifiedChains[0]) > 0 {
+			cert := r.TLS.VerifiedChains[0][0]
+			r = r.WithContext(context.WithValue(r.Context(), peerIdentityKey{},
+				&PeerIdentity{
+					Subject:     cert.Subject,
+					DNSNames:    cert.DNSNames,
+					URIs:        cert.URIs,
+					Certificate: cert,
+				}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func parseClientAuth(mode string) (tls.ClientAuthType, error) {
+	if mode == "" {
+		return tls.NoClientCert, nil
+	}
+	ca, ok := clientAuthTypes[strings.ToLower(mode)]
+	if !ok {
+		return tls.NoClientCert, fmt.Errorf("unknown client auth mode %q", mode)
+	}
+	return ca, nil
+}
+
+func loadCertPool(fileName string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("no valid certificates found in CA bundle")
+	}
+	return pool, nil
+}
+
+// clientAuthEnabled returns true if client certificates are requested.
+func (s *Service) clientAuthEnabled() bool {
+	return s.ClientCAFile != "" ||
+		(s.ClientAuth != "" && !strings.EqualFold(s.ClientAuth, ClientAuthNone))
+}
+
+// configureClientAuth applies the client certificate verification settings
+// to the provided TLS config.
+func (s *Service) configureClientAuth(cfg *tls.Config) error {
+	clientAuth, err := parseClientAuth(s.ClientAuth)
+	if err != nil {
+		return err
+	}
+	if s.ClientCAFile != "" {
+		if cfg.ClientCAs, err = loadCertPool(s.ClientCAFile); err != nil {
+			return err
+		}
+		if clientAuth == tls.NoClientCert {
+			clientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	cfg.ClientAuth = clientAuth
+	return nil
+}
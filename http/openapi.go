@@ -0,0 +1,342 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// OpenAPI flags.
+const (
+	flagOpenAPISpecFile = "http-openapi-spec-file"
+	flagOpenAPIPath     = "http-openapi-path"
+	flagOpenAPIUIPath   = "http-openapi-ui-path"
+	flagOpenAPIValidate = "http-openapi-validate"
+)
+
+const (
+	defaultOpenAPIPath   = "/openapi.json"
+	defaultOpenAPIUIPath = "/docs"
+)
+
+// swaggerUIBundle and swaggerUIStyle hold the files of the swagger-ui-dist
+// package used by the Swagger UI page.
+const (
+	swaggerUIBundle = "swagger-ui-bundle.js"
+	swaggerUIStyle  = "swagger-ui.css"
+)
+
+// swaggerUICSP holds the Content-Security-Policy of the Swagger UI page. It
+// allows the assets served from the same origin, the inline script holding
+// the nonce and the inline styles of the Swagger UI.
+const swaggerUICSP = "default-src 'none'; script-src 'self' 'nonce-%s'; style-src 'self' 'unsafe-inline'; connect-src 'self'; img-src 'self' data:; base-uri 'self'; form-action 'self'; frame-ancestors 'self';" //nolint:lll // for clarity
+
+var swaggerUI = template.Must(template.New("swagger-ui").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8"/>
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="{{.AssetPath}}/` + swaggerUIStyle + `"/>
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.AssetPath}}/` + swaggerUIBundle + `"></script>
+  <script nonce="{{.Nonce}}">
+    window.onload = () => { window.ui = SwaggerUIBundle({ url: {{.SpecPath}}, dom_id: '#swagger-ui' }); };
+  </script>
+</body>
+</html>
+`))
+
+// OpenAPI implements a run.Config which serves an OpenAPI document and a
+// Swagger UI on the provided Service. Optionally incoming requests matching
+// an operation of the document are validated against it, returning a
+// structured 400 response on mismatches.
+//
+// The Swagger UI is only served if UIAssets holds the swagger-ui-dist files.
+// They are served from the same origin below UIPath, so the documentation
+// does not depend on a CDN serving the expected scripts. Its route sets its
+// own Content-Security-Policy allowing the inline script starting the UI,
+// replacing the one set by SecureHeaders.
+type OpenAPI struct {
+	Service *Service
+	// Spec holds the OpenAPI document (JSON or YAML) if SpecFile is not set.
+	Spec     []byte
+	SpecFile string
+	Path     string
+	UIPath   string
+	// UIAssets holds the files of the swagger-ui-dist package, e.g. a
+	// vendored copy embedded through embed.FS and fs.Sub.
+	UIAssets         fs.FS
+	ValidateRequests bool
+
+	doc    *openapi3.T
+	spec   []byte
+	router routers.Router
+}
+
+// ValidationErrorResponse holds the response body of a request failing the
+// OpenAPI validation.
+type ValidationErrorResponse struct {
+	Error   string            `json:"error"`
+	Details []ValidationIssue `json:"details"`
+}
+
+// ValidationIssue describes a single OpenAPI validation failure.
+type ValidationIssue struct {
+	In      string `json:"in,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Name implements run.Unit.
+func (o *OpenAPI) Name() string {
+	return "http-openapi"
+}
+
+// FlagSet implements run.Config.
+func (o *OpenAPI) FlagSet() *run.FlagSet {
+	if o.Path == "" {
+		o.Path = defaultOpenAPIPath
+	}
+	if o.UIPath == "" && o.UIAssets != nil {
+		o.UIPath = defaultOpenAPIUIPath
+	}
+
+	flags := run.NewFlagSet("HTTP OpenAPI options")
+
+	flags.StringVar(&o.SpecFile, flagOpenAPISpecFile, o.SpecFile,
+		"OpenAPI document to serve (overrides the embedded document)")
+
+	flags.StringVar(&o.Path, flagOpenAPIPath, o.Path,
+		"Path to serve the OpenAPI document on")
+
+	flags.StringVar(&o.UIPath, flagOpenAPIUIPath, o.UIPath,
+		"Path to serve the Swagger UI on (empty to disable)")
+
+	flags.BoolVar(&o.ValidateRequests, flagOpenAPIValidate, o.ValidateRequests,
+		"Validate incoming requests against the OpenAPI document")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (o *OpenAPI) Validate() error {
+	var mErr error
+
+	if o.Service == nil {
+		mErr = multierror.Append(mErr, errors.New("missing http service"))
+	}
+
+	if o.SpecFile != "" {
+		if _, err := os.Stat(o.SpecFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagOpenAPISpecFile, err))
+		}
+	} else if len(o.Spec) == 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagOpenAPISpecFile, flag.ErrRequired))
+	}
+
+	if !strings.HasPrefix(o.Path, "/") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagOpenAPIPath, flag.ErrInvalidPath))
+	}
+	if o.UIPath != "" {
+		if !strings.HasPrefix(o.UIPath, "/") {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagOpenAPIUIPath, flag.ErrInvalidPath))
+		}
+		if o.UIAssets == nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagOpenAPIUIPath,
+					flag.ValidationError("Swagger UI requires the swagger-ui-dist assets")))
+		} else if _, err := fs.Stat(o.UIAssets, swaggerUIBundle); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagOpenAPIUIPath,
+					flag.ValidationError("Swagger UI assets miss "+swaggerUIBundle)))
+		}
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (o *OpenAPI) PreRun() (err error) {
+	spec := o.Spec
+	if o.SpecFile != "" {
+		if spec, err = os.ReadFile(o.SpecFile); err != nil {
+			return fmt.Errorf("unable to read OpenAPI document: %w", err)
+		}
+	}
+	loader := openapi3.NewLoader()
+	if o.doc, err = loader.LoadFromData(spec); err != nil {
+		return fmt.Errorf("unable to load OpenAPI document: %w", err)
+	}
+	if err = o.doc.Validate(loader.Context); err != nil {
+		return fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	if o.spec, err = o.doc.MarshalJSON(); err != nil {
+		return err
+	}
+
+	o.Service.Handle("GET "+o.Path, http.HandlerFunc(o.serveSpec))
+	if o.UIPath != "" {
+		assets := o.uiAssetPath()
+		o.Service.Handle("GET "+o.UIPath, http.HandlerFunc(o.serveUI))
+		o.Service.Handle("GET "+assets+"/",
+			http.StripPrefix(assets, http.FileServerFS(o.UIAssets)))
+	}
+
+	if o.ValidateRequests {
+		if o.router, err = legacy.NewRouter(routingDoc(o.doc)); err != nil {
+			return fmt.Errorf("unable to create OpenAPI router: %w", err)
+		}
+		o.Service.Use(o.validationHandler)
+	}
+
+	return nil
+}
+
+func (o *OpenAPI) serveSpec(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(o.spec)
+}
+
+func (o *OpenAPI) serveUI(w http.ResponseWriter, _ *http.Request) {
+	title := "API documentation"
+	if o.doc.Info != nil && o.doc.Info.Title != "" {
+		title = o.doc.Info.Title
+	}
+	nonce := rand.Text()
+	w.Header().Set("Content-Security-Policy", fmt.Sprintf(swaggerUICSP, nonce))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = swaggerUI.Execute(w, struct{ Title, SpecPath, AssetPath, Nonce string }{
+		title, o.Path, o.uiAssetPath(), nonce,
+	})
+}
+
+// uiAssetPath returns the path the Swagger UI assets are served on.
+func (o *OpenAPI) uiAssetPath() string {
+	return strings.TrimSuffix(o.UIPath, "/") + "/assets"
+}
+
+// validationHandler holds a middleware validating requests matching an
+// operation of the OpenAPI document. Other requests are passed through.
+func (o *OpenAPI) validationHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, params, err := o.router.FindRoute(r)
+		if err != nil {
+			// not described by the document
+			next.ServeHTTP(w, r)
+			return
+		}
+		err = openapi3filter.ValidateRequest(r.Context(),
+			&openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: params,
+				Route:      route,
+				Options: &openapi3filter.Options{
+					MultiError:         true,
+					AuthenticationFunc: noopAuthentication,
+				},
+			})
+		if err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// noopAuthentication leaves authentication to the authentication middleware.
+func noopAuthentication(context.Context, *openapi3filter.AuthenticationInput) error {
+	return nil
+}
+
+func writeValidationError(w http.ResponseWriter, err error) {
+	resp := ValidationErrorResponse{Error: "request validation failed"}
+
+	var errs []error
+	var me openapi3.MultiError
+	if errors.As(err, &me) {
+		errs = me
+	} else {
+		errs = []error{err}
+	}
+	for _, e := range errs {
+		issue := ValidationIssue{Message: e.Error()}
+		var re *openapi3filter.RequestError
+		if errors.As(e, &re) {
+			switch {
+			case re.Parameter != nil:
+				issue.In, issue.Field = re.Parameter.In, re.Parameter.Name
+			case re.RequestBody != nil:
+				issue.In = "body"
+			}
+			var se *openapi3.SchemaError
+			if errors.As(re.Err, &se) {
+				if issue.In == "body" {
+					issue.Field = strings.Join(se.JSONPointer(), ".")
+				}
+				issue.Message = se.Reason
+			}
+		}
+		resp.Details = append(resp.Details, issue)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// routingDoc returns a copy of the document with the servers reduced to their
+// base paths, so requests match regardless of the host they were sent to.
+func routingDoc(doc *openapi3.T) *openapi3.T {
+	d := *doc
+	d.Servers = nil
+	for _, srv := range doc.Servers {
+		u, err := url.Parse(srv.URL)
+		if err != nil || u.Path == "" || u.Path == "/" {
+			continue
+		}
+		d.Servers = append(d.Servers, &openapi3.Server{URL: u.Path})
+	}
+	return &d
+}
+
+var (
+	_ run.Config    = (*OpenAPI)(nil)
+	_ run.PreRunner = (*OpenAPI)(nil)
+)
@@ -0,0 +1,152 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testSpec = `openapi: 3.0.0
+info: {title: Items, version: "1"}
+servers: [{url: "https://api.example.com/v1"}]
+paths:
+  /items/{id}:
+    post:
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: integer}}
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name]
+              properties: {name: {type: string}}
+      responses: {"200": {description: ok}}
+`
+
+func TestOpenAPIValidation(t *testing.T) {
+	s := &Service{}
+	s.FlagSet()
+	s.Handler = textHandler("ok")
+	o := &OpenAPI{Service: s, Spec: []byte(testSpec), ValidateRequests: true}
+	o.FlagSet()
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.wrapHandler(); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		s.Handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := serve(http.MethodPost, "/v1/items/3", `{"name":"x"}`); rec.Code != http.StatusOK {
+		t.Errorf("valid request: expected 200, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/undocumented", ""); rec.Code != http.StatusOK {
+		t.Errorf("undocumented request: expected 200, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/openapi.json", ""); rec.Code != http.StatusOK ||
+		!strings.Contains(rec.Body.String(), `"title":"Items"`) {
+		t.Errorf("unexpected document response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec := serve(http.MethodPost, "/v1/items/abc", `{}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid request: expected 400, got %d", rec.Code)
+	}
+	var resp ValidationErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Details) != 2 {
+		t.Fatalf("expected 2 validation issues, got %+v", resp.Details)
+	}
+	if d := resp.Details[0]; d.In != "path" || d.Field != "id" {
+		t.Errorf("unexpected path issue: %+v", d)
+	}
+	if d := resp.Details[1]; d.In != "body" || d.Field != "name" {
+		t.Errorf("unexpected body issue: %+v", d)
+	}
+}
+
+func TestOpenAPISpecFileAndUI(t *testing.T) {
+	s := &Service{SecureHeaders: true}
+	s.FlagSet()
+	s.Handler = textHandler("ok")
+	o := &OpenAPI{Service: s, SpecFile: filepath.Join(t.TempDir(), "openapi.yaml"), UIPath: "/docs"}
+	o.FlagSet()
+	if err := o.Validate(); err == nil {
+		t.Error("expected missing document and Swagger UI assets to be rejected")
+	}
+	o.UIAssets = fstest.MapFS{
+		swaggerUIBundle: {Data: []byte("bundle")},
+		swaggerUIStyle:  {Data: []byte("style")},
+	}
+	if err := os.WriteFile(o.SpecFile, []byte(testSpec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if o.Spec != nil {
+		t.Error("expected Validate to leave the document to PreRun")
+	}
+	if err := o.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.wrapHandler(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<title>Items</title>") {
+		t.Fatalf("unexpected Swagger UI response: %d %s", rec.Code, rec.Body.String())
+	}
+	csp := rec.Header().Values("Content-Security-Policy")
+	if len(csp) != 1 || !strings.Contains(csp[0], "script-src 'self' 'nonce-") {
+		t.Fatalf("expected the Swagger UI policy to replace the secure headers policy, got %q", csp)
+	}
+	_, nonce, _ := strings.Cut(csp[0], "'nonce-")
+	nonce, _, _ = strings.Cut(nonce, "'")
+	if !strings.Contains(rec.Body.String(), `<script nonce="`+nonce+`">`) {
+		t.Errorf("expected the inline script to hold the nonce of %q", csp[0])
+	}
+	if !strings.Contains(rec.Body.String(), `<script src="/docs/assets/`+swaggerUIBundle+`">`) {
+		t.Errorf("expected the Swagger UI bundle to be loaded from the same origin")
+	}
+
+	rec = httptest.NewRecorder()
+	s.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/assets/"+swaggerUIBundle, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "bundle" {
+		t.Errorf("unexpected Swagger UI asset response: %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/pires/go-proxyproto"
+
+	"github.com/basvanbeek/telemetry"
+)
+
+type clientIPKey struct{}
+
+// ClientIPFromContext returns the resolved client IP found in the provided
+// context.
+func ClientIPFromContext(ctx context.Context) (netip.Addr, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(netip.Addr)
+	return ip, ok
+}
+
+// ClientIP returns the resolved client IP of the request. If the request did
+// not pass through RealIPHandler, the IP of the remote address is returned.
+func ClientIP(r *http.Request) netip.Addr {
+	if ip, ok := ClientIPFromContext(r.Context()); ok {
+		return ip
+	}
+	return remoteIP(r.RemoteAddr)
+}
+
+// RealIPHandler holds a middleware resolving the real client IP of requests.
+// If the request originates from one of the trusted proxies, the client IP is
+// taken from the X-Forwarded-For header (the right-most address not belonging
+// to a trusted proxy) or the X-Real-IP header. Otherwise the remote address
+// is used. The client IP is stored in the request context and added to the
+// telemetry key/value pairs so loggers using the request context include it.
+func RealIPHandler(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+			if !ip.IsValid() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := context.WithValue(r.Context(), clientIPKey{}, ip)
+			ctx = telemetry.KeyValuesToContext(ctx, "client_ip", ip.String())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	ip := remoteIP(r.RemoteAddr)
+	if !isTrusted(ip, trusted) {
+		return ip
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				// malformed entry, don't trust anything to the left of it
+				return ip
+			}
+			ip = hop.Unmap()
+			if !isTrusted(ip, trusted) {
+				return ip
+			}
+		}
+		return ip
+	}
+
+	if xri, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return xri.Unmap()
+	}
+	return ip
+}
+
+func remoteIP(addr string) netip.Addr {
+	if ap, err := netip.ParseAddrPort(addr); err == nil {
+		return ap.Addr().Unmap()
+	}
+	ip, _ := netip.ParseAddr(addr)
+	return ip.Unmap()
+}
+
+func isTrusted(ip netip.Addr, trusted []netip.Prefix) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	for _, p := range trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses a list of IP addresses and CIDR ranges.
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if strings.Contains(p, "/") {
+			prefix, err := netip.ParsePrefix(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", p, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP %q: %w", p, err)
+		}
+		ip = ip.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// proxyProtocolListener wraps the listener to accept PROXY protocol (v1 and
+// v2) headers. The addresses from the header are only used for connections
+// originating from trusted proxies.
+func proxyProtocolListener(l net.Listener, trusted []netip.Prefix) net.Listener {
+	return &proxyproto.Listener{
+		Listener: l,
+		ConnPolicy: func(opts proxyproto.ConnPolicyOptions) (proxyproto.Policy, error) {
+			if isTrusted(remoteIP(opts.Upstream.String()), trusted) {
+				return proxyproto.USE, nil
+			}
+			return proxyproto.IGNORE, nil
+		},
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name, remote, xff, xri, want string
+	}{
+		{"direct", "203.0.113.7:1234", "", "", "203.0.113.7"},
+		{"untrusted spoof", "203.0.113.7:1234", "1.2.3.4", "", "203.0.113.7"},
+		{"trusted xff", "10.1.2.3:1234", "1.2.3.4", "", "1.2.3.4"},
+		{"trusted chain", "10.1.2.3:1234", "6.6.6.6, 1.2.3.4, 192.168.1.1", "", "1.2.3.4"},
+		{"trusted x-real-ip", "192.168.1.1:1234", "", "1.2.3.4", "1.2.3.4"},
+		{"malformed xff", "10.1.2.3:1234", "1.2.3.4, junk", "", "10.1.2.3"},
+		{"ipv4 mapped", "[::ffff:10.1.2.3]:1234", "1.2.3.4", "", "1.2.3.4"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tc.remote
+		if tc.xff != "" {
+			r.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		if tc.xri != "" {
+			r.Header.Set("X-Real-IP", tc.xri)
+		}
+		if got := resolveClientIP(r, trusted).String(); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+}
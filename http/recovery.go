@@ -0,0 +1,54 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// recoveryHandler holds a middleware converting handler panics into 500
+// responses. The panic and its stack trace are logged and counted.
+// http.ErrAbortHandler is passed on as it is used to deliberately abort a
+// response.
+func (s *Service) recoveryHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := newResponseRecorder(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler { //nolint:errorlint // sentinel panic value
+				panic(v)
+			}
+			if s.metrics != nil {
+				s.metrics.panics.Inc()
+			}
+			log.Context(r.Context()).Error("panic while handling request",
+				fmt.Errorf("%v", v),
+				"method", r.Method, "path", r.URL.Path, "stack", string(debug.Stack()))
+			if rec.wroteHeader {
+				// response is partially sent, abort the connection so the
+				// client does not mistake it for a complete one
+				panic(http.ErrAbortHandler)
+			}
+			http.Error(rec, http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
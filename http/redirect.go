@@ -0,0 +1,122 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+const acmeChallengePath = "/.well-known/acme-challenge/"
+
+// redirectHandler returns a handler redirecting all requests to their HTTPS
+// equivalent on the provided port. ACME HTTP-01 challenges are answered by
+// the challenge handler if provided.
+func redirectHandler(httpsPort string, challenge http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if challenge != nil && strings.HasPrefix(r.URL.Path, acmeChallengePath) {
+			challenge.ServeHTTP(w, r)
+			return
+		}
+
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			// IPv6 literal without port
+			host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		}
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			// IPv6 literal
+			host = "[" + host + "]"
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			// keep the method and body
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, target, status)
+	})
+}
+
+// acmeChallengeHandler returns the handler answering ACME HTTP-01 challenges.
+func (s *Service) acmeChallengeHandler() http.Handler {
+	if s.ACMEChallengeHandler != nil {
+		return s.ACMEChallengeHandler
+	}
+	if s.ACMEChallengeDir != "" {
+		return http.StripPrefix(acmeChallengePath,
+			http.FileServer(http.Dir(s.ACMEChallengeDir)))
+	}
+	return nil
+}
+
+// setupRedirect creates the HTTP to HTTPS redirect listener and registers it
+// to be served alongside the main listener.
+func (s *Service) setupRedirect(httpsPort string) error {
+	if s.TLSConfig == nil {
+		return errors.New("HTTP to HTTPS redirect listener requires TLS")
+	}
+
+	srv := &http.Server{
+		Handler:           redirectHandler(httpsPort, s.acmeChallengeHandler()),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       30 * time.Second,
+	}
+	l, err := net.Listen("tcp", s.RedirectAddress)
+	if err != nil {
+		return err
+	}
+	s.addAux("redirect", srv, func() error {
+		return srv.Serve(l)
+	})
+	return nil
+}
+
+// validateRedirect checks the HTTP to HTTPS redirect settings.
+func (s *Service) validateRedirect() error {
+	var mErr error
+
+	if s.RedirectAddress != "" {
+		if _, _, err := net.SplitHostPort(s.RedirectAddress); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagRedirectAddress, err))
+		}
+	}
+
+	if s.ACMEChallengeDir != "" {
+		if fi, err := os.Stat(s.ACMEChallengeDir); err != nil || !fi.IsDir() {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagACMEChallengeDir, flag.ErrInvalidPath))
+		}
+	}
+
+	return mErr
+}
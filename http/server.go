@@ -16,7 +16,6 @@
 package http
 
 import (
-	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -27,30 +26,156 @@ import (
 	"math/big"
 	"net"
 	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/basvanbeek/multierror"
 	"github.com/basvanbeek/run"
 	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/quic-go/quic-go/http3"
 )
 
 // package flags.
 const (
 	flagListenAddress = "http-listen-address"
 	flagSecureHeaders = "secure-headers"
+	flagClientCAFile  = "http-client-ca-file"
+	flagClientAuth    = "http-client-auth"
+
+	flagEnableH2C                 = "http-enable-h2c"
+	flagHTTP2MaxConcurrentStreams = "http2-max-concurrent-streams"
+	flagHTTP2MaxReadFrameSize     = "http2-max-read-frame-size"
+	flagHTTP2ReadIdleTimeout      = "http2-read-idle-timeout"
+	flagHTTP2PingTimeout          = "http2-ping-timeout"
+	flagEnableH3                  = "http-enable-h3"
+	flagUnixSocketMode            = "http-unix-socket-mode"
+	flagEnableMetrics             = "http-enable-metrics"
+	flagMetricsPath               = "http-metrics-path"
+	flagAdminAddress              = "http-admin-address"
+	flagRequestIDHeader           = "http-request-id-header"
+	flagReadHeaderTimeout         = "http-read-header-timeout"
+	flagReadTimeout               = "http-read-timeout"
+	flagWriteTimeout              = "http-write-timeout"
+	flagIdleTimeout               = "http-idle-timeout"
+	flagMaxHeaderBytes            = "http-max-header-bytes"
+	flagShutdownTimeout           = "http-shutdown-timeout"
+	flagAuthPrefixes              = "http-auth-prefixes"
+	flagAuthBasicUsers            = "http-auth-basic-users"
+	flagAuthAPIKeys               = "http-auth-api-keys"
+	flagAuthAPIKeyHeader          = "http-auth-api-key-header"
+	flagMaxBodySize               = "http-max-body-size"
+	flagMaxBodySizePrefixes       = "http-max-body-size-prefixes"
+	flagDisablePanicRecovery      = "http-disable-panic-recovery"
+	flagTrustedProxies            = "http-trusted-proxies"
+	flagProxyProtocol             = "http-proxy-protocol"
+	flagTLSMinVersion             = "http-tls-min-version"
+	flagTLSMaxVersion             = "http-tls-max-version"
+	flagTLSCipherSuites           = "http-tls-cipher-suites"
+	flagTLSCurvePreferences       = "http-tls-curve-preferences"
+	flagRedirectAddress           = "http-redirect-address"
+	flagACMEChallengeDir          = "http-acme-challenge-dir"
+	flagMaintenance               = "http-maintenance"
+	flagMaintenanceFile           = "http-maintenance-file"
+	flagMaintenanceMessage        = "http-maintenance-message"
+	flagMaintenanceRetryAfter     = "http-maintenance-retry-after"
+	flagMaintenanceAllow          = "http-maintenance-allow"
 )
 
 const (
-	defaultHTTPAddress = ":80"
+	defaultHTTPAddress     = ":80"
+	defaultUnixSocketMode  = "0660"
+	defaultMetricsPath     = "/metrics"
+	defaultShutdownTimeout = 5 * time.Second
+	defaultAPIKeyHeader    = "X-API-Key"
 )
 
+var log = scope.Register("http", "HTTP server")
+
 // Service implements a run.Group compatible HTTP Server.
 type Service struct {
 	Address       string
 	SecureHeaders bool
+	ClientCAFile  string
+	ClientAuth    string
+
+	TLSMinVersion       string
+	TLSMaxVersion       string
+	TLSCipherSuites     []string
+	TLSCurvePreferences []string
+
+	EnableH2C                 bool
+	HTTP2MaxConcurrentStreams int
+	HTTP2MaxReadFrameSize     int
+	HTTP2ReadIdleTimeout      time.Duration
+	HTTP2PingTimeout          time.Duration
+	EnableH3                  bool
+	UnixSocketMode            string
+	EnableMetrics             bool
+	MetricsPath               string
+	AdminAddress              string
+	RequestIDHeader           string
+	ShutdownTimeout           time.Duration
+	AuthPrefixes              []string
+	AuthBasicUsers            string
+	AuthAPIKeys               string
+	AuthAPIKeyHeader          string
+	// TokenValidator optionally holds the validator for bearer tokens on
+	// routes requiring authentication.
+	TokenValidator TokenValidator
+	// Authenticators optionally holds additional Authenticators for routes
+	// requiring authentication.
+	Authenticators      []Authenticator
+	MaxBodySize         string
+	MaxBodySizePrefixes []string
+	// DisablePanicRecovery disables converting handler panics into 500
+	// responses, letting them reach the net/http server instead.
+	DisablePanicRecovery bool
+	// TrustedProxies holds the IP addresses and CIDR ranges of the proxies
+	// trusted to provide the real client IP.
+	TrustedProxies []string
+	ProxyProtocol  bool
+	// RedirectAddress optionally holds the address of a listener redirecting
+	// all plain HTTP traffic to the HTTPS listener.
+	RedirectAddress  string
+	ACMEChallengeDir string
+	// ACMEChallengeHandler optionally holds the handler answering ACME HTTP-01
+	// challenges on the redirect listener, e.g. autocert.Manager.HTTPHandler.
+	ACMEChallengeHandler http.Handler
+	// Maintenance holds the initial maintenance mode, which can be changed at
+	// runtime through SetMaintenance, the maintenance file or the admin
+	// listener.
+	Maintenance           bool
+	MaintenanceFile       string
+	MaintenanceMessage    string
+	MaintenanceRetryAfter time.Duration
+	MaintenanceAllow      []string
+	// Watcher optionally holds the file watcher (e.g. filewatcher.Service)
+	// used to watch the maintenance file. It is required if MaintenanceFile
+	// is set.
+	Watcher FileWatcher
+	// Registry optionally holds the Prometheus registry to register the HTTP
+	// metrics with. If nil, the Prometheus default registry is used.
+	Registry *prometheus.Registry
 
 	*http.Server
-	l net.Listener
+	l        net.Listener
+	h3       *http3.Server
+	f        []func(*http.ServeMux)
+	mw       []func(http.Handler) http.Handler
+	vhosts   map[string]http.Handler
+	aux      []auxServer
+	admin    *http.Server
+	metrics  *httpMetrics
+	trusted  []netip.Prefix
+	inFlight atomic.Int64
+
+	maintenance     atomic.Bool
+	maintenanceFile atomic.Bool
 }
 
 // Name implements run.Unit.
@@ -63,6 +188,24 @@ func (s *Service) FlagSet() *run.FlagSet {
 	if s.Address == "" {
 		s.Address = defaultHTTPAddress
 	}
+	if s.UnixSocketMode == "" {
+		s.UnixSocketMode = defaultUnixSocketMode
+	}
+	if s.MetricsPath == "" {
+		s.MetricsPath = defaultMetricsPath
+	}
+	if s.RequestIDHeader == "" {
+		s.RequestIDHeader = defaultRequestIDHeader
+	}
+	if s.ShutdownTimeout == 0 {
+		s.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if s.AuthAPIKeyHeader == "" {
+		s.AuthAPIKeyHeader = defaultAPIKeyHeader
+	}
+	if s.MaintenanceMessage == "" {
+		s.MaintenanceMessage = defaultMaintenanceMessage
+	}
 	if s.Server == nil {
 		s.Server = &http.Server{
 			ReadHeaderTimeout: 60 * time.Second,
@@ -71,13 +214,16 @@ func (s *Service) FlagSet() *run.FlagSet {
 			IdleTimeout:       120 * time.Second,
 		}
 	}
+	if s.MaxHeaderBytes == 0 {
+		s.MaxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
 	flags := run.NewFlagSet("HTTP server options")
 
 	flags.StringVarP(
 		&s.Address,
 		flagListenAddress, "a",
 		s.Address,
-		`HTTP server listen address, e.g. ":443" or "localhost:80"`)
+		`HTTP server listen address, e.g. ":443", "localhost:80" or "unix:///run/http.sock"`)
 
 	flags.BoolVar(
 		&s.SecureHeaders,
@@ -86,14 +232,263 @@ func (s *Service) FlagSet() *run.FlagSet {
 		"Enable HTTP header security. Only do this in production as we're enabling HTTP-STS!",
 	)
 
+	flags.StringVar(
+		&s.ClientCAFile,
+		flagClientCAFile,
+		s.ClientCAFile,
+		"CA bundle (PEM) used to verify HTTP client certificates")
+
+	flags.StringVar(
+		&s.ClientAuth,
+		flagClientAuth,
+		s.ClientAuth,
+		`HTTP client certificate mode: "none", "request", "require", `+
+			`"verify-if-given" or "require-and-verify"`)
+
+	flags.StringVar(
+		&s.TLSMinVersion,
+		flagTLSMinVersion,
+		s.TLSMinVersion,
+		`Min. TLS version: "1.0", "1.1", "1.2" or "1.3" (defaults to 1.3 for the ephemeral certificate)`)
+
+	flags.StringVar(
+		&s.TLSMaxVersion,
+		flagTLSMaxVersion,
+		s.TLSMaxVersion,
+		`Max. TLS version: "1.0", "1.1", "1.2" or "1.3"`)
+
+	flags.StringSliceVar(
+		&s.TLSCipherSuites,
+		flagTLSCipherSuites,
+		s.TLSCipherSuites,
+		"TLS 1.0-1.2 cipher suites, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 (empty for Go defaults)")
+
+	flags.StringSliceVar(
+		&s.TLSCurvePreferences,
+		flagTLSCurvePreferences,
+		s.TLSCurvePreferences,
+		"TLS key exchange curves in order of preference: X25519, P256, P384, P521, X25519MLKEM768")
+
+	flags.BoolVar(
+		&s.EnableH2C,
+		flagEnableH2C,
+		s.EnableH2C,
+		"Enable HTTP/2 over cleartext (h2c) for non TLS connections")
+
+	flags.IntVar(
+		&s.HTTP2MaxConcurrentStreams,
+		flagHTTP2MaxConcurrentStreams,
+		s.HTTP2MaxConcurrentStreams,
+		"Max. concurrent HTTP/2 streams per connection (0 uses the Go default of 250)")
+
+	flags.IntVar(
+		&s.HTTP2MaxReadFrameSize,
+		flagHTTP2MaxReadFrameSize,
+		s.HTTP2MaxReadFrameSize,
+		"Max. HTTP/2 frame size in bytes the server is willing to read (0 uses the default)")
+
+	flags.DurationVar(
+		&s.HTTP2ReadIdleTimeout,
+		flagHTTP2ReadIdleTimeout,
+		s.HTTP2ReadIdleTimeout,
+		"Idle time after which a HTTP/2 health check ping is sent (0 disables health checks)")
+
+	flags.DurationVar(
+		&s.HTTP2PingTimeout,
+		flagHTTP2PingTimeout,
+		s.HTTP2PingTimeout,
+		"Time to wait for a HTTP/2 ping response before closing the connection")
+
+	flags.BoolVar(
+		&s.EnableH3,
+		flagEnableH3,
+		s.EnableH3,
+		"Enable an additional HTTP/3 (QUIC) listener on the same address")
+
+	flags.StringVar(
+		&s.UnixSocketMode,
+		flagUnixSocketMode,
+		s.UnixSocketMode,
+		"File mode (octal) of the unix domain socket if listening on a unix:// address")
+
+	flags.BoolVar(
+		&s.EnableMetrics,
+		flagEnableMetrics,
+		s.EnableMetrics,
+		"Enable Prometheus HTTP request metrics")
+
+	flags.StringVar(
+		&s.MetricsPath,
+		flagMetricsPath,
+		s.MetricsPath,
+		"Path of the Prometheus metrics endpoint")
+
+	flags.StringVar(
+		&s.AdminAddress,
+		flagAdminAddress,
+		s.AdminAddress,
+		`Optional admin listener address for metrics and debug endpoints (pprof, expvar, build info), e.g. "localhost:9090"`)
+
+	flags.StringVar(
+		&s.RequestIDHeader,
+		flagRequestIDHeader,
+		s.RequestIDHeader,
+		"Header used to read and propagate request IDs (empty to disable)")
+
+	flags.DurationVar(
+		&s.ReadHeaderTimeout,
+		flagReadHeaderTimeout,
+		s.ReadHeaderTimeout,
+		"Max. duration for reading request headers (0 uses the read timeout)")
+
+	flags.DurationVar(
+		&s.ReadTimeout,
+		flagReadTimeout,
+		s.ReadTimeout,
+		"Max. duration for reading the entire request, including the body (0 for no timeout)")
+
+	flags.DurationVar(
+		&s.WriteTimeout,
+		flagWriteTimeout,
+		s.WriteTimeout,
+		"Max. duration before timing out writes of the response (0 for no timeout)")
+
+	flags.DurationVar(
+		&s.IdleTimeout,
+		flagIdleTimeout,
+		s.IdleTimeout,
+		"Max. time to wait for the next request on keep-alive connections")
+
+	flags.IntVar(
+		&s.MaxHeaderBytes,
+		flagMaxHeaderBytes,
+		s.MaxHeaderBytes,
+		"Max. size in bytes of the request headers")
+
+	flags.DurationVar(
+		&s.ShutdownTimeout,
+		flagShutdownTimeout,
+		s.ShutdownTimeout,
+		"Max. time to wait for active requests to finish on shutdown")
+
+	flags.StringSliceVar(
+		&s.AuthPrefixes,
+		flagAuthPrefixes,
+		s.AuthPrefixes,
+		`URL path prefixes requiring authentication, e.g. "/api,/admin" ("/" for all)`)
+
+	flags.SensitiveStringVar(
+		&s.AuthBasicUsers,
+		flagAuthBasicUsers,
+		s.AuthBasicUsers,
+		`Basic auth users as comma separated "username:password" pairs`)
+
+	flags.SensitiveStringVar(
+		&s.AuthAPIKeys,
+		flagAuthAPIKeys,
+		s.AuthAPIKeys,
+		`Static API keys as comma separated "subject:key" pairs`)
+
+	flags.StringVar(
+		&s.AuthAPIKeyHeader,
+		flagAuthAPIKeyHeader,
+		s.AuthAPIKeyHeader,
+		"Request header holding the API key")
+
+	flags.StringVar(
+		&s.MaxBodySize,
+		flagMaxBodySize,
+		s.MaxBodySize,
+		`Max. request body size, e.g. "512K" or "10M" (empty or 0 for unlimited)`)
+
+	flags.StringSliceVar(
+		&s.MaxBodySizePrefixes,
+		flagMaxBodySizePrefixes,
+		s.MaxBodySizePrefixes,
+		`Max. request body size per URL path prefix, e.g. "/upload=100M,/api=1M"`)
+
+	flags.BoolVar(
+		&s.DisablePanicRecovery,
+		flagDisablePanicRecovery,
+		s.DisablePanicRecovery,
+		"Disable recovering handler panics into 500 responses")
+
+	flags.StringSliceVar(
+		&s.TrustedProxies,
+		flagTrustedProxies,
+		s.TrustedProxies,
+		`IPs and CIDR ranges of proxies trusted to provide the client IP, e.g. "10.0.0.0/8,192.168.1.1"`)
+
+	flags.BoolVar(
+		&s.ProxyProtocol,
+		flagProxyProtocol,
+		s.ProxyProtocol,
+		"Accept PROXY protocol headers from trusted proxies")
+
+	flags.StringVar(
+		&s.RedirectAddress,
+		flagRedirectAddress,
+		s.RedirectAddress,
+		`Optional listener address redirecting plain HTTP to HTTPS, e.g. ":80" (requires TLS)`)
+
+	flags.StringVar(
+		&s.ACMEChallengeDir,
+		flagACMEChallengeDir,
+		s.ACMEChallengeDir,
+		"Directory holding ACME HTTP-01 challenge files served on the redirect listener")
+
+	flags.BoolVar(
+		&s.Maintenance,
+		flagMaintenance,
+		s.Maintenance,
+		"Start in maintenance mode, answering requests with 503 Service Unavailable")
+
+	flags.StringVar(
+		&s.MaintenanceFile,
+		flagMaintenanceFile,
+		s.MaintenanceFile,
+		"File enabling maintenance mode while it exists (requires a file watcher)")
+
+	flags.StringVar(
+		&s.MaintenanceMessage,
+		flagMaintenanceMessage,
+		s.MaintenanceMessage,
+		"Response body returned in maintenance mode")
+
+	flags.DurationVar(
+		&s.MaintenanceRetryAfter,
+		flagMaintenanceRetryAfter,
+		s.MaintenanceRetryAfter,
+		"Retry-After returned in maintenance mode (0 to omit)")
+
+	flags.StringSliceVar(
+		&s.MaintenanceAllow,
+		flagMaintenanceAllow,
+		s.MaintenanceAllow,
+		`URL path prefixes served in maintenance mode, e.g. "/health,/status"`)
+
 	return flags
 }
 
-// Validate implements run.Config.
-func (s *Service) Validate() error {
+// validateListenAddress checks the TCP or unix domain socket listen address.
+func (s *Service) validateListenAddress() error {
 	var mErr error
 
-	if s.Address != "" {
+	if path, ok := unixSocketPath(s.Address); ok {
+		if path == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagListenAddress, flag.ErrInvalidPath))
+		}
+		if s.EnableH3 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagEnableH3,
+					flag.ValidationError("not supported on unix domain sockets")))
+		}
+		if _, err := parseFileMode(s.UnixSocketMode); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagUnixSocketMode, err))
+		}
+	} else if s.Address != "" {
 		if _, _, err := net.SplitHostPort(s.Address); err != nil {
 			mErr = multierror.Append(mErr,
 				flag.NewValidationError(flagListenAddress, err))
@@ -106,45 +501,274 @@ func (s *Service) Validate() error {
 	return mErr
 }
 
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	if err := s.validateListenAddress(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if clientAuth, err := parseClientAuth(s.ClientAuth); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagClientAuth, err))
+	} else if clientAuth >= tls.VerifyClientCertIfGiven && s.ClientCAFile == "" &&
+		(s.TLSConfig == nil || s.TLSConfig.ClientCAs == nil) {
+		// don't verify client certificates against the system roots
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagClientCAFile,
+				flag.ValidationError("required to verify client certificates")))
+	}
+
+	if err := s.validateTLSOptions(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if s.HTTP2MaxConcurrentStreams < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagHTTP2MaxConcurrentStreams, flag.ErrInvalidVal))
+	}
+
+	if s.HTTP2MaxReadFrameSize != 0 &&
+		(s.HTTP2MaxReadFrameSize < 16<<10 || s.HTTP2MaxReadFrameSize > 16<<20) {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagHTTP2MaxReadFrameSize,
+				flag.ValidationError("must be between 16KiB and 16MiB")))
+	}
+
+	for _, t := range []struct {
+		flag string
+		d    time.Duration
+	}{
+		{flagReadHeaderTimeout, s.ReadHeaderTimeout},
+		{flagReadTimeout, s.ReadTimeout},
+		{flagWriteTimeout, s.WriteTimeout},
+		{flagIdleTimeout, s.IdleTimeout},
+	} {
+		if t.d < 0 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(t.flag, flag.ErrInvalidVal))
+		}
+	}
+
+	if s.ShutdownTimeout < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagShutdownTimeout, flag.ErrInvalidVal))
+	}
+
+	if err := s.validateAuth(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if s.MaxBodySize != "" {
+		if _, err := parseByteSize(s.MaxBodySize); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagMaxBodySize, err))
+		}
+	}
+
+	if _, err := parsePrefixLimits(s.MaxBodySizePrefixes); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagMaxBodySizePrefixes, err))
+	}
+
+	if s.MaxHeaderBytes < 4096 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagMaxHeaderBytes,
+				flag.ValidationError("must be at least 4KiB")))
+	}
+
+	if s.AdminAddress != "" {
+		if _, _, err := net.SplitHostPort(s.AdminAddress); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagAdminAddress, err))
+		}
+	}
+
+	if s.EnableMetrics && !strings.HasPrefix(s.MetricsPath, "/") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagMetricsPath, flag.ErrInvalidPath))
+	}
+
+	if _, err := parseTrustedProxies(s.TrustedProxies); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagTrustedProxies, err))
+	}
+
+	if s.ProxyProtocol && len(s.TrustedProxies) == 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagProxyProtocol,
+				flag.ValidationError("requires trusted proxies to be configured")))
+	}
+
+	if err := s.validateRedirect(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if err := s.validateMaintenance(); err != nil {
+		mErr = multierror.Append(mErr, err)
+	}
+
+	if s.ClientCAFile != "" {
+		if _, err := loadCertPool(s.ClientCAFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagClientCAFile, err))
+		}
+	}
+
+	return mErr
+}
+
 // Serve implements run.Service.
 func (s *Service) Serve() error {
 	// listen and serve time
-	if s.SecureHeaders {
-		s.Handler = SecurityHandler(s.Handler)
-	}
-
-	var err error
-	s.l, err = net.Listen("tcp", s.Address)
-	if err != nil {
+	if err := s.wrapHandler(); err != nil {
 		return err
 	}
+	s.configureHTTP2()
 
-	var port string
-	if _, port, err = net.SplitHostPort(s.Address); err != nil {
-		return err
+	var (
+		err  error
+		port string
+	)
+	if path, ok := unixSocketPath(s.Address); ok {
+		var mode os.FileMode
+		if mode, err = parseFileMode(s.UnixSocketMode); err != nil {
+			return err
+		}
+		if s.l, err = listenUnix(path, mode); err != nil {
+			return err
+		}
+	} else {
+		if s.l, err = net.Listen("tcp", s.Address); err != nil {
+			return err
+		}
+		if _, port, err = net.SplitHostPort(s.Address); err != nil {
+			return err
+		}
 	}
-	if port == "443" && s.TLSConfig == nil {
+	if s.ProxyProtocol {
+		s.l = proxyProtocolListener(s.l, s.trusted)
+	}
+	if (port == "443" || s.clientAuthEnabled() || s.EnableH3) && s.TLSConfig == nil {
 		// use ephemeral TLS config
 		s.TLSConfig, err = createEphemeralTLSConfig(30 * 24 * time.Hour)
 		if err != nil {
 			return err
 		}
 	}
+	if s.clientAuthEnabled() {
+		if err = s.configureClientAuth(s.TLSConfig); err != nil {
+			return err
+		}
+	}
+	if s.TLSConfig != nil {
+		if err = s.configureTLS(s.TLSConfig); err != nil {
+			return err
+		}
+	}
+
+	if s.EnableH3 {
+		if err = s.setupH3(); err != nil {
+			return err
+		}
+	}
+	if s.AdminAddress != "" {
+		if err = s.setupAdmin(); err != nil {
+			return err
+		}
+	}
+	if s.RedirectAddress != "" {
+		if err = s.setupRedirect(port); err != nil {
+			return err
+		}
+	}
+	if s.MaintenanceFile != "" {
+		if err = s.watchMaintenanceFile(); err != nil {
+			return err
+		}
+	}
 
 	if s.TLSConfig != nil {
-		return s.ServeTLS(s.l, "", "")
+		return s.serveWithAux(func() error {
+			return s.ServeTLS(s.l, "", "")
+		})
+	}
+
+	return s.serveWithAux(func() error {
+		return s.Server.Serve(s.l)
+	})
+}
+
+// Attach allows one to register HTTP handlers to this server. Once the server
+// is about to serve, the registration function provided to this call will be
+// executed against the Service's http.ServeMux (during the run.Group Serve
+// stage).
+func (s *Service) Attach(fn func(mux *http.ServeMux)) {
+	s.f = append(s.f, fn)
+}
+
+// Handle registers the handler for the given pattern. See http.ServeMux for
+// the supported pattern syntax.
+func (s *Service) Handle(pattern string, h http.Handler) {
+	s.Attach(func(mux *http.ServeMux) {
+		mux.Handle(pattern, h)
+	})
+}
+
+// Use registers middleware wrapping the routes of this server. Middleware is
+// applied in registration order: the first one registered sees the request
+// first. Built-in middleware (request ID, metrics, authentication, etc.) runs
+// before any registered middleware.
+func (s *Service) Use(mw ...func(http.Handler) http.Handler) {
+	s.mw = append(s.mw, mw...)
+}
+
+// buildHandler returns the handler to serve. Virtual hosts registered through
+// HandleHost take precedence, followed by the routes registered through Attach
+// and Handle. All other requests fall through to the configured Handler (or
+// http.DefaultServeMux if not set). The debug endpoints registered on
+// http.DefaultServeMux by importing net/http/pprof or expvar, directly or
+// through a dependency, are not served; they are available on the admin
+// listener instead.
+func (s *Service) buildHandler() http.Handler {
+	fallback := s.Handler
+	if fallback == nil {
+		fallback = http.DefaultServeMux
+	}
+	fallbackMux, _ := fallback.(*http.ServeMux)
+
+	mux := http.NewServeMux()
+	for _, f := range s.f {
+		f(mux)
 	}
 
-	return s.Server.Serve(s.l)
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			setRoute(r, pattern)
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if fallback == http.DefaultServeMux && isDebugEndpoint(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		if fallbackMux != nil {
+			_, pattern := fallbackMux.Handler(r)
+			setRoute(r, pattern)
+		}
+		fallback.ServeHTTP(w, r)
+	})
+	if len(s.vhosts) > 0 {
+		h = s.vhostHandler(h)
+	}
+	return h
 }
 
 // GracefulStop implements run.Service.
 func (s *Service) GracefulStop() {
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(5*time.Second))
-	defer cancel()
-
 	if s.Server != nil {
-		_ = s.Shutdown(ctx)
+		s.shutdown()
 	}
 	if s.l != nil {
 		_ = s.l.Close()
@@ -0,0 +1,280 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func textHandler(text string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, text)
+	})
+}
+
+func TestBuildHandlerRoutesAttachedHandlersFirst(t *testing.T) {
+	s := &Service{}
+	s.FlagSet()
+	s.Handler = textHandler("fallback")
+	s.Handle("GET /api/", textHandler("api"))
+	s.Attach(func(mux *http.ServeMux) {
+		mux.Handle("/health", textHandler("health"))
+	})
+
+	h := s.buildHandler()
+
+	for path, want := range map[string]string{
+		"/api/items": "api",
+		"/health":    "health",
+		"/other":     "fallback",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := rec.Body.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", path, want, got)
+		}
+	}
+}
+
+func TestMetricsRecordRoutePattern(t *testing.T) {
+	s := &Service{EnableMetrics: true, Registry: prometheus.NewRegistry()}
+	s.FlagSet()
+	s.Handle("GET /items/{id}", textHandler("item"))
+	if err := s.setupMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	h := s.metrics.handler(s.buildHandler())
+
+	for _, path := range []string{"/items/1", "/items/2", "/unknown"} {
+		h.ServeHTTP(httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(s.metrics.requests.
+		WithLabelValues(http.MethodGet, "GET /items/{id}", "200")); got != 2 {
+		t.Errorf("expected 2 requests for item route, got %v", got)
+	}
+	if got := testutil.ToFloat64(s.metrics.requests.
+		WithLabelValues(http.MethodGet, routeOther, "404")); got != 1 {
+		t.Errorf("expected 1 request for fallback route, got %v", got)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	s := &Service{EnableMetrics: true, Registry: prometheus.NewRegistry()}
+	s.FlagSet()
+	s.Handle("GET /panic", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	if err := s.wrapHandler(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rec.Code)
+	}
+	if got := testutil.ToFloat64(s.metrics.panics); got != 1 {
+		t.Errorf("expected 1 recorded panic, got %v", got)
+	}
+	if got := testutil.ToFloat64(s.metrics.requests.
+		WithLabelValues(http.MethodGet, "GET /panic", "500")); got != 1 {
+		t.Errorf("expected 1 failed request, got %v", got)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	for _, tc := range []struct {
+		port, method, target, status, location, body string
+	}{
+		{"8443", http.MethodGet, "http://example.com/a?b=c", "301", "https://example.com:8443/a?b=c", ""},
+		{"8443", http.MethodPost, "http://example.com:8080/form", "308", "https://example.com:8443/form", ""},
+		{"8443", http.MethodGet, "http://example.com/.well-known/acme-challenge/token", "200", "", "challenge"},
+		{"8443", http.MethodGet, "http://[::1]/a", "301", "https://[::1]:8443/a", ""},
+		{"443", http.MethodGet, "http://[::1]/a", "301", "https://[::1]/a", ""},
+		{"443", http.MethodGet, "http://[::1]:8080/a", "301", "https://[::1]/a", ""},
+	} {
+		h := redirectHandler(tc.port, textHandler("challenge"))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+		if got := strconv.Itoa(rec.Code); got != tc.status {
+			t.Errorf("%s: expected status %s, got %s", tc.target, tc.status, got)
+		}
+		if got := rec.Header().Get("Location"); got != tc.location {
+			t.Errorf("%s: expected location %q, got %q", tc.target, tc.location, got)
+		}
+		if tc.body != "" && rec.Body.String() != tc.body {
+			t.Errorf("%s: expected body %q, got %q", tc.target, tc.body, rec.Body.String())
+		}
+	}
+}
+
+func TestVirtualHosts(t *testing.T) {
+	s := &Service{}
+	s.FlagSet()
+	s.Handler = textHandler("default")
+	s.HandleHost("Example.com", textHandler("exact"))
+	s.HandleHost("*.example.com", textHandler("wildcard"))
+	s.HandleHost("api.example.com", textHandler("api"))
+
+	h := s.buildHandler()
+
+	for host, want := range map[string]string{
+		"example.com":        "exact",
+		"example.com:8080":   "exact",
+		"www.example.com":    "wildcard",
+		"API.example.com.":   "api",
+		"v1.api.example.com": "default",
+		"other.org":          "default",
+	} {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = host
+		h.ServeHTTP(rec, r)
+		if got := rec.Body.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", host, want, got)
+		}
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	s := &Service{
+		Maintenance:           true,
+		MaintenanceAllow:      []string{"/health"},
+		MaintenanceRetryAfter: time.Minute,
+	}
+	s.FlagSet()
+	s.Handler = textHandler("ok")
+	if err := s.wrapHandler(); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := serve("/api")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected 503 with Retry-After, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec = serve("/health"); rec.Code != http.StatusOK {
+		t.Errorf("expected allow-listed path to be served, got %d", rec.Code)
+	}
+
+	s.SetMaintenance(false)
+	if rec = serve("/api"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 after disabling maintenance, got %d", rec.Code)
+	}
+}
+
+// chanWatcher implements FileWatcher, delivering the notifications sent on
+// the channel.
+type chanWatcher chan []byte
+
+func (w chanWatcher) AddWatcherWithRemoval(string, string) (<-chan []byte, error) { return w, nil }
+
+func TestMaintenanceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maintenance")
+	s := &Service{Server: &http.Server{}, MaintenanceFile: path}
+	s.FlagSet()
+	if err := s.Validate(); err == nil || !strings.Contains(err.Error(), "file watcher") {
+		t.Errorf("expected maintenance file without file watcher to be rejected, got %v", err)
+	}
+
+	w := make(chanWatcher)
+	defer close(w)
+	s.Watcher = w
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.watchMaintenanceFile(); err != nil {
+		t.Fatal(err)
+	}
+	if s.InMaintenance() {
+		t.Error("expected maintenance mode to be disabled without maintenance file")
+	}
+
+	// notify sends the notification twice, as the second send only completes
+	// once the first one has been handled
+	notify := func(b []byte) {
+		w <- b
+		w <- b
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	notify([]byte{})
+	if !s.InMaintenance() {
+		t.Error("expected maintenance mode to be enabled once the maintenance file is created")
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	notify(nil)
+	if s.InMaintenance() {
+		t.Error("expected maintenance mode to be disabled once the maintenance file is removed")
+	}
+}
+
+func TestValidateClientAuth(t *testing.T) {
+	for mode, valid := range map[string]bool{
+		ClientAuthNone:             true,
+		ClientAuthRequest:          true,
+		ClientAuthRequire:          true,
+		ClientAuthVerifyIfGiven:    false,
+		ClientAuthRequireAndVerify: false,
+	} {
+		s := &Service{ClientAuth: mode}
+		s.FlagSet()
+		if err := s.Validate(); (err == nil) != valid {
+			t.Errorf("%s without client CA: expected valid %t, got %v", mode, valid, err)
+		}
+		// client CAs set through the TLS config
+		s.TLSConfig = &tls.Config{ClientCAs: x509.NewCertPool()}
+		if err := s.Validate(); err != nil {
+			t.Errorf("%s with client CAs: expected valid, got %v", mode, err)
+		}
+	}
+}
+
+func TestValidateReadHeaderTimeout(t *testing.T) {
+	// a zero read header timeout falls back to the read timeout
+	s := &Service{Server: &http.Server{ReadTimeout: time.Minute}}
+	s.FlagSet()
+	if err := s.Validate(); err != nil {
+		t.Errorf("expected custom server without read header timeout to be valid, got %v", err)
+	}
+
+	s.ReadHeaderTimeout = -time.Second
+	if err := s.Validate(); err == nil {
+		t.Error("expected negative read header timeout to be invalid")
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// SSE broker flags.
+const (
+	flagSSEPath         = "http-sse-path"
+	flagSSEHeartbeat    = "http-sse-heartbeat"
+	flagSSEHistory      = "http-sse-history"
+	flagSSEClientBuffer = "http-sse-client-buffer"
+	flagSSERetry        = "http-sse-retry"
+)
+
+const (
+	defaultSSEPath         = "/events"
+	defaultSSEHeartbeat    = 15 * time.Second
+	defaultSSEHistory      = 100
+	defaultSSEClientBuffer = 32
+	sseWriteTimeout        = 10 * time.Second
+)
+
+// SSEEvent holds a Server-Sent Event.
+type SSEEvent struct {
+	ID    uint64
+	Topic string
+	// Event holds the optional event type.
+	Event string
+	Data  []byte
+}
+
+type sseClient struct {
+	topics []string
+	send   chan SSEEvent
+	// gone is closed if the broker dropped the client for being too slow.
+	gone chan struct{}
+}
+
+// SSEBroker implements a run.Config which serves a Server-Sent Events
+// endpoint on the provided Service. Clients subscribe to one or more topics
+// through the "topic" query parameter. Events published to a topic are sent to
+// all subscribed clients. Recently published events are kept, so reconnecting
+// clients providing a Last-Event-ID header receive the events they missed.
+// Idle connections are kept alive with heartbeat comments and all streams are
+// closed once the Service shuts down.
+type SSEBroker struct {
+	Service      *Service
+	Path         string
+	Heartbeat    time.Duration
+	History      int
+	ClientBuffer int
+	// Retry optionally holds the reconnection delay advertised to clients.
+	Retry time.Duration
+
+	mu      sync.Mutex
+	clients map[*sseClient]struct{}
+	history []SSEEvent
+	lastID  uint64
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Name implements run.Unit.
+func (b *SSEBroker) Name() string {
+	return "http-sse"
+}
+
+// FlagSet implements run.Config.
+func (b *SSEBroker) FlagSet() *run.FlagSet {
+	if b.Path == "" {
+		b.Path = defaultSSEPath
+	}
+	if b.Heartbeat == 0 {
+		b.Heartbeat = defaultSSEHeartbeat
+	}
+	if b.History == 0 {
+		b.History = defaultSSEHistory
+	}
+	if b.ClientBuffer == 0 {
+		b.ClientBuffer = defaultSSEClientBuffer
+	}
+
+	flags := run.NewFlagSet("HTTP Server-Sent Events options")
+
+	flags.StringVar(&b.Path, flagSSEPath, b.Path,
+		"Path to serve the Server-Sent Events stream on")
+
+	flags.DurationVar(&b.Heartbeat, flagSSEHeartbeat, b.Heartbeat,
+		"Interval of heartbeats keeping idle event streams alive")
+
+	flags.IntVar(&b.History, flagSSEHistory, b.History,
+		"Number of recent events kept for replay to reconnecting clients")
+
+	flags.IntVar(&b.ClientBuffer, flagSSEClientBuffer, b.ClientBuffer,
+		"Number of events buffered per client before it is dropped as too slow")
+
+	flags.DurationVar(&b.Retry, flagSSERetry, b.Retry,
+		"Reconnection delay advertised to clients (0 for the client default)")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (b *SSEBroker) Validate() error {
+	var mErr error
+
+	if b.Service == nil {
+		mErr = multierror.Append(mErr, errors.New("missing http service"))
+	}
+	if !strings.HasPrefix(b.Path, "/") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagSSEPath, flag.ErrInvalidPath))
+	}
+	if b.Heartbeat <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagSSEHeartbeat, flag.ErrInvalidVal))
+	}
+	if b.History < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagSSEHistory, flag.ErrInvalidVal))
+	}
+	if b.ClientBuffer <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagSSEClientBuffer, flag.ErrInvalidVal))
+	}
+	if b.Retry < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagSSERetry, flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (b *SSEBroker) PreRun() error {
+	b.clients = make(map[*sseClient]struct{})
+	b.done = make(chan struct{})
+
+	b.Service.Handle("GET "+b.Path, b)
+	// long-lived streams would otherwise hold up the graceful shutdown
+	b.Service.RegisterOnShutdown(b.Close)
+
+	return nil
+}
+
+// Publish sends an event to all clients subscribed to the topic and returns
+// its ID.
+func (b *SSEBroker) Publish(topic, event string, data []byte) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	ev := SSEEvent{ID: b.lastID, Topic: topic, Event: event, Data: data}
+	if b.History > 0 {
+		if len(b.history) == b.History {
+			b.history = b.history[1:]
+		}
+		b.history = append(b.history, ev)
+	}
+
+	for c := range b.clients {
+		if !slices.Contains(c.topics, topic) {
+			continue
+		}
+		select {
+		case c.send <- ev:
+		default:
+			log.Debug("dropping slow Server-Sent Events client", "topic", topic)
+			delete(b.clients, c)
+			close(c.gone)
+		}
+	}
+	return ev.ID
+}
+
+// Clients returns the number of connected clients.
+func (b *SSEBroker) Clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
+// Close disconnects all clients.
+func (b *SSEBroker) Close() {
+	b.once.Do(func() { close(b.done) })
+}
+
+// subscribe registers a client and returns the events published after
+// lastID it should receive first.
+func (b *SSEBroker) subscribe(c *sseClient, lastID uint64, replay bool) []SSEEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clients[c] = struct{}{}
+	if !replay {
+		return nil
+	}
+	var missed []SSEEvent
+	for _, ev := range b.history {
+		if ev.ID > lastID && slices.Contains(c.topics, ev.Topic) {
+			missed = append(missed, ev)
+		}
+	}
+	return missed
+}
+
+func (b *SSEBroker) unsubscribe(c *sseClient) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.clients, c)
+}
+
+// ServeHTTP implements http.Handler.
+func (b *SSEBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	topics := r.URL.Query()["topic"]
+	if len(topics) == 0 {
+		http.Error(w, "missing topic", http.StatusBadRequest)
+		return
+	}
+
+	lastID, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	replay := err == nil
+
+	rc := http.NewResponseController(w)
+	bw := bufio.NewWriter(w)
+	flush := func() error {
+		// the server write timeout would otherwise end the stream
+		if err := rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout)); err != nil &&
+			!errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	c := &sseClient{
+		topics: topics,
+		send:   make(chan SSEEvent, b.ClientBuffer),
+		gone:   make(chan struct{}),
+	}
+	missed := b.subscribe(c, lastID, replay)
+	defer b.unsubscribe(c)
+
+	if b.Retry > 0 {
+		_, _ = bw.WriteString("retry: " + strconv.FormatInt(b.Retry.Milliseconds(), 10) + "\n\n")
+	}
+	for _, ev := range missed {
+		writeSSEEvent(bw, ev)
+	}
+	if err = flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(b.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-b.done:
+			return
+		case <-c.gone:
+			return
+		case ev := <-c.send:
+			writeSSEEvent(bw, ev)
+		case <-heartbeat.C:
+			_, _ = bw.WriteString(": heartbeat\n\n")
+		}
+		if err = flush(); err != nil {
+			return
+		}
+	}
+}
+
+// sseLineBreaks normalizes the CRLF, CR and LF line breaks of the
+// Server-Sent Events stream to LF, sseNoLineBreaks drops them.
+var (
+	sseLineBreaks   = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+	sseNoLineBreaks = strings.NewReplacer("\r", "", "\n", "")
+)
+
+// writeSSEEvent writes the event to the stream. Line breaks in the event type
+// are dropped and the data is written as one data field per line, so neither
+// can inject fields or events.
+func writeSSEEvent(w *bufio.Writer, ev SSEEvent) {
+	_, _ = w.WriteString("id: " + strconv.FormatUint(ev.ID, 10) + "\n")
+	if event := sseNoLineBreaks.Replace(ev.Event); event != "" {
+		_, _ = w.WriteString("event: " + event + "\n")
+	}
+	for _, line := range strings.Split(sseLineBreaks.Replace(string(ev.Data)), "\n") {
+		_, _ = w.WriteString("data: " + line + "\n")
+	}
+	_, _ = w.WriteString("\n")
+}
+
+var (
+	_ run.Config    = (*SSEBroker)(nil)
+	_ run.PreRunner = (*SSEBroker)(nil)
+	_ http.Handler  = (*SSEBroker)(nil)
+)
@@ -0,0 +1,94 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEBroker(t *testing.T) {
+	s := &Service{}
+	s.FlagSet()
+	b := &SSEBroker{Service: s}
+	b.FlagSet()
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.wrapHandler(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s.Handler)
+	defer srv.Close()
+	defer b.Close()
+
+	b.Publish("news", "", []byte("missed"))
+	b.Publish("other", "", []byte("not subscribed"))
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events?topic=news", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	for deadline := time.Now().Add(time.Second); b.Clients() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not subscribe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	b.Publish("news", "update", []byte("line 1\nline 2"))
+
+	want := []string{
+		"id: 1", "data: missed", "",
+		"id: 3", "event: update", "data: line 1", "data: line 2", "",
+	}
+	sc := bufio.NewScanner(res.Body)
+	var got []string
+	for len(got) < len(want) && sc.Scan() {
+		got = append(got, sc.Text())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected stream:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestWriteSSEEvent(t *testing.T) {
+	var sb strings.Builder
+	w := bufio.NewWriter(&sb)
+	writeSSEEvent(w, SSEEvent{
+		ID:    7,
+		Event: "update\r\nid: 1",
+		Data:  []byte("a\r\nb\rc\nevent: injected"),
+	})
+	_ = w.Flush()
+
+	want := "id: 7\nevent: updateid: 1\ndata: a\ndata: b\ndata: c\ndata: event: injected\n\n"
+	if sb.String() != want {
+		t.Errorf("unexpected event:\n%q", sb.String())
+	}
+}
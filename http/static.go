@@ -0,0 +1,225 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// static file flags.
+const (
+	flagStaticDir    = "http-static-dir"
+	flagStaticPrefix = "http-static-prefix"
+	flagStaticSPA    = "http-static-spa"
+	flagStaticMaxAge = "http-static-max-age"
+)
+
+const (
+	defaultStaticMaxAge = time.Hour
+	indexFile           = "index.html"
+)
+
+// StaticFiles implements a run.Config which serves static files from a
+// directory or fs.FS (e.g. an embed.FS) on the provided Service. Responses
+// carry Cache-Control and ETag headers. In SPA mode, unknown paths without a
+// file extension are answered with the root index.html.
+//
+// The files are served below Prefix, which is required and can't be the
+// root, as the files would otherwise shadow the Handler of the Service.
+type StaticFiles struct {
+	Service *Service
+	// FS holds the files to serve if Dir is not set.
+	FS     fs.FS
+	Dir    string
+	Prefix string
+	SPA    bool
+	MaxAge time.Duration
+
+	fsys  fs.FS
+	etags sync.Map
+}
+
+// Name implements run.Unit.
+func (sf *StaticFiles) Name() string {
+	return "http-static"
+}
+
+// FlagSet implements run.Config.
+func (sf *StaticFiles) FlagSet() *run.FlagSet {
+	if sf.MaxAge == 0 {
+		sf.MaxAge = defaultStaticMaxAge
+	}
+
+	flags := run.NewFlagSet("HTTP static file options")
+
+	flags.StringVar(&sf.Dir, flagStaticDir, sf.Dir,
+		"Directory holding the static files to serve (overrides embedded files)")
+
+	flags.StringVar(&sf.Prefix, flagStaticPrefix, sf.Prefix,
+		"URL path prefix to serve the static files on (required, can't be the root)")
+
+	flags.BoolVar(&sf.SPA, flagStaticSPA, sf.SPA,
+		"Serve index.html for unknown paths (single page application mode)")
+
+	flags.DurationVar(&sf.MaxAge, flagStaticMaxAge, sf.MaxAge,
+		"Cache-Control max-age for static files (index.html is never cached)")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (sf *StaticFiles) Validate() error {
+	var mErr error
+
+	if sf.Service == nil {
+		mErr = multierror.Append(mErr, errors.New("missing http service"))
+	}
+
+	if sf.Dir != "" {
+		if fi, err := os.Stat(sf.Dir); err != nil || !fi.IsDir() {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(flagStaticDir, flag.ErrInvalidPath))
+		}
+	} else if sf.FS == nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagStaticDir, flag.ErrRequired))
+	}
+
+	switch {
+	case sf.Prefix == "":
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagStaticPrefix, flag.ErrRequired))
+	case !strings.HasPrefix(sf.Prefix, "/") || strings.Trim(sf.Prefix, "/") == "":
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagStaticPrefix, flag.ErrInvalidPath))
+	}
+
+	if sf.MaxAge < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagStaticMaxAge, flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (sf *StaticFiles) PreRun() error {
+	sf.fsys = sf.FS
+	if sf.Dir != "" {
+		sf.fsys = os.DirFS(sf.Dir)
+	}
+
+	pattern := sf.Prefix
+	if !strings.HasSuffix(pattern, "/") {
+		pattern += "/"
+	}
+	sf.Service.Handle(pattern,
+		http.StripPrefix(strings.TrimSuffix(pattern, "/"), sf))
+
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (sf *StaticFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = indexFile
+	}
+
+	fi, err := fs.Stat(sf.fsys, name)
+	if err == nil && fi.IsDir() {
+		name = path.Join(name, indexFile)
+		fi, err = fs.Stat(sf.fsys, name)
+	}
+	if err != nil {
+		if !sf.SPA || path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+		// single page application: let the client side router handle it
+		name = indexFile
+		if fi, err = fs.Stat(sf.fsys, name); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	if path.Base(name) == indexFile {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control",
+			"public, max-age="+strconv.Itoa(int(sf.MaxAge.Seconds())))
+	}
+	if etag, err := sf.etag(name, fi); err == nil {
+		w.Header().Set("ETag", etag)
+	}
+
+	http.ServeFileFS(w, r, sf.fsys, name)
+}
+
+// etag returns the ETag of the provided file. Files with a modification time
+// use a weak ETag based on size and modification time. Files without (like
+// the ones in an embed.FS) are hashed once and cached.
+func (sf *StaticFiles) etag(name string, fi fs.FileInfo) (string, error) {
+	if !fi.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, fi.Size(), fi.ModTime().UnixNano()), nil
+	}
+	if etag, ok := sf.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+
+	f, err := sf.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	sf.etags.Store(name, etag)
+	return etag, nil
+}
+
+var (
+	_ run.Config    = (*StaticFiles)(nil)
+	_ run.PreRunner = (*StaticFiles)(nil)
+	_ http.Handler  = (*StaticFiles)(nil)
+)
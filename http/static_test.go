@@ -0,0 +1,94 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticFilesSPA(t *testing.T) {
+	s := &Service{}
+	s.FlagSet()
+	sf := &StaticFiles{
+		Service: s,
+		SPA:     true,
+		FS: fstest.MapFS{
+			"index.html":    {Data: []byte("index")},
+			"assets/app.js": {Data: []byte("app")},
+		},
+	}
+	sf.FlagSet()
+	sf.Prefix = "/ui"
+	if err := sf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	h := s.buildHandler()
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/ui/assets/app.js", http.StatusOK, "app"},
+		{"/ui/", http.StatusOK, "index"},
+		{"/ui/some/route", http.StatusOK, "index"},
+		{"/ui/missing.css", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, rec.Code)
+			continue
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: expected body %q, got %q", tt.path, tt.body, rec.Body.String())
+		}
+	}
+
+	// conditional request using the ETag of the previous response
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/assets/app.js", nil))
+	req := httptest.NewRequest(http.MethodGet, "/ui/assets/app.js", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, rec.Code)
+	}
+}
+
+func TestStaticFilesPrefix(t *testing.T) {
+	for prefix, valid := range map[string]bool{
+		"":        false,
+		"/":       false,
+		"//":      false,
+		"ui":      false,
+		"/ui":     true,
+		"/ui/app": true,
+	} {
+		sf := &StaticFiles{Service: &Service{}, FS: fstest.MapFS{}, Prefix: prefix}
+		sf.FlagSet()
+		if err := sf.Validate(); (err == nil) != valid {
+			t.Errorf("%q: expected valid %t, got %v", prefix, valid, err)
+		}
+	}
+}
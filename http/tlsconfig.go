@@ -0,0 +1,178 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"x25519":         tls.X25519,
+	"p256":           tls.CurveP256,
+	"p384":           tls.CurveP384,
+	"p521":           tls.CurveP521,
+	"x25519mlkem768": tls.X25519MLKEM768,
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	if v == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(v), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q, expected one of 1.0, 1.1, 1.2 or 1.3", v)
+	}
+	return version, nil
+}
+
+// parseCipherSuites parses cipher suite names as listed by tls.CipherSuites.
+// Insecure cipher suites are not accepted.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	available := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		available[cs.Name] = cs.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := available[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func parseCurvePreferences(names []string) ([]tls.CurveID, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	curves := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		key := strings.NewReplacer("-", "", "_", "").
+			Replace(strings.ToLower(strings.TrimSpace(name)))
+		curve, ok := tlsCurves[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve %q, expected one of "+
+				"X25519, P256, P384, P521 or X25519MLKEM768", name)
+		}
+		curves = append(curves, curve)
+	}
+	return curves, nil
+}
+
+// validateTLSOptions checks the TLS version, cipher suite and curve settings.
+func (s *Service) validateTLSOptions() error {
+	var mErr error
+
+	minVersion, err := parseTLSVersion(s.TLSMinVersion)
+	if err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagTLSMinVersion, err))
+	}
+	maxVersion, err := parseTLSVersion(s.TLSMaxVersion)
+	if err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagTLSMaxVersion, err))
+	}
+	if minVersion != 0 && maxVersion != 0 && maxVersion < minVersion {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagTLSMaxVersion,
+				flag.ValidationError("must not be lower than the min. TLS version")))
+	}
+	if s.EnableH3 && maxVersion != 0 && maxVersion < tls.VersionTLS13 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagTLSMaxVersion,
+				flag.ValidationError("HTTP/3 requires TLS 1.3")))
+	}
+
+	if len(s.TLSCipherSuites) > 0 && minVersion == tls.VersionTLS13 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagTLSCipherSuites,
+				flag.ValidationError("not configurable for TLS 1.3")))
+	}
+	if _, err = parseCipherSuites(s.TLSCipherSuites); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagTLSCipherSuites, err))
+	}
+
+	if _, err = parseCurvePreferences(s.TLSCurvePreferences); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagTLSCurvePreferences, err))
+	}
+
+	return mErr
+}
+
+// configureTLS applies the TLS version, cipher suite and curve settings to
+// the provided TLS config. Unset options leave the config untouched, e.g. the
+// ephemeral TLS config defaults to TLS 1.3 only, so configuring cipher suites
+// requires lowering the min. TLS version to 1.2.
+func (s *Service) configureTLS(cfg *tls.Config) error {
+	minVersion, err := parseTLSVersion(s.TLSMinVersion)
+	if err != nil {
+		return err
+	}
+	if minVersion != 0 {
+		cfg.MinVersion = minVersion
+	}
+	maxVersion, err := parseTLSVersion(s.TLSMaxVersion)
+	if err != nil {
+		return err
+	}
+	if maxVersion != 0 {
+		cfg.MaxVersion = maxVersion
+	}
+	// crypto/tls defaults to TLS 1.2 as min. version of servers
+	effectiveMin := cfg.MinVersion
+	if effectiveMin == 0 {
+		effectiveMin = tls.VersionTLS12
+	}
+	if cfg.MaxVersion != 0 && cfg.MaxVersion < effectiveMin {
+		return fmt.Errorf("max. TLS version %s is lower than the min. TLS version %s, "+
+			"lower the min. TLS version as well", tls.VersionName(cfg.MaxVersion),
+			tls.VersionName(effectiveMin))
+	}
+	suites, err := parseCipherSuites(s.TLSCipherSuites)
+	if err != nil {
+		return err
+	}
+	if suites != nil {
+		cfg.CipherSuites = suites
+	}
+	curves, err := parseCurvePreferences(s.TLSCurvePreferences)
+	if err != nil {
+		return err
+	}
+	if curves != nil {
+		cfg.CurvePreferences = curves
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestConfigureTLSVersions(t *testing.T) {
+	for name, test := range map[string]struct {
+		min, max string
+		// custom holds whether to use a config without min. version
+		// instead of the ephemeral config, which only allows TLS 1.3
+		custom bool
+		valid  bool
+	}{
+		"defaults":                 {valid: true},
+		"max below ephemeral min":  {max: "1.2"},
+		"lowered min and max":      {min: "1.2", max: "1.2", valid: true},
+		"max below default min":    {max: "1.1", custom: true},
+		"max at default min":       {max: "1.2", custom: true, valid: true},
+		"min and max TLS 1.3 only": {min: "1.3", max: "1.3", valid: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &tls.Config{}
+			if !test.custom {
+				var err error
+				if cfg, err = createEphemeralTLSConfig(time.Hour); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := &Service{TLSMinVersion: test.min, TLSMaxVersion: test.max}
+			err := s.configureTLS(cfg)
+			if test.valid && err != nil {
+				t.Errorf("expected valid config, got %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("expected error for max. version below the effective min. version")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HandleHost registers the handler for all requests to the given hostname.
+// A hostname starting with "*." matches a single subdomain label, e.g.
+// "*.example.com" matches "api.example.com" but not "example.com" or
+// "v1.api.example.com". Exact hostnames take precedence over wildcards.
+// Requests to hostnames without a registered handler are served by the routes
+// registered through Attach and Handle.
+func (s *Service) HandleHost(host string, h http.Handler) {
+	if s.vhosts == nil {
+		s.vhosts = make(map[string]http.Handler)
+	}
+	s.vhosts[normalizeHost(host)] = h
+}
+
+// vhostHandler returns a handler dispatching requests by hostname. The
+// hostname is taken from the Host header. On TLS connections it must match
+// the SNI server name, otherwise the request is answered with 421 Misdirected
+// Request.
+func (s *Service) vhostHandler(fallback http.Handler) http.Handler {
+	vhosts := s.vhosts
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := normalizeHost(r.Host)
+		if r.TLS != nil && r.TLS.ServerName != "" &&
+			normalizeHost(r.TLS.ServerName) != host {
+			http.Error(w, http.StatusText(http.StatusMisdirectedRequest),
+				http.StatusMisdirectedRequest)
+			return
+		}
+
+		h, ok := vhosts[host]
+		if !ok {
+			if _, parent, found := strings.Cut(host, "."); found {
+				h, ok = vhosts["*."+parent]
+			}
+		}
+		if !ok {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		if mux, isMux := h.(*http.ServeMux); isMux {
+			_, pattern := mux.Handler(r)
+			setRoute(r, pattern)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// normalizeHost strips the port and trailing dot from the hostname and
+// lowercases it.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,438 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// WebSocket hub flags.
+const (
+	flagWSPath           = "http-ws-path"
+	flagWSMaxMessageSize = "http-ws-max-message-size"
+	flagWSAllowedOrigins = "http-ws-allowed-origins"
+	flagWSPingInterval   = "http-ws-ping-interval"
+	flagWSSendQueue      = "http-ws-send-queue"
+)
+
+const (
+	defaultWSPath           = "/ws"
+	defaultWSMaxMessageSize = "64K"
+	defaultWSPingInterval   = 30 * time.Second
+	defaultWSSendQueue      = 64
+	wsWriteTimeout          = 10 * time.Second
+)
+
+// WebSocket message types.
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+)
+
+// ErrConnectionClosed is returned when sending to a closed WebSocket
+// connection.
+var ErrConnectionClosed = errors.New("websocket connection closed")
+
+type wsMessage struct {
+	typ  int
+	data []byte
+}
+
+// WSConn holds a WebSocket connection managed by a WebSocketHub.
+type WSConn struct {
+	ID string
+
+	hub   *WebSocketHub
+	conn  *websocket.Conn
+	send  chan wsMessage
+	rooms map[string]struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Send queues a text message for the connection.
+func (c *WSConn) Send(data []byte) error {
+	return c.SendMessage(TextMessage, data)
+}
+
+// SendMessage queues a message of the provided type for the connection. A
+// connection which can't keep up with its send queue is closed.
+func (c *WSConn) SendMessage(messageType int, data []byte) error {
+	select {
+	case <-c.done:
+		return ErrConnectionClosed
+	default:
+	}
+	select {
+	case c.send <- wsMessage{typ: messageType, data: data}:
+		return nil
+	default:
+		log.Debug("closing slow websocket connection", "id", c.ID)
+		c.Close()
+		return ErrConnectionClosed
+	}
+}
+
+// Join adds the connection to a room.
+func (c *WSConn) Join(room string) {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	if _, ok := c.hub.conns[c]; !ok {
+		return
+	}
+	if c.hub.rooms[room] == nil {
+		c.hub.rooms[room] = make(map[*WSConn]struct{})
+	}
+	c.hub.rooms[room][c] = struct{}{}
+	c.rooms[room] = struct{}{}
+}
+
+// Leave removes the connection from a room.
+func (c *WSConn) Leave(room string) {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	c.hub.leave(c, room)
+}
+
+// Close closes the connection.
+func (c *WSConn) Close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// WebSocketHub implements a run.Config which serves a WebSocket endpoint on
+// the provided Service. Connections can be grouped in rooms to broadcast
+// messages to. Each connection has its own send queue and is kept alive with
+// ping/pong messages. All connections are closed with a "going away" close
+// message once the Service shuts down.
+type WebSocketHub struct {
+	Service        *Service
+	Path           string
+	MaxMessageSize string
+	// AllowedOrigins holds the origins allowed to connect, e.g.
+	// "https://example.com". If empty, only same origin requests are allowed.
+	AllowedOrigins []string
+	PingInterval   time.Duration
+	SendQueue      int
+	// OnConnect is optionally called for each new connection, e.g. to join
+	// rooms based on the request.
+	OnConnect func(c *WSConn, r *http.Request)
+	// OnMessage is optionally called for each message received.
+	OnMessage func(c *WSConn, messageType int, data []byte)
+	// OnDisconnect is optionally called once a connection is closed.
+	OnDisconnect func(c *WSConn)
+
+	upgrader websocket.Upgrader
+	maxSize  int64
+	mu       sync.Mutex
+	conns    map[*WSConn]struct{}
+	rooms    map[string]map[*WSConn]struct{}
+	wg       sync.WaitGroup
+	done     chan struct{}
+	once     sync.Once
+}
+
+// Name implements run.Unit.
+func (h *WebSocketHub) Name() string {
+	return "http-websocket"
+}
+
+// FlagSet implements run.Config.
+func (h *WebSocketHub) FlagSet() *run.FlagSet {
+	if h.Path == "" {
+		h.Path = defaultWSPath
+	}
+	if h.MaxMessageSize == "" {
+		h.MaxMessageSize = defaultWSMaxMessageSize
+	}
+	if h.PingInterval == 0 {
+		h.PingInterval = defaultWSPingInterval
+	}
+	if h.SendQueue == 0 {
+		h.SendQueue = defaultWSSendQueue
+	}
+
+	flags := run.NewFlagSet("HTTP WebSocket options")
+
+	flags.StringVar(&h.Path, flagWSPath, h.Path,
+		"Path to serve the WebSocket endpoint on")
+
+	flags.StringVar(&h.MaxMessageSize, flagWSMaxMessageSize, h.MaxMessageSize,
+		`Max. size of received WebSocket messages, e.g. "64K" or "1M"`)
+
+	flags.StringSliceVar(&h.AllowedOrigins, flagWSAllowedOrigins, h.AllowedOrigins,
+		`Origins allowed to connect, e.g. "https://example.com" ("*" for any, empty for same origin)`)
+
+	flags.DurationVar(&h.PingInterval, flagWSPingInterval, h.PingInterval,
+		"Interval of WebSocket pings keeping connections alive")
+
+	flags.IntVar(&h.SendQueue, flagWSSendQueue, h.SendQueue,
+		"Number of messages queued per connection before it is closed as too slow")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (h *WebSocketHub) Validate() error {
+	var mErr error
+
+	if h.Service == nil {
+		mErr = multierror.Append(mErr, errors.New("missing http service"))
+	}
+	if !strings.HasPrefix(h.Path, "/") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagWSPath, flag.ErrInvalidPath))
+	}
+	if size, err := parseByteSize(h.MaxMessageSize); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagWSMaxMessageSize, err))
+	} else if size <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagWSMaxMessageSize, flag.ErrInvalidVal))
+	}
+	if h.PingInterval <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagWSPingInterval, flag.ErrInvalidVal))
+	}
+	if h.SendQueue <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(flagWSSendQueue, flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (h *WebSocketHub) PreRun() (err error) {
+	if h.maxSize, err = parseByteSize(h.MaxMessageSize); err != nil {
+		return err
+	}
+	h.conns = make(map[*WSConn]struct{})
+	h.rooms = make(map[string]map[*WSConn]struct{})
+	h.done = make(chan struct{})
+	h.upgrader = websocket.Upgrader{}
+	if len(h.AllowedOrigins) > 0 {
+		h.upgrader.CheckOrigin = h.checkOrigin
+	}
+
+	h.Service.Handle("GET "+h.Path, h)
+	// hijacked connections are not tracked by the HTTP server
+	h.Service.RegisterOnShutdown(h.Close)
+
+	return nil
+}
+
+// Broadcast sends a text message to all connections.
+func (h *WebSocketHub) Broadcast(data []byte) {
+	h.mu.Lock()
+	conns := make([]*WSConn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		_ = c.Send(data)
+	}
+}
+
+// BroadcastRoom sends a text message to all connections in the room.
+func (h *WebSocketHub) BroadcastRoom(room string, data []byte) {
+	h.mu.Lock()
+	conns := make([]*WSConn, 0, len(h.rooms[room]))
+	for c := range h.rooms[room] {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		_ = c.Send(data)
+	}
+}
+
+// Connections returns the number of open connections.
+func (h *WebSocketHub) Connections() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.conns)
+}
+
+// Close closes all connections and waits for them to finish.
+func (h *WebSocketHub) Close() {
+	h.once.Do(func() { close(h.done) })
+	h.wg.Wait()
+}
+
+func (h *WebSocketHub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return slices.ContainsFunc(h.AllowedOrigins, func(allowed string) bool {
+		return allowed == "*" || strings.EqualFold(allowed, origin)
+	})
+}
+
+// leave removes the connection from a room. Must be called with h.mu held.
+func (h *WebSocketHub) leave(c *WSConn, room string) {
+	delete(c.rooms, room)
+	if members, ok := h.rooms[room]; ok {
+		delete(members, c)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+func (h *WebSocketHub) register(c *WSConn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-h.done:
+		return false
+	default:
+	}
+	h.conns[c] = struct{}{}
+	h.wg.Add(1)
+	return true
+}
+
+func (h *WebSocketHub) unregister(c *WSConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for room := range c.rooms {
+		h.leave(c, room)
+	}
+	delete(h.conns, c)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *WebSocketHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has responded with an error
+		log.Context(r.Context()).Debug("websocket upgrade failed", "error", err.Error())
+		return
+	}
+
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	c := &WSConn{
+		ID:    hex.EncodeToString(id[:]),
+		hub:   h,
+		conn:  conn,
+		send:  make(chan wsMessage, h.SendQueue),
+		rooms: make(map[string]struct{}),
+		done:  make(chan struct{}),
+	}
+	if !h.register(c) {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+			time.Now().Add(wsWriteTimeout))
+		_ = conn.Close()
+		return
+	}
+	defer h.wg.Done()
+
+	if h.OnConnect != nil {
+		h.OnConnect(c, r)
+	}
+
+	go h.readPump(c)
+	h.writePump(c)
+
+	h.unregister(c)
+	if h.OnDisconnect != nil {
+		h.OnDisconnect(c)
+	}
+}
+
+// readPump reads messages from the connection until it fails or is closed.
+func (h *WebSocketHub) readPump(c *WSConn) {
+	defer c.Close()
+
+	pongWait := 2 * h.PingInterval
+	c.conn.SetReadLimit(h.maxSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		typ, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if h.OnMessage != nil {
+			h.OnMessage(c, typ, data)
+		}
+	}
+}
+
+// writePump writes queued messages and pings to the connection until it is
+// closed, either by the peer, the hub or the application.
+func (h *WebSocketHub) writePump(c *WSConn) {
+	ping := time.NewTicker(h.PingInterval)
+	defer func() {
+		ping.Stop()
+		_ = c.conn.Close()
+	}()
+
+	closeWith := func(code int) {
+		_ = c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(code, ""), time.Now().Add(wsWriteTimeout))
+	}
+
+	for {
+		select {
+		case <-h.done:
+			closeWith(websocket.CloseGoingAway)
+			return
+		case <-c.done:
+			closeWith(websocket.CloseNormalClosure)
+			return
+		case msg := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteMessage(msg.typ, msg.data); err != nil {
+				c.Close()
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil,
+				time.Now().Add(wsWriteTimeout)); err != nil {
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+var (
+	_ run.Config    = (*WebSocketHub)(nil)
+	_ run.PreRunner = (*WebSocketHub)(nil)
+	_ http.Handler  = (*WebSocketHub)(nil)
+)
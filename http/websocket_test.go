@@ -0,0 +1,87 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketHub(t *testing.T) {
+	s := &Service{}
+	s.FlagSet()
+	h := &WebSocketHub{
+		Service: s,
+		OnConnect: func(c *WSConn, r *http.Request) {
+			c.Join(r.URL.Query().Get("room"))
+		},
+		OnMessage: func(c *WSConn, typ int, data []byte) {
+			_ = c.SendMessage(typ, append([]byte("echo: "), data...))
+		},
+	}
+	h.FlagSet()
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.wrapHandler(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s.Handler)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?room=lobby"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	expect := func(want string) {
+		t.Helper()
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("expected %q, got %q", want, data)
+		}
+	}
+
+	if err = conn.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	expect("echo: hi")
+
+	h.BroadcastRoom("other", []byte("not for us"))
+	h.BroadcastRoom("lobby", []byte("welcome"))
+	expect("welcome")
+
+	h.Close()
+	if _, _, err = conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected going away close, got %v", err)
+	}
+	if n := h.Connections(); n != 0 {
+		t.Errorf("expected no connections after close, got %d", n)
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpclient provides a run.Config implementation to configure an
+// outbound HTTP client.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// package flags.
+const (
+	defaultTimeout             = 30 * time.Second
+	defaultDialTimeout         = 5 * time.Second
+	defaultTLSHandshakeTimeout = 5 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 10
+	defaultRetryWaitMin        = 100 * time.Millisecond
+	defaultRetryWaitMax        = 5 * time.Second
+	defaultUserAgent           = "run-handlers-httpclient"
+
+	Timeout               = "http-client-timeout"
+	DialTimeout           = "http-client-dial-timeout"
+	TLSHandshakeTimeout   = "http-client-tls-handshake-timeout"
+	ResponseHeaderTimeout = "http-client-response-header-timeout"
+	IdleConnTimeout       = "http-client-idle-conn-timeout"
+	MaxIdleConns          = "http-client-max-idle-conns"
+	MaxIdleConnsPerHost   = "http-client-max-idle-conns-per-host"
+	MaxConnsPerHost       = "http-client-max-conns-per-host"
+	Proxy                 = "http-client-proxy"
+	CAFile                = "http-client-ca-file"
+	CertFile              = "http-client-cert-file"
+	KeyFile               = "http-client-key-file"
+	InsecureSkipVerify    = "http-client-insecure-skip-verify"
+	UserAgent             = "http-client-user-agent"
+	MaxRetries            = "http-client-max-retries"
+	RetryWaitMin          = "http-client-retry-wait-min"
+	RetryWaitMax          = "http-client-retry-wait-max"
+)
+
+// Config implements run.Config to allow configuration of an outbound HTTP
+// client.
+type Config struct {
+	Prefix string
+
+	Timeout               time.Duration
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	// Proxy holds the proxy URL to use. If empty the proxy is taken from the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy              string
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+	UserAgent          string
+	// MaxRetries holds the number of times a failed idempotent request is
+	// retried. Zero disables retries.
+	MaxRetries   int
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+
+	client *http.Client
+}
+
+func (c *Config) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("http-client")
+}
+
+// Initialize implements run.Initializer.
+func (c *Config) Initialize() {
+	if c.Timeout == 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = defaultDialTimeout
+	}
+	if c.TLSHandshakeTimeout == 0 {
+		c.TLSHandshakeTimeout = defaultTLSHandshakeTimeout
+	}
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = defaultIdleConnTimeout
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = defaultMaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost == 0 {
+		c.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	if c.RetryWaitMin == 0 {
+		c.RetryWaitMin = defaultRetryWaitMin
+	}
+	if c.RetryWaitMax == 0 {
+		c.RetryWaitMax = defaultRetryWaitMax
+	}
+	if c.UserAgent == "" {
+		c.UserAgent = defaultUserAgent
+	}
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("HTTP client options")
+
+	flags.DurationVar(&c.Timeout, c.prefix(Timeout),
+		c.Timeout, "overall request timeout (0 to disable)")
+
+	flags.DurationVar(&c.DialTimeout, c.prefix(DialTimeout),
+		c.DialTimeout, "connection dial timeout")
+
+	flags.DurationVar(&c.TLSHandshakeTimeout, c.prefix(TLSHandshakeTimeout),
+		c.TLSHandshakeTimeout, "TLS handshake timeout")
+
+	flags.DurationVar(&c.ResponseHeaderTimeout, c.prefix(ResponseHeaderTimeout),
+		c.ResponseHeaderTimeout, "time to wait for response headers (0 to disable)")
+
+	flags.DurationVar(&c.IdleConnTimeout, c.prefix(IdleConnTimeout),
+		c.IdleConnTimeout, "max. time an idle connection is kept open")
+
+	flags.IntVar(&c.MaxIdleConns, c.prefix(MaxIdleConns),
+		c.MaxIdleConns, "max. idle connections")
+
+	flags.IntVar(&c.MaxIdleConnsPerHost, c.prefix(MaxIdleConnsPerHost),
+		c.MaxIdleConnsPerHost, "max. idle connections per host")
+
+	flags.IntVar(&c.MaxConnsPerHost, c.prefix(MaxConnsPerHost),
+		c.MaxConnsPerHost, "max. connections per host (0 for no limit)")
+
+	flags.StringVar(&c.Proxy, c.prefix(Proxy),
+		c.Proxy, "proxy URL (defaults to the HTTP_PROXY/HTTPS_PROXY environment)")
+
+	flags.StringVar(&c.CAFile, c.prefix(CAFile),
+		c.CAFile, "PEM encoded CA bundle to verify servers with (defaults to system roots)")
+
+	flags.StringVar(&c.CertFile, c.prefix(CertFile),
+		c.CertFile, "PEM encoded client certificate for mutual TLS")
+
+	flags.StringVar(&c.KeyFile, c.prefix(KeyFile),
+		c.KeyFile, "PEM encoded client key for mutual TLS")
+
+	flags.BoolVar(&c.InsecureSkipVerify, c.prefix(InsecureSkipVerify),
+		c.InsecureSkipVerify, "skip server certificate verification (testing only)")
+
+	flags.StringVar(&c.UserAgent, c.prefix(UserAgent),
+		c.UserAgent, "User-Agent header to send if not set on the request")
+
+	flags.IntVar(&c.MaxRetries, c.prefix(MaxRetries),
+		c.MaxRetries, "max. retries of failed idempotent requests (0 to disable)")
+
+	flags.DurationVar(&c.RetryWaitMin, c.prefix(RetryWaitMin),
+		c.RetryWaitMin, "initial retry backoff")
+
+	flags.DurationVar(&c.RetryWaitMax, c.prefix(RetryWaitMax),
+		c.RetryWaitMax, "max. retry backoff")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	for _, v := range []struct {
+		name  string
+		value int64
+	}{
+		{Timeout, int64(c.Timeout)},
+		{DialTimeout, int64(c.DialTimeout)},
+		{TLSHandshakeTimeout, int64(c.TLSHandshakeTimeout)},
+		{ResponseHeaderTimeout, int64(c.ResponseHeaderTimeout)},
+		{IdleConnTimeout, int64(c.IdleConnTimeout)},
+		{MaxIdleConns, int64(c.MaxIdleConns)},
+		{MaxIdleConnsPerHost, int64(c.MaxIdleConnsPerHost)},
+		{MaxConnsPerHost, int64(c.MaxConnsPerHost)},
+		{MaxRetries, int64(c.MaxRetries)},
+	} {
+		if v.value < 0 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(v.name), flag.ErrInvalidVal))
+		}
+	}
+
+	if c.Proxy != "" {
+		if u, err := url.Parse(c.Proxy); err != nil || u.Scheme == "" || u.Host == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(Proxy), flag.ErrInvalidVal))
+		}
+	}
+
+	if c.CAFile != "" {
+		if _, err := os.Stat(c.CAFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(CAFile), flag.ErrInvalidPath))
+		}
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(CertFile),
+				errors.New("client certificate and key must be provided together")))
+	}
+
+	if c.RetryWaitMin <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(RetryWaitMin), flag.ErrInvalidVal))
+	}
+	if c.RetryWaitMax < c.RetryWaitMin {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(RetryWaitMax),
+				fmt.Errorf("must not be smaller than %s", c.prefix(RetryWaitMin))))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (c *Config) PreRun() error {
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if c.Proxy != "" {
+		u, _ := url.Parse(c.Proxy)
+		proxy = http.ProxyURL(u)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   c.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	var rt http.RoundTripper = &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   c.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: c.ResponseHeaderTimeout,
+		IdleConnTimeout:       c.IdleConnTimeout,
+		MaxIdleConns:          c.MaxIdleConns,
+		MaxIdleConnsPerHost:   c.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       c.MaxConnsPerHost,
+		ForceAttemptHTTP2:     true,
+		ExpectContinueTimeout: time.Second,
+	}
+	if c.MaxRetries > 0 {
+		rt = &retryTransport{
+			next:       rt,
+			maxRetries: c.MaxRetries,
+			waitMin:    c.RetryWaitMin,
+			waitMax:    c.RetryWaitMax,
+		}
+	}
+	rt = &userAgentTransport{next: rt, userAgent: c.UserAgent}
+
+	c.client = &http.Client{
+		Transport: rt,
+		Timeout:   c.Timeout,
+	}
+
+	return nil
+}
+
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // explicitly opted in
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// Client returns the configured HTTP client.
+func (c *Config) Client() *http.Client {
+	return c.client
+}
+
+var (
+	_ run.Initializer = (*Config)(nil)
+	_ run.Config      = (*Config)(nil)
+	_ run.PreRunner   = (*Config)(nil)
+)
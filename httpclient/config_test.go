@@ -0,0 +1,75 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/basvanbeek/run-handlers/httpclient"
+)
+
+func newClient(t *testing.T, maxRetries int) *http.Client {
+	t.Helper()
+	c := &httpclient.Config{MaxRetries: maxRetries, RetryWaitMin: time.Millisecond}
+	c.Initialize()
+	_ = c.FlagSet()
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PreRun(); err != nil {
+		t.Fatal(err)
+	}
+	return c.Client()
+}
+
+func TestClientRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "" {
+			t.Error("expected User-Agent header")
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	res, err := newClient(t, 3).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("expected 200 after 3 calls, got %d after %d", res.StatusCode, calls.Load())
+	}
+
+	// non idempotent requests are not retried
+	calls.Store(0)
+	res, err = newClient(t, 3).Post(srv.URL, "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("expected 503 after 1 call, got %d after %d", res.StatusCode, calls.Load())
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Priority of a job. Jobs with a higher priority are fetched first.
+type Priority string
+
+// Supported priorities.
+const (
+	PriorityHigh   Priority = "high"
+	PriorityNormal Priority = "normal"
+	PriorityLow    Priority = "low"
+)
+
+// priorities holds the priorities in fetch order.
+var priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// Job holds a background job.
+type Job struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	Priority Priority        `json:"priority"`
+	// Attempt holds the number of the current attempt, starting at 1.
+	Attempt    int       `json:"attempt"`
+	MaxRetries int       `json:"maxRetries"`
+	EnqueuedAt time.Time `json:"enqueuedAt"`
+	// LastError holds the error of the previous attempt.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Decode decodes the job payload into v.
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler processes a job. Returning an error retries the job with backoff
+// until its retries are exhausted, after which it is moved to the
+// dead-letter list. Wrap the error with Permanent to skip the retries.
+type Handler func(ctx context.Context, job *Job) error
+
+// Option configures an enqueued job.
+type Option func(*enqueueOptions)
+
+type enqueueOptions struct {
+	priority   Priority
+	runAt      time.Time
+	maxRetries *int
+	id         string
+}
+
+// WithPriority sets the priority of the job.
+func WithPriority(p Priority) Option {
+	return func(o *enqueueOptions) { o.priority = p }
+}
+
+// WithDelay delays the job by the provided duration.
+func WithDelay(d time.Duration) Option {
+	return func(o *enqueueOptions) { o.runAt = time.Now().Add(d) }
+}
+
+// WithRunAt schedules the job at the provided time.
+func WithRunAt(t time.Time) Option {
+	return func(o *enqueueOptions) { o.runAt = t }
+}
+
+// WithMaxRetries overrides the default max. number of retries.
+func WithMaxRetries(n int) Option {
+	return func(o *enqueueOptions) { o.maxRetries = &n }
+}
+
+// WithID sets the job ID instead of a generated one.
+func WithID(id string) Option {
+	return func(o *enqueueOptions) { o.id = id }
+}
+
+// ErrInvalidPriority is returned when enqueuing a job with an unknown
+// priority.
+var ErrInvalidPriority = errors.New("invalid job priority")
+
+// Enqueue adds a job of the provided type with the JSON encoded payload to
+// the queue and returns its ID.
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload any, opts ...Option) (string, error) {
+	o := enqueueOptions{priority: PriorityNormal, maxRetries: &q.MaxRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !validPriority(o.priority) {
+		return "", ErrInvalidPriority
+	}
+	if o.id == "" {
+		o.id = newID()
+	}
+
+	job := &Job{
+		ID:         o.id,
+		Type:       jobType,
+		Priority:   o.priority,
+		MaxRetries: *o.maxRetries,
+		EnqueuedAt: time.Now().UTC(),
+	}
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return "", err
+		}
+		job.Payload = b
+	}
+	b, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+
+	if o.runAt.After(time.Now()) {
+		err = q.rdb.ZAdd(ctx, q.key("delayed"), redis.Z{
+			Score:  float64(o.runAt.UnixMilli()),
+			Member: string(b),
+		}).Err()
+	} else {
+		err = q.rdb.LPush(ctx, q.queueKey(job.Priority), string(b)).Err()
+	}
+	if err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// Stats holds the number of jobs by state.
+type Stats struct {
+	Queued     map[Priority]int64
+	Delayed    int64
+	Running    int64
+	DeadLetter int64
+}
+
+// Stats returns the number of jobs by state.
+func (q *Queue) Stats(ctx context.Context) (Stats, error) {
+	pipe := q.rdb.Pipeline()
+	queued := make(map[Priority]*redis.IntCmd, len(priorities))
+	for _, p := range priorities {
+		queued[p] = pipe.LLen(ctx, q.queueKey(p))
+	}
+	delayed := pipe.ZCard(ctx, q.key("delayed"))
+	running := pipe.ZCard(ctx, q.key("running"))
+	dead := pipe.LLen(ctx, q.key("dead"))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Stats{}, err
+	}
+
+	st := Stats{
+		Queued:     make(map[Priority]int64, len(priorities)),
+		Delayed:    delayed.Val(),
+		Running:    running.Val(),
+		DeadLetter: dead.Val(),
+	}
+	for p, cmd := range queued {
+		st.Queued[p] = cmd.Val()
+	}
+	return st, nil
+}
+
+// DeadLetters returns up to limit jobs from the dead-letter list, most
+// recent first.
+func (q *Queue) DeadLetters(ctx context.Context, limit int) ([]*Job, error) {
+	res, err := q.rdb.LRange(ctx, q.key("dead"), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(res))
+	for _, raw := range res {
+		var job Job
+		if err = json.Unmarshal([]byte(raw), &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+// RetryDeadLetters moves all jobs from the dead-letter list back onto their
+// queues with reset attempts and returns the number of moved jobs.
+func (q *Queue) RetryDeadLetters(ctx context.Context) (int, error) {
+	var n int
+	for {
+		raw, err := q.rdb.RPop(ctx, q.key("dead")).Result()
+		if errors.Is(err, redis.Nil) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		var job Job
+		if err = json.Unmarshal([]byte(raw), &job); err != nil {
+			log.Error("dropping invalid dead-letter job", err)
+			continue
+		}
+		job.Attempt = 0
+		b, err := json.Marshal(&job)
+		if err != nil {
+			return n, err
+		}
+		if err = q.rdb.LPush(ctx, q.queueKey(job.Priority), string(b)).Err(); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// permanentError marks an error as not retryable.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps the error to move the job to the dead-letter list without
+// retrying it.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+func (q *Queue) queueKey(p Priority) string {
+	return q.key("queue:" + string(p))
+}
+
+func validPriority(p Priority) bool {
+	for _, v := range priorities {
+		if p == v {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,242 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jobs provides a Redis backed background job queue with priorities,
+// delayed jobs, retries with backoff and dead-letter handling.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("jobs", "background jobs")
+
+// package flags.
+const (
+	defaultKeyPrefix       = "{jobs}:"
+	defaultWorkers         = 10
+	defaultPollInterval    = 500 * time.Millisecond
+	defaultMaxRetries      = 5
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = 10 * time.Minute
+	defaultJobTimeout      = 5 * time.Minute
+	defaultShutdownTimeout = 30 * time.Second
+	defaultDeadLetterMax   = 10000
+
+	KeyPrefix       = "jobs-key-prefix"
+	Workers         = "jobs-workers"
+	PollInterval    = "jobs-poll-interval"
+	MaxRetries      = "jobs-max-retries"
+	RetryBackoff    = "jobs-retry-backoff"
+	RetryMaxBackoff = "jobs-retry-max-backoff"
+	JobTimeout      = "jobs-timeout"
+	ShutdownTimeout = "jobs-shutdown-timeout"
+	DeadLetterMax   = "jobs-dead-letter-max"
+)
+
+// Queue errors.
+var (
+	ErrNoRedis     = errors.New("redis client not configured")
+	ErrUnknownType = errors.New("no handler registered for job type")
+)
+
+// Queue implements run.Config and run.ServiceContext for a Redis backed job
+// queue. Jobs are enqueued with Enqueue and processed by the workers started
+// in ServeContext using the Handlers registered with Handle. Set Workers to
+// a negative value, or to 0 through its flag, for enqueue only instances.
+// Initialize sets the zero-valued fields to their defaults.
+//
+// Jobs are held in a list per priority, delayed and retried jobs in a sorted
+// set by due time. Fetched jobs are tracked until acknowledged; jobs of
+// crashed or stuck workers are requeued once JobTimeout expires, counting as
+// a failed attempt, so handlers should be idempotent. All keys share a hash
+// tag to support Redis Cluster.
+type Queue struct {
+	Prefix string
+
+	// Redis returns the Redis client, e.g. the Pool method of the redis
+	// handler.
+	Redis func() redis.UniversalClient
+
+	KeyPrefix       string
+	Workers         int
+	PollInterval    time.Duration
+	MaxRetries      int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	JobTimeout      time.Duration
+	ShutdownTimeout time.Duration
+	DeadLetterMax   int
+
+	rdb      redis.UniversalClient
+	mtx      sync.RWMutex
+	handlers map[string]Handler
+}
+
+func (q *Queue) prefix(s string) string {
+	if q.Prefix != "" {
+		return q.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (q *Queue) Name() string {
+	return q.prefix("jobs")
+}
+
+// Initialize implements run.Initializer.
+func (q *Queue) Initialize() {
+	if q.KeyPrefix == "" {
+		q.KeyPrefix = defaultKeyPrefix
+	}
+	if q.Workers == 0 {
+		q.Workers = defaultWorkers
+	}
+	if q.PollInterval == 0 {
+		q.PollInterval = defaultPollInterval
+	}
+	if q.MaxRetries == 0 {
+		q.MaxRetries = defaultMaxRetries
+	}
+	if q.RetryBackoff == 0 {
+		q.RetryBackoff = defaultRetryBackoff
+	}
+	if q.RetryMaxBackoff == 0 {
+		q.RetryMaxBackoff = defaultRetryMaxBackoff
+	}
+	if q.JobTimeout == 0 {
+		q.JobTimeout = defaultJobTimeout
+	}
+	if q.ShutdownTimeout == 0 {
+		q.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if q.DeadLetterMax == 0 {
+		q.DeadLetterMax = defaultDeadLetterMax
+	}
+}
+
+// FlagSet implements run.Config.
+func (q *Queue) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Job queue options")
+
+	flags.StringVar(&q.KeyPrefix, q.prefix(KeyPrefix),
+		q.KeyPrefix, "prefix of the Redis keys")
+
+	flags.IntVar(&q.Workers, q.prefix(Workers),
+		q.Workers, "number of concurrent workers (0 or negative for enqueue only)")
+
+	flags.DurationVar(&q.PollInterval, q.prefix(PollInterval),
+		q.PollInterval, "interval for polling empty queues and due jobs")
+
+	flags.IntVar(&q.MaxRetries, q.prefix(MaxRetries),
+		q.MaxRetries, "default max. number of retries of a failed job")
+
+	flags.DurationVar(&q.RetryBackoff, q.prefix(RetryBackoff),
+		q.RetryBackoff, "backoff before the first retry, doubled for each next retry")
+
+	flags.DurationVar(&q.RetryMaxBackoff, q.prefix(RetryMaxBackoff),
+		q.RetryMaxBackoff, "max. backoff between retries")
+
+	flags.DurationVar(&q.JobTimeout, q.prefix(JobTimeout),
+		q.JobTimeout, "max. duration of a job, after which it is considered failed")
+
+	flags.DurationVar(&q.ShutdownTimeout, q.prefix(ShutdownTimeout),
+		q.ShutdownTimeout, "max. time to wait for running jobs on shutdown")
+
+	flags.IntVar(&q.DeadLetterMax, q.prefix(DeadLetterMax),
+		q.DeadLetterMax, "max. number of jobs kept in the dead-letter list")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (q *Queue) Validate() error {
+	var mErr error
+
+	if q.Redis == nil {
+		mErr = multierror.Append(mErr, ErrNoRedis)
+	}
+	if q.KeyPrefix == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(q.prefix(KeyPrefix), flag.ErrRequired))
+	}
+	for _, v := range []struct {
+		flag  string
+		valid bool
+	}{
+		{PollInterval, q.PollInterval > 0},
+		{MaxRetries, q.MaxRetries >= 0},
+		{RetryBackoff, q.RetryBackoff > 0},
+		{RetryMaxBackoff, q.RetryMaxBackoff >= q.RetryBackoff},
+		{JobTimeout, q.JobTimeout > 0},
+		{ShutdownTimeout, q.ShutdownTimeout >= 0},
+		{DeadLetterMax, q.DeadLetterMax > 0},
+	} {
+		if !v.valid {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(q.prefix(v.flag), flag.ErrInvalidVal))
+		}
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (q *Queue) PreRun() error {
+	if q.rdb = q.Redis(); q.rdb == nil {
+		return ErrNoRedis
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return q.rdb.Ping(ctx).Err()
+}
+
+// Handle registers the Handler for the job type. Register handlers before
+// ServeContext.
+func (q *Queue) Handle(jobType string, h Handler) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if q.handlers == nil {
+		q.handlers = make(map[string]Handler)
+	}
+	q.handlers[jobType] = h
+}
+
+func (q *Queue) handler(jobType string) Handler {
+	q.mtx.RLock()
+	defer q.mtx.RUnlock()
+	return q.handlers[jobType]
+}
+
+func (q *Queue) key(name string) string {
+	return q.KeyPrefix + name
+}
+
+var (
+	_ run.Initializer    = (*Queue)(nil)
+	_ run.Config         = (*Queue)(nil)
+	_ run.PreRunner      = (*Queue)(nil)
+	_ run.ServiceContext = (*Queue)(nil)
+)
@@ -0,0 +1,378 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// fetchScript pops a job from the first non-empty queue and tracks it
+	// as running under the fetch token until the provided deadline.
+	//
+	// KEYS: running, running jobs, queues in priority order.
+	// ARGV: deadline, fetch token.
+	fetchScript = redis.NewScript(`
+for i = 3, #KEYS do
+	local job = redis.call("RPOP", KEYS[i])
+	if job then
+		redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+		redis.call("HSET", KEYS[2], ARGV[2], job)
+		return job
+	end
+end
+return false`)
+
+	// settleScript removes a running job by its fetch token and, depending
+	// on the action, schedules its retry, moves it to the dead-letter list
+	// or pushes it back onto its queue. If a max. deadline is provided, only
+	// running jobs which expired before it are settled. It returns 0 if the
+	// token is not running (anymore), e.g. as it expired and was reclaimed.
+	//
+	// KEYS: running, running jobs, delayed, dead, queue.
+	// ARGV: fetch token, action (ack, retry, dead or requeue), job, retry
+	// time, max. dead-letter list length, max. deadline (optional).
+	settleScript = redis.NewScript(`
+local deadline = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if not deadline then
+	return 0
+end
+if ARGV[6] ~= "" and tonumber(deadline) > tonumber(ARGV[6]) then
+	return 0
+end
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+if ARGV[2] == "retry" then
+	redis.call("ZADD", KEYS[3], ARGV[4], ARGV[3])
+elseif ARGV[2] == "dead" then
+	redis.call("LPUSH", KEYS[4], ARGV[3])
+	redis.call("LTRIM", KEYS[4], 0, tonumber(ARGV[5]) - 1)
+elseif ARGV[2] == "requeue" then
+	redis.call("LPUSH", KEYS[5], ARGV[3])
+end
+return 1`)
+
+	// promoteScript moves due delayed jobs onto their queues.
+	//
+	// KEYS: delayed, high, normal and low queue.
+	// ARGV: now, max. number of jobs to move.
+	promoteScript = redis.NewScript(`
+local queues = {high = KEYS[2], normal = KEYS[3], low = KEYS[4]}
+local n = 0
+local jobs = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, job in ipairs(jobs) do
+	redis.call("ZREM", KEYS[1], job)
+	local ok, decoded = pcall(cjson.decode, job)
+	local queue = KEYS[3]
+	if ok and queues[decoded.priority] then
+		queue = queues[decoded.priority]
+	end
+	redis.call("LPUSH", queue, job)
+	n = n + 1
+end
+return n`)
+)
+
+// settle actions.
+const (
+	actionAck     = "ack"
+	actionRetry   = "retry"
+	actionDead    = "dead"
+	actionRequeue = "requeue"
+)
+
+// promoteBatch holds the max. number of jobs moved per promote run.
+const promoteBatch = 100
+
+// ServeContext implements run.ServiceContext. It runs the workers and moves
+// due delayed jobs and expired running jobs onto their queues. On shutdown
+// the workers stop fetching jobs and running jobs get until the shutdown
+// timeout to finish, after which their context is canceled.
+func (q *Queue) ServeContext(ctx context.Context) error {
+	if q.Workers <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	jobCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(q.Workers + 1)
+	go func() {
+		defer wg.Done()
+		q.promote(ctx)
+	}()
+	for i := 0; i < q.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			q.work(ctx, jobCtx)
+		}()
+	}
+
+	<-ctx.Done()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(q.ShutdownTimeout):
+		log.Info("canceling running jobs", "timeout", q.ShutdownTimeout)
+		cancel()
+		<-done
+	}
+	return nil
+}
+
+// promote periodically moves due delayed jobs onto their queues and
+// reclaims expired running jobs.
+func (q *Queue) promote(ctx context.Context) {
+	ticker := time.NewTicker(q.PollInterval)
+	defer ticker.Stop()
+
+	keys := []string{q.key("delayed")}
+	for _, p := range priorities {
+		keys = append(keys, q.queueKey(p))
+	}
+	for {
+		for {
+			n, err := promoteScript.Run(ctx, q.rdb, keys,
+				time.Now().UnixMilli(), promoteBatch).Int()
+			if err != nil && ctx.Err() == nil {
+				log.Error("unable to promote jobs", err)
+			}
+			if n < promoteBatch {
+				break
+			}
+		}
+		for {
+			n, err := q.reclaim(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Error("unable to reclaim expired jobs", err)
+			}
+			if n < promoteBatch {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reclaim handles running jobs which exceeded their deadline, e.g. as their
+// worker crashed. The expired run counts as an attempt: the job is pushed
+// back onto its queue or, if its retries are exhausted, moved to the
+// dead-letter list. It returns the number of expired jobs found.
+func (q *Queue) reclaim(ctx context.Context) (int, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	tokens, err := q.rdb.ZRangeByScore(ctx, q.key("running"), &redis.ZRangeBy{
+		Min: "-inf", Max: now, Count: promoteBatch,
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+	for _, token := range tokens {
+		raw, err := q.rdb.HGet(ctx, q.key("running:jobs"), token).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return 0, err
+		}
+
+		var (
+			job    Job
+			action = actionDead
+			next   = raw
+		)
+		if errors.Is(err, redis.Nil) {
+			// no job stored for the token, just drop it
+			action = actionAck
+		} else if err = json.Unmarshal([]byte(raw), &job); err == nil {
+			job.Attempt++
+			job.LastError = "job exceeded its timeout"
+			if job.Attempt <= job.MaxRetries {
+				action = actionRequeue
+			}
+			b, err := json.Marshal(&job)
+			if err != nil {
+				return 0, err
+			}
+			next = string(b)
+		}
+
+		ok, err := q.settle(ctx, token, action, next, &job, time.Time{}, now)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case !ok || action == actionAck:
+		case action == actionDead:
+			log.Info("expired job moved to dead-letter list", "id", job.ID,
+				"type", job.Type, "attempts", job.Attempt)
+		default:
+			log.Info("expired job requeued", "id", job.ID, "type", job.Type,
+				"attempts", job.Attempt)
+		}
+	}
+	return len(tokens), nil
+}
+
+// settle runs settleScript for the fetch token.
+func (q *Queue) settle(
+	ctx context.Context, token, action, next string, job *Job, retryAt time.Time, maxDeadline string,
+) (bool, error) {
+	queue := q.queueKey(PriorityNormal)
+	if job != nil && validPriority(job.Priority) {
+		queue = q.queueKey(job.Priority)
+	}
+	keys := []string{q.key("running"), q.key("running:jobs"), q.key("delayed"), q.key("dead"), queue}
+	n, err := settleScript.Run(ctx, q.rdb, keys,
+		token, action, next, retryAt.UnixMilli(), q.DeadLetterMax, maxDeadline).Int()
+	return n == 1, err
+}
+
+// work fetches and processes jobs until ctx is canceled. Jobs run with
+// jobCtx as parent, so they can outlive ctx during shutdown.
+func (q *Queue) work(ctx, jobCtx context.Context) {
+	keys := []string{q.key("running"), q.key("running:jobs")}
+	for _, p := range priorities {
+		keys = append(keys, q.queueKey(p))
+	}
+	for ctx.Err() == nil {
+		// allow for some slack to acknowledge the job before it expires
+		deadline := time.Now().Add(q.JobTimeout + q.PollInterval).UnixMilli()
+		token := newID()
+		raw, err := fetchScript.Run(ctx, q.rdb, keys, deadline, token).Text()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+				log.Error("unable to fetch job", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(q.PollInterval):
+			}
+			continue
+		}
+		q.process(jobCtx, token, raw)
+	}
+}
+
+// process runs the handler of the job and acknowledges, retries or
+// dead-letters it.
+func (q *Queue) process(ctx context.Context, token, raw string) {
+	var job Job
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		log.Error("invalid job", err)
+		q.finish(token, raw, nil, err)
+		return
+	}
+	job.Attempt++
+
+	err := q.run(ctx, &job)
+	if err != nil {
+		log.Error("job failed", err, "id", job.ID, "type", job.Type, "attempt", job.Attempt)
+	}
+	q.finish(token, raw, &job, err)
+}
+
+func (q *Queue) run(ctx context.Context, job *Job) (err error) {
+	h := q.handler(job.Type)
+	if h == nil {
+		return Permanent(fmt.Errorf("%w: %s", ErrUnknownType, job.Type))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, q.JobTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("job handler panic", fmt.Errorf("%v", r),
+				"id", job.ID, "type", job.Type, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// finish removes the job from the running set and schedules a retry or moves
+// it to the dead-letter list on failure. If the job expired in the meantime,
+// it has been reclaimed and its result is dropped.
+func (q *Queue) finish(token, raw string, job *Job, jobErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		action   = actionAck
+		next     string
+		retryAt  time.Time
+		permErr  permanentError
+		runsLeft = job != nil && job.Attempt <= job.MaxRetries
+	)
+	if jobErr != nil && job != nil {
+		job.LastError = jobErr.Error()
+		b, err := json.Marshal(job)
+		if err != nil {
+			log.Error("unable to encode job", err, "id", job.ID)
+			return
+		}
+		next = string(b)
+		if runsLeft && !errors.As(jobErr, &permErr) {
+			action, retryAt = actionRetry, time.Now().Add(q.backoff(job.Attempt))
+		} else {
+			action = actionDead
+		}
+	} else if jobErr != nil {
+		// undecodable jobs are dead-lettered as is
+		action, next = actionDead, raw
+	}
+
+	ok, err := q.settle(ctx, token, action, next, job, retryAt, "")
+	if err != nil {
+		log.Error("unable to finish job", err)
+		return
+	}
+	if !ok && job != nil {
+		log.Info("job exceeded its timeout and was reclaimed, dropping its result",
+			"id", job.ID, "type", job.Type)
+		return
+	}
+	if action == actionDead && job != nil {
+		log.Info("job moved to dead-letter list", "id", job.ID, "type", job.Type,
+			"attempts", job.Attempt)
+	}
+}
+
+// backoff returns the exponential backoff with jitter for the attempt.
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.RetryBackoff
+	for i := 1; i < attempt && d < q.RetryMaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, q.RetryMaxBackoff)
+	// add up to 20% jitter to spread retries of jobs failing together
+	return d + time.Duration(rand.Int64N(int64(d)/5+1))
+}
@@ -0,0 +1,366 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jwt provides a JWT verifier with JWKS auto-refresh, usable as http
+// middleware and as gRPC server interceptors.
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("jwt", "JWT verifier")
+
+// package flags.
+const (
+	defaultRefreshInterval    = 15 * time.Minute
+	defaultMinRefreshInterval = time.Minute
+	defaultLeeway             = time.Minute
+	defaultFetchTimeout       = 10 * time.Second
+
+	Issuer             = "jwt-issuer"
+	Audiences          = "jwt-audience"
+	SkipAudienceCheck  = "jwt-skip-audience-check"
+	JWKSURL            = "jwt-jwks-url"
+	KeyFiles           = "jwt-key-file"
+	Algorithms         = "jwt-algorithms"
+	RefreshInterval    = "jwt-jwks-refresh-interval"
+	MinRefreshInterval = "jwt-jwks-min-refresh-interval"
+	Leeway             = "jwt-leeway"
+)
+
+var defaultAlgorithms = []string{
+	string(jose.RS256), string(jose.RS384), string(jose.RS512),
+	string(jose.PS256), string(jose.PS384), string(jose.PS512),
+	string(jose.ES256), string(jose.ES384), string(jose.ES512),
+	string(jose.EdDSA),
+}
+
+// ErrNoKeys is returned if no verification keys are configured.
+var ErrNoKeys = errors.New("no verification keys")
+
+// Verifier implements run.Config to allow configuration of a JWT verifier.
+// Keys are loaded from a JWKS URL, static key files or both. Keys from the
+// JWKS URL are refreshed in the background every RefreshInterval and on
+// demand when a token references an unknown key, at most once every
+// MinRefreshInterval.
+type Verifier struct {
+	Prefix string
+
+	// Issuer holds the expected token issuer, required for JWKS URLs.
+	Issuer string
+	// Audiences holds the accepted token audiences, at least one is
+	// required unless SkipAudienceCheck is set. Without audience check, any
+	// token of the issuer is accepted, including tokens meant for other
+	// services.
+	Audiences         []string
+	SkipAudienceCheck bool
+	JWKSURL           string
+	// KeyFiles holds paths to PEM encoded public keys or JWKS JSON files.
+	KeyFiles           []string
+	Algorithms         []string
+	RefreshInterval    time.Duration
+	MinRefreshInterval time.Duration
+	Leeway             time.Duration
+	// HTTPClient is used to fetch the JWKS. Defaults to a client with a 10s
+	// timeout.
+	HTTPClient *http.Client
+
+	algs        []jose.SignatureAlgorithm
+	static      []jose.JSONWebKey
+	keys        atomic.Pointer[jose.JSONWebKeySet]
+	refreshMtx  sync.Mutex
+	lastRefresh time.Time
+}
+
+func (v *Verifier) prefix(s string) string {
+	if v.Prefix != "" {
+		return v.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (v *Verifier) Name() string {
+	return v.prefix("jwt")
+}
+
+// FlagSet implements run.Config.
+func (v *Verifier) FlagSet() *run.FlagSet {
+	if len(v.Algorithms) == 0 {
+		v.Algorithms = defaultAlgorithms
+	}
+	if v.RefreshInterval == 0 {
+		v.RefreshInterval = defaultRefreshInterval
+	}
+	if v.MinRefreshInterval == 0 {
+		v.MinRefreshInterval = defaultMinRefreshInterval
+	}
+	if v.Leeway == 0 {
+		v.Leeway = defaultLeeway
+	}
+
+	flags := run.NewFlagSet("JWT options")
+
+	flags.StringVar(&v.Issuer, v.prefix(Issuer),
+		v.Issuer, "expected token issuer")
+
+	flags.StringArrayVar(&v.Audiences, v.prefix(Audiences),
+		v.Audiences, "accepted token audience (can be repeated)")
+
+	flags.BoolVar(&v.SkipAudienceCheck, v.prefix(SkipAudienceCheck),
+		v.SkipAudienceCheck, "accept tokens for any audience")
+
+	flags.StringVar(&v.JWKSURL, v.prefix(JWKSURL),
+		v.JWKSURL, "URL of the JWKS holding the verification keys")
+
+	flags.StringArrayVar(&v.KeyFiles, v.prefix(KeyFiles),
+		v.KeyFiles, "path to a PEM public key or JWKS file (can be repeated)")
+
+	flags.StringSliceVar(&v.Algorithms, v.prefix(Algorithms),
+		v.Algorithms, "accepted signature algorithms")
+
+	flags.DurationVar(&v.RefreshInterval, v.prefix(RefreshInterval),
+		v.RefreshInterval, "interval for refreshing the JWKS")
+
+	flags.DurationVar(&v.MinRefreshInterval, v.prefix(MinRefreshInterval),
+		v.MinRefreshInterval, "min. interval between JWKS refreshes for unknown keys")
+
+	flags.DurationVar(&v.Leeway, v.prefix(Leeway),
+		v.Leeway, "allowed clock skew when validating token times")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (v *Verifier) Validate() error {
+	var mErr error
+
+	if v.JWKSURL == "" && len(v.KeyFiles) == 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(v.prefix(JWKSURL),
+				flag.ValidationError("a JWKS URL or key file is required")))
+	}
+	if v.JWKSURL != "" {
+		if u, err := url.Parse(v.JWKSURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(v.prefix(JWKSURL), flag.ErrInvalidVal))
+		}
+		if v.Issuer == "" {
+			// a JWKS is typically shared by all tokens of an identity provider
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(v.prefix(Issuer), flag.ErrRequired))
+		}
+	}
+	switch {
+	case len(v.Audiences) == 0 && !v.SkipAudienceCheck:
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(v.prefix(Audiences),
+				flag.ValidationError("required unless the audience check is skipped")))
+	case len(v.Audiences) > 0 && v.SkipAudienceCheck:
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(v.prefix(SkipAudienceCheck),
+				flag.ValidationError("audiences are configured")))
+	}
+	for _, f := range v.KeyFiles {
+		if _, err := os.Stat(f); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(v.prefix(KeyFiles), flag.ErrInvalidPath))
+		}
+	}
+
+	v.algs = v.algs[:0]
+	for _, a := range v.Algorithms {
+		alg := jose.SignatureAlgorithm(strings.TrimSpace(a))
+		if alg == "none" || !isSupported(alg) {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(v.prefix(Algorithms), flag.ErrInvalidVal))
+			continue
+		}
+		v.algs = append(v.algs, alg)
+	}
+	if v.RefreshInterval <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(v.prefix(RefreshInterval), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+func isSupported(alg jose.SignatureAlgorithm) bool {
+	for _, a := range defaultAlgorithms {
+		if string(alg) == a {
+			return true
+		}
+	}
+	return alg == jose.HS256 || alg == jose.HS384 || alg == jose.HS512
+}
+
+// PreRun implements run.PreRunner. It loads the static keys and fetches the
+// JWKS.
+func (v *Verifier) PreRun() error {
+	if v.HTTPClient == nil {
+		v.HTTPClient = &http.Client{Timeout: defaultFetchTimeout}
+	}
+	for _, f := range v.KeyFiles {
+		keys, err := loadKeyFile(f)
+		if err != nil {
+			return err
+		}
+		v.static = append(v.static, keys...)
+	}
+	v.keys.Store(&jose.JSONWebKeySet{Keys: v.static})
+
+	if v.JWKSURL == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultFetchTimeout)
+	defer cancel()
+	return v.refresh(ctx)
+}
+
+// ServeContext implements run.ServiceContext. It periodically refreshes the
+// JWKS.
+func (v *Verifier) ServeContext(ctx context.Context) error {
+	if v.JWKSURL == "" {
+		<-ctx.Done()
+		return nil
+	}
+	ticker := time.NewTicker(v.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := v.refresh(ctx); err != nil {
+				log.Error("unable to refresh JWKS", err)
+			}
+		}
+	}
+}
+
+// refresh fetches the JWKS and replaces the current key set.
+func (v *Verifier) refresh(ctx context.Context) error {
+	v.refreshMtx.Lock()
+	defer v.refreshMtx.Unlock()
+	v.lastRefresh = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.JWKSURL, http.NoBody)
+	if err != nil {
+		return err
+	}
+	res, err := v.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to fetch JWKS: %w", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to fetch JWKS: unexpected status %s", res.Status)
+	}
+
+	var set jose.JSONWebKeySet
+	if err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&set); err != nil {
+		return fmt.Errorf("unable to decode JWKS: %w", err)
+	}
+	set.Keys = append(set.Keys, v.static...)
+	v.keys.Store(&set)
+	return nil
+}
+
+// refreshUnknown refreshes the JWKS for an unknown key ID unless it was
+// refreshed within MinRefreshInterval. It returns true if the key set was
+// refreshed.
+func (v *Verifier) refreshUnknown(ctx context.Context) bool {
+	if v.JWKSURL == "" {
+		return false
+	}
+	v.refreshMtx.Lock()
+	recent := time.Since(v.lastRefresh) < v.MinRefreshInterval
+	v.refreshMtx.Unlock()
+	if recent {
+		return false
+	}
+	if err := v.refresh(ctx); err != nil {
+		log.Error("unable to refresh JWKS", err)
+		return false
+	}
+	return true
+}
+
+// loadKeyFile loads a JWKS JSON file or PEM encoded public keys.
+func loadKeyFile(path string) ([]jose.JSONWebKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key file: %w", err)
+	}
+
+	var set jose.JSONWebKeySet
+	if json.Unmarshal(b, &set) == nil && len(set.Keys) > 0 {
+		return set.Keys, nil
+	}
+
+	var keys []jose.JSONWebKey
+	for block, rest := pem.Decode(b); block != nil; block, rest = pem.Decode(rest) {
+		var key crypto.PublicKey
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse key file %s: %w", path, err)
+		}
+		keys = append(keys, jose.JSONWebKey{Key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoKeys, path)
+	}
+	return keys, nil
+}
+
+var (
+	_ run.Config         = (*Verifier)(nil)
+	_ run.PreRunner      = (*Verifier)(nil)
+	_ run.ServiceContext = (*Verifier)(nil)
+)
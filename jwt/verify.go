@@ -0,0 +1,121 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
+)
+
+// verification errors.
+var (
+	// ErrInvalidToken is returned for malformed tokens or tokens with an
+	// invalid signature.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrUnknownKey is returned if no key matches the token key ID.
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrMissingExpiry is returned for tokens without expiry.
+	ErrMissingExpiry = errors.New("token has no expiry")
+)
+
+// Claims holds the verified claims of a token.
+type Claims struct {
+	josejwt.Claims
+	// Extra holds all claims, including the registered claims.
+	Extra map[string]any
+}
+
+// Verify verifies the token signature and validates the issuer, audience
+// and time claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	tok, err := josejwt.ParseSigned(token, v.algs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	kid := tok.Headers[0].KeyID
+
+	keys := v.candidates(kid)
+	if len(keys) == 0 && v.refreshUnknown(ctx) {
+		keys = v.candidates(kid)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+
+	var claims Claims
+	for _, key := range keys {
+		if err = tok.Claims(key.Key, &claims.Claims, &claims.Extra); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if claims.Expiry == nil {
+		return nil, ErrMissingExpiry
+	}
+	if len(v.Audiences) == 0 && !v.SkipAudienceCheck {
+		// guard against verifiers used without being validated
+		return nil, fmt.Errorf("%w: no audience configured", ErrInvalidToken)
+	}
+	if err = claims.ValidateWithLeeway(josejwt.Expected{
+		Issuer:      v.Issuer,
+		AnyAudience: v.Audiences,
+		Time:        time.Now(),
+	}, v.Leeway); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// candidates returns the keys matching the key ID. Tokens without key ID
+// are tried against all keys.
+func (v *Verifier) candidates(kid string) []jose.JSONWebKey {
+	set := v.keys.Load()
+	if set == nil {
+		return nil
+	}
+	if kid == "" {
+		return set.Keys
+	}
+	keys := set.Key(kid)
+	// static PEM keys have no key ID
+	for _, k := range set.Keys {
+		if k.KeyID == "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+type claimsKey struct{}
+
+// ContextWithClaims returns a copy of the context holding the claims.
+func ContextWithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+// ClaimsFromContext returns the verified claims attached by Middleware or
+// the gRPC interceptors.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*Claims)
+	return c, ok
+}
@@ -0,0 +1,255 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafka provides run handlers for producing to and consuming from
+// Kafka. The connection settings held by Config are shared by the Producer
+// and Consumer handlers.
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("kafka", "Kafka producer and consumer")
+
+// package flags.
+const (
+	defaultBroker = "localhost:9092"
+
+	Brokers       = "kafka-brokers"
+	ClientID      = "kafka-client-id"
+	EnableTLS     = "kafka-tls"
+	TLSCAFile     = "kafka-tls-ca-file"
+	TLSCertFile   = "kafka-tls-cert-file"
+	TLSKeyFile    = "kafka-tls-key-file"
+	SASLMechanism = "kafka-sasl-mechanism"
+	SASLUserName  = "kafka-sasl-username"
+	SASLPassword  = "kafka-sasl-password"
+)
+
+// supported SASL mechanisms.
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// Config implements run.Config to allow configuration of the Kafka
+// connection settings shared by Producer and Consumer.
+type Config struct {
+	Prefix string
+
+	Brokers     []string
+	ClientID    string
+	EnableTLS   bool
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+	// SASLMechanism holds the SASL mechanism to authenticate with: PLAIN,
+	// SCRAM-SHA-256 or SCRAM-SHA-512. SASL is disabled if empty.
+	SASLMechanism string
+	SASLUserName  string
+	SASLPassword  string
+}
+
+// prefix returns the name prefixed with the Config prefix. It is safe to call
+// on a nil Config, so Producer and Consumer can report a missing Config in
+// Validate.
+func (c *Config) prefix(s string) string {
+	if c != nil && c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("kafka")
+}
+
+// Initialize implements run.Initializer.
+func (c *Config) Initialize() {
+	if c.Brokers == nil {
+		c.Brokers = []string{defaultBroker}
+	}
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Kafka options")
+
+	if brokers := os.Getenv("KAFKA_BROKERS"); brokers != "" {
+		c.Brokers = strings.Split(brokers, ",")
+	}
+	if user := os.Getenv("KAFKA_SASL_USERNAME"); user != "" {
+		c.SASLUserName = user
+	}
+	if pass := os.Getenv("KAFKA_SASL_PASSWORD"); pass != "" {
+		c.SASLPassword = pass
+	}
+
+	flags.StringArrayVar(&c.Brokers, c.prefix(Brokers),
+		c.Brokers, "Kafka seed brokers")
+
+	flags.StringVar(&c.ClientID, c.prefix(ClientID),
+		c.ClientID, "Kafka client ID")
+
+	flags.BoolVar(&c.EnableTLS, c.prefix(EnableTLS),
+		c.EnableTLS, "connect to the Kafka brokers using TLS")
+
+	flags.StringVar(&c.TLSCAFile, c.prefix(TLSCAFile),
+		c.TLSCAFile, "path to CA file for verifying the Kafka brokers")
+
+	flags.StringVar(&c.TLSCertFile, c.prefix(TLSCertFile),
+		c.TLSCertFile, "path to client TLS certificate file")
+
+	flags.StringVar(&c.TLSKeyFile, c.prefix(TLSKeyFile),
+		c.TLSKeyFile, "path to client TLS key file")
+
+	flags.StringVar(&c.SASLMechanism, c.prefix(SASLMechanism),
+		c.SASLMechanism, "SASL mechanism (PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512)")
+
+	flags.StringVar(&c.SASLUserName, c.prefix(SASLUserName),
+		c.SASLUserName, "SASL username")
+
+	flags.SensitiveStringVar(&c.SASLPassword, c.prefix(SASLPassword),
+		c.SASLPassword, "SASL password")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if len(c.Brokers) == 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Brokers), flag.ErrRequired))
+	}
+	for _, addr := range c.Brokers {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(Brokers),
+					fmt.Errorf("invalid broker: %w", err)))
+		}
+	}
+
+	for name, path := range map[string]string{
+		TLSCAFile:   c.TLSCAFile,
+		TLSCertFile: c.TLSCertFile,
+		TLSKeyFile:  c.TLSKeyFile,
+	} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(name), flag.ErrInvalidPath))
+		}
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(TLSKeyFile),
+				flag.ValidationError("TLS certificate and key need to be provided together")))
+	}
+
+	switch strings.ToUpper(c.SASLMechanism) {
+	case "":
+	case SASLPlain, SASLScramSHA256, SASLScramSHA512:
+		if c.SASLUserName == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(SASLUserName), flag.ErrRequired))
+		}
+	default:
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(SASLMechanism), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// opts returns the kgo client options for the connection settings.
+func (c *Config) opts() ([]kgo.Opt, error) {
+	opts := []kgo.Opt{kgo.SeedBrokers(c.Brokers...)}
+	if c.ClientID != "" {
+		opts = append(opts, kgo.ClientID(c.ClientID))
+	}
+	if c.EnableTLS || c.TLSCAFile != "" || c.TLSCertFile != "" {
+		cfg, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.DialTLSConfig(cfg))
+	}
+	if m := c.saslMechanism(); m != nil {
+		opts = append(opts, kgo.SASL(m))
+	}
+	return opts, nil
+}
+
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.TLSCAFile != "" {
+		pem, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in CA file")
+		}
+	}
+	if c.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func (c *Config) saslMechanism() sasl.Mechanism {
+	switch strings.ToUpper(c.SASLMechanism) {
+	case SASLPlain:
+		return plain.Auth{User: c.SASLUserName, Pass: c.SASLPassword}.AsMechanism()
+	case SASLScramSHA256:
+		return scram.Auth{User: c.SASLUserName, Pass: c.SASLPassword}.AsSha256Mechanism()
+	case SASLScramSHA512:
+		return scram.Auth{User: c.SASLUserName, Pass: c.SASLPassword}.AsSha512Mechanism()
+	default:
+		return nil
+	}
+}
+
+var (
+	_ run.Initializer = (*Config)(nil)
+	_ run.Config      = (*Config)(nil)
+)
@@ -0,0 +1,231 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// consumer flags.
+const (
+	defaultStartOffset   = "latest"
+	defaultCommitTimeout = 10 * time.Second
+
+	ConsumerGroup         = "kafka-consumer-group"
+	ConsumerStartOffset   = "kafka-consumer-start-offset"
+	ConsumerCommitTimeout = "kafka-consumer-commit-timeout"
+)
+
+// Handler handles a consumed record. Records are marked for commit after
+// being handled, also if the handler returns an error.
+type Handler func(ctx context.Context, record *kgo.Record) error
+
+// Consumer implements a run.Config and run.ServiceContext consuming the
+// topics of the registered handlers as part of a consumer group. Offsets of
+// handled records are committed periodically, on partition revocation during
+// rebalances and on shutdown.
+type Consumer struct {
+	// Config holds the shared connection settings.
+	Config *Config
+
+	Group string
+	// StartOffset holds the offset to start consuming partitions without
+	// committed offset from: earliest or latest.
+	StartOffset   string
+	CommitTimeout time.Duration
+	// OnError is called for records a handler returned an error for. If not
+	// set, the error is logged.
+	OnError func(record *kgo.Record, err error)
+
+	// Options holds additional kgo options applied after the flag based
+	// options.
+	Options []kgo.Opt
+
+	mtx      sync.Mutex
+	handlers map[string]Handler
+	client   *kgo.Client
+}
+
+// Name implements run.Unit.
+func (c *Consumer) Name() string {
+	return c.Config.prefix("kafka-consumer")
+}
+
+// Handle registers the handler for the records of the topic. Handlers need
+// to be registered before the Consumer is started.
+func (c *Consumer) Handle(topic string, h Handler) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.handlers == nil {
+		c.handlers = make(map[string]Handler)
+	}
+	c.handlers[topic] = h
+}
+
+// FlagSet implements run.Config.
+func (c *Consumer) FlagSet() *run.FlagSet {
+	if c.StartOffset == "" {
+		c.StartOffset = defaultStartOffset
+	}
+	if c.CommitTimeout == 0 {
+		c.CommitTimeout = defaultCommitTimeout
+	}
+
+	flags := run.NewFlagSet("Kafka consumer options")
+
+	flags.StringVar(&c.Group, c.Config.prefix(ConsumerGroup),
+		c.Group, "consumer group")
+
+	flags.StringVar(&c.StartOffset, c.Config.prefix(ConsumerStartOffset),
+		c.StartOffset, "offset to start partitions without committed offset from (earliest or latest)")
+
+	flags.DurationVar(&c.CommitTimeout, c.Config.prefix(ConsumerCommitTimeout),
+		c.CommitTimeout, "max. time to commit offsets on rebalance and shutdown")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Consumer) Validate() error {
+	var mErr error
+
+	if c.Config == nil {
+		return errors.New("kafka consumer requires a connection config")
+	}
+	if c.Group == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.Config.prefix(ConsumerGroup), flag.ErrRequired))
+	}
+	switch strings.ToLower(c.StartOffset) {
+	case "earliest", "latest":
+	default:
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.Config.prefix(ConsumerStartOffset), flag.ErrInvalidVal))
+	}
+	if c.CommitTimeout <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.Config.prefix(ConsumerCommitTimeout), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (c *Consumer) PreRun() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if len(c.handlers) == 0 {
+		return errors.New("kafka consumer has no handlers registered")
+	}
+	topics := make([]string, 0, len(c.handlers))
+	for topic := range c.handlers {
+		topics = append(topics, topic)
+	}
+
+	opts, err := c.Config.opts()
+	if err != nil {
+		return err
+	}
+	offset := kgo.NewOffset().AtEnd()
+	if strings.EqualFold(c.StartOffset, "earliest") {
+		offset = kgo.NewOffset().AtStart()
+	}
+	opts = append(opts,
+		kgo.ConsumerGroup(c.Group),
+		kgo.ConsumeTopics(topics...),
+		kgo.ConsumeResetOffset(offset),
+		kgo.AutoCommitMarks(),
+		kgo.BlockRebalanceOnPoll(),
+		kgo.OnPartitionsRevoked(c.commit),
+	)
+	if c.client, err = kgo.NewClient(append(opts, c.Options...)...); err != nil {
+		return fmt.Errorf("kafka consumer creation failed: %w", err)
+	}
+
+	return nil
+}
+
+// commit commits the offsets of the handled records, e.g. before partitions
+// are revoked during a rebalance.
+func (c *Consumer) commit(ctx context.Context, cl *kgo.Client, _ map[string][]int32) {
+	ctx, cancel := context.WithTimeout(ctx, c.CommitTimeout)
+	defer cancel()
+	if err := cl.CommitMarkedOffsets(ctx); err != nil {
+		log.Error("unable to commit offsets", err, "group", c.Group)
+	}
+}
+
+// ServeContext implements run.ServiceContext. It dispatches the consumed
+// records to the registered handlers until the context is canceled, after
+// which the offsets of handled records are committed and the group is left.
+func (c *Consumer) ServeContext(ctx context.Context) error {
+	defer func() {
+		c.commit(context.Background(), c.client, nil)
+		c.client.Close()
+	}()
+
+	for {
+		fetches := c.client.PollFetches(ctx)
+		if fetches.IsClientClosed() || ctx.Err() != nil {
+			c.client.AllowRebalance()
+			return nil
+		}
+		fetches.EachError(func(topic string, partition int32, err error) {
+			log.Error("fetch failed", err, "topic", topic, "partition", partition)
+		})
+		fetches.EachRecord(func(r *kgo.Record) {
+			c.handle(ctx, r)
+		})
+		c.client.AllowRebalance()
+	}
+}
+
+// handle dispatches the record to the handler of its topic and marks it for
+// commit.
+func (c *Consumer) handle(ctx context.Context, r *kgo.Record) {
+	defer c.client.MarkCommitRecords(r)
+	h := c.handlers[r.Topic]
+	if h == nil {
+		return
+	}
+	if err := h(ctx, r); err != nil {
+		if c.OnError != nil {
+			c.OnError(r, err)
+			return
+		}
+		log.Error("handling record failed", err,
+			"topic", r.Topic, "partition", r.Partition, "offset", r.Offset)
+	}
+}
+
+// Client returns the Kafka client of the consumer.
+func (c *Consumer) Client() *kgo.Client { return c.client }
+
+var (
+	_ run.Config         = (*Consumer)(nil)
+	_ run.PreRunner      = (*Consumer)(nil)
+	_ run.ServiceContext = (*Consumer)(nil)
+)
@@ -0,0 +1,197 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// producer flags.
+const (
+	defaultAcks         = "all"
+	defaultCompression  = "none"
+	defaultFlushTimeout = 10 * time.Second
+
+	ProducerAcks         = "kafka-producer-acks"
+	ProducerCompression  = "kafka-producer-compression"
+	ProducerLinger       = "kafka-producer-linger"
+	ProducerFlushTimeout = "kafka-producer-flush-timeout"
+)
+
+// Producer implements a run.Config and run.ServiceContext providing a Kafka
+// client for producing records. Buffered records are flushed on shutdown.
+type Producer struct {
+	// Config holds the shared connection settings.
+	Config *Config
+
+	// Acks holds the required acknowledgements: all, leader or none.
+	Acks string
+	// Compression holds the batch compression: none, gzip, snappy, lz4 or
+	// zstd.
+	Compression  string
+	Linger       time.Duration
+	FlushTimeout time.Duration
+
+	// Options holds additional kgo options applied after the flag based
+	// options.
+	Options []kgo.Opt
+
+	client *kgo.Client
+}
+
+// Name implements run.Unit.
+func (p *Producer) Name() string {
+	return p.Config.prefix("kafka-producer")
+}
+
+// FlagSet implements run.Config.
+func (p *Producer) FlagSet() *run.FlagSet {
+	if p.Acks == "" {
+		p.Acks = defaultAcks
+	}
+	if p.Compression == "" {
+		p.Compression = defaultCompression
+	}
+	if p.FlushTimeout == 0 {
+		p.FlushTimeout = defaultFlushTimeout
+	}
+
+	flags := run.NewFlagSet("Kafka producer options")
+
+	flags.StringVar(&p.Acks, p.Config.prefix(ProducerAcks),
+		p.Acks, "required acknowledgements (all, leader or none)")
+
+	flags.StringVar(&p.Compression, p.Config.prefix(ProducerCompression),
+		p.Compression, "batch compression (none, gzip, snappy, lz4 or zstd)")
+
+	flags.DurationVar(&p.Linger, p.Config.prefix(ProducerLinger),
+		p.Linger, "time to wait for batches to fill before sending")
+
+	flags.DurationVar(&p.FlushTimeout, p.Config.prefix(ProducerFlushTimeout),
+		p.FlushTimeout, "max. time to flush buffered records on shutdown")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (p *Producer) Validate() error {
+	var mErr error
+
+	if p.Config == nil {
+		return errors.New("kafka producer requires a connection config")
+	}
+	if _, err := p.acks(); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(p.Config.prefix(ProducerAcks), err))
+	}
+	if _, err := p.compression(); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(p.Config.prefix(ProducerCompression), err))
+	}
+	if p.Linger < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(p.Config.prefix(ProducerLinger), flag.ErrInvalidVal))
+	}
+	if p.FlushTimeout <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(p.Config.prefix(ProducerFlushTimeout), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+func (p *Producer) acks() ([]kgo.Opt, error) {
+	switch strings.ToLower(p.Acks) {
+	case "all":
+		return []kgo.Opt{kgo.RequiredAcks(kgo.AllISRAcks())}, nil
+	case "leader":
+		return []kgo.Opt{kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite()}, nil
+	case "none":
+		return []kgo.Opt{kgo.RequiredAcks(kgo.NoAck()), kgo.DisableIdempotentWrite()}, nil
+	default:
+		return nil, flag.ErrInvalidVal
+	}
+}
+
+func (p *Producer) compression() (kgo.CompressionCodec, error) {
+	switch strings.ToLower(p.Compression) {
+	case "none":
+		return kgo.NoCompression(), nil
+	case "gzip":
+		return kgo.GzipCompression(), nil
+	case "snappy":
+		return kgo.SnappyCompression(), nil
+	case "lz4":
+		return kgo.Lz4Compression(), nil
+	case "zstd":
+		return kgo.ZstdCompression(), nil
+	default:
+		return kgo.CompressionCodec{}, flag.ErrInvalidVal
+	}
+}
+
+// PreRun implements run.PreRunner.
+func (p *Producer) PreRun() error {
+	opts, err := p.Config.opts()
+	if err != nil {
+		return err
+	}
+	acks, _ := p.acks()
+	codec, _ := p.compression()
+	opts = append(opts, acks...)
+	opts = append(opts, kgo.ProducerBatchCompression(codec))
+	if p.Linger > 0 {
+		opts = append(opts, kgo.ProducerLinger(p.Linger))
+	}
+	if p.client, err = kgo.NewClient(append(opts, p.Options...)...); err != nil {
+		return fmt.Errorf("kafka producer creation failed: %w", err)
+	}
+
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. It flushes the buffered
+// records once the context is canceled.
+func (p *Producer) ServeContext(ctx context.Context) error {
+	<-ctx.Done()
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), p.FlushTimeout)
+	defer cancel()
+	if err := p.client.Flush(flushCtx); err != nil {
+		log.Error("unable to flush buffered records", err)
+	}
+	p.client.Close()
+
+	return nil
+}
+
+// Client returns the Kafka client for producing records.
+func (p *Producer) Client() *kgo.Client { return p.client }
+
+var (
+	_ run.Config         = (*Producer)(nil)
+	_ run.PreRunner      = (*Producer)(nil)
+	_ run.ServiceContext = (*Producer)(nil)
+)
@@ -0,0 +1,86 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderelection
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// kubernetesElector elects a leader using a Kubernetes Lease object.
+type kubernetesElector struct {
+	le *leaderelection.LeaderElector
+}
+
+func newKubernetesElector(s *Service) (*kubernetesElector, error) {
+	var (
+		cfg *rest.Config
+		err error
+	)
+	if s.Kubeconfig != "" {
+		cfg, err = clientcmd.BuildConfigFromFlags("", s.Kubeconfig)
+	} else {
+		cfg, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, err
+	}
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      s.LockName,
+				Namespace: s.Namespace,
+			},
+			Client: cs.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: s.Identity,
+			},
+		},
+		LeaseDuration:   s.LeaseDuration,
+		RenewDeadline:   s.RenewDeadline,
+		RetryPeriod:     s.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            s.LockName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: s.startLeading,
+			OnStoppedLeading: s.stopLeading,
+			OnNewLeader:      s.newLeader,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &kubernetesElector{le: le}, nil
+}
+
+func (k *kubernetesElector) run(ctx context.Context) error {
+	// Run returns when leadership is lost, so keep participating until
+	// the context is canceled
+	for ctx.Err() == nil {
+		k.le.Run(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// redisElector elects a leader using a Redis key holding the identity of the
+// leader with the lease duration as expiry.
+type redisElector struct {
+	s   *Service
+	rdb redis.UniversalClient
+	key string
+}
+
+func newRedisElector(s *Service) (*redisElector, error) {
+	rdb := s.Redis()
+	if rdb == nil {
+		return nil, errors.New("redis client not available")
+	}
+	return &redisElector{s: s, rdb: rdb, key: s.KeyPrefix + s.LockName}, nil
+}
+
+func (r *redisElector) run(ctx context.Context) error {
+	ticker := time.NewTicker(r.s.RetryPeriod)
+	defer ticker.Stop()
+
+	var lastRenew time.Time
+	for {
+		if r.s.IsLeader() {
+			if r.renew(ctx) {
+				lastRenew = time.Now()
+			} else if ctx.Err() == nil && time.Since(lastRenew) > r.s.RenewDeadline {
+				r.s.stopLeading()
+			}
+		} else if r.acquire(ctx) {
+			lastRenew = time.Now()
+			r.s.newLeader(r.s.Identity)
+			r.s.startLeading(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			r.release()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// acquire tries to acquire the lock and records the observed leader if it
+// is held by another replica.
+func (r *redisElector) acquire(ctx context.Context) bool {
+	ok, err := r.rdb.SetNX(ctx, r.key, r.s.Identity, r.s.LeaseDuration).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("unable to acquire lock", err, "key", r.key)
+		}
+		return false
+	}
+	if ok {
+		return true
+	}
+	leader, err := r.rdb.Get(ctx, r.key).Result()
+	switch {
+	case err == nil:
+		if leader == r.s.Identity {
+			// lock still held from before a failed renewal
+			return true
+		}
+		r.s.newLeader(leader)
+	case errors.Is(err, redis.Nil):
+		r.s.newLeader("")
+	}
+	return false
+}
+
+// renew extends the lock expiry. A lock taken over by another replica ends
+// leadership immediately, connection errors are retried until the renew
+// deadline passes.
+func (r *redisElector) renew(ctx context.Context) bool {
+	n, err := renewScript.Run(ctx, r.rdb, []string{r.key},
+		r.s.Identity, r.s.LeaseDuration.Milliseconds()).Int()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("unable to renew lock", err, "key", r.key)
+		}
+		return false
+	}
+	if n == 0 {
+		log.Info("lock lost", "key", r.key)
+		r.s.stopLeading()
+		return false
+	}
+	return true
+}
+
+// release deletes the lock if held by this replica, allowing other replicas
+// to take over without waiting for the lease to expire.
+func (r *redisElector) release() {
+	if !r.s.IsLeader() {
+		return
+	}
+	r.s.stopLeading()
+	ctx, cancel := context.WithTimeout(context.Background(), r.s.RetryPeriod)
+	defer cancel()
+	if err := releaseScript.Run(ctx, r.rdb, []string{r.key}, r.s.Identity).Err(); err != nil {
+		log.Error("unable to release lock", err, "key", r.key)
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package leaderelection provides a run handler electing a leader between
+// replicas using Kubernetes Lease objects or a Redis lock, allowing cron jobs
+// and consumers to run active/passive.
+package leaderelection
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("leaderelection", "leader election")
+
+// Supported backends.
+const (
+	BackendKubernetes = "kubernetes"
+	BackendRedis      = "redis"
+)
+
+// package flags.
+const (
+	defaultBackend       = BackendKubernetes
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+	defaultKeyPrefix     = "leader:"
+
+	Backend       = "leader-election-backend"
+	LockName      = "leader-election-lock-name"
+	Identity      = "leader-election-identity"
+	LeaseDuration = "leader-election-lease-duration"
+	RenewDeadline = "leader-election-renew-deadline"
+	RetryPeriod   = "leader-election-retry-period"
+	Namespace     = "leader-election-namespace"
+	Kubeconfig    = "leader-election-kubeconfig"
+	KeyPrefix     = "leader-election-key-prefix"
+)
+
+// Service implements run.Config and run.ServiceContext for leader election.
+//
+// OnStartedLeading is called in its own goroutine when leadership is
+// acquired. Its context is canceled when leadership is lost, after which
+// OnStoppedLeading is called. Use IsLeader for polling style checks, e.g.
+// from cron jobs.
+type Service struct {
+	Prefix string
+
+	Backend string
+	// LockName holds the name of the Lease object or Redis key. It defaults
+	// to the run.Group name.
+	LockName string
+	// Identity uniquely identifies this replica. It defaults to the hostname
+	// with a random suffix.
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+
+	// Namespace holds the namespace of the Lease object. It defaults to the
+	// POD_NAMESPACE environment variable or the service account namespace.
+	Namespace string
+	// Kubeconfig holds the path of a kubeconfig file. If empty, the in-cluster
+	// configuration is used.
+	Kubeconfig string
+
+	// Redis returns the Redis client used by the redis backend, e.g. the Pool
+	// method of the redis handler.
+	Redis     func() redis.UniversalClient
+	KeyPrefix string
+
+	OnStartedLeading func(ctx context.Context)
+	OnStoppedLeading func()
+	// OnNewLeader is called when the observed leader changes, including when
+	// this replica becomes leader.
+	OnNewLeader func(identity string)
+
+	elector  elector
+	isLeader atomic.Bool
+	mtx      sync.RWMutex
+	leader   string
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// elector runs the leader election of a backend until the context is
+// canceled.
+type elector interface {
+	run(ctx context.Context) error
+}
+
+func (s *Service) prefix(v string) string {
+	if s.Prefix != "" {
+		return s.Prefix + "-" + v
+	}
+	return v
+}
+
+// Name implements run.Unit.
+func (s *Service) Name() string {
+	return s.prefix("leader-election")
+}
+
+// Initialize implements run.Initializer.
+func (s *Service) Initialize() {
+	if s.Backend == "" {
+		s.Backend = defaultBackend
+	}
+	if s.LeaseDuration == 0 {
+		s.LeaseDuration = defaultLeaseDuration
+	}
+	if s.RenewDeadline == 0 {
+		s.RenewDeadline = defaultRenewDeadline
+	}
+	if s.RetryPeriod == 0 {
+		s.RetryPeriod = defaultRetryPeriod
+	}
+	if s.KeyPrefix == "" {
+		s.KeyPrefix = defaultKeyPrefix
+	}
+}
+
+// GroupName implements run.Namer.
+func (s *Service) GroupName(name string) {
+	if s.LockName == "" {
+		s.LockName = name
+	}
+}
+
+// FlagSet implements run.Config.
+func (s *Service) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Leader election options")
+
+	if s.Identity == "" {
+		s.Identity = defaultIdentity()
+	}
+	if s.Namespace == "" {
+		s.Namespace = defaultNamespace()
+	}
+
+	flags.StringVar(&s.Backend, s.prefix(Backend),
+		s.Backend, "leader election backend: kubernetes or redis")
+
+	flags.StringVar(&s.LockName, s.prefix(LockName),
+		s.LockName, "name of the Lease object or Redis key")
+
+	flags.StringVar(&s.Identity, s.prefix(Identity),
+		s.Identity, "unique identity of this replica")
+
+	flags.DurationVar(&s.LeaseDuration, s.prefix(LeaseDuration),
+		s.LeaseDuration, "duration non-leaders wait before trying to acquire leadership")
+
+	flags.DurationVar(&s.RenewDeadline, s.prefix(RenewDeadline),
+		s.RenewDeadline, "duration the leader retries renewing leadership before giving up")
+
+	flags.DurationVar(&s.RetryPeriod, s.prefix(RetryPeriod),
+		s.RetryPeriod, "interval between acquire and renew attempts")
+
+	flags.StringVar(&s.Namespace, s.prefix(Namespace),
+		s.Namespace, "namespace of the Lease object")
+
+	flags.StringVar(&s.Kubeconfig, s.prefix(Kubeconfig),
+		s.Kubeconfig, "path of the kubeconfig file (default in-cluster config)")
+
+	flags.StringVar(&s.KeyPrefix, s.prefix(KeyPrefix),
+		s.KeyPrefix, "prefix of the Redis lock key")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	switch s.Backend {
+	case BackendKubernetes:
+		if s.Namespace == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(Namespace), flag.ErrRequired))
+		}
+		if s.Kubeconfig != "" {
+			if _, err := os.Stat(s.Kubeconfig); err != nil {
+				mErr = multierror.Append(mErr,
+					flag.NewValidationError(s.prefix(Kubeconfig), flag.ErrInvalidPath))
+			}
+		}
+	case BackendRedis:
+		if s.Redis == nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(Backend), flag.ValidationError("redis client not configured")))
+		}
+	default:
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(Backend), flag.ErrInvalidVal))
+	}
+	if s.LockName == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(LockName), flag.ErrRequired))
+	}
+	if s.Identity == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(Identity), flag.ErrRequired))
+	}
+	if s.RetryPeriod <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(RetryPeriod), flag.ErrInvalidVal))
+	}
+	if s.RenewDeadline <= s.RetryPeriod {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(RenewDeadline),
+				flag.ValidationError("renew deadline must be greater than retry period")))
+	}
+	if s.LeaseDuration <= s.RenewDeadline {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(LeaseDuration),
+				flag.ValidationError("lease duration must be greater than renew deadline")))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() (err error) {
+	switch s.Backend {
+	case BackendKubernetes:
+		s.elector, err = newKubernetesElector(s)
+	case BackendRedis:
+		s.elector, err = newRedisElector(s)
+	}
+	return err
+}
+
+// ServeContext implements run.ServiceContext.
+func (s *Service) ServeContext(ctx context.Context) error {
+	defer s.stopLeading()
+	return s.elector.run(ctx)
+}
+
+// IsLeader returns true if this replica currently holds leadership.
+func (s *Service) IsLeader() bool {
+	return s.isLeader.Load()
+}
+
+// Leader returns the identity of the currently observed leader.
+func (s *Service) Leader() string {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.leader
+}
+
+// startLeading marks this replica as leader and runs OnStartedLeading.
+func (s *Service) startLeading(ctx context.Context) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.isLeader.Load() {
+		return
+	}
+	log.Info("started leading", "lock", s.LockName, "identity", s.Identity)
+	s.isLeader.Store(true)
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		if s.OnStartedLeading != nil {
+			s.OnStartedLeading(ctx)
+		}
+	}(s.done)
+}
+
+// stopLeading cancels the OnStartedLeading context, waits for it to return
+// and runs OnStoppedLeading.
+func (s *Service) stopLeading() {
+	s.mtx.Lock()
+	if !s.isLeader.Load() {
+		s.mtx.Unlock()
+		return
+	}
+	s.isLeader.Store(false)
+	if s.leader == s.Identity {
+		s.leader = ""
+	}
+	cancel, done := s.cancel, s.done
+	s.mtx.Unlock()
+
+	log.Info("stopped leading", "lock", s.LockName, "identity", s.Identity)
+	cancel()
+	<-done
+	if s.OnStoppedLeading != nil {
+		s.OnStoppedLeading()
+	}
+}
+
+// newLeader records the observed leader and runs OnNewLeader on change.
+func (s *Service) newLeader(identity string) {
+	s.mtx.Lock()
+	if s.leader == identity {
+		s.mtx.Unlock()
+		return
+	}
+	s.leader = identity
+	s.mtx.Unlock()
+
+	log.Debug("new leader", "lock", s.LockName, "leader", identity)
+	if s.OnNewLeader != nil && identity != "" {
+		s.OnNewLeader(identity)
+	}
+}
+
+func defaultIdentity() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	if host == "" {
+		return hex.EncodeToString(b)
+	}
+	return host + "_" + hex.EncodeToString(b)
+}
+
+func defaultNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	b, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err == nil {
+		if ns := strings.TrimSpace(string(b)); ns != "" {
+			return ns
+		}
+	}
+	return "default"
+}
+
+var (
+	_ run.Initializer    = (*Service)(nil)
+	_ run.Namer          = (*Service)(nil)
+	_ run.Config         = (*Service)(nil)
+	_ run.PreRunner      = (*Service)(nil)
+	_ run.ServiceContext = (*Service)(nil)
+)
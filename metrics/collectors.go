@@ -0,0 +1,124 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// PoolStats holds connection pool statistics, e.g. of pgx, go-redis or
+// other pools.
+type PoolStats struct {
+	// Total holds the number of open connections.
+	Total int
+	// InUse holds the number of connections in use.
+	InUse int
+	// Idle holds the number of idle connections.
+	Idle int
+	// Max holds the max. number of connections.
+	Max int
+	// WaitCount holds the total number of waits for a connection.
+	WaitCount int64
+	// WaitDuration holds the total time waited for connections.
+	WaitDuration time.Duration
+}
+
+// RegisterDB registers the statistics of a database/sql connection pool.
+func (s *Service) RegisterDB(name string, db *sql.DB) error {
+	return register(s.Registry(), collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterPool registers the statistics of a connection pool. The stats
+// function is called on each scrape, e.g. for a pgxpool:
+//
+//	m.RegisterPool("postgresql", func() metrics.PoolStats {
+//		st := pg.Pool().Stat()
+//		return metrics.PoolStats{
+//			Total:        int(st.TotalConns()),
+//			InUse:        int(st.AcquiredConns()),
+//			Idle:         int(st.IdleConns()),
+//			Max:          int(st.MaxConns()),
+//			WaitCount:    st.EmptyAcquireCount(),
+//			WaitDuration: st.AcquireDuration(),
+//		}
+//	})
+func (s *Service) RegisterPool(name string, stats func() PoolStats) error {
+	return register(s.Registry(), newPoolCollector(name, stats))
+}
+
+// register registers the collector, ignoring identical collectors
+// registered before.
+func register(reg prometheus.Registerer, c prometheus.Collector) error {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+type poolCollector struct {
+	stats        func() PoolStats
+	total        *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	max          *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+func newPoolCollector(name string, stats func() PoolStats) *poolCollector {
+	labels := prometheus.Labels{"pool": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc("pool_"+metric, help, nil, labels)
+	}
+	return &poolCollector{
+		stats:        stats,
+		total:        desc("connections_open", "Number of open connections."),
+		inUse:        desc("connections_in_use", "Number of connections in use."),
+		idle:         desc("connections_idle", "Number of idle connections."),
+		max:          desc("connections_max", "Max. number of connections."),
+		waitCount:    desc("wait_total", "Total number of waits for a connection."),
+		waitDuration: desc("wait_seconds_total", "Total time waited for connections."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.total
+	ch <- p.inUse
+	ch <- p.idle
+	ch <- p.max
+	ch <- p.waitCount
+	ch <- p.waitDuration
+}
+
+// Collect implements prometheus.Collector.
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	st := p.stats()
+	ch <- prometheus.MustNewConstMetric(p.total, prometheus.GaugeValue, float64(st.Total))
+	ch <- prometheus.MustNewConstMetric(p.inUse, prometheus.GaugeValue, float64(st.InUse))
+	ch <- prometheus.MustNewConstMetric(p.idle, prometheus.GaugeValue, float64(st.Idle))
+	ch <- prometheus.MustNewConstMetric(p.max, prometheus.GaugeValue, float64(st.Max))
+	ch <- prometheus.MustNewConstMetric(p.waitCount, prometheus.CounterValue, float64(st.WaitCount))
+	ch <- prometheus.MustNewConstMetric(p.waitDuration, prometheus.CounterValue, st.WaitDuration.Seconds())
+}
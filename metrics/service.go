@@ -0,0 +1,285 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics provides a run handler owning a Prometheus registry,
+// exposing it on its own listener or an existing http handler, and
+// optionally pushing it to a Prometheus Pushgateway.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("metrics", "Prometheus metrics")
+
+// package flags.
+const (
+	defaultListenAddress   = ":9090"
+	defaultPath            = "/metrics"
+	defaultPushInterval    = 15 * time.Second
+	defaultShutdownTimeout = 5 * time.Second
+
+	ListenAddress   = "metrics-listen-address"
+	Path            = "metrics-path"
+	PushGateway     = "metrics-push-gateway"
+	PushJob         = "metrics-push-job"
+	PushInterval    = "metrics-push-interval"
+	RuntimeMetrics  = "metrics-runtime"
+	GlobalSink      = "metrics-global-sink"
+	ShutdownTimeout = "metrics-shutdown-timeout"
+)
+
+// Service implements run.Config and run.ServiceContext for a Prometheus
+// registry. Pass Registry to the other handlers, e.g. the Registry field of
+// the http and grpc services, or mount Handler on an existing http server
+// and leave ListenAddress empty.
+//
+// With GlobalSink enabled the registry is installed as the global telemetry
+// MetricSink, exposing the metrics of handlers using telemetry, e.g. cron.
+type Service struct {
+	Prefix string
+
+	// ListenAddress holds the address of the dedicated metrics listener. It
+	// is disabled if empty.
+	ListenAddress string
+	Path          string
+	// PushGateway holds the Pushgateway URL to push the metrics to.
+	PushGateway     string
+	PushJob         string
+	PushInterval    time.Duration
+	RuntimeMetrics  bool
+	GlobalSink      bool
+	ShutdownTimeout time.Duration
+
+	once     sync.Once
+	registry *prometheus.Registry
+	sink     *sink
+	server   *http.Server
+	listener net.Listener
+}
+
+func (s *Service) prefix(v string) string {
+	if s.Prefix != "" {
+		return s.Prefix + "-" + v
+	}
+	return v
+}
+
+// Name implements run.Unit.
+func (s *Service) Name() string {
+	return s.prefix("metrics")
+}
+
+// Initialize implements run.Initializer.
+func (s *Service) Initialize() {
+	s.init()
+	s.ListenAddress = defaultListenAddress
+	s.Path = defaultPath
+	s.PushInterval = defaultPushInterval
+	s.RuntimeMetrics = true
+	s.GlobalSink = true
+	s.ShutdownTimeout = defaultShutdownTimeout
+}
+
+func (s *Service) init() {
+	s.once.Do(func() {
+		s.registry = prometheus.NewRegistry()
+		s.sink = newSink(s.registry)
+	})
+}
+
+// Registry returns the Prometheus registry. It can be called before the
+// run.Group phases to wire up the other handlers.
+func (s *Service) Registry() *prometheus.Registry {
+	s.init()
+	return s.registry
+}
+
+// MetricSink returns a telemetry MetricSink creating its metrics in the
+// registry.
+func (s *Service) MetricSink() telemetry.MetricSink {
+	s.init()
+	return s.sink
+}
+
+// Handler returns the http.Handler exposing the registry.
+func (s *Service) Handler() http.Handler {
+	return promhttp.HandlerFor(s.Registry(), promhttp.HandlerOpts{
+		Registry:          s.Registry(),
+		EnableOpenMetrics: true,
+	})
+}
+
+// FlagSet implements run.Config.
+func (s *Service) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Metrics options")
+
+	flags.StringVar(&s.ListenAddress, s.prefix(ListenAddress),
+		s.ListenAddress, "listen address of the metrics endpoint (empty disables the listener)")
+
+	flags.StringVar(&s.Path, s.prefix(Path),
+		s.Path, "path of the metrics endpoint")
+
+	flags.StringVar(&s.PushGateway, s.prefix(PushGateway),
+		s.PushGateway, "Pushgateway URL to push metrics to")
+
+	flags.StringVar(&s.PushJob, s.prefix(PushJob),
+		s.PushJob, "job name used when pushing metrics (defaults to the service name)")
+
+	flags.DurationVar(&s.PushInterval, s.prefix(PushInterval),
+		s.PushInterval, "interval for pushing metrics")
+
+	flags.BoolVar(&s.RuntimeMetrics, s.prefix(RuntimeMetrics),
+		s.RuntimeMetrics, "register Go runtime and process metrics")
+
+	flags.BoolVar(&s.GlobalSink, s.prefix(GlobalSink),
+		s.GlobalSink, "install the registry as global telemetry metric sink")
+
+	flags.DurationVar(&s.ShutdownTimeout, s.prefix(ShutdownTimeout),
+		s.ShutdownTimeout, "timeout for the final push and listener shutdown")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	if s.ListenAddress != "" {
+		if _, _, err := net.SplitHostPort(s.ListenAddress); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(ListenAddress), flag.ErrInvalidVal))
+		}
+	}
+	if len(s.Path) == 0 || s.Path[0] != '/' {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(Path), flag.ErrInvalidPath))
+	}
+	if s.PushGateway != "" {
+		if u, err := url.Parse(s.PushGateway); err != nil || u.Host == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(PushGateway), flag.ErrInvalidVal))
+		}
+		if s.PushInterval <= 0 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(PushInterval), flag.ErrInvalidVal))
+		}
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() error {
+	s.init()
+	if s.RuntimeMetrics {
+		for _, c := range []prometheus.Collector{
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		} {
+			var are prometheus.AlreadyRegisteredError
+			if err := s.registry.Register(c); err != nil && !errors.As(err, &are) {
+				return err
+			}
+		}
+	}
+	if s.GlobalSink {
+		telemetry.SetGlobalMetricSink(s.sink)
+	}
+
+	if s.ListenAddress == "" {
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle(s.Path, s.Handler())
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	var err error
+	s.listener, err = net.Listen("tcp", s.ListenAddress)
+	return err
+}
+
+// ServeContext implements run.ServiceContext.
+func (s *Service) ServeContext(ctx context.Context) error {
+	errc := make(chan error, 1)
+	if s.server != nil {
+		go func() {
+			if err := s.server.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errc <- err
+			}
+		}()
+	}
+
+	var pusher *push.Pusher
+	var tick <-chan time.Time
+	if s.PushGateway != "" {
+		job := s.PushJob
+		if job == "" {
+			job = s.Name()
+		}
+		pusher = push.New(s.PushGateway, job).Gatherer(s.registry)
+		ticker := time.NewTicker(s.PushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case <-tick:
+			if err := pusher.PushContext(ctx); err != nil {
+				log.Error("unable to push metrics", err)
+			}
+		case <-ctx.Done():
+			sctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+			defer cancel()
+			if pusher != nil {
+				if err := pusher.PushContext(sctx); err != nil {
+					log.Error("unable to push metrics", err)
+				}
+			}
+			if s.server != nil {
+				_ = s.server.Shutdown(sctx)
+			}
+			return nil
+		}
+	}
+}
+
+var (
+	_ run.Initializer    = (*Service)(nil)
+	_ run.Config         = (*Service)(nil)
+	_ run.PreRunner      = (*Service)(nil)
+	_ run.ServiceContext = (*Service)(nil)
+)
@@ -0,0 +1,245 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/basvanbeek/telemetry"
+)
+
+type labelOp int
+
+const (
+	opInsert labelOp = iota
+	opUpdate
+	opUpsert
+	opDelete
+)
+
+// label implements telemetry.Label.
+type label string
+
+// labelValue implements telemetry.LabelValue.
+type labelValue struct {
+	name  string
+	value string
+	op    labelOp
+}
+
+func (l label) Insert(value string) telemetry.LabelValue {
+	return labelValue{name: string(l), value: value, op: opInsert}
+}
+
+func (l label) Update(value string) telemetry.LabelValue {
+	return labelValue{name: string(l), value: value, op: opUpdate}
+}
+
+func (l label) Upsert(value string) telemetry.LabelValue {
+	return labelValue{name: string(l), value: value, op: opUpsert}
+}
+
+func (l label) Delete() telemetry.LabelValue {
+	return labelValue{name: string(l), op: opDelete}
+}
+
+// apply applies the label values to the provided set, following the
+// semantics of the telemetry.Label operations.
+func apply(set map[string]string, values []telemetry.LabelValue) map[string]string {
+	res := make(map[string]string, len(set)+len(values))
+	for k, v := range set {
+		res[k] = v
+	}
+	for _, lv := range values {
+		v, ok := lv.(labelValue)
+		if !ok {
+			continue
+		}
+		_, exists := res[v.name]
+		switch {
+		case v.op == opDelete:
+			delete(res, v.name)
+		case v.op == opInsert && exists, v.op == opUpdate && !exists:
+		default:
+			res[v.name] = v.value
+		}
+	}
+	return res
+}
+
+type ctxLabels struct{}
+
+// sink implements telemetry.MetricSink on top of a Prometheus registry.
+type sink struct {
+	reg prometheus.Registerer
+}
+
+func newSink(reg prometheus.Registerer) *sink {
+	return &sink{reg: reg}
+}
+
+func (s *sink) NewSum(name, description string, opts ...telemetry.MetricOption) telemetry.Metric {
+	o, labels := options(opts)
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: sanitize(name), Help: description,
+	}, labels)
+	vec = registerVec(s.reg, vec)
+	// counters can't decrease, negative values are dropped
+	add := func(lv prometheus.Labels, v float64) {
+		if v >= 0 {
+			vec.With(lv).Add(v)
+		}
+	}
+	return &metric{name: name, enabled: o.EnabledCondition, labels: labels, observe: add, add: add}
+}
+
+func (s *sink) NewGauge(name, description string, opts ...telemetry.MetricOption) telemetry.Metric {
+	o, labels := options(opts)
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: sanitize(name), Help: description,
+	}, labels)
+	vec = registerVec(s.reg, vec)
+	return &metric{name: name, enabled: o.EnabledCondition, labels: labels,
+		observe: func(lv prometheus.Labels, v float64) { vec.With(lv).Set(v) },
+		add:     func(lv prometheus.Labels, v float64) { vec.With(lv).Add(v) },
+	}
+}
+
+func (s *sink) NewDistribution(name, description string, bounds []float64, opts ...telemetry.MetricOption) telemetry.Metric {
+	o, labels := options(opts)
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: sanitize(name), Help: description, Buckets: bounds,
+	}, labels)
+	vec = registerVec(s.reg, vec)
+	return &metric{name: name, enabled: o.EnabledCondition, labels: labels,
+		observe: func(lv prometheus.Labels, v float64) { vec.With(lv).Observe(v) },
+		add:     func(lv prometheus.Labels, v float64) { vec.With(lv).Observe(v) },
+	}
+}
+
+func (s *sink) NewLabel(name string) telemetry.Label {
+	return label(sanitize(name))
+}
+
+func (s *sink) ContextWithLabels(ctx context.Context, values ...telemetry.LabelValue) (context.Context, error) {
+	set, _ := ctx.Value(ctxLabels{}).(map[string]string)
+	return context.WithValue(ctx, ctxLabels{}, apply(set, values)), nil
+}
+
+// metric implements telemetry.Metric.
+type metric struct {
+	name    string
+	enabled func() bool
+	labels  []string
+	bound   map[string]string
+	observe func(prometheus.Labels, float64)
+	add     func(prometheus.Labels, float64)
+}
+
+func (m *metric) Name() string { return m.name }
+
+func (m *metric) Increment() { m.record(m.add, m.bound, 1) }
+
+func (m *metric) Decrement() { m.record(m.add, m.bound, -1) }
+
+func (m *metric) Record(value float64) { m.record(m.observe, m.bound, value) }
+
+func (m *metric) RecordContext(ctx context.Context, value float64) {
+	set, _ := ctx.Value(ctxLabels{}).(map[string]string)
+	if len(m.bound) > 0 {
+		merged := apply(set, nil)
+		for k, v := range m.bound {
+			merged[k] = v
+		}
+		set = merged
+	}
+	m.record(m.observe, set, value)
+}
+
+func (m *metric) With(labelValues ...telemetry.LabelValue) telemetry.Metric {
+	c := *m
+	c.bound = apply(m.bound, labelValues)
+	return &c
+}
+
+func (m *metric) record(fn func(prometheus.Labels, float64), set map[string]string, value float64) {
+	if m.enabled != nil && !m.enabled() {
+		return
+	}
+	lv := make(prometheus.Labels, len(m.labels))
+	for _, l := range m.labels {
+		lv[l] = set[l]
+	}
+	fn(lv, value)
+}
+
+func options(opts []telemetry.MetricOption) (telemetry.MetricOptions, []string) {
+	var o telemetry.MetricOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	labels := make([]string, 0, len(o.Labels))
+	for _, l := range o.Labels {
+		if v, ok := l.(label); ok {
+			labels = append(labels, string(v))
+		}
+	}
+	return o, labels
+}
+
+// registerVec registers the collector, returning a previously registered
+// identical collector if present. Collectors conflicting with existing ones
+// are logged and left unregistered.
+func registerVec[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		log.Error("unable to register metric", err)
+	}
+	return c
+}
+
+// sanitize converts the name into a valid Prometheus metric or label name.
+func sanitize(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+var (
+	_ telemetry.MetricSink = (*sink)(nil)
+	_ telemetry.Metric     = (*metric)(nil)
+	_ telemetry.Label      = label("")
+)
@@ -0,0 +1,404 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mqtt provides a run handler managing an MQTT client connection.
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("mqtt", "MQTT client")
+
+// ErrTimeout is returned if the broker did not respond in time.
+var ErrTimeout = errors.New("mqtt operation timed out")
+
+// package flags.
+const (
+	defaultBroker         = "tcp://localhost:1883"
+	defaultConnectTimeout = 10 * time.Second
+	defaultKeepAlive      = 30 * time.Second
+	defaultQuiesce        = 250 * time.Millisecond
+
+	Broker         = "mqtt-broker"
+	ClientID       = "mqtt-client-id"
+	UserName       = "mqtt-username"
+	Password       = "mqtt-password"
+	QoS            = "mqtt-qos"
+	CleanSession   = "mqtt-clean-session"
+	KeepAlive      = "mqtt-keep-alive"
+	ConnectTimeout = "mqtt-connect-timeout"
+	Quiesce        = "mqtt-disconnect-quiesce"
+	WillTopic      = "mqtt-will-topic"
+	WillPayload    = "mqtt-will-payload"
+	WillRetained   = "mqtt-will-retained"
+	TLSCAFile      = "mqtt-tls-ca-file"
+	TLSCertFile    = "mqtt-tls-cert-file"
+	TLSKeyFile     = "mqtt-tls-key-file"
+)
+
+// Handler handles a message received on a subscribed topic.
+type Handler func(topic string, payload []byte)
+
+type subscription struct {
+	qos     byte
+	handler Handler
+}
+
+// Config implements run.Config to allow configuration of an MQTT client. The
+// client connects in PreRun and automatically reconnects, restoring the
+// registered subscriptions. It disconnects when the ServeContext returns.
+type Config struct {
+	Prefix string
+
+	Broker   string
+	ClientID string
+	UserName string
+	Password string
+	// QoS holds the default quality of service level (0, 1 or 2) used for
+	// subscriptions and publishing.
+	QoS            int
+	CleanSession   bool
+	KeepAlive      time.Duration
+	ConnectTimeout time.Duration
+	Quiesce        time.Duration
+	// WillTopic, WillPayload and WillRetained configure the Last Will and
+	// Testament published by the broker if the client disconnects
+	// ungracefully.
+	WillTopic    string
+	WillPayload  string
+	WillRetained bool
+	TLSCAFile    string
+	TLSCertFile  string
+	TLSKeyFile   string
+
+	// Options allows additional customization of the client options.
+	Options func(*paho.ClientOptions)
+
+	mtx    sync.Mutex
+	client paho.Client
+	subs   map[string]subscription
+}
+
+func (c *Config) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("mqtt")
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	if c.Broker == "" {
+		c.Broker = defaultBroker
+	}
+	if c.ClientID == "" {
+		c.ClientID, _ = os.Hostname()
+	}
+	if c.KeepAlive == 0 {
+		c.KeepAlive = defaultKeepAlive
+	}
+	if c.ConnectTimeout == 0 {
+		c.ConnectTimeout = defaultConnectTimeout
+	}
+	if c.Quiesce == 0 {
+		c.Quiesce = defaultQuiesce
+	}
+	if envPassword := os.Getenv("MQTT_PASSWORD"); envPassword != "" {
+		c.Password = envPassword
+	}
+
+	flags := run.NewFlagSet("MQTT options")
+
+	flags.StringVar(&c.Broker, c.prefix(Broker),
+		c.Broker, "MQTT broker URL (tcp://, ssl://, ws:// or wss://)")
+
+	flags.StringVar(&c.ClientID, c.prefix(ClientID),
+		c.ClientID, "MQTT client ID")
+
+	flags.StringVar(&c.UserName, c.prefix(UserName),
+		c.UserName, "MQTT username")
+
+	flags.SensitiveStringVar(&c.Password, c.prefix(Password),
+		c.Password, "MQTT password")
+
+	flags.IntVar(&c.QoS, c.prefix(QoS),
+		c.QoS, "default quality of service level (0, 1 or 2)")
+
+	flags.BoolVar(&c.CleanSession, c.prefix(CleanSession),
+		c.CleanSession, "start with a clean session")
+
+	flags.DurationVar(&c.KeepAlive, c.prefix(KeepAlive),
+		c.KeepAlive, "keep alive interval")
+
+	flags.DurationVar(&c.ConnectTimeout, c.prefix(ConnectTimeout),
+		c.ConnectTimeout, "timeout for connecting and broker acknowledgements")
+
+	flags.DurationVar(&c.Quiesce, c.prefix(Quiesce),
+		c.Quiesce, "time to wait for pending work when disconnecting")
+
+	flags.StringVar(&c.WillTopic, c.prefix(WillTopic),
+		c.WillTopic, "topic of the last will message")
+
+	flags.StringVar(&c.WillPayload, c.prefix(WillPayload),
+		c.WillPayload, "payload of the last will message")
+
+	flags.BoolVar(&c.WillRetained, c.prefix(WillRetained),
+		c.WillRetained, "retain the last will message")
+
+	flags.StringVar(&c.TLSCAFile, c.prefix(TLSCAFile),
+		c.TLSCAFile, "path to CA file for verifying the MQTT broker")
+
+	flags.StringVar(&c.TLSCertFile, c.prefix(TLSCertFile),
+		c.TLSCertFile, "path to client TLS certificate file")
+
+	flags.StringVar(&c.TLSKeyFile, c.prefix(TLSKeyFile),
+		c.TLSKeyFile, "path to client TLS key file")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if c.Broker == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Broker), flag.ErrRequired))
+	} else if _, err := url.Parse(c.Broker); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Broker), flag.ErrInvalidVal))
+	}
+	if c.ClientID == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientID), flag.ErrRequired))
+	}
+	if c.QoS < 0 || c.QoS > 2 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(QoS), flag.ErrInvalidVal))
+	}
+	if c.ConnectTimeout <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ConnectTimeout), flag.ErrInvalidVal))
+	}
+	if c.WillPayload != "" && c.WillTopic == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(WillTopic), flag.ErrRequired))
+	}
+
+	for name, path := range map[string]string{
+		TLSCAFile:   c.TLSCAFile,
+		TLSCertFile: c.TLSCertFile,
+		TLSKeyFile:  c.TLSKeyFile,
+	} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(name), flag.ErrInvalidPath))
+		}
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(TLSKeyFile),
+				flag.ValidationError("TLS certificate and key need to be provided together")))
+	}
+
+	return mErr
+}
+
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.TLSCAFile != "" {
+		pem, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in CA file")
+		}
+	}
+	if c.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// PreRun implements run.PreRunner.
+func (c *Config) PreRun() error {
+	opts := paho.NewClientOptions().
+		AddBroker(c.Broker).
+		SetClientID(c.ClientID).
+		SetUsername(c.UserName).
+		SetPassword(c.Password).
+		SetCleanSession(c.CleanSession).
+		SetKeepAlive(c.KeepAlive).
+		SetConnectTimeout(c.ConnectTimeout).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Error("connection lost", err)
+		})
+	if c.WillTopic != "" {
+		opts.SetWill(c.WillTopic, c.WillPayload, byte(c.QoS), c.WillRetained)
+	}
+	if c.TLSCAFile != "" || c.TLSCertFile != "" {
+		cfg, err := c.tlsConfig()
+		if err != nil {
+			return err
+		}
+		opts.SetTLSConfig(cfg)
+	}
+	if c.Options != nil {
+		c.Options(opts)
+	}
+
+	client := paho.NewClient(opts)
+	c.mtx.Lock()
+	c.client = client
+	c.mtx.Unlock()
+
+	if err := c.wait(client.Connect()); err != nil {
+		return fmt.Errorf("unable to connect to MQTT broker: %w", err)
+	}
+	return nil
+}
+
+// onConnect (re)establishes the registered subscriptions.
+func (c *Config) onConnect(client paho.Client) {
+	c.mtx.Lock()
+	subs := make(map[string]subscription, len(c.subs))
+	for topic, sub := range c.subs {
+		subs[topic] = sub
+	}
+	c.mtx.Unlock()
+
+	for topic, sub := range subs {
+		if err := c.wait(client.Subscribe(topic, sub.qos, callback(sub.handler))); err != nil {
+			log.Error("unable to subscribe", err, "topic", topic)
+		}
+	}
+}
+
+// ServeContext implements run.ServiceContext.
+func (c *Config) ServeContext(ctx context.Context) error {
+	<-ctx.Done()
+	c.mtx.Lock()
+	client := c.client
+	c.mtx.Unlock()
+	if client != nil {
+		client.Disconnect(uint(c.Quiesce.Milliseconds()))
+	}
+	return nil
+}
+
+// Subscribe registers the handler for messages on the topic using the
+// configured QoS. Subscriptions registered before PreRun are established
+// once connected; all subscriptions are restored after a reconnect.
+func (c *Config) Subscribe(topic string, h Handler) error {
+	return c.SubscribeQoS(topic, byte(c.QoS), h)
+}
+
+// SubscribeQoS is like Subscribe with an explicit QoS level.
+func (c *Config) SubscribeQoS(topic string, qos byte, h Handler) error {
+	c.mtx.Lock()
+	if c.subs == nil {
+		c.subs = make(map[string]subscription)
+	}
+	c.subs[topic] = subscription{qos: qos, handler: h}
+	client := c.client
+	c.mtx.Unlock()
+
+	if client == nil || !client.IsConnectionOpen() {
+		return nil
+	}
+	return c.wait(client.Subscribe(topic, qos, callback(h)))
+}
+
+// Unsubscribe removes the subscription of the topic.
+func (c *Config) Unsubscribe(topic string) error {
+	c.mtx.Lock()
+	delete(c.subs, topic)
+	client := c.client
+	c.mtx.Unlock()
+
+	if client == nil || !client.IsConnectionOpen() {
+		return nil
+	}
+	return c.wait(client.Unsubscribe(topic))
+}
+
+// Publish publishes the payload to the topic using the configured QoS and
+// waits for the broker to acknowledge it.
+func (c *Config) Publish(topic string, payload []byte, retained bool) error {
+	c.mtx.Lock()
+	client := c.client
+	c.mtx.Unlock()
+	if client == nil {
+		return paho.ErrNotConnected
+	}
+	return c.wait(client.Publish(topic, byte(c.QoS), retained, payload))
+}
+
+// Client returns the underlying MQTT client.
+func (c *Config) Client() paho.Client {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.client
+}
+
+func (c *Config) wait(t paho.Token) error {
+	if !t.WaitTimeout(c.ConnectTimeout) {
+		return ErrTimeout
+	}
+	return t.Error()
+}
+
+func callback(h Handler) paho.MessageHandler {
+	return func(_ paho.Client, msg paho.Message) {
+		h(msg.Topic(), msg.Payload())
+	}
+}
+
+var (
+	_ run.Config         = (*Config)(nil)
+	_ run.PreRunner      = (*Config)(nil)
+	_ run.ServiceContext = (*Config)(nil)
+)
@@ -0,0 +1,334 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nats provides a run.Config implementation to configure a NATS
+// connection.
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("nats", "NATS connection")
+
+// package flags.
+const (
+	defaultURL           = nats.DefaultURL
+	defaultMaxReconnects = nats.DefaultMaxReconnect
+	defaultReconnectWait = nats.DefaultReconnectWait
+	defaultDrainTimeout  = 30 * time.Second
+
+	URLs            = "nats-urls"
+	ClientName      = "nats-client-name"
+	UserName        = "nats-username"
+	Password        = "nats-password"
+	Token           = "nats-token"
+	CredentialsFile = "nats-credentials-file"
+	NKeySeedFile    = "nats-nkey-seed-file"
+	TLSCertFile     = "nats-tls-cert-file"
+	TLSKeyFile      = "nats-tls-key-file"
+	TLSCAFile       = "nats-tls-ca-file"
+	MaxReconnects   = "nats-max-reconnects"
+	ReconnectWait   = "nats-reconnect-wait"
+	ReconnectJitter = "nats-reconnect-jitter"
+	DrainTimeout    = "nats-drain-timeout"
+)
+
+// Config implements run.Config to allow configuration of a NATS connection.
+// The connection is established in PreRun and drained on shutdown.
+type Config struct {
+	Prefix string
+
+	URLs       []string
+	ClientName string
+	UserName   string
+	Password   string
+	Token      string
+	// CredentialsFile holds the path to a credentials file holding the user
+	// JWT and nkey seed.
+	CredentialsFile string
+	// NKeySeedFile holds the path to a file holding an nkey seed.
+	NKeySeedFile    string
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSCAFile       string
+	MaxReconnects   int
+	ReconnectWait   time.Duration
+	ReconnectJitter time.Duration
+	DrainTimeout    time.Duration
+
+	// Options holds additional nats options applied after the flag based
+	// options.
+	Options []nats.Option
+
+	conn *nats.Conn
+}
+
+func (c *Config) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("nats")
+}
+
+// Initialize implements run.Initializer.
+func (c *Config) Initialize() {
+	if c.URLs == nil {
+		c.URLs = []string{defaultURL}
+	}
+	if c.MaxReconnects == 0 {
+		c.MaxReconnects = defaultMaxReconnects
+	}
+	if c.ReconnectWait == 0 {
+		c.ReconnectWait = defaultReconnectWait
+	}
+	if c.DrainTimeout == 0 {
+		c.DrainTimeout = defaultDrainTimeout
+	}
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("NATS options")
+
+	if urls := os.Getenv("NATS_URLS"); urls != "" {
+		c.URLs = strings.Split(urls, ",")
+	}
+	if creds := os.Getenv("NATS_CREDENTIALS_FILE"); creds != "" {
+		c.CredentialsFile = creds
+	}
+	if user := os.Getenv("NATS_USERNAME"); user != "" {
+		c.UserName = user
+	}
+	if pass := os.Getenv("NATS_PASSWORD"); pass != "" {
+		c.Password = pass
+	}
+	if token := os.Getenv("NATS_TOKEN"); token != "" {
+		c.Token = token
+	}
+
+	flags.StringArrayVar(&c.URLs, c.prefix(URLs),
+		c.URLs, "NATS server URLs")
+
+	flags.StringVar(&c.ClientName, c.prefix(ClientName),
+		c.ClientName, "NATS client connection name")
+
+	flags.StringVar(&c.UserName, c.prefix(UserName),
+		c.UserName, "NATS username")
+
+	flags.SensitiveStringVar(&c.Password, c.prefix(Password),
+		c.Password, "NATS password")
+
+	flags.SensitiveStringVar(&c.Token, c.prefix(Token),
+		c.Token, "NATS authentication token")
+
+	flags.StringVar(&c.CredentialsFile, c.prefix(CredentialsFile),
+		c.CredentialsFile, "path to NATS credentials file holding user JWT and nkey seed")
+
+	flags.StringVar(&c.NKeySeedFile, c.prefix(NKeySeedFile),
+		c.NKeySeedFile, "path to NATS nkey seed file")
+
+	flags.StringVar(&c.TLSCertFile, c.prefix(TLSCertFile),
+		c.TLSCertFile, "path to client TLS certificate file")
+
+	flags.StringVar(&c.TLSKeyFile, c.prefix(TLSKeyFile),
+		c.TLSKeyFile, "path to client TLS key file")
+
+	flags.StringVar(&c.TLSCAFile, c.prefix(TLSCAFile),
+		c.TLSCAFile, "path to CA file for verifying the NATS servers")
+
+	flags.IntVar(&c.MaxReconnects, c.prefix(MaxReconnects),
+		c.MaxReconnects, "max. reconnect attempts (-1 is unlimited)")
+
+	flags.DurationVar(&c.ReconnectWait, c.prefix(ReconnectWait),
+		c.ReconnectWait, "wait time between reconnect attempts")
+
+	flags.DurationVar(&c.ReconnectJitter, c.prefix(ReconnectJitter),
+		c.ReconnectJitter, "max. jitter added to the reconnect wait time")
+
+	flags.DurationVar(&c.DrainTimeout, c.prefix(DrainTimeout),
+		c.DrainTimeout, "max. time to drain the connection on shutdown")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if len(c.URLs) == 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(URLs), flag.ErrRequired))
+	}
+	for _, u := range c.URLs {
+		if _, err := url.Parse(u); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(URLs),
+					fmt.Errorf("invalid url: %w", err)))
+		}
+	}
+
+	for name, path := range map[string]string{
+		CredentialsFile: c.CredentialsFile,
+		NKeySeedFile:    c.NKeySeedFile,
+		TLSCertFile:     c.TLSCertFile,
+		TLSKeyFile:      c.TLSKeyFile,
+		TLSCAFile:       c.TLSCAFile,
+	} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(name), flag.ErrInvalidPath))
+		}
+	}
+
+	if c.CredentialsFile != "" && c.NKeySeedFile != "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(NKeySeedFile),
+				flag.ValidationError("cannot be combined with a credentials file")))
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(TLSKeyFile),
+				flag.ValidationError("TLS certificate and key need to be provided together")))
+	}
+
+	if c.DrainTimeout <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(DrainTimeout), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// options returns the nats options derived from the configuration.
+func (c *Config) options() ([]nats.Option, error) {
+	opts := []nats.Option{
+		nats.MaxReconnects(c.MaxReconnects),
+		nats.ReconnectWait(c.ReconnectWait),
+		nats.ReconnectJitter(c.ReconnectJitter, c.ReconnectJitter),
+		nats.DrainTimeout(c.DrainTimeout),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Error("disconnected", err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			log.Info("reconnected", "url", nc.ConnectedUrlRedacted())
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
+			if sub != nil {
+				log.Error("async error", err, "subject", sub.Subject)
+				return
+			}
+			log.Error("async error", err)
+		}),
+	}
+	if c.ClientName != "" {
+		opts = append(opts, nats.Name(c.ClientName))
+	}
+	if c.UserName != "" {
+		opts = append(opts, nats.UserInfo(c.UserName, c.Password))
+	}
+	if c.Token != "" {
+		opts = append(opts, nats.Token(c.Token))
+	}
+	if c.CredentialsFile != "" {
+		opts = append(opts, nats.UserCredentials(c.CredentialsFile))
+	}
+	if c.NKeySeedFile != "" {
+		opt, err := nats.NkeyOptionFromSeed(c.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nkey seed file: %w", err)
+		}
+		opts = append(opts, opt)
+	}
+	if c.TLSCertFile != "" {
+		opts = append(opts, nats.ClientCert(c.TLSCertFile, c.TLSKeyFile))
+	}
+	if c.TLSCAFile != "" {
+		opts = append(opts, nats.RootCAs(c.TLSCAFile))
+	}
+	return append(opts, c.Options...), nil
+}
+
+// PreRun implements run.PreRunner.
+func (c *Config) PreRun() error {
+	opts, err := c.options()
+	if err != nil {
+		return err
+	}
+	c.conn, err = nats.Connect(strings.Join(c.URLs, ","), opts...)
+	if err != nil {
+		return fmt.Errorf("nats connect failed: %w", err)
+	}
+	log.Info("connected", "url", c.conn.ConnectedUrlRedacted())
+
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. It drains the connection once
+// the context is canceled, so pending messages are processed before closing.
+func (c *Config) ServeContext(ctx context.Context) error {
+	closed := make(chan struct{})
+	c.conn.SetClosedHandler(func(*nats.Conn) { close(closed) })
+	if c.conn.IsClosed() {
+		return errors.New("nats connection closed")
+	}
+
+	select {
+	case <-closed:
+		return errors.New("nats connection closed")
+	case <-ctx.Done():
+	}
+
+	if err := c.conn.Drain(); err != nil {
+		log.Error("drain failed", err)
+		c.conn.Close()
+		return nil
+	}
+	<-closed
+	return nil
+}
+
+// Conn returns the established NATS connection.
+func (c *Config) Conn() *nats.Conn { return c.conn }
+
+var (
+	_ run.Initializer    = (*Config)(nil)
+	_ run.Config         = (*Config)(nil)
+	_ run.PreRunner      = (*Config)(nil)
+	_ run.ServiceContext = (*Config)(nil)
+)
@@ -0,0 +1,234 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oidc provides a run handler implementing an OpenID Connect relying
+// party with login and callback handlers storing the authenticated identity
+// in a session store.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gorilla/sessions"
+	"golang.org/x/oauth2"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("oidc", "OpenID Connect relying party")
+
+// package flags.
+const (
+	defaultPostLoginURL     = "/"
+	defaultLoginPath        = "/auth/login"
+	defaultSessionName      = "oidc"
+	defaultDiscoveryTimeout = 10 * time.Second
+
+	Issuer           = "oidc-issuer"
+	ClientID         = "oidc-client-id"
+	ClientSecret     = "oidc-client-secret"
+	Scopes           = "oidc-scopes"
+	RedirectURL      = "oidc-redirect-url"
+	PostLoginURL     = "oidc-post-login-url"
+	LoginPath        = "oidc-login-path"
+	SessionName      = "oidc-session-name"
+	DiscoveryTimeout = "oidc-discovery-timeout"
+)
+
+// ErrNoSessionStore is returned by PreRun if no session store is provided.
+var ErrNoSessionStore = errors.New("missing session store")
+
+// Config implements run.Config to allow configuration of an OpenID Connect
+// relying party. PreRun performs the issuer discovery. The issuer signing
+// keys (JWKS) are cached and refreshed when tokens signed with unknown keys
+// are encountered.
+//
+// Sessions provides the session store holding the login state and the
+// authenticated identity. It is called on each request so it can return a
+// store which is only available after PreRun, e.g.:
+//
+//	sess := &session.Config{Redis: rds}
+//	rp := &oidc.Config{Sessions: func() sessions.Store { return sess.Handler() }}
+type Config struct {
+	Prefix string
+
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+	// PostLoginURL holds the default URL to redirect to after login.
+	PostLoginURL string
+	// LoginPath holds the path of the login handler RequireAuth redirects
+	// unauthenticated requests to.
+	LoginPath        string
+	SessionName      string
+	DiscoveryTimeout time.Duration
+
+	Sessions func() sessions.Store
+
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+	oauth2   *oauth2.Config
+}
+
+func (c *Config) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("oidc")
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	if envSecret := os.Getenv("OIDC_CLIENT_SECRET"); envSecret != "" {
+		c.ClientSecret = envSecret
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	if c.PostLoginURL == "" {
+		c.PostLoginURL = defaultPostLoginURL
+	}
+	if c.LoginPath == "" {
+		c.LoginPath = defaultLoginPath
+	}
+	if c.SessionName == "" {
+		c.SessionName = defaultSessionName
+	}
+	if c.DiscoveryTimeout == 0 {
+		c.DiscoveryTimeout = defaultDiscoveryTimeout
+	}
+
+	flags := run.NewFlagSet("OIDC options")
+
+	flags.StringVar(&c.Issuer, c.prefix(Issuer),
+		c.Issuer, "OpenID Connect issuer URL")
+
+	flags.StringVar(&c.ClientID, c.prefix(ClientID),
+		c.ClientID, "OAuth2 client ID")
+
+	flags.SensitiveStringVar(&c.ClientSecret, c.prefix(ClientSecret),
+		c.ClientSecret, "OAuth2 client secret")
+
+	flags.StringSliceVar(&c.Scopes, c.prefix(Scopes),
+		c.Scopes, "OAuth2 scopes to request")
+
+	flags.StringVar(&c.RedirectURL, c.prefix(RedirectURL),
+		c.RedirectURL, "URL of the callback handler registered with the issuer")
+
+	flags.StringVar(&c.PostLoginURL, c.prefix(PostLoginURL),
+		c.PostLoginURL, "default URL to redirect to after login")
+
+	flags.StringVar(&c.LoginPath, c.prefix(LoginPath),
+		c.LoginPath, "path of the login handler")
+
+	flags.StringVar(&c.SessionName, c.prefix(SessionName),
+		c.SessionName, "name of the session holding the identity")
+
+	flags.DurationVar(&c.DiscoveryTimeout, c.prefix(DiscoveryTimeout),
+		c.DiscoveryTimeout, "timeout for the issuer discovery")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if u, err := url.Parse(c.Issuer); c.Issuer == "" || err != nil || u.Host == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Issuer), flag.ErrRequired))
+	}
+	if c.ClientID == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(ClientID), flag.ErrRequired))
+	}
+	if u, err := url.Parse(c.RedirectURL); c.RedirectURL == "" || err != nil || !u.IsAbs() {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(RedirectURL), flag.ErrInvalidVal))
+	}
+	if !containsOpenID(c.Scopes) {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Scopes),
+				flag.ValidationError("scopes need to include "+oidc.ScopeOpenID)))
+	}
+	if c.SessionName == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(SessionName), flag.ErrRequired))
+	}
+
+	return mErr
+}
+
+func containsOpenID(scopes []string) bool {
+	for _, s := range scopes {
+		if s == oidc.ScopeOpenID {
+			return true
+		}
+	}
+	return false
+}
+
+// PreRun implements run.PreRunner.
+func (c *Config) PreRun() error {
+	if c.Sessions == nil {
+		return ErrNoSessionStore
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.DiscoveryTimeout)
+	defer cancel()
+	var err error
+	if c.provider, err = oidc.NewProvider(ctx, c.Issuer); err != nil {
+		return fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	c.verifier = c.provider.Verifier(&oidc.Config{ClientID: c.ClientID})
+	c.oauth2 = &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Endpoint:     c.provider.Endpoint(),
+		RedirectURL:  c.RedirectURL,
+		Scopes:       c.Scopes,
+	}
+	return nil
+}
+
+// Provider returns the discovered OpenID Connect provider.
+func (c *Config) Provider() *oidc.Provider {
+	return c.provider
+}
+
+// Verifier returns the ID token verifier, e.g. for verifying bearer tokens.
+func (c *Config) Verifier() *oidc.IDTokenVerifier {
+	return c.verifier
+}
+
+var (
+	_ run.Config    = (*Config)(nil)
+	_ run.PreRunner = (*Config)(nil)
+)
@@ -0,0 +1,174 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// session value keys.
+const (
+	sessionIdentity = "oidc_identity"
+	sessionState    = "oidc_state"
+	sessionNonce    = "oidc_nonce"
+	sessionVerifier = "oidc_verifier"
+	sessionReturnTo = "oidc_return_to"
+)
+
+// LoginHandler returns a handler redirecting to the issuer to authenticate.
+// A relative return_to query parameter sets the URL to redirect to after
+// login.
+func (c *Config) LoginHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, err := c.Sessions().Get(r, c.SessionName)
+		if err != nil {
+			log.Error("unable to get session", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		state, nonce, verifier := randomString(), randomString(), oauth2.GenerateVerifier()
+		sess.Values[sessionState] = state
+		sess.Values[sessionNonce] = nonce
+		sess.Values[sessionVerifier] = verifier
+		sess.Values[sessionReturnTo] = c.PostLoginURL
+		if rt := r.URL.Query().Get("return_to"); isRelative(rt) {
+			sess.Values[sessionReturnTo] = rt
+		}
+		if err = sess.Save(r, w); err != nil {
+			log.Error("unable to save session", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, c.oauth2.AuthCodeURL(state,
+			oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+	})
+}
+
+// CallbackHandler returns the handler for the redirect URL. It exchanges the
+// authorization code, verifies the ID token and stores the identity in the
+// session.
+func (c *Config) CallbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, err := c.Sessions().Get(r, c.SessionName)
+		if err != nil {
+			log.Error("unable to get session", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			log.Info("authentication failed", "error", e, "description", q.Get("error_description"))
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		state, _ := sess.Values[sessionState].(string)
+		if state == "" || q.Get("state") != state {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		verifier, _ := sess.Values[sessionVerifier].(string)
+		nonce, _ := sess.Values[sessionNonce].(string)
+
+		token, err := c.oauth2.Exchange(r.Context(), q.Get("code"), oauth2.VerifierOption(verifier))
+		if err != nil {
+			log.Error("code exchange failed", err)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		rawIDToken, ok := token.Extra("id_token").(string)
+		if !ok {
+			http.Error(w, "missing id token", http.StatusUnauthorized)
+			return
+		}
+		idToken, err := c.verifier.Verify(r.Context(), rawIDToken)
+		if err != nil || idToken.Nonce != nonce {
+			log.Error("id token verification failed", err)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		var claims struct {
+			Email             string   `json:"email"`
+			EmailVerified     bool     `json:"email_verified"`
+			Name              string   `json:"name"`
+			PreferredUsername string   `json:"preferred_username"`
+			Groups            []string `json:"groups"`
+		}
+		if err = idToken.Claims(&claims); err != nil {
+			log.Error("unable to parse id token claims", err)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		returnTo, _ := sess.Values[sessionReturnTo].(string)
+		for _, k := range []string{sessionState, sessionNonce, sessionVerifier, sessionReturnTo} {
+			delete(sess.Values, k)
+		}
+		sess.Values[sessionIdentity] = &Identity{
+			Subject:           idToken.Subject,
+			Email:             claims.Email,
+			EmailVerified:     claims.EmailVerified,
+			Name:              claims.Name,
+			PreferredUsername: claims.PreferredUsername,
+			Groups:            claims.Groups,
+			Expiry:            idToken.Expiry,
+		}
+		if err = sess.Save(r, w); err != nil {
+			log.Error("unable to save session", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if returnTo == "" {
+			returnTo = c.PostLoginURL
+		}
+		http.Redirect(w, r, returnTo, http.StatusFound)
+	})
+}
+
+// LogoutHandler returns a handler removing the identity from the session
+// and redirecting to the post login URL.
+func (c *Config) LogoutHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, err := c.Sessions().Get(r, c.SessionName)
+		if err == nil {
+			delete(sess.Values, sessionIdentity)
+			err = sess.Save(r, w)
+		}
+		if err != nil {
+			log.Error("unable to clear session", err)
+		}
+		http.Redirect(w, r, c.PostLoginURL, http.StatusFound)
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// isRelative returns true for local paths, preventing open redirects.
+func isRelative(s string) bool {
+	return strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") && !strings.HasPrefix(s, "/\\")
+}
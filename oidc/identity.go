@@ -0,0 +1,95 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"context"
+	"encoding/gob"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func init() {
+	gob.Register(&Identity{})
+}
+
+// Identity holds the authenticated identity as stored in the session.
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Groups            []string
+	Expiry            time.Time
+}
+
+// Expired returns true if the ID token the identity was established with
+// has expired.
+func (i *Identity) Expired() bool {
+	return !i.Expiry.IsZero() && time.Now().After(i.Expiry)
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the identity attached by Middleware.
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// identity returns the unexpired identity stored in the request session.
+func (c *Config) identity(r *http.Request) *Identity {
+	sess, err := c.Sessions().Get(r, c.SessionName)
+	if err != nil {
+		return nil
+	}
+	id, ok := sess.Values[sessionIdentity].(*Identity)
+	if !ok || id.Expired() {
+		return nil
+	}
+	return id
+}
+
+// Middleware attaches the identity of authenticated requests to the request
+// context. Requests without identity are passed on unchanged.
+func (c *Config) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := c.identity(r); id != nil {
+			r = r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAuth is like Middleware but redirects unauthenticated GET requests
+// to the login handler and rejects other unauthenticated requests.
+func (c *Config) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := c.identity(r)
+		if id == nil {
+			if r.Method != http.MethodGet {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r,
+				c.LoginPath+"?"+url.Values{"return_to": {r.URL.RequestURI()}}.Encode(),
+				http.StatusFound)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
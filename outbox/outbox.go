@@ -0,0 +1,253 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package outbox implements the transactional outbox pattern on PostgreSQL.
+// Events are written into an outbox table inside application transactions
+// and relayed to a Sink, e.g. Kafka, NATS or SNS, with at-least-once
+// delivery.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("outbox", "transactional outbox")
+
+// package flags.
+const (
+	defaultTable         = "outbox"
+	defaultRelayName     = "default"
+	defaultNotifyChannel = "outbox"
+	defaultBatchSize     = 100
+	defaultPollInterval  = time.Second
+	defaultRetention     = 24 * time.Hour
+
+	Table         = "outbox-table"
+	DisableRelay  = "outbox-disable-relay"
+	RelayName     = "outbox-relay-name"
+	NotifyChannel = "outbox-notify-channel"
+	BatchSize     = "outbox-batch-size"
+	PollInterval  = "outbox-poll-interval"
+	Retention     = "outbox-retention"
+	CreateTables  = "outbox-create-tables"
+)
+
+// Outbox errors.
+var (
+	ErrNoPool = errors.New("postgresql pool not configured")
+	ErrNoSink = errors.New("outbox sink not configured")
+)
+
+// Event holds an outbox event.
+type Event struct {
+	// ID holds the sequence number assigned by the database.
+	ID      int64
+	Topic   string
+	Key     string
+	Payload []byte
+	Headers map[string]string
+	// CreatedAt holds the time the event was written.
+	CreatedAt time.Time
+}
+
+// Sink publishes relayed events. Events are published in commit order, one
+// at a time; an event is published again if the relay fails before
+// recording its offset.
+type Sink interface {
+	Publish(ctx context.Context, e *Event) error
+}
+
+// SinkFunc adapts a function to a Sink, e.g. wrapping a Kafka producer:
+//
+//	outbox.SinkFunc(func(ctx context.Context, e *outbox.Event) error {
+//		return producer.Produce(ctx, e.Topic, []byte(e.Key), e.Payload)
+//	})
+type SinkFunc func(ctx context.Context, e *Event) error
+
+// Publish implements Sink.
+func (f SinkFunc) Publish(ctx context.Context, e *Event) error {
+	return f(ctx, e)
+}
+
+// Outbox implements run.Config and run.ServiceContext for a transactional
+// outbox on PostgreSQL 13 or later. Write events with Write or WriteSQL as
+// part of the application transactions. The relay publishes the committed
+// events to the Sink in commit order and tracks its offset in a separate
+// table. Multiple replicas can run the relay; only one relays at a time.
+//
+// Relayed events are deleted after the retention period. Note that long
+// running transactions delay the relay, as events are only relayed once all
+// transactions that started before them have finished.
+//
+// Initialize sets the zero-valued fields to their defaults.
+type Outbox struct {
+	Prefix string
+
+	// Pool returns the PostgreSQL pool, e.g. the Pool method of the
+	// postgresql handler.
+	Pool func() *pgxpool.Pool
+	Sink Sink
+
+	Table string
+	// DisableRelay disables the relay for write only instances.
+	DisableRelay bool
+	// RelayName identifies the relay offset, allowing independent relays
+	// of the same table to different sinks.
+	RelayName string
+	// NotifyChannel holds the channel used to notify the relay of new
+	// events. Set it to empty through its flag to only poll.
+	NotifyChannel string
+	BatchSize     int
+	PollInterval  time.Duration
+	Retention     time.Duration
+	// CreateTables creates the outbox tables if they don't exist.
+	CreateTables bool
+
+	pool *pgxpool.Pool
+}
+
+func (o *Outbox) prefix(s string) string {
+	if o.Prefix != "" {
+		return o.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (o *Outbox) Name() string {
+	return o.prefix("outbox")
+}
+
+// Initialize implements run.Initializer.
+func (o *Outbox) Initialize() {
+	if o.Table == "" {
+		o.Table = defaultTable
+	}
+	if o.RelayName == "" {
+		o.RelayName = defaultRelayName
+	}
+	if o.NotifyChannel == "" {
+		o.NotifyChannel = defaultNotifyChannel
+	}
+	if o.BatchSize == 0 {
+		o.BatchSize = defaultBatchSize
+	}
+	if o.PollInterval == 0 {
+		o.PollInterval = defaultPollInterval
+	}
+	if o.Retention == 0 {
+		o.Retention = defaultRetention
+	}
+}
+
+// FlagSet implements run.Config.
+func (o *Outbox) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Outbox options")
+
+	flags.StringVar(&o.Table, o.prefix(Table),
+		o.Table, "outbox table name, the offsets are kept in <table>_offsets")
+
+	flags.BoolVar(&o.DisableRelay, o.prefix(DisableRelay),
+		o.DisableRelay, "don't relay the outbox events to the sink (write only instance)")
+
+	flags.StringVar(&o.RelayName, o.prefix(RelayName),
+		o.RelayName, "name of the relay offset")
+
+	flags.StringVar(&o.NotifyChannel, o.prefix(NotifyChannel),
+		o.NotifyChannel, "LISTEN/NOTIFY channel for new events (empty to only poll)")
+
+	flags.IntVar(&o.BatchSize, o.prefix(BatchSize),
+		o.BatchSize, "max. number of events relayed per transaction")
+
+	flags.DurationVar(&o.PollInterval, o.prefix(PollInterval),
+		o.PollInterval, "interval for polling new events")
+
+	flags.DurationVar(&o.Retention, o.prefix(Retention),
+		o.Retention, "time relayed events are kept")
+
+	flags.BoolVar(&o.CreateTables, o.prefix(CreateTables),
+		o.CreateTables, "create the outbox tables if they don't exist")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (o *Outbox) Validate() error {
+	var mErr error
+
+	if o.Pool == nil {
+		mErr = multierror.Append(mErr, ErrNoPool)
+	}
+	if !o.DisableRelay && o.Sink == nil {
+		mErr = multierror.Append(mErr, ErrNoSink)
+	}
+	if o.Table == "" || strings.Count(o.Table, ".") > 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(o.prefix(Table), flag.ErrInvalidVal))
+	}
+	if o.RelayName == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(o.prefix(RelayName), flag.ErrRequired))
+	}
+	if o.BatchSize <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(o.prefix(BatchSize), flag.ErrInvalidVal))
+	}
+	if o.PollInterval <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(o.prefix(PollInterval), flag.ErrInvalidVal))
+	}
+	if o.Retention < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(o.prefix(Retention), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (o *Outbox) PreRun() error {
+	if o.pool = o.Pool(); o.pool == nil {
+		return ErrNoPool
+	}
+	if !o.CreateTables {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return o.createTables(ctx)
+}
+
+// table returns the sanitized table name with the optional suffix.
+func (o *Outbox) table(suffix string) string {
+	return pgx.Identifier(strings.Split(o.Table+suffix, ".")).Sanitize()
+}
+
+var (
+	_ run.Initializer    = (*Outbox)(nil)
+	_ run.Config         = (*Outbox)(nil)
+	_ run.PreRunner      = (*Outbox)(nil)
+	_ run.ServiceContext = (*Outbox)(nil)
+)
@@ -0,0 +1,259 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// cleanupInterval holds the interval for deleting expired events.
+const cleanupInterval = time.Minute
+
+// createTables creates the outbox and offsets tables. The txid column holds
+// the id of the writing transaction, which, unlike the event id, allows to
+// relay events in commit order without skipping events of slow
+// transactions.
+func (o *Outbox) createTables(ctx context.Context) error {
+	table, offsets := o.table(""), o.table("_offsets")
+	index := pgx.Identifier{lastPart(o.Table) + "_txid_id_idx"}.Sanitize()
+	_, err := o.pool.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS `+table+` (
+	id         bigserial PRIMARY KEY,
+	txid       bigint NOT NULL DEFAULT pg_current_xact_id()::text::bigint,
+	topic      text NOT NULL,
+	key        text NOT NULL DEFAULT '',
+	payload    bytea,
+	headers    jsonb,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS `+index+` ON `+table+` (txid, id);
+CREATE TABLE IF NOT EXISTS `+offsets+` (
+	name       text PRIMARY KEY,
+	txid       bigint NOT NULL,
+	id         bigint NOT NULL,
+	updated_at timestamptz NOT NULL DEFAULT now()
+)`)
+	return err
+}
+
+// ServeContext implements run.ServiceContext. It relays the outbox events
+// to the Sink until the context is canceled.
+func (o *Outbox) ServeContext(ctx context.Context) error {
+	if o.DisableRelay {
+		<-ctx.Done()
+		return nil
+	}
+
+	if _, err := o.pool.Exec(ctx, "INSERT INTO "+o.table("_offsets")+
+		" (name, txid, id) VALUES ($1, 0, 0) ON CONFLICT (name) DO NOTHING", o.RelayName,
+	); err != nil {
+		return fmt.Errorf("unable to initialize relay offset: %w", err)
+	}
+
+	notify := make(chan struct{}, 1)
+	if o.NotifyChannel != "" {
+		go o.listen(ctx, notify)
+	}
+
+	poll := time.NewTicker(o.PollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(cleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		n, err := o.relay(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error("unable to relay events", err, "relay", o.RelayName)
+		}
+		if err == nil && n == o.BatchSize {
+			// more events pending
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-cleanup.C:
+			o.cleanup(ctx)
+		case <-poll.C:
+		case <-notify:
+		}
+	}
+}
+
+// relay publishes the next batch of committed events and records the offset
+// of the last published event. The offset row lock ensures a single active
+// relay per name.
+func (o *Outbox) relay(ctx context.Context) (int, error) {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	var txid, id int64
+	err = tx.QueryRow(ctx, "SELECT txid, id FROM "+o.table("_offsets")+
+		" WHERE name = $1 FOR UPDATE SKIP LOCKED", o.RelayName,
+	).Scan(&txid, &id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// another replica is relaying
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// only events of transactions older than any running transaction are
+	// relayed, so no events can show up before the offset afterwards
+	rows, err := tx.Query(ctx, "SELECT id, txid, topic, key, payload, headers, created_at FROM "+
+		o.table("")+" WHERE (txid, id) > ($1, $2) AND txid < pg_snapshot_xmin(pg_current_snapshot())::text::bigint"+
+		" ORDER BY txid, id LIMIT $3", txid, id, o.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	type row struct {
+		Event
+		txid int64
+	}
+	var events []row
+	for rows.Next() {
+		var (
+			r       row
+			headers []byte
+		)
+		if err = rows.Scan(&r.ID, &r.txid, &r.Topic, &r.Key, &r.Payload, &headers, &r.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if len(headers) > 0 {
+			if err = json.Unmarshal(headers, &r.Headers); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("invalid headers of event %d: %w", r.ID, err)
+			}
+		}
+		events = append(events, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var (
+		published int
+		pubErr    error
+	)
+	for i := range events {
+		if pubErr = o.Sink.Publish(ctx, &events[i].Event); pubErr != nil {
+			pubErr = fmt.Errorf("unable to publish event %d: %w", events[i].ID, pubErr)
+			break
+		}
+		published++
+	}
+	if published == 0 {
+		return 0, pubErr
+	}
+
+	last := events[published-1]
+	if _, err = tx.Exec(ctx, "UPDATE "+o.table("_offsets")+
+		" SET txid = $2, id = $3, updated_at = now() WHERE name = $1",
+		o.RelayName, last.txid, last.ID,
+	); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return published, pubErr
+}
+
+// listen signals notify on new events until the context is canceled. The
+// relay keeps polling if listening fails.
+func (o *Outbox) listen(ctx context.Context, notify chan<- struct{}) {
+	for ctx.Err() == nil {
+		if err := o.waitForNotifications(ctx, notify); err != nil && ctx.Err() == nil {
+			log.Error("unable to listen for events", err, "channel", o.NotifyChannel)
+			select {
+			case <-ctx.Done():
+			case <-time.After(o.PollInterval):
+			}
+		}
+	}
+}
+
+func (o *Outbox) waitForNotifications(ctx context.Context, notify chan<- struct{}) error {
+	conn, err := o.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release(conn)
+
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{o.NotifyChannel}.Sanitize()); err != nil {
+		return err
+	}
+	for {
+		if _, err = conn.Conn().WaitForNotification(ctx); err != nil {
+			return err
+		}
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// release returns the connection to the pool, closing it if it may still
+// be listening.
+func release(conn *pgxpool.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.Exec(ctx, "UNLISTEN *"); err != nil {
+		_ = conn.Conn().Close(ctx)
+	}
+	conn.Release()
+}
+
+// cleanup deletes the events relayed by all relays and older than the
+// retention period.
+func (o *Outbox) cleanup(ctx context.Context) {
+	tag, err := o.pool.Exec(ctx, "DELETE FROM "+o.table("")+" e"+
+		" WHERE e.created_at < now() - make_interval(secs => $1)"+
+		" AND NOT EXISTS (SELECT 1 FROM "+o.table("_offsets")+" f WHERE (e.txid, e.id) > (f.txid, f.id))",
+		o.Retention.Seconds())
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("unable to delete relayed events", err)
+		}
+		return
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		log.Debug("deleted relayed events", "count", n)
+	}
+}
+
+func lastPart(name string) string {
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == '.' {
+			return name[i+1:]
+		}
+	}
+	return name
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Write writes the events into the outbox as part of the transaction, e.g.
+// one started on the pool of the postgresql handler. The events are relayed
+// once the transaction commits.
+func (o *Outbox) Write(ctx context.Context, tx pgx.Tx, events ...*Event) error {
+	b := &pgx.Batch{}
+	for _, e := range events {
+		headers, err := encodeHeaders(e.Headers)
+		if err != nil {
+			return err
+		}
+		b.Queue(o.insertSQL(), e.Topic, e.Key, e.Payload, headers)
+	}
+	if o.NotifyChannel != "" {
+		b.Queue("SELECT pg_notify($1, '')", o.NotifyChannel)
+	}
+	return tx.SendBatch(ctx, b).Close()
+}
+
+// WriteSQL writes the events into the outbox as part of the database/sql
+// transaction, e.g. one started on the pool of the dbpool handler using a
+// PostgreSQL driver.
+func (o *Outbox) WriteSQL(ctx context.Context, tx *sql.Tx, events ...*Event) error {
+	for _, e := range events {
+		headers, err := encodeHeaders(e.Headers)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, o.insertSQL(), e.Topic, e.Key, e.Payload, headers); err != nil {
+			return err
+		}
+	}
+	if o.NotifyChannel != "" {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_notify($1, '')", o.NotifyChannel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Outbox) insertSQL() string {
+	return "INSERT INTO " + o.table("") +
+		" (topic, key, payload, headers) VALUES ($1, $2, $3, $4::jsonb)"
+}
+
+// encodeHeaders returns the headers as JSON string, or nil if empty.
+func encodeHeaders(headers map[string]string) (any, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(headers)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
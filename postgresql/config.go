@@ -53,9 +53,14 @@ type Config struct {
 	MaxOpenConnections int32
 	MaxConnLifetime    time.Duration
 	MaxConnIdleTime    time.Duration
+	// LockTable holds the table of the lock leases obtained by TryLock. It
+	// is created if it does not exist. Defaults to "run_locks".
+	LockTable string
 
 	pool         *pgxpool.Pool
 	readOnlyPool *pgxpool.Pool
+	lockMtx      sync.Mutex
+	lockReady    bool
 }
 
 func (c *Config) prefix(s string) string {
@@ -0,0 +1,84 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultLockTable = "run_locks"
+
+// TryLock obtains a distributed lock for the key which expires after the
+// provided time to live, e.g. for cron.WithDistributedLock. It returns false
+// if the lock is held by another instance. The lock is a lease row in the
+// LockTable, so no connection is held while the lock is.
+func (c *Config) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, errors.New("lock time to live must be positive")
+	}
+	table, err := c.lockTable(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// the holder is informational only
+	holder, _ := os.Hostname()
+	var ok bool
+	err = c.pool.QueryRow(ctx, `
+INSERT INTO `+table+` (key, holder, expires_at)
+VALUES ($1, $2, now() + make_interval(secs => $3))
+ON CONFLICT (key) DO UPDATE
+SET holder = excluded.holder, expires_at = excluded.expires_at
+WHERE `+table+`.expires_at <= now()
+RETURNING true`,
+		key, holder, ttl.Seconds(),
+	).Scan(&ok)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// the lease of another instance has not expired yet
+		return false, nil
+	}
+	return ok, err
+}
+
+// lockTable returns the quoted lock table name, creating the table if it
+// does not exist yet.
+func (c *Config) lockTable(ctx context.Context) (string, error) {
+	name := c.LockTable
+	if name == "" {
+		name = defaultLockTable
+	}
+	table := pgx.Identifier{name}.Sanitize()
+
+	c.lockMtx.Lock()
+	defer c.lockMtx.Unlock()
+	if c.lockReady {
+		return table, nil
+	}
+	if _, err := c.pool.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS `+table+` (
+	key        text PRIMARY KEY,
+	holder     text NOT NULL,
+	expires_at timestamptz NOT NULL
+)`); err != nil {
+		return "", err
+	}
+	c.lockReady = true
+	return table, nil
+}
@@ -0,0 +1,123 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcra returns the result of a request arriving at now for a key with the
+// theoretical arrival time tat, and the new theoretical arrival time.
+func gcra(now, tat time.Time, interval time.Duration, burst int) (Result, time.Time) {
+	if tat.Before(now) {
+		tat = now
+	}
+	offset := interval * time.Duration(burst)
+	newTAT := tat.Add(interval)
+	if allowAt := newTAT.Add(-offset); now.Before(allowAt) {
+		return Result{
+			Limit:      burst,
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}, tat
+	}
+	reset := newTAT.Sub(now)
+	return Result{
+		Allowed:    true,
+		Limit:      burst,
+		Remaining:  int((offset - reset) / interval),
+		ResetAfter: reset,
+	}, newTAT
+}
+
+// localBackend holds the theoretical arrival times in memory, limiting each
+// instance on its own. Expired keys are periodically removed.
+type localBackend struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+const sweepInterval = time.Minute
+
+func (l *localBackend) take(_ context.Context, key string, interval time.Duration, burst int) (Result, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.tats == nil {
+		l.tats = make(map[string]time.Time)
+	}
+	if now.Sub(l.lastSweep) > sweepInterval {
+		for k, tat := range l.tats {
+			if tat.Before(now) {
+				delete(l.tats, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	res, tat := gcra(now, l.tats[key], interval, burst)
+	l.tats[key] = tat
+	return res, nil
+}
+
+// gcraScript implements gcra in redis, using the redis clock so the limits
+// don't depend on the clocks of the instances. Times are in microseconds.
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local offset = interval * tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local newtat = tat + interval
+local allowat = newtat - offset
+if now < allowat then
+	return {0, allowat - now, tat - now}
+end
+redis.call('SET', KEYS[1], string.format('%d', newtat), 'PX', math.ceil((newtat - now) / 1000))
+return {1, 0, newtat - now}
+`)
+
+// redisBackend holds the theoretical arrival times in redis, applying the
+// limits across instances.
+type redisBackend struct {
+	client redis.UniversalClient
+}
+
+func (r *redisBackend) take(ctx context.Context, key string, interval time.Duration, burst int) (Result, error) {
+	v, err := gcraScript.Run(ctx, r.client, []string{key},
+		max(interval.Microseconds(), 1), burst).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{
+		Allowed:    v[0] == 1,
+		Limit:      burst,
+		RetryAfter: time.Duration(v[1]) * time.Microsecond,
+		ResetAfter: time.Duration(v[2]) * time.Microsecond,
+	}
+	if res.Allowed {
+		res.Remaining = int((interval*time.Duration(burst) - res.ResetAfter) / interval)
+	}
+	return res, nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// allow checks the call against the named policy.
+func (s *Service) allow(ctx context.Context, policy, fullMethod string, key func(ctx context.Context, fullMethod string) string) error {
+	res, err := s.Take(ctx, policy, key(ctx, fullMethod))
+	if err != nil {
+		// don't turn limiter failures into outages
+		log.Context(ctx).Error("rate limiter failed", err, "policy", policy)
+		return nil
+	}
+	if !res.Allowed {
+		st, _ := status.New(codes.ResourceExhausted, "rate limit exceeded").
+			WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)})
+		return st.Err()
+	}
+	return nil
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor limiting calls by the
+// named policy, e.g. to be registered with the grpc handler:
+//
+//	srv.Interceptors().AddUnaryServerPhase(grpc.PhaseLimits, s.UnaryServerInterceptor("api", nil))
+//
+// Calls are keyed by the provided func or, if nil, by the peer IP. Rejected
+// calls fail with codes.ResourceExhausted holding the retry delay.
+func (s *Service) UnaryServerInterceptor(
+	policy string, key func(ctx context.Context, fullMethod string) string,
+) grpc.UnaryServerInterceptor {
+	if key == nil {
+		key = peerHost
+	}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := s.allow(ctx, policy, info.FullMethod, key); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the stream variant of
+// UnaryServerInterceptor. The limits apply to the creation of streams, not
+// their messages.
+func (s *Service) StreamServerInterceptor(
+	policy string, key func(ctx context.Context, fullMethod string) string,
+) grpc.StreamServerInterceptor {
+	if key == nil {
+		key = peerHost
+	}
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := s.allow(ss.Context(), policy, info.FullMethod, key); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// peerHost returns the host of the peer found in the context.
+func peerHost(ctx context.Context, _ string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
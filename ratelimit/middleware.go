@@ -0,0 +1,72 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware returns http middleware limiting requests by the named policy.
+// Requests are keyed by the provided func or, if nil, by the client IP as
+// resolved by ClientIP. Rejected requests receive a 429 response with
+// Retry-After header. Limiter failures are logged and let requests pass.
+func (s *Service) Middleware(policy string, key func(r *http.Request) string) func(http.Handler) http.Handler {
+	if key == nil {
+		key = s.clientKey
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := s.Take(r.Context(), policy, key(r))
+			if err != nil {
+				log.Context(r.Context()).Error("rate limiter failed", err, "policy", policy)
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("X-RateLimit-Reset", seconds(res.ResetAfter))
+			if !res.Allowed {
+				h.Set("Retry-After", seconds(res.RetryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds returns the duration in whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// clientKey keys requests by the client IP resolved by ClientIP, falling back
+// to the IP of the remote address.
+func (s *Service) clientKey(r *http.Request) string {
+	if s.ClientIP != nil {
+		if ip := s.ClientIP(r); ip.IsValid() {
+			return ip.String()
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
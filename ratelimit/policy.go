@@ -0,0 +1,83 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/basvanbeek/run/pkg/flag"
+)
+
+// Policy holds a rate limit of Limit requests per Period, allowing bursts
+// of up to Burst requests.
+type Policy struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// ParsePolicy parses a "limit[/period][:burst]" policy, e.g. "100/1m:20".
+// The period defaults to a second and the burst to the limit. A period
+// without number (e.g. "m") is taken as one unit.
+func ParsePolicy(spec string) (Policy, error) {
+	p := Policy{Period: time.Second}
+	spec, burst, hasBurst := strings.Cut(strings.TrimSpace(spec), ":")
+	limit, period, hasPeriod := strings.Cut(spec, "/")
+
+	var err error
+	if p.Limit, err = strconv.Atoi(limit); err != nil {
+		return Policy{}, flag.ValidationError("invalid limit")
+	}
+	if hasPeriod {
+		if period != "" && (period[0] < '0' || period[0] > '9') {
+			period = "1" + period
+		}
+		if p.Period, err = time.ParseDuration(period); err != nil {
+			return Policy{}, flag.ValidationError("invalid period")
+		}
+	}
+	p.Burst = p.Limit
+	if hasBurst {
+		if p.Burst, err = strconv.Atoi(burst); err != nil {
+			return Policy{}, flag.ValidationError("invalid burst")
+		}
+	}
+	return p, p.validate()
+}
+
+func (p Policy) validate() error {
+	switch {
+	case p.Limit <= 0:
+		return flag.ValidationError("limit must be positive")
+	case p.Period <= 0:
+		return flag.ValidationError("period must be positive")
+	case p.Burst <= 0:
+		return flag.ValidationError("burst must be positive")
+	}
+	return nil
+}
+
+// interval returns the emission interval of the policy, the time in which a
+// single request is restored.
+func (p Policy) interval() time.Duration {
+	return p.Period / time.Duration(p.Limit)
+}
+
+// String returns the policy in its "limit/period:burst" form.
+func (p Policy) String() string {
+	return strconv.Itoa(p.Limit) + "/" + p.Period.String() + ":" + strconv.Itoa(p.Burst)
+}
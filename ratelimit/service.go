@@ -0,0 +1,239 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit provides a rate limiter service for use with the run
+// package. It implements the generic cell rate algorithm (GCRA), a token
+// bucket variant, with an in-memory or Redis backend shared by its http
+// middleware, gRPC interceptors and application code.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+	"github.com/redis/go-redis/v9"
+)
+
+var log = scope.Register("ratelimit", "rate limiter")
+
+// package flags.
+const (
+	defaultBackend   = BackendLocal
+	defaultKeyPrefix = "ratelimit:"
+
+	Backend   = "ratelimit-backend"
+	KeyPrefix = "ratelimit-key-prefix"
+	Policies  = "ratelimit-policies"
+)
+
+// Supported backends.
+const (
+	BackendLocal = "local"
+	BackendRedis = "redis"
+)
+
+// ErrUnknownPolicy is returned for limits checked against an unknown policy.
+var ErrUnknownPolicy = errors.New("unknown rate limit policy")
+
+// Limiter decides if a request identified by key is allowed under the
+// provided limit of requests per second with the provided burst. If not, it
+// returns the time after which the request can be retried.
+//
+// Limiter matches the Limiter of the grpc handler, so the Service can be
+// used as its RateLimiter.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit float64, burst int) (ok bool, retryAfter time.Duration, err error)
+}
+
+// Result holds the outcome of a rate limit check.
+type Result struct {
+	Allowed bool
+	// Limit holds the burst of the policy, Remaining the number of requests
+	// currently allowed.
+	Limit     int
+	Remaining int
+	// RetryAfter holds the time after which a rejected request can be
+	// retried, ResetAfter the time after which the limit is fully restored.
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// backend implements the GCRA state store.
+type backend interface {
+	take(ctx context.Context, key string, interval time.Duration, burst int) (Result, error)
+}
+
+// Service implements run.Config and run.PreRunner for a rate limiter with
+// named limit policies. Policies are configured through the Policies field
+// or the policies flag, which takes "name=limit[/period][:burst]" pairs,
+// e.g. "api=100/1m:20" for 100 requests per minute with bursts of 20. The
+// period defaults to a second and the burst to the limit.
+type Service struct {
+	Prefix    string
+	Backend   string
+	KeyPrefix string
+	// Policies optionally holds the limit policies by name.
+	Policies    map[string]Policy
+	PolicySpecs map[string]string
+
+	// Redis returns the client used by the redis backend, e.g. the Pool
+	// func of the redis handler.
+	Redis func() redis.UniversalClient
+	// ClientIP optionally resolves the client IP used to key requests passing
+	// the Middleware, e.g. the ClientIP func of the http package when running
+	// behind proxies. If not set, the IP of the remote address is used.
+	ClientIP func(r *http.Request) netip.Addr
+
+	backend backend
+}
+
+func (s *Service) prefix(v string) string {
+	if s.Prefix != "" {
+		return s.Prefix + "-" + v
+	}
+	return v
+}
+
+// Name implements run.Unit.
+func (s *Service) Name() string {
+	return s.prefix("ratelimit")
+}
+
+// Initialize implements run.Initializer.
+func (s *Service) Initialize() {
+	if s.Backend == "" {
+		s.Backend = defaultBackend
+	}
+	if s.KeyPrefix == "" {
+		s.KeyPrefix = defaultKeyPrefix
+	}
+}
+
+// FlagSet implements run.Config.
+func (s *Service) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Rate limit options")
+
+	flags.StringVar(&s.Backend, s.prefix(Backend), s.Backend,
+		"rate limit backend ("+BackendLocal+" or "+BackendRedis+")")
+
+	flags.StringVar(&s.KeyPrefix, s.prefix(KeyPrefix), s.KeyPrefix,
+		"prefix of the rate limit keys in redis")
+
+	flags.StringToStringVar(&s.PolicySpecs, s.prefix(Policies), s.PolicySpecs,
+		`limit policies as "name=limit[/period][:burst]" pairs`)
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	switch s.Backend {
+	case BackendLocal:
+	case BackendRedis:
+		if s.Redis == nil {
+			mErr = multierror.Append(mErr, flag.NewValidationError(s.prefix(Backend),
+				flag.ValidationError("redis backend requires a redis client")))
+		}
+	default:
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(Backend), flag.ErrInvalidVal))
+	}
+	for name, p := range s.Policies {
+		if err := p.validate(); err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("rate limit policy %s: %w", name, err))
+		}
+	}
+	for name, spec := range s.PolicySpecs {
+		p, err := ParsePolicy(spec)
+		if err != nil {
+			mErr = multierror.Append(mErr, flag.NewValidationError(s.prefix(Policies),
+				fmt.Errorf("%s: %w", name, err)))
+			continue
+		}
+		if s.Policies == nil {
+			s.Policies = make(map[string]Policy)
+		}
+		s.Policies[name] = p
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() error {
+	switch s.Backend {
+	case BackendRedis:
+		s.backend = &redisBackend{client: s.Redis()}
+	default:
+		s.backend = &localBackend{}
+	}
+	log.Info("rate limiter configured", "backend", s.Backend, "policies", len(s.Policies))
+	return nil
+}
+
+// Take checks the key against the named policy, consuming a request if
+// allowed.
+func (s *Service) Take(ctx context.Context, policy, key string) (Result, error) {
+	p, ok := s.Policies[policy]
+	if !ok {
+		return Result{}, fmt.Errorf("%w: %s", ErrUnknownPolicy, policy)
+	}
+	return s.backend.take(ctx, s.KeyPrefix+policy+":"+key, p.interval(), p.Burst)
+}
+
+// Wait blocks until the key is allowed by the named policy or the context is
+// done.
+func (s *Service) Wait(ctx context.Context, policy, key string) error {
+	for {
+		res, err := s.Take(ctx, policy, key)
+		if err != nil || res.Allowed {
+			return err
+		}
+		t := time.NewTimer(res.RetryAfter)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Allow implements Limiter.
+func (s *Service) Allow(ctx context.Context, key string, limit float64, burst int) (bool, time.Duration, error) {
+	if limit <= 0 {
+		return true, 0, nil
+	}
+	res, err := s.backend.take(ctx, s.KeyPrefix+key,
+		time.Duration(math.Ceil(float64(time.Second)/limit)), max(burst, 1))
+	return res.Allowed, res.RetryAfter, err
+}
+
+var (
+	_ run.Initializer = (*Service)(nil)
+	_ run.Config      = (*Service)(nil)
+	_ run.PreRunner   = (*Service)(nil)
+	_ Limiter         = (*Service)(nil)
+)
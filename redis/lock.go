@@ -0,0 +1,35 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// TryLock obtains a distributed lock for the key which expires after the
+// provided time to live, e.g. for cron.WithDistributedLock. It returns false
+// if the lock is held by another instance.
+func (c *Config) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		// SetNX without expiration would hold the lock forever
+		return false, errors.New("lock time to live must be positive")
+	}
+	// the holder is informational only
+	holder, _ := os.Hostname()
+	return c.rdb.SetNX(ctx, key, holder, ttl).Result()
+}
@@ -0,0 +1,274 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// State of a circuit breaker.
+type State int
+
+// Circuit breaker states.
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+// String implements fmt.Stringer.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// Circuit breaker errors.
+var (
+	// ErrOpen is returned for calls rejected by an open circuit breaker.
+	ErrOpen = errors.New("circuit breaker is open")
+	// ErrTooManyRequests is returned for calls rejected by a half-open
+	// circuit breaker already probing the dependency.
+	ErrTooManyRequests = errors.New("circuit breaker is half-open, too many requests")
+)
+
+// Settings holds the thresholds of a circuit breaker.
+type Settings struct {
+	// FailureThreshold holds the number of consecutive failures opening the
+	// breaker. 0 disables it.
+	FailureThreshold int
+	// FailureRatio holds the ratio of failed calls within the Window opening
+	// the breaker once MinRequests is reached. 0 disables it.
+	FailureRatio float64
+	MinRequests  int
+	// Window holds the interval after which the counts of a closed breaker
+	// are reset.
+	Window time.Duration
+	// OpenTimeout holds the time an open breaker waits before allowing
+	// probe calls.
+	OpenTimeout time.Duration
+	// HalfOpenRequests holds the number of probe calls in half-open state.
+	// The breaker closes once all of them succeed.
+	HalfOpenRequests int
+}
+
+// Counts holds the call counts of the current breaker generation.
+type Counts struct {
+	Requests             int `json:"requests"`
+	Successes            int `json:"successes"`
+	Failures             int `json:"failures"`
+	ConsecutiveSuccesses int `json:"consecutiveSuccesses"`
+	ConsecutiveFailures  int `json:"consecutiveFailures"`
+	Rejected             int `json:"rejected"`
+}
+
+// Breaker implements a circuit breaker for a named dependency.
+type Breaker struct {
+	name     string
+	settings Settings
+	onChange func(b *Breaker, from, to State)
+	onReject func(b *Breaker)
+
+	mtx        sync.Mutex
+	state      State
+	generation uint64
+	counts     Counts
+	since      time.Time
+	expiry     time.Time
+}
+
+func newBreaker(name string, s Settings) *Breaker {
+	b := &Breaker{name: name, settings: s, since: time.Now()}
+	b.newGeneration(b.since)
+	return b
+}
+
+// Name returns the name of the breaker.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	state, _ := b.current(time.Now())
+	return state
+}
+
+// Counts returns the counts of the current breaker generation.
+func (b *Breaker) Counts() Counts {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.current(time.Now())
+	return b.counts
+}
+
+// Allow checks if a call is allowed. If so, done must be called with the
+// outcome of the call.
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	b.mtx.Lock()
+	now := time.Now()
+	state, generation := b.current(now)
+	switch {
+	case state == StateOpen:
+		err = ErrOpen
+	case state == StateHalfOpen && b.counts.Requests >= b.settings.HalfOpenRequests:
+		err = ErrTooManyRequests
+	}
+	if err != nil {
+		b.counts.Rejected++
+		b.mtx.Unlock()
+		if b.onReject != nil {
+			b.onReject(b)
+		}
+		return nil, err
+	}
+	b.counts.Requests++
+	b.mtx.Unlock()
+
+	return func(success bool) {
+		b.done(generation, success)
+	}, nil
+}
+
+// Execute runs fn if allowed by the breaker, recording its outcome. Errors
+// of canceled contexts are not counted as failures.
+func (b *Breaker) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn(ctx)
+	done(err == nil || errors.Is(err, context.Canceled))
+	return err
+}
+
+// Execute runs fn if allowed by the breaker, recording its outcome, e.g. for
+// database queries returning a value.
+func Execute[T any](ctx context.Context, b *Breaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	var v T
+	err := b.Execute(ctx, func(ctx context.Context) (err error) {
+		v, err = fn(ctx)
+		return err
+	})
+	return v, err
+}
+
+func (b *Breaker) done(generation uint64, success bool) {
+	b.mtx.Lock()
+	now := time.Now()
+	state, current := b.current(now)
+	if generation != current {
+		// outcome of a call started in a previous state
+		b.mtx.Unlock()
+		return
+	}
+
+	var to State
+	c := &b.counts
+	if success {
+		c.Successes++
+		c.ConsecutiveSuccesses++
+		c.ConsecutiveFailures = 0
+		if state == StateHalfOpen && c.ConsecutiveSuccesses >= b.settings.HalfOpenRequests {
+			to = StateClosed
+		} else {
+			to = state
+		}
+	} else {
+		c.Failures++
+		c.ConsecutiveFailures++
+		c.ConsecutiveSuccesses = 0
+		switch {
+		case state == StateHalfOpen, b.trip():
+			to = StateOpen
+		default:
+			to = state
+		}
+	}
+
+	changed := to != state
+	if changed {
+		b.setState(to, now)
+	}
+	b.mtx.Unlock()
+	if changed && b.onChange != nil {
+		b.onChange(b, state, to)
+	}
+}
+
+// trip returns true if the counts exceed the thresholds.
+func (b *Breaker) trip() bool {
+	s, c := b.settings, b.counts
+	if s.FailureThreshold > 0 && c.ConsecutiveFailures >= s.FailureThreshold {
+		return true
+	}
+	return s.FailureRatio > 0 && c.Requests >= s.MinRequests &&
+		float64(c.Failures)/float64(c.Requests) >= s.FailureRatio
+}
+
+// current returns the state and generation, moving an open breaker to
+// half-open once its timeout expired and resetting the counts of a closed
+// breaker once its window expired.
+func (b *Breaker) current(now time.Time) (State, uint64) {
+	switch b.state {
+	case StateClosed:
+		if !b.expiry.IsZero() && now.After(b.expiry) {
+			b.newGeneration(now)
+		}
+	case StateOpen:
+		if now.After(b.expiry) {
+			b.setState(StateHalfOpen, now)
+			if b.onChange != nil {
+				// called with the lock held, callbacks must not use the
+				// breaker
+				b.onChange(b, StateOpen, StateHalfOpen)
+			}
+		}
+	}
+	return b.state, b.generation
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	b.state = state
+	b.since = now
+	b.newGeneration(now)
+}
+
+func (b *Breaker) newGeneration(now time.Time) {
+	b.generation++
+	b.counts = Counts{}
+	switch b.state {
+	case StateClosed:
+		b.expiry = time.Time{}
+		if b.settings.Window > 0 {
+			b.expiry = now.Add(b.settings.Window)
+		}
+	case StateOpen:
+		b.expiry = now.Add(b.settings.OpenTimeout)
+	case StateHalfOpen:
+		b.expiry = time.Time{}
+	}
+}
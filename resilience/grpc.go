@@ -0,0 +1,75 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failureCodes holds the status codes indicating an unhealthy dependency.
+var failureCodes = map[codes.Code]bool{
+	codes.Unknown:           true,
+	codes.DeadlineExceeded:  true,
+	codes.ResourceExhausted: true,
+	codes.Internal:          true,
+	codes.Unavailable:       true,
+}
+
+// UnaryClientInterceptor returns an interceptor guarding calls with the
+// breaker of the named dependency, or of the connection target if name is
+// empty. Rejected calls fail with codes.Unavailable.
+func (r *Registry) UnaryClientInterceptor(name string) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context, method string, req, reply any,
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		done, err := r.breaker(name, cc).Allow()
+		if err != nil {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		done(!failureCodes[status.Code(err)])
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor guarding the creation of
+// streams with the breaker of the named dependency, or of the connection
+// target if name is empty.
+func (r *Registry) StreamClientInterceptor(name string) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+		method string, streamer grpc.Streamer, opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		done, err := r.breaker(name, cc).Allow()
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		done(!failureCodes[status.Code(err)])
+		return cs, err
+	}
+}
+
+func (r *Registry) breaker(name string, cc *grpc.ClientConn) *Breaker {
+	if name == "" {
+		name = cc.Target()
+	}
+	return r.Get(name)
+}
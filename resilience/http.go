@@ -0,0 +1,89 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Transport wraps the RoundTripper with the breaker of the named dependency.
+// If name is empty, a breaker per request host is used. Transport errors and
+// 5xx responses count as failures. Rejected requests fail with ErrOpen or
+// ErrTooManyRequests.
+func (r *Registry) Transport(name string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		n := name
+		if n == "" {
+			n = req.URL.Host
+		}
+		done, err := r.Get(n).Allow()
+		if err != nil {
+			return nil, err
+		}
+		res, err := next.RoundTrip(req)
+		done(err == nil && res.StatusCode < http.StatusInternalServerError ||
+			err != nil && req.Context().Err() != nil)
+		return res, err
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// BreakerStatus holds the status of a breaker as served by the status
+// endpoint.
+type BreakerStatus struct {
+	Name   string    `json:"name"`
+	State  string    `json:"state"`
+	Since  time.Time `json:"since"`
+	Counts Counts    `json:"counts"`
+}
+
+// Status returns the status of the breakers sorted by name.
+func (r *Registry) Status() []BreakerStatus {
+	breakers := r.Breakers()
+	status := make([]BreakerStatus, 0, len(breakers))
+	for _, b := range breakers {
+		b.mtx.Lock()
+		state, _ := b.current(time.Now())
+		status = append(status, BreakerStatus{
+			Name:   b.name,
+			State:  state.String(),
+			Since:  b.since,
+			Counts: b.counts,
+		})
+		b.mtx.Unlock()
+	}
+	return status
+}
+
+// Handler returns the status endpoint serving the breaker status as JSON,
+// e.g. for mounting on an admin server.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(r.Status())
+	})
+}
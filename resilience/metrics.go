@@ -0,0 +1,82 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resilience
+
+import (
+	"github.com/basvanbeek/telemetry"
+)
+
+// metrics holds the circuit breaker metrics.
+type metrics struct {
+	name        telemetry.Label
+	toState     telemetry.Label
+	stateGauge  telemetry.Metric
+	transitions telemetry.Metric
+	rejections  telemetry.Metric
+}
+
+func newMetrics(sink telemetry.MetricSink) *metrics {
+	name := sink.NewLabel("name")
+	toState := sink.NewLabel("state")
+	return &metrics{
+		name:    name,
+		toState: toState,
+		stateGauge: sink.NewGauge("circuit_breaker_state",
+			"State of the circuit breaker: 0 closed, 1 half-open, 2 open.",
+			telemetry.WithLabels(name)),
+		transitions: sink.NewSum("circuit_breaker_transitions_total",
+			"Total number of circuit breaker state transitions.",
+			telemetry.WithLabels(name, toState)),
+		rejections: sink.NewSum("circuit_breaker_rejected_total",
+			"Total number of calls rejected by the circuit breaker.",
+			telemetry.WithLabels(name)),
+	}
+}
+
+// setupMetrics creates the metrics using the configured MetricSink or, if not
+// set, the global MetricSink as soon as it is registered.
+func (r *Registry) setupMetrics() {
+	if r.MetricSink != nil {
+		r.metrics.Store(newMetrics(r.MetricSink))
+		return
+	}
+	telemetry.ToGlobalMetricSink(func(sink telemetry.MetricSink) {
+		r.metrics.Store(newMetrics(sink))
+	})
+}
+
+// state records the state of the breaker.
+func (m *metrics) state(name string, s State) {
+	if m == nil {
+		return
+	}
+	m.stateGauge.With(m.name.Upsert(name)).Record(float64(s))
+}
+
+// transition records a state transition of the breaker.
+func (m *metrics) transition(name string, to State) {
+	if m == nil {
+		return
+	}
+	m.transitions.With(m.name.Upsert(name), m.toState.Upsert(to.String())).Increment()
+}
+
+// rejected records a call rejected by the breaker.
+func (m *metrics) rejected(name string) {
+	if m == nil {
+		return
+	}
+	m.rejections.With(m.name.Upsert(name)).Increment()
+}
@@ -0,0 +1,264 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resilience provides a registry of circuit breakers for named
+// dependencies, with wrappers for HTTP clients, gRPC clients and database
+// calls, state metrics and a status endpoint.
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("resilience", "circuit breakers")
+
+// package flags.
+const (
+	defaultFailureThreshold = 5
+	defaultMinRequests      = 20
+	defaultWindow           = time.Minute
+	defaultOpenTimeout      = 30 * time.Second
+	defaultHalfOpenRequests = 1
+
+	FailureThreshold = "circuit-breaker-failure-threshold"
+	FailureRatio     = "circuit-breaker-failure-ratio"
+	MinRequests      = "circuit-breaker-min-requests"
+	Window           = "circuit-breaker-window"
+	OpenTimeout      = "circuit-breaker-open-timeout"
+	HalfOpenRequests = "circuit-breaker-half-open-requests"
+	Overrides        = "circuit-breaker-overrides"
+)
+
+// Registry implements run.Config for a shared registry of circuit breakers,
+// one per named dependency. Breakers use the default Settings unless
+// overridden per name through Settings or the overrides flag, which takes
+// "name=failure-threshold/open-timeout" pairs, e.g. "payments=3/10s".
+type Registry struct {
+	Prefix string
+
+	Defaults Settings
+	// Settings optionally holds the breaker settings by name.
+	Settings  map[string]Settings
+	Overrides map[string]string
+
+	// MetricSink holds the sink used for the breaker metrics. If not set, the
+	// global telemetry MetricSink is used once registered.
+	MetricSink telemetry.MetricSink
+
+	mtx      sync.RWMutex
+	breakers map[string]*Breaker
+	metrics  atomic.Pointer[metrics]
+	once     sync.Once
+}
+
+func (r *Registry) prefix(s string) string {
+	if r.Prefix != "" {
+		return r.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (r *Registry) Name() string {
+	return r.prefix("circuit-breakers")
+}
+
+// Initialize implements run.Initializer.
+func (r *Registry) Initialize() {
+	r.Defaults = Settings{
+		FailureThreshold: defaultFailureThreshold,
+		MinRequests:      defaultMinRequests,
+		Window:           defaultWindow,
+		OpenTimeout:      defaultOpenTimeout,
+		HalfOpenRequests: defaultHalfOpenRequests,
+	}
+}
+
+// FlagSet implements run.Config.
+func (r *Registry) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Circuit breaker options")
+
+	flags.IntVar(&r.Defaults.FailureThreshold, r.prefix(FailureThreshold),
+		r.Defaults.FailureThreshold, "consecutive failures opening a breaker (0 disables)")
+
+	flags.Float64Var(&r.Defaults.FailureRatio, r.prefix(FailureRatio),
+		r.Defaults.FailureRatio, "failure ratio within the window opening a breaker (0 disables)")
+
+	flags.IntVar(&r.Defaults.MinRequests, r.prefix(MinRequests),
+		r.Defaults.MinRequests, "min. calls within the window before the failure ratio applies")
+
+	flags.DurationVar(&r.Defaults.Window, r.prefix(Window),
+		r.Defaults.Window, "interval after which the counts of a closed breaker are reset")
+
+	flags.DurationVar(&r.Defaults.OpenTimeout, r.prefix(OpenTimeout),
+		r.Defaults.OpenTimeout, "time an open breaker waits before probing the dependency")
+
+	flags.IntVar(&r.Defaults.HalfOpenRequests, r.prefix(HalfOpenRequests),
+		r.Defaults.HalfOpenRequests, "probe calls which must succeed to close a half-open breaker")
+
+	flags.StringToStringVar(&r.Overrides, r.prefix(Overrides),
+		r.Overrides, `per dependency "name=failure-threshold/open-timeout" settings`)
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (r *Registry) Validate() error {
+	var mErr error
+
+	if err := r.Defaults.validate(); err != nil {
+		mErr = multierror.Append(mErr, flag.NewValidationError(r.prefix(FailureThreshold), err))
+	}
+	for name, s := range r.Settings {
+		if err := s.validate(); err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("circuit breaker %s: %w", name, err))
+		}
+	}
+	for name, v := range r.Overrides {
+		s, err := r.parseOverride(v)
+		if err == nil {
+			err = s.validate()
+		}
+		if err != nil {
+			mErr = multierror.Append(mErr, flag.NewValidationError(r.prefix(Overrides),
+				fmt.Errorf("%s: %w", name, err)))
+			continue
+		}
+		if r.Settings == nil {
+			r.Settings = make(map[string]Settings)
+		}
+		r.Settings[name] = s
+	}
+
+	return mErr
+}
+
+// parseOverride parses a "failure-threshold/open-timeout" override.
+func (r *Registry) parseOverride(v string) (Settings, error) {
+	threshold, timeout, ok := strings.Cut(v, "/")
+	if !ok {
+		return Settings{}, flag.ValidationError(`expected "failure-threshold/open-timeout"`)
+	}
+	s := r.Defaults
+	var err error
+	if s.FailureThreshold, err = strconv.Atoi(threshold); err != nil {
+		return Settings{}, err
+	}
+	if s.OpenTimeout, err = time.ParseDuration(timeout); err != nil {
+		return Settings{}, err
+	}
+	return s, nil
+}
+
+func (s Settings) validate() error {
+	switch {
+	case s.FailureThreshold < 0:
+		return flag.ValidationError("invalid failure threshold")
+	case s.FailureRatio < 0 || s.FailureRatio > 1:
+		return flag.ValidationError("failure ratio must be between 0 and 1")
+	case s.FailureThreshold == 0 && s.FailureRatio == 0:
+		return flag.ValidationError("either failure threshold or ratio is required")
+	case s.MinRequests < 0 || s.Window < 0:
+		return flag.ValidationError("invalid failure ratio window")
+	case s.OpenTimeout <= 0:
+		return flag.ValidationError("invalid open timeout")
+	case s.HalfOpenRequests <= 0:
+		return flag.ValidationError("invalid number of half-open requests")
+	}
+	return nil
+}
+
+// Get returns the breaker for the named dependency, creating it on first
+// use.
+func (r *Registry) Get(name string) *Breaker {
+	r.mtx.RLock()
+	b, ok := r.breakers[name]
+	r.mtx.RUnlock()
+	if ok {
+		return b
+	}
+
+	r.once.Do(r.setupMetrics)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if b, ok = r.breakers[name]; ok {
+		return b
+	}
+	s, ok := r.Settings[name]
+	if !ok {
+		s = r.Defaults
+		if s.HalfOpenRequests == 0 && s.OpenTimeout == 0 {
+			// registry used without the run.Group lifecycle
+			r.Initialize()
+			s = r.Defaults
+		}
+	}
+	b = newBreaker(name, s)
+	b.onChange = r.changed
+	b.onReject = r.rejected
+	if r.breakers == nil {
+		r.breakers = make(map[string]*Breaker)
+	}
+	r.breakers[name] = b
+	r.metrics.Load().state(name, StateClosed)
+	return b
+}
+
+// Do runs fn guarded by the breaker of the named dependency, e.g. for
+// database calls. Use the generic Execute for calls returning a value.
+func (r *Registry) Do(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	return r.Get(name).Execute(ctx, fn)
+}
+
+// Breakers returns the breakers sorted by name.
+func (r *Registry) Breakers() []*Breaker {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	breakers := make([]*Breaker, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	sort.Slice(breakers, func(i, j int) bool { return breakers[i].name < breakers[j].name })
+	return breakers
+}
+
+func (r *Registry) changed(b *Breaker, from, to State) {
+	log.Info("circuit breaker state changed", "name", b.name, "from", from.String(), "to", to.String())
+	m := r.metrics.Load()
+	m.state(b.name, to)
+	m.transition(b.name, to)
+}
+
+func (r *Registry) rejected(b *Breaker) {
+	r.metrics.Load().rejected(b.name)
+}
+
+var (
+	_ run.Initializer = (*Registry)(nil)
+	_ run.Config      = (*Registry)(nil)
+)
@@ -0,0 +1,381 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package smtp provides a run handler for sending mail through an SMTP
+// server with connection pooling and an optional outbound queue.
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("smtp", "SMTP mailer")
+
+// Supported TLS modes.
+const (
+	TLSModeStartTLS = "starttls"
+	TLSModeImplicit = "implicit"
+	TLSModeNone     = "none"
+)
+
+// package flags.
+const (
+	defaultHost         = "localhost"
+	defaultPort         = 587
+	defaultTimeout      = 30 * time.Second
+	defaultMaxConns     = 2
+	defaultIdleTimeout  = 30 * time.Second
+	defaultQueueWorkers = 2
+	defaultMaxAttempts  = 5
+	defaultRetryBackoff = 5 * time.Second
+	defaultDrainTimeout = 30 * time.Second
+
+	Host         = "smtp-host"
+	Port         = "smtp-port"
+	UserName     = "smtp-username"
+	Password     = "smtp-password"
+	TLSMode      = "smtp-tls-mode"
+	From         = "smtp-from"
+	Timeout      = "smtp-timeout"
+	MaxConns     = "smtp-max-conns"
+	IdleTimeout  = "smtp-idle-timeout"
+	TemplateDir  = "smtp-template-dir"
+	QueueSize    = "smtp-queue-size"
+	QueueWorkers = "smtp-queue-workers"
+	MaxAttempts  = "smtp-max-attempts"
+	RetryBackoff = "smtp-retry-backoff"
+	DrainTimeout = "smtp-drain-timeout"
+)
+
+var (
+	// ErrQueueFull is returned by Send if the outbound queue is full.
+	ErrQueueFull = errors.New("smtp queue full")
+	// ErrClosed is returned by Send after the mailer shut down.
+	ErrClosed = errors.New("smtp mailer closed")
+)
+
+// Config implements run.Config to allow configuration of an SMTP mailer.
+//
+// If QueueSize is set, Send queues messages which are delivered by
+// background workers with retries. The queue is drained on graceful
+// shutdown, bounded by DrainTimeout.
+type Config struct {
+	Prefix string
+
+	Host     string
+	Port     int
+	UserName string
+	Password string
+	// TLSMode holds the TLS mode: starttls, implicit or none.
+	TLSMode     string
+	From        string
+	Timeout     time.Duration
+	MaxConns    int
+	IdleTimeout time.Duration
+	// TemplateDir holds the directory with message templates. HTML templates
+	// need the .html extension, text templates the .txt extension.
+	TemplateDir  string
+	QueueSize    int
+	QueueWorkers int
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	DrainTimeout time.Duration
+
+	pool   *pool
+	html   *htmltemplate.Template
+	text   *texttemplate.Template
+	mtx    sync.RWMutex
+	queue  chan *Message
+	closed bool
+}
+
+func (c *Config) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("smtp")
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	if c.Host == "" {
+		c.Host = defaultHost
+	}
+	if c.Port == 0 {
+		c.Port = defaultPort
+	}
+	if envPassword := os.Getenv("SMTP_PASSWORD"); envPassword != "" {
+		c.Password = envPassword
+	}
+	if c.TLSMode == "" {
+		c.TLSMode = TLSModeStartTLS
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.MaxConns == 0 {
+		c.MaxConns = defaultMaxConns
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = defaultIdleTimeout
+	}
+	if c.QueueWorkers == 0 {
+		c.QueueWorkers = defaultQueueWorkers
+	}
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+	if c.RetryBackoff == 0 {
+		c.RetryBackoff = defaultRetryBackoff
+	}
+	if c.DrainTimeout == 0 {
+		c.DrainTimeout = defaultDrainTimeout
+	}
+
+	flags := run.NewFlagSet("SMTP options")
+
+	flags.StringVar(&c.Host, c.prefix(Host),
+		c.Host, "SMTP server host")
+
+	flags.IntVar(&c.Port, c.prefix(Port),
+		c.Port, "SMTP server port")
+
+	flags.StringVar(&c.UserName, c.prefix(UserName),
+		c.UserName, "username for PLAIN authentication")
+
+	flags.SensitiveStringVar(&c.Password, c.prefix(Password),
+		c.Password, "password for PLAIN authentication")
+
+	flags.StringVar(&c.TLSMode, c.prefix(TLSMode),
+		c.TLSMode, "TLS mode (starttls, implicit or none)")
+
+	flags.StringVar(&c.From, c.prefix(From),
+		c.From, "default sender address")
+
+	flags.DurationVar(&c.Timeout, c.prefix(Timeout),
+		c.Timeout, "timeout for sending a message")
+
+	flags.IntVar(&c.MaxConns, c.prefix(MaxConns),
+		c.MaxConns, "max. pooled connections")
+
+	flags.DurationVar(&c.IdleTimeout, c.prefix(IdleTimeout),
+		c.IdleTimeout, "max. idle time of pooled connections")
+
+	flags.StringVar(&c.TemplateDir, c.prefix(TemplateDir),
+		c.TemplateDir, "directory holding *.html and *.txt message templates")
+
+	flags.IntVar(&c.QueueSize, c.prefix(QueueSize),
+		c.QueueSize, "size of the outbound queue (0 sends synchronously)")
+
+	flags.IntVar(&c.QueueWorkers, c.prefix(QueueWorkers),
+		c.QueueWorkers, "number of queue workers")
+
+	flags.IntVar(&c.MaxAttempts, c.prefix(MaxAttempts),
+		c.MaxAttempts, "max. delivery attempts of queued messages")
+
+	flags.DurationVar(&c.RetryBackoff, c.prefix(RetryBackoff),
+		c.RetryBackoff, "backoff between delivery attempts of queued messages")
+
+	flags.DurationVar(&c.DrainTimeout, c.prefix(DrainTimeout),
+		c.DrainTimeout, "max. time to drain the queue on shutdown")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if c.Host == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Host), flag.ErrRequired))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Port), flag.ErrInvalidVal))
+	}
+	switch c.TLSMode {
+	case TLSModeStartTLS, TLSModeImplicit, TLSModeNone:
+	default:
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(TLSMode), flag.ErrInvalidVal))
+	}
+	if c.From != "" {
+		if _, err := mail.ParseAddress(c.From); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(From), flag.ErrInvalidVal))
+		}
+	}
+	if c.MaxConns < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(MaxConns), flag.ErrInvalidVal))
+	}
+	if c.TemplateDir != "" {
+		if fi, err := os.Stat(c.TemplateDir); err != nil || !fi.IsDir() {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(TemplateDir), flag.ErrInvalidPath))
+		}
+	}
+	if c.QueueSize < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(QueueSize), flag.ErrInvalidVal))
+	}
+	if c.QueueSize > 0 {
+		if c.QueueWorkers < 1 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(QueueWorkers), flag.ErrInvalidVal))
+		}
+		if c.MaxAttempts < 1 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(MaxAttempts), flag.ErrInvalidVal))
+		}
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner. It loads the templates and verifies the
+// SMTP server can be reached.
+func (c *Config) PreRun() error {
+	if err := c.loadTemplates(); err != nil {
+		return err
+	}
+
+	c.pool = &pool{
+		addr:        net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		host:        c.Host,
+		userName:    c.UserName,
+		password:    c.Password,
+		tlsMode:     c.TLSMode,
+		idleTimeout: c.IdleTimeout,
+		idle:        make(chan *conn, c.MaxConns),
+		slots:       make(chan struct{}, c.MaxConns),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to connect to SMTP server: %w", err)
+	}
+	c.pool.put(cn, nil)
+
+	if c.QueueSize > 0 {
+		c.queue = make(chan *Message, c.QueueSize)
+	}
+	return nil
+}
+
+func (c *Config) loadTemplates() error {
+	if c.TemplateDir == "" {
+		return nil
+	}
+	if files, _ := filepath.Glob(filepath.Join(c.TemplateDir, "*.html")); len(files) > 0 {
+		t, err := htmltemplate.ParseFiles(files...)
+		if err != nil {
+			return fmt.Errorf("unable to parse HTML templates: %w", err)
+		}
+		c.html = t
+	}
+	if files, _ := filepath.Glob(filepath.Join(c.TemplateDir, "*.txt")); len(files) > 0 {
+		t, err := texttemplate.ParseFiles(files...)
+		if err != nil {
+			return fmt.Errorf("unable to parse text templates: %w", err)
+		}
+		c.text = t
+	}
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. It runs the queue workers and
+// drains the queue on shutdown.
+func (c *Config) ServeContext(ctx context.Context) error {
+	if c.queue == nil {
+		<-ctx.Done()
+		c.pool.close()
+		return nil
+	}
+
+	drainCtx, cancelDrain := context.WithCancel(context.Background())
+	defer cancelDrain()
+
+	var wg sync.WaitGroup
+	for range c.QueueWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range c.queue {
+				c.deliver(drainCtx, msg)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	c.mtx.Lock()
+	c.closed = true
+	close(c.queue)
+	c.mtx.Unlock()
+
+	timer := time.AfterFunc(c.DrainTimeout, cancelDrain)
+	defer timer.Stop()
+	wg.Wait()
+
+	c.pool.close()
+	return nil
+}
+
+// deliver sends a queued message, retrying on failure.
+func (c *Config) deliver(ctx context.Context, msg *Message) {
+	var err error
+	for attempt := 1; attempt <= c.MaxAttempts; attempt++ {
+		if err = c.send(ctx, msg); err == nil {
+			return
+		}
+		if ctx.Err() != nil || attempt == c.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(c.RetryBackoff * time.Duration(attempt)):
+		}
+	}
+	log.Error("unable to deliver message", err, "subject", msg.Subject, "to", msg.To)
+}
+
+var (
+	_ run.Config         = (*Config)(nil)
+	_ run.PreRunner      = (*Config)(nil)
+	_ run.ServiceContext = (*Config)(nil)
+)
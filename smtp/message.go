@@ -0,0 +1,338 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoRecipients is returned if a message has no recipients.
+	ErrNoRecipients = errors.New("message has no recipients")
+	// ErrNoSender is returned if neither the message nor the Config provide
+	// a sender.
+	ErrNoSender = errors.New("message has no sender")
+	// ErrTemplateNotFound is returned if no template with the message
+	// template name exists.
+	ErrTemplateNotFound = errors.New("template not found")
+)
+
+// Message holds an email message.
+type Message struct {
+	// From overrides the configured sender if set.
+	From    string
+	ReplyTo string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	// Text and HTML hold the message bodies. At least one should be set,
+	// unless Template is used.
+	Text string
+	HTML string
+	// Template holds the name of the templates to render the bodies with,
+	// i.e. <Template>.html and <Template>.txt from the template directory,
+	// using Data.
+	Template    string
+	Data        any
+	Attachments []Attachment
+	Headers     map[string]string
+}
+
+// Attachment holds a file attached to a message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Send sends the message. If the outbound queue is enabled the message is
+// queued and delivered asynchronously; template and address errors are
+// still returned directly.
+func (c *Config) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = c.From
+	}
+	if msg.From == "" {
+		return ErrNoSender
+	}
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		return ErrNoRecipients
+	}
+	if err := c.render(&msg); err != nil {
+		return err
+	}
+
+	if c.queue == nil {
+		return c.send(ctx, &msg)
+	}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.closed {
+		return ErrClosed
+	}
+	select {
+	case c.queue <- &msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// render executes the message templates.
+func (c *Config) render(msg *Message) error {
+	if msg.Template == "" {
+		return nil
+	}
+	var found bool
+	if c.html != nil {
+		if t := c.html.Lookup(msg.Template + ".html"); t != nil {
+			var b strings.Builder
+			if err := t.Execute(&b, msg.Data); err != nil {
+				return fmt.Errorf("unable to render HTML template %s: %w", msg.Template, err)
+			}
+			msg.HTML, found = b.String(), true
+		}
+	}
+	if c.text != nil {
+		if t := c.text.Lookup(msg.Template + ".txt"); t != nil {
+			var b strings.Builder
+			if err := t.Execute(&b, msg.Data); err != nil {
+				return fmt.Errorf("unable to render text template %s: %w", msg.Template, err)
+			}
+			msg.Text, found = b.String(), true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, msg.Template)
+	}
+	msg.Template = ""
+	return nil
+}
+
+// send delivers the message using a pooled connection.
+func (c *Config) send(ctx context.Context, msg *Message) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+	var rcpts []string
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, r := range list {
+			addr, err := mail.ParseAddress(r)
+			if err != nil {
+				return fmt.Errorf("invalid recipient %q: %w", r, err)
+			}
+			rcpts = append(rcpts, addr.Address)
+		}
+	}
+	data, err := msg.build()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		return err
+	}
+	err = cn.send(from.Address, rcpts, data)
+	c.pool.put(cn, err)
+	return err
+}
+
+func (cn *conn) send(from string, rcpts []string, data []byte) error {
+	if err := cn.client.Mail(from); err != nil {
+		return err
+	}
+	for _, r := range rcpts {
+		if err := cn.client.Rcpt(r); err != nil {
+			return err
+		}
+	}
+	w, err := cn.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// build renders the message in MIME format.
+func (msg *Message) build() ([]byte, error) {
+	var buf bytes.Buffer
+	h := textproto.MIMEHeader{}
+	h.Set("From", msg.From)
+	if len(msg.To) > 0 {
+		h.Set("To", strings.Join(msg.To, ", "))
+	}
+	if len(msg.Cc) > 0 {
+		h.Set("Cc", strings.Join(msg.Cc, ", "))
+	}
+	if msg.ReplyTo != "" {
+		h.Set("Reply-To", msg.ReplyTo)
+	}
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	h.Set("Date", time.Now().Format(time.RFC1123Z))
+	h.Set("Message-ID", messageID(msg.From))
+	h.Set("MIME-Version", "1.0")
+	for k, v := range msg.Headers {
+		h.Set(k, v)
+	}
+
+	bh, body, err := msg.body()
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Attachments) == 0 {
+		for k, v := range bh {
+			h[k] = v
+		}
+		writeHeader(&buf, h)
+		buf.Write(body)
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	h.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	writeHeader(&buf, h)
+	pw, err := mw.CreatePart(bh)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = pw.Write(body); err != nil {
+		return nil, err
+	}
+	for _, a := range msg.Attachments {
+		ct := a.ContentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		pw, err = mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(ct, map[string]string{"name": a.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err = writeBase64(pw, a.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err = mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// body returns the header and content of the text and/or HTML body.
+func (msg *Message) body() (textproto.MIMEHeader, []byte, error) {
+	var buf bytes.Buffer
+	if msg.Text == "" || msg.HTML == "" {
+		ct, body := "text/plain; charset=utf-8", msg.Text
+		if msg.HTML != "" {
+			ct, body = "text/html; charset=utf-8", msg.HTML
+		}
+		h := textproto.MIMEHeader{
+			"Content-Type":              {ct},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		}
+		err := writeQuotedPrintable(&buf, body)
+		return h, buf.Bytes(), err
+	}
+
+	mw := multipart.NewWriter(&buf)
+	h := textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + mw.Boundary()},
+	}
+	for _, part := range []struct{ ct, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.ct},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = writeQuotedPrintable(pw, part.body); err != nil {
+			return nil, nil, err
+		}
+	}
+	err := mw.Close()
+	return h, buf.Bytes(), err
+}
+
+func writeHeader(w io.Writer, h textproto.MIMEHeader) {
+	for k, vs := range h {
+		for _, v := range vs {
+			_, _ = fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+	_, _ = io.WriteString(w, "\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, s); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeBase64(w io.Writer, data []byte) error {
+	const lineLen = 76
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 0 {
+		n := min(len(enc), lineLen)
+		if _, err := io.WriteString(w, enc[:n]+"\r\n"); err != nil {
+			return err
+		}
+		enc = enc[n:]
+	}
+	return nil
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
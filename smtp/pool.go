@@ -0,0 +1,168 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// errNoStartTLS is returned if the server does not support STARTTLS.
+var errNoStartTLS = errors.New("smtp server does not support STARTTLS")
+
+// conn holds a pooled SMTP connection.
+type conn struct {
+	client *smtp.Client
+	nc     net.Conn
+	used   time.Time
+}
+
+// pool holds up to cap(slots) SMTP connections of which idle ones are kept
+// for reuse.
+type pool struct {
+	addr        string
+	host        string
+	userName    string
+	password    string
+	tlsMode     string
+	idleTimeout time.Duration
+	idle        chan *conn
+	slots       chan struct{}
+}
+
+// get returns an idle connection or dials a new one. The connection needs to
+// be returned with put.
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	deadline, _ := ctx.Deadline()
+	for {
+		select {
+		case cn := <-p.idle:
+			if time.Since(cn.used) > p.idleTimeout {
+				cn.close()
+				continue
+			}
+			_ = cn.nc.SetDeadline(deadline)
+			if err := cn.client.Noop(); err != nil {
+				cn.close()
+				continue
+			}
+			return cn, nil
+		default:
+		}
+		cn, err := p.dial(ctx)
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		_ = cn.nc.SetDeadline(deadline)
+		return cn, nil
+	}
+}
+
+// put returns the connection to the pool. Connections which encountered an
+// error are closed.
+func (p *pool) put(cn *conn, err error) {
+	defer func() { <-p.slots }()
+	if err == nil {
+		err = cn.client.Reset()
+	}
+	if err != nil {
+		cn.close()
+		return
+	}
+	cn.used = time.Now()
+	_ = cn.nc.SetDeadline(time.Time{})
+	select {
+	case p.idle <- cn:
+	default:
+		cn.quit()
+	}
+}
+
+func (p *pool) dial(ctx context.Context) (*conn, error) {
+	var (
+		nc  net.Conn
+		err error
+		cfg = &tls.Config{ServerName: p.host, MinVersion: tls.VersionTLS12}
+	)
+	if p.tlsMode == TLSModeImplicit {
+		nc, err = (&tls.Dialer{Config: cfg}).DialContext(ctx, "tcp", p.addr)
+	} else {
+		nc, err = (&net.Dialer{}).DialContext(ctx, "tcp", p.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = nc.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(nc, p.host)
+	if err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	cn := &conn{client: client, nc: nc}
+	if p.tlsMode == TLSModeStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			cn.close()
+			return nil, errNoStartTLS
+		}
+		if err = client.StartTLS(cfg); err != nil {
+			cn.close()
+			return nil, err
+		}
+	}
+	if p.userName != "" {
+		if err = client.Auth(smtp.PlainAuth("", p.userName, p.password, p.host)); err != nil {
+			cn.close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// close closes all idle connections.
+func (p *pool) close() {
+	for {
+		select {
+		case cn := <-p.idle:
+			cn.quit()
+		default:
+			return
+		}
+	}
+}
+
+func (cn *conn) quit() {
+	_ = cn.nc.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := cn.client.Quit(); err != nil {
+		cn.close()
+	}
+}
+
+func (cn *conn) close() {
+	_ = cn.client.Close()
+}
@@ -0,0 +1,64 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sns
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// Attributes holds SNS message attributes.
+type Attributes map[string]types.MessageAttributeValue
+
+// String sets a string attribute.
+func (a Attributes) String(name, value string) Attributes {
+	a[name] = types.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+	return a
+}
+
+// Number sets a numeric attribute.
+func (a Attributes) Number(name string, value float64) Attributes {
+	a[name] = types.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.FormatFloat(value, 'f', -1, 64)),
+	}
+	return a
+}
+
+// StringArray sets a string array attribute, which can be used in
+// subscription filter policies.
+func (a Attributes) StringArray(name string, values ...string) Attributes {
+	b, _ := json.Marshal(values)
+	a[name] = types.MessageAttributeValue{
+		DataType:    aws.String("String.Array"),
+		StringValue: aws.String(string(b)),
+	}
+	return a
+}
+
+// Binary sets a binary attribute.
+func (a Attributes) Binary(name string, value []byte) Attributes {
+	a[name] = types.MessageAttributeValue{
+		DataType:    aws.String("Binary"),
+		BinaryValue: value,
+	}
+	return a
+}
@@ -0,0 +1,264 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sns provides a run handler for publishing messages to AWS SNS and
+// optionally events to AWS EventBridge.
+package sns
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	awssns "github.com/aws/aws-sdk-go-v2/service/sns"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("sns", "SNS/EventBridge publisher")
+
+// package flags.
+const (
+	defaultMaxAttempts   = 5
+	defaultMaxBackoff    = 20 * time.Second
+	defaultFlushInterval = time.Second
+	defaultFlushTimeout  = 10 * time.Second
+	defaultBufferSize    = 1000
+
+	Region        = "sns-region"
+	Endpoint      = "sns-endpoint"
+	TopicARN      = "sns-topic-arn"
+	EventBus      = "sns-eventbridge-bus"
+	EventSource   = "sns-eventbridge-source"
+	MaxAttempts   = "sns-max-attempts"
+	MaxBackoff    = "sns-max-backoff"
+	Buffered      = "sns-buffered"
+	BufferSize    = "sns-buffer-size"
+	FlushInterval = "sns-flush-interval"
+	FlushTimeout  = "sns-flush-timeout"
+)
+
+// Config implements run.Config to allow configuration of an SNS publisher
+// with optional EventBridge support. AWS credentials are resolved through the
+// default AWS credential chain.
+//
+// In buffered mode Publish and PutEvent queue messages locally, which are
+// sent in batches every flush interval and flushed on graceful shutdown.
+type Config struct {
+	Prefix string
+
+	Region   string
+	Endpoint string
+	// TopicARN holds the default topic messages are published to.
+	TopicARN string
+	// EventBus holds the EventBridge event bus; EventBridge support is
+	// enabled if set.
+	EventBus      string
+	EventSource   string
+	MaxAttempts   int
+	MaxBackoff    time.Duration
+	Buffered      bool
+	BufferSize    int
+	FlushInterval time.Duration
+	FlushTimeout  time.Duration
+
+	sns *awssns.Client
+	eb  *eventbridge.Client
+
+	mtx      sync.Mutex
+	messages []Message
+	events   []Event
+}
+
+func (c *Config) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("sns")
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+	if c.BufferSize == 0 {
+		c.BufferSize = defaultBufferSize
+	}
+	if c.FlushInterval == 0 {
+		c.FlushInterval = defaultFlushInterval
+	}
+	if c.FlushTimeout == 0 {
+		c.FlushTimeout = defaultFlushTimeout
+	}
+
+	flags := run.NewFlagSet("SNS options")
+
+	flags.StringVar(&c.Region, c.prefix(Region),
+		c.Region, "AWS region (defaults to the AWS environment configuration)")
+
+	flags.StringVar(&c.Endpoint, c.prefix(Endpoint),
+		c.Endpoint, "custom AWS endpoint URL (e.g. for local testing)")
+
+	flags.StringVar(&c.TopicARN, c.prefix(TopicARN),
+		c.TopicARN, "ARN of the SNS topic to publish to")
+
+	flags.StringVar(&c.EventBus, c.prefix(EventBus),
+		c.EventBus, "EventBridge event bus name or ARN (enables EventBridge)")
+
+	flags.StringVar(&c.EventSource, c.prefix(EventSource),
+		c.EventSource, "source of the EventBridge events")
+
+	flags.IntVar(&c.MaxAttempts, c.prefix(MaxAttempts),
+		c.MaxAttempts, "maximum attempts for AWS requests")
+
+	flags.DurationVar(&c.MaxBackoff, c.prefix(MaxBackoff),
+		c.MaxBackoff, "maximum backoff between AWS request attempts")
+
+	flags.BoolVar(&c.Buffered, c.prefix(Buffered),
+		c.Buffered, "buffer messages locally and publish them in batches")
+
+	flags.IntVar(&c.BufferSize, c.prefix(BufferSize),
+		c.BufferSize, "maximum number of buffered messages")
+
+	flags.DurationVar(&c.FlushInterval, c.prefix(FlushInterval),
+		c.FlushInterval, "interval for flushing buffered messages")
+
+	flags.DurationVar(&c.FlushTimeout, c.prefix(FlushTimeout),
+		c.FlushTimeout, "timeout for flushing buffered messages on shutdown")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if c.TopicARN != "" && !strings.HasPrefix(c.TopicARN, "arn:") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(TopicARN), flag.ErrInvalidVal))
+	}
+	if c.EventBus != "" && c.EventSource == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(EventSource), flag.ErrRequired))
+	}
+	if c.MaxAttempts < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(MaxAttempts), flag.ErrInvalidVal))
+	}
+	if c.Buffered {
+		if c.BufferSize < 1 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(BufferSize), flag.ErrInvalidVal))
+		}
+		if c.FlushInterval <= 0 {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(FlushInterval), flag.ErrInvalidVal))
+		}
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (c *Config) PreRun() error {
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRetryer(func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.MaxAttempts = c.MaxAttempts
+				o.MaxBackoff = c.MaxBackoff
+			})
+		}),
+	}
+	if c.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(c.Region))
+	}
+	if c.Endpoint != "" {
+		opts = append(opts, awsconfig.WithBaseEndpoint(c.Endpoint))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.FlushTimeout)
+	defer cancel()
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	c.sns = awssns.NewFromConfig(cfg)
+	if c.EventBus != "" {
+		c.eb = eventbridge.NewFromConfig(cfg)
+	}
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. In buffered mode it
+// periodically flushes the buffer and flushes the remaining messages on
+// shutdown.
+func (c *Config) ServeContext(ctx context.Context) error {
+	if !c.Buffered {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(c.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fctx, cancel := context.WithTimeout(context.Background(), c.FlushTimeout)
+			defer cancel()
+			if err := c.Flush(fctx); err != nil {
+				log.Error("unable to flush buffered messages on shutdown", err)
+			}
+			return nil
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				log.Error("unable to flush buffered messages", err)
+			}
+		}
+	}
+}
+
+// SNS returns the SNS client.
+func (c *Config) SNS() *awssns.Client {
+	return c.sns
+}
+
+// EventBridge returns the EventBridge client or nil if EventBridge is not
+// enabled.
+func (c *Config) EventBridge() *eventbridge.Client {
+	return c.eb
+}
+
+var (
+	_ run.Config         = (*Config)(nil)
+	_ run.PreRunner      = (*Config)(nil)
+	_ run.ServiceContext = (*Config)(nil)
+)
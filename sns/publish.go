@@ -0,0 +1,278 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	awssns "github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// maxBatch holds the maximum number of entries of SNS and EventBridge batch
+// requests.
+const maxBatch = 10
+
+var (
+	// ErrBufferFull is returned in buffered mode if the buffer is full.
+	ErrBufferFull = errors.New("publish buffer full")
+	// ErrNoTopic is returned if no topic ARN is configured or provided.
+	ErrNoTopic = errors.New("no topic ARN")
+	// ErrEventBridgeDisabled is returned by PutEvent if no event bus is
+	// configured.
+	ErrEventBridgeDisabled = errors.New("eventbridge not enabled")
+)
+
+// Message holds an SNS message.
+type Message struct {
+	// TopicARN overrides the configured topic if set.
+	TopicARN   string
+	Subject    string
+	Body       string
+	Attributes Attributes
+	// GroupID and DeduplicationID are used for FIFO topics.
+	GroupID         string
+	DeduplicationID string
+}
+
+// Event holds an EventBridge event.
+type Event struct {
+	DetailType string
+	// Detail is marshaled to JSON.
+	Detail any
+	Time   time.Time
+}
+
+// Publish publishes the message to SNS. In buffered mode the message is
+// queued and sent with the next flush.
+func (c *Config) Publish(ctx context.Context, msg Message) error {
+	if msg.TopicARN == "" {
+		msg.TopicARN = c.TopicARN
+	}
+	if msg.TopicARN == "" {
+		return ErrNoTopic
+	}
+	if c.Buffered {
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		if len(c.messages)+len(c.events) >= c.BufferSize {
+			return ErrBufferFull
+		}
+		c.messages = append(c.messages, msg)
+		return nil
+	}
+
+	in := &awssns.PublishInput{
+		TopicArn:          aws.String(msg.TopicARN),
+		Message:           aws.String(msg.Body),
+		MessageAttributes: msg.Attributes,
+	}
+	if msg.Subject != "" {
+		in.Subject = aws.String(msg.Subject)
+	}
+	if msg.GroupID != "" {
+		in.MessageGroupId = aws.String(msg.GroupID)
+	}
+	if msg.DeduplicationID != "" {
+		in.MessageDeduplicationId = aws.String(msg.DeduplicationID)
+	}
+	_, err := c.sns.Publish(ctx, in)
+	return err
+}
+
+// PutEvent sends the event to the configured EventBridge event bus. In
+// buffered mode the event is queued and sent with the next flush.
+func (c *Config) PutEvent(ctx context.Context, ev Event) error {
+	if c.eb == nil {
+		return ErrEventBridgeDisabled
+	}
+	if c.Buffered {
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		if len(c.messages)+len(c.events) >= c.BufferSize {
+			return ErrBufferFull
+		}
+		c.events = append(c.events, ev)
+		return nil
+	}
+
+	entry, err := c.entry(ev)
+	if err != nil {
+		return err
+	}
+	failed, err := c.putEvents(ctx, []ebtypes.PutEventsRequestEntry{entry})
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("eventbridge rejected event: %w", failed[0])
+	}
+	return nil
+}
+
+// Flush sends all buffered messages and events. Messages failing to be sent
+// remain buffered.
+func (c *Config) Flush(ctx context.Context) error {
+	c.mtx.Lock()
+	messages, events := c.messages, c.events
+	c.messages, c.events = nil, nil
+	c.mtx.Unlock()
+
+	var (
+		mErr          error
+		retryMessages []Message
+		retryEvents   []Event
+	)
+	for len(messages) > 0 {
+		n := batchSize(len(messages), func(i int) bool {
+			return messages[i].TopicARN == messages[0].TopicARN
+		})
+		failed, err := c.publishBatch(ctx, messages[:n])
+		if err != nil {
+			mErr = errors.Join(mErr, err)
+		}
+		retryMessages = append(retryMessages, failed...)
+		messages = messages[n:]
+	}
+	for len(events) > 0 {
+		n := min(len(events), maxBatch)
+		entries := make([]ebtypes.PutEventsRequestEntry, 0, n)
+		for _, ev := range events[:n] {
+			entry, err := c.entry(ev)
+			if err != nil {
+				mErr = errors.Join(mErr, err)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		failed, err := c.putEvents(ctx, entries)
+		if err != nil {
+			mErr = errors.Join(mErr, err)
+			retryEvents = append(retryEvents, events[:n]...)
+		} else {
+			for _, f := range failed {
+				mErr = errors.Join(mErr, f)
+			}
+		}
+		events = events[n:]
+	}
+
+	if len(retryMessages) > 0 || len(retryEvents) > 0 {
+		c.mtx.Lock()
+		c.messages = append(retryMessages, c.messages...)
+		c.events = append(retryEvents, c.events...)
+		c.mtx.Unlock()
+	}
+	return mErr
+}
+
+// publishBatch publishes messages sharing the same topic and returns the
+// messages which failed to be published.
+func (c *Config) publishBatch(ctx context.Context, messages []Message) ([]Message, error) {
+	entries := make([]types.PublishBatchRequestEntry, len(messages))
+	for i, msg := range messages {
+		entries[i] = types.PublishBatchRequestEntry{
+			Id:                aws.String(strconv.Itoa(i)),
+			Message:           aws.String(msg.Body),
+			MessageAttributes: msg.Attributes,
+		}
+		if msg.Subject != "" {
+			entries[i].Subject = aws.String(msg.Subject)
+		}
+		if msg.GroupID != "" {
+			entries[i].MessageGroupId = aws.String(msg.GroupID)
+		}
+		if msg.DeduplicationID != "" {
+			entries[i].MessageDeduplicationId = aws.String(msg.DeduplicationID)
+		}
+	}
+
+	out, err := c.sns.PublishBatch(ctx, &awssns.PublishBatchInput{
+		TopicArn:                   aws.String(messages[0].TopicARN),
+		PublishBatchRequestEntries: entries,
+	})
+	if err != nil {
+		return messages, err
+	}
+
+	var (
+		failed []Message
+		mErr   error
+	)
+	for _, f := range out.Failed {
+		i, _ := strconv.Atoi(aws.ToString(f.Id))
+		mErr = errors.Join(mErr, fmt.Errorf("sns rejected message: %s: %s",
+			aws.ToString(f.Code), aws.ToString(f.Message)))
+		// only retry failures caused by the service
+		if !f.SenderFault {
+			failed = append(failed, messages[i])
+		}
+	}
+	return failed, mErr
+}
+
+func (c *Config) entry(ev Event) (ebtypes.PutEventsRequestEntry, error) {
+	detail, err := json.Marshal(ev.Detail)
+	if err != nil {
+		return ebtypes.PutEventsRequestEntry{}, fmt.Errorf("unable to marshal event detail: %w", err)
+	}
+	entry := ebtypes.PutEventsRequestEntry{
+		EventBusName: aws.String(c.EventBus),
+		Source:       aws.String(c.EventSource),
+		DetailType:   aws.String(ev.DetailType),
+		Detail:       aws.String(string(detail)),
+	}
+	if !ev.Time.IsZero() {
+		entry.Time = aws.Time(ev.Time)
+	}
+	return entry, nil
+}
+
+// putEvents sends the entries and returns the errors of rejected entries.
+func (c *Config) putEvents(ctx context.Context, entries []ebtypes.PutEventsRequestEntry) ([]error, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	out, err := c.eb.PutEvents(ctx, &eventbridge.PutEventsInput{Entries: entries})
+	if err != nil {
+		return nil, err
+	}
+	var failed []error
+	for _, e := range out.Entries {
+		if e.ErrorCode != nil {
+			failed = append(failed, fmt.Errorf("%s: %s",
+				aws.ToString(e.ErrorCode), aws.ToString(e.ErrorMessage)))
+		}
+	}
+	return failed, nil
+}
+
+// batchSize returns the number of leading items, up to maxBatch, for which
+// same returns true.
+func batchSize(n int, same func(i int) bool) int {
+	i := 1
+	for i < n && i < maxBatch && same(i) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,87 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtunnel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// forward holds a local listener forwarding its connections through the
+// tunnel.
+type forward struct {
+	name     string
+	remote   string
+	listener net.Listener
+}
+
+// serveForward accepts connections until the listener is closed.
+func (t *Tunnel) serveForward(ctx context.Context, f *forward) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Error("ssh tunnel forward accept failed", err, "name", f.name)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.pipe(ctx, f, conn)
+		}()
+	}
+}
+
+// pipe copies data between the local connection and the remote address
+// until either side closes or the context is canceled.
+func (t *Tunnel) pipe(ctx context.Context, f *forward, local net.Conn) {
+	defer func() { _ = local.Close() }()
+
+	dialCtx, cancel := context.WithTimeout(ctx, t.DialTimeout)
+	remote, err := t.DialContext(dialCtx, "tcp", f.remote)
+	cancel()
+	if err != nil {
+		log.Error("ssh tunnel forward dial failed", err, "name", f.name, "remote", f.remote)
+		return
+	}
+	defer func() { _ = remote.Close() }()
+
+	done := make(chan struct{}, 2)
+	cp := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go cp(remote, local)
+	go cp(local, remote)
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func (t *Tunnel) closeForwards() {
+	for _, f := range t.forwards {
+		_ = f.listener.Close()
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sshtunnel provides an SSH tunnel handler for use with the run
+// package, giving access to databases and services only reachable through a
+// bastion host.
+package sshtunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("ssh-tunnel", "SSH tunnel")
+
+// package flags.
+const (
+	defaultPort              = "22"
+	defaultDialTimeout       = 10 * time.Second
+	defaultKeepAliveInterval = 30 * time.Second
+
+	Host                  = "ssh-tunnel-host"
+	User                  = "ssh-tunnel-user"
+	KeyFile               = "ssh-tunnel-key-file"
+	KeyPassphrase         = "ssh-tunnel-key-passphrase"
+	KnownHosts            = "ssh-tunnel-known-hosts"
+	InsecureIgnoreHostKey = "ssh-tunnel-insecure-ignore-host-key"
+	Forwards              = "ssh-tunnel-forwards"
+	DialTimeout           = "ssh-tunnel-dial-timeout"
+	KeepAliveInterval     = "ssh-tunnel-keepalive-interval"
+)
+
+// Tunnel errors.
+var (
+	ErrNotConnected   = errors.New("ssh tunnel not connected")
+	ErrUnknownForward = errors.New("unknown ssh tunnel forward")
+)
+
+// Tunnel implements run.Config and run.ServiceContext for an SSH tunnel. It
+// connects to the SSH server in PreRun, so handlers registered after it can
+// use it in their PreRun:
+//
+//   - Dial (or DialContext) dials through the tunnel, e.g. as the Dialer of
+//     the redis client options or the DialFunc of a pgx connection config.
+//   - Forwards listen locally and forward their connections through the
+//     tunnel, for clients without custom dialer support (e.g. the dbpool
+//     handler). Addr returns the local address of a forward for use in
+//     their DSN.
+//
+// Forwards are configured as "name=[[bind-address:]port:]host:hostport"
+// pairs following the ssh -L syntax, e.g. "db=3306:db.internal:3306". If no
+// port is given, a random local port is used. The connection is kept alive
+// and re-established if lost.
+type Tunnel struct {
+	Prefix                string
+	Host                  string
+	User                  string
+	KeyFile               string
+	KeyPassphrase         string
+	KnownHosts            string
+	InsecureIgnoreHostKey bool
+	Forwards              map[string]string
+	DialTimeout           time.Duration
+	KeepAliveInterval     time.Duration
+
+	mtx       sync.RWMutex
+	client    *ssh.Client
+	config    *ssh.ClientConfig
+	forwards  map[string]*forward
+	reconnect chan struct{}
+}
+
+func (t *Tunnel) prefix(s string) string {
+	if t.Prefix != "" {
+		return t.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (t *Tunnel) Name() string {
+	return t.prefix("ssh-tunnel")
+}
+
+// Initialize implements run.Initializer.
+func (t *Tunnel) Initialize() {
+	if t.DialTimeout == 0 {
+		t.DialTimeout = defaultDialTimeout
+	}
+	if t.KeepAliveInterval == 0 {
+		t.KeepAliveInterval = defaultKeepAliveInterval
+	}
+}
+
+// FlagSet implements run.Config.
+func (t *Tunnel) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("SSH tunnel options")
+
+	flags.StringVar(&t.Host, t.prefix(Host),
+		t.Host, "SSH server (host[:port])")
+
+	flags.StringVar(&t.User, t.prefix(User),
+		t.User, "SSH user")
+
+	flags.StringVar(&t.KeyFile, t.prefix(KeyFile),
+		t.KeyFile, "private key file (uses the SSH agent if not set)")
+
+	flags.StringVar(&t.KeyPassphrase, t.prefix(KeyPassphrase),
+		t.KeyPassphrase, "passphrase of the private key")
+
+	flags.StringVar(&t.KnownHosts, t.prefix(KnownHosts),
+		t.KnownHosts, "known_hosts file used to verify the host key")
+
+	flags.BoolVar(&t.InsecureIgnoreHostKey, t.prefix(InsecureIgnoreHostKey),
+		t.InsecureIgnoreHostKey, "skip host key verification (development only)")
+
+	flags.StringToStringVar(&t.Forwards, t.prefix(Forwards),
+		t.Forwards, `local forwards as "name=[[bind-address:]port:]host:hostport" pairs`)
+
+	flags.DurationVar(&t.DialTimeout, t.prefix(DialTimeout),
+		t.DialTimeout, "timeout for connecting to the SSH server")
+
+	flags.DurationVar(&t.KeepAliveInterval, t.prefix(KeepAliveInterval),
+		t.KeepAliveInterval, "interval of the keepalive requests")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (t *Tunnel) Validate() error {
+	var mErr error
+
+	if t.Host == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(t.prefix(Host), flag.ErrRequired))
+	}
+	if t.User == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(t.prefix(User), flag.ErrRequired))
+	}
+	if t.KeyFile != "" {
+		if _, err := os.Stat(t.KeyFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(t.prefix(KeyFile), flag.ErrInvalidPath))
+		}
+	} else if os.Getenv("SSH_AUTH_SOCK") == "" {
+		mErr = multierror.Append(mErr, flag.NewValidationError(t.prefix(KeyFile),
+			flag.ValidationError("required if no SSH agent is available")))
+	}
+	switch {
+	case t.KnownHosts != "":
+		if _, err := os.Stat(t.KnownHosts); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(t.prefix(KnownHosts), flag.ErrInvalidPath))
+		}
+	case !t.InsecureIgnoreHostKey:
+		mErr = multierror.Append(mErr, flag.NewValidationError(t.prefix(KnownHosts),
+			flag.ValidationError("required unless host key verification is skipped")))
+	}
+	for name, spec := range t.Forwards {
+		if _, _, err := parseForward(spec); err != nil {
+			mErr = multierror.Append(mErr, flag.NewValidationError(t.prefix(Forwards),
+				fmt.Errorf("%s: %w", name, err)))
+		}
+	}
+	if t.DialTimeout <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(t.prefix(DialTimeout), flag.ErrInvalidVal))
+	}
+	if t.KeepAliveInterval <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(t.prefix(KeepAliveInterval), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (t *Tunnel) PreRun() (err error) {
+	if _, _, err = net.SplitHostPort(t.Host); err != nil {
+		t.Host = net.JoinHostPort(t.Host, defaultPort)
+	}
+	if t.config, err = t.clientConfig(); err != nil {
+		return err
+	}
+	if err = t.connect(); err != nil {
+		return err
+	}
+	t.reconnect = make(chan struct{}, 1)
+
+	t.forwards = make(map[string]*forward, len(t.Forwards))
+	for name, spec := range t.Forwards {
+		local, remote, _ := parseForward(spec)
+		l, err := net.Listen("tcp", local)
+		if err != nil {
+			t.closeForwards()
+			return fmt.Errorf("unable to listen for ssh tunnel forward %s: %w", name, err)
+		}
+		t.forwards[name] = &forward{name: name, remote: remote, listener: l}
+		log.Info("ssh tunnel forward", "name", name, "local", l.Addr().String(), "remote", remote)
+	}
+	return nil
+}
+
+func (t *Tunnel) clientConfig() (*ssh.ClientConfig, error) {
+	var auth ssh.AuthMethod
+	if t.KeyFile != "" {
+		b, err := os.ReadFile(t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ssh key: %w", err)
+		}
+		var signer ssh.Signer
+		if t.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(b, []byte(t.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(b)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse ssh key: %w", err)
+		}
+		auth = ssh.PublicKeys(signer)
+	} else {
+		conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to ssh agent: %w", err)
+		}
+		auth = ssh.PublicKeysCallback(agent.NewClient(conn).Signers)
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey() //nolint:gosec // explicitly requested
+	if t.KnownHosts != "" {
+		var err error
+		if hostKeyCallback, err = knownhosts.New(t.KnownHosts); err != nil {
+			return nil, fmt.Errorf("unable to load known hosts: %w", err)
+		}
+	}
+
+	return &ssh.ClientConfig{
+		User:            t.User,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         t.DialTimeout,
+	}, nil
+}
+
+// connect establishes the SSH connection, replacing a previous connection.
+func (t *Tunnel) connect() error {
+	client, err := ssh.Dial("tcp", t.Host, t.config)
+	if err != nil {
+		return fmt.Errorf("unable to connect to ssh server %s: %w", t.Host, err)
+	}
+	t.mtx.Lock()
+	prev := t.client
+	t.client = client
+	t.mtx.Unlock()
+	if prev != nil {
+		_ = prev.Close()
+	}
+	log.Info("ssh tunnel connected", "host", t.Host, "user", t.User)
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. It serves the forwards and
+// keeps the connection alive until the context is canceled.
+func (t *Tunnel) ServeContext(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, f := range t.forwards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.serveForward(ctx, f)
+		}()
+	}
+
+	keepAlive := time.NewTicker(t.KeepAliveInterval)
+	defer keepAlive.Stop()
+	backoff := time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			t.closeForwards()
+			wg.Wait()
+			t.mtx.Lock()
+			if t.client != nil {
+				_ = t.client.Close()
+			}
+			t.mtx.Unlock()
+			return nil
+		case <-keepAlive.C:
+		case <-t.reconnect:
+		}
+		if t.alive() {
+			backoff = time.Second
+			continue
+		}
+		log.Info("ssh tunnel connection lost, reconnecting", "host", t.Host)
+		if err := t.connect(); err != nil {
+			log.Error("unable to reconnect ssh tunnel", err, "retry", backoff.String())
+			keepAlive.Reset(backoff)
+			backoff = min(backoff*2, t.KeepAliveInterval)
+			continue
+		}
+		keepAlive.Reset(t.KeepAliveInterval)
+		backoff = time.Second
+	}
+}
+
+// alive sends a keepalive request, returning false if the connection is lost.
+func (t *Tunnel) alive() bool {
+	t.mtx.RLock()
+	client := t.client
+	t.mtx.RUnlock()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		return err == nil
+	case <-time.After(t.DialTimeout):
+		return false
+	}
+}
+
+// Dial dials the address from the SSH server.
+func (t *Tunnel) Dial(network, addr string) (net.Conn, error) {
+	return t.DialContext(context.Background(), network, addr)
+}
+
+// DialContext dials the address from the SSH server. A failed dial triggers
+// a connection check so a lost connection is re-established.
+func (t *Tunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	t.mtx.RLock()
+	client := t.client
+	t.mtx.RUnlock()
+	if client == nil {
+		return nil, ErrNotConnected
+	}
+	conn, err := client.DialContext(ctx, network, addr)
+	if err != nil && ctx.Err() == nil {
+		select {
+		case t.reconnect <- struct{}{}:
+		default:
+		}
+	}
+	return conn, err
+}
+
+// Addr returns the local address of the named forward.
+func (t *Tunnel) Addr(name string) (string, error) {
+	f, ok := t.forwards[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownForward, name)
+	}
+	return f.listener.Addr().String(), nil
+}
+
+// parseForward parses a "[[bind-address:]port:]host:hostport" forward into
+// its local and remote address. The bind address defaults to localhost and
+// the port to a random port.
+func parseForward(spec string) (local, remote string, err error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	switch len(parts) {
+	case 2:
+		local, remote = "127.0.0.1:0", spec
+	case 3:
+		local, remote = net.JoinHostPort("127.0.0.1", parts[0]), net.JoinHostPort(parts[1], parts[2])
+	case 4:
+		local, remote = net.JoinHostPort(parts[0], parts[1]), net.JoinHostPort(parts[2], parts[3])
+	default:
+		return "", "", flag.ValidationError(`expected "[[bind-address:]port:]host:hostport"`)
+	}
+	for _, addr := range []string{local, remote} {
+		if host, port, err := net.SplitHostPort(addr); err != nil || host == "" || port == "" {
+			return "", "", flag.ValidationError("invalid address " + addr)
+		}
+	}
+	return local, remote, nil
+}
+
+var (
+	_ run.Initializer    = (*Tunnel)(nil)
+	_ run.Config         = (*Tunnel)(nil)
+	_ run.PreRunner      = (*Tunnel)(nil)
+	_ run.ServiceContext = (*Tunnel)(nil)
+)
@@ -0,0 +1,150 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metric types.
+const (
+	typeCount     = "c"
+	typeGauge     = "g"
+	typeHistogram = "h"
+	typeTiming    = "ms"
+)
+
+// Count adds the value to a counter.
+func (s *Service) Count(name string, value float64, tags ...string) {
+	s.emit(name, value, typeCount, tags)
+}
+
+// Gauge sets the value of a gauge.
+func (s *Service) Gauge(name string, value float64, tags ...string) {
+	s.gauge(name, value, false, tags)
+}
+
+// Histogram records the value in a histogram.
+func (s *Service) Histogram(name string, value float64, tags ...string) {
+	s.emit(name, value, typeHistogram, tags)
+}
+
+// Timing records the duration in milliseconds.
+func (s *Service) Timing(name string, ms float64, tags ...string) {
+	s.emit(name, ms, typeTiming, tags)
+}
+
+// gauge sets the gauge or adds the delta to its last value, as DogStatsD
+// does not support relative gauge updates.
+func (s *Service) gauge(name string, value float64, delta bool, tags []string) {
+	s.init()
+	key := name + "|" + strings.Join(tags, ",")
+	s.mtx.Lock()
+	if delta {
+		value += s.gauges[key]
+	}
+	s.gauges[key] = value
+	s.mtx.Unlock()
+	s.emit(name, value, typeGauge, tags)
+}
+
+// emit formats the metric and adds it to the buffer, sending the buffer
+// first if the metric doesn't fit.
+func (s *Service) emit(name string, value float64, typ string, tags []string) {
+	var sb strings.Builder
+	if s.Namespace != "" {
+		sb.WriteString(s.Namespace)
+		sb.WriteByte('.')
+	}
+	sb.WriteString(sanitize(name))
+	sb.WriteByte(':')
+	sb.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	sb.WriteByte('|')
+	sb.WriteString(typ)
+	if len(s.Tags)+len(tags) > 0 {
+		sb.WriteString("|#")
+		sb.WriteString(strings.Join(append(append([]string(nil), s.Tags...), tags...), ","))
+	}
+	line := sb.String()
+
+	s.init()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.buf.Len() > 0 && s.buf.Len()+1+len(line) > s.packetSize() {
+		s.send()
+	}
+	if s.buf.Len() > 0 {
+		s.buf.WriteByte('\n')
+	}
+	s.buf.WriteString(line)
+}
+
+// Flush sends the buffered metrics.
+func (s *Service) Flush() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.send()
+}
+
+// send writes the buffer to the agent. Metrics emitted before PreRun are
+// kept until the connection is available, unless the buffer is full.
+func (s *Service) send() {
+	if s.buf.Len() == 0 {
+		return
+	}
+	if s.conn == nil {
+		if s.buf.Len() >= s.packetSize() {
+			s.buf.Reset()
+		}
+		return
+	}
+	if _, err := s.conn.Write(s.buf.Bytes()); err != nil {
+		log.Debug("unable to send metrics", "error", err.Error())
+	}
+	s.buf.Reset()
+}
+
+func (s *Service) packetSize() int {
+	if s.MaxPacketSize > 0 {
+		return s.MaxPacketSize
+	}
+	return defaultUDPPacketSize
+}
+
+// tags returns the label set as sorted DogStatsD tags.
+func tags(set map[string]string) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(set))
+	for k, v := range set {
+		out = append(out, k+":"+v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// sanitize replaces the characters reserved by the StatsD protocol.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,256 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statsd provides a run handler emitting metrics to a StatsD or
+// Datadog (DogStatsD) agent.
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("statsd", "StatsD metrics")
+
+// package flags.
+const (
+	defaultAddress       = "127.0.0.1:8125"
+	defaultFlushInterval = time.Second
+	defaultUDPPacketSize = 1432
+	defaultUDSPacketSize = 8192
+
+	Address           = "statsd-address"
+	Namespace         = "statsd-namespace"
+	Tags              = "statsd-tags"
+	FlushInterval     = "statsd-flush-interval"
+	MaxPacketSize     = "statsd-max-packet-size"
+	DisableGlobalSink = "statsd-disable-global-sink"
+)
+
+// Service implements run.Config and run.ServiceContext for a StatsD client.
+// Metrics are buffered into packets which are sent when full, on each flush
+// interval and on shutdown. Tags use the DogStatsD format.
+//
+// Use MetricSink to have handlers using telemetry, e.g. cron, emit into
+// StatsD. Unless DisableGlobalSink is set, it is installed as global
+// telemetry MetricSink.
+//
+// The agent address and tags default to the Datadog DD_AGENT_HOST,
+// DD_DOGSTATSD_PORT, DD_ENV, DD_SERVICE and DD_VERSION environment
+// variables. Initialize sets the zero-valued fields to their defaults.
+type Service struct {
+	Prefix string
+
+	// Address holds the agent address as host:port for UDP or as
+	// unix:///path for a Unix domain socket.
+	Address       string
+	Namespace     string
+	Tags          []string
+	FlushInterval time.Duration
+	// MaxPacketSize holds the max. size of a packet. It defaults to 1432
+	// bytes for UDP and 8192 bytes for Unix domain sockets.
+	MaxPacketSize     int
+	DisableGlobalSink bool
+
+	once   sync.Once
+	sink   *sink
+	mtx    sync.Mutex
+	conn   net.Conn
+	buf    bytes.Buffer
+	gauges map[string]float64
+}
+
+func (s *Service) prefix(v string) string {
+	if s.Prefix != "" {
+		return s.Prefix + "-" + v
+	}
+	return v
+}
+
+// Name implements run.Unit.
+func (s *Service) Name() string {
+	return s.prefix("statsd")
+}
+
+// Initialize implements run.Initializer.
+func (s *Service) Initialize() {
+	s.init()
+	if s.Address == "" {
+		s.Address = defaultAddress
+	}
+	if s.FlushInterval == 0 {
+		s.FlushInterval = defaultFlushInterval
+	}
+}
+
+func (s *Service) init() {
+	s.once.Do(func() {
+		s.sink = &sink{s: s}
+		s.gauges = make(map[string]float64)
+	})
+}
+
+// MetricSink returns a telemetry MetricSink emitting into StatsD.
+func (s *Service) MetricSink() telemetry.MetricSink {
+	s.init()
+	return s.sink
+}
+
+// FlagSet implements run.Config.
+func (s *Service) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("StatsD options")
+
+	if host := os.Getenv("DD_AGENT_HOST"); host != "" {
+		port := os.Getenv("DD_DOGSTATSD_PORT")
+		if port == "" {
+			port = "8125"
+		}
+		s.Address = net.JoinHostPort(host, port)
+	}
+	for _, t := range []struct{ env, tag string }{
+		{"DD_ENV", "env"},
+		{"DD_SERVICE", "service"},
+		{"DD_VERSION", "version"},
+	} {
+		if v := os.Getenv(t.env); v != "" && !hasTag(s.Tags, t.tag) {
+			s.Tags = append(s.Tags, t.tag+":"+v)
+		}
+	}
+
+	flags.StringVar(&s.Address, s.prefix(Address),
+		s.Address, "StatsD agent address (host:port or unix:///path)")
+
+	flags.StringVar(&s.Namespace, s.prefix(Namespace),
+		s.Namespace, "namespace prefixed to all metric names")
+
+	flags.StringSliceVar(&s.Tags, s.prefix(Tags),
+		s.Tags, "tags added to all metrics, e.g. env:prod")
+
+	flags.DurationVar(&s.FlushInterval, s.prefix(FlushInterval),
+		s.FlushInterval, "interval for flushing buffered metrics")
+
+	flags.IntVar(&s.MaxPacketSize, s.prefix(MaxPacketSize),
+		s.MaxPacketSize, "max. packet size in bytes (default depends on transport)")
+
+	flags.BoolVar(&s.DisableGlobalSink, s.prefix(DisableGlobalSink),
+		s.DisableGlobalSink, "don't install as global telemetry metric sink")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	if path, ok := strings.CutPrefix(s.Address, "unix://"); ok {
+		if path == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(Address), flag.ErrInvalidPath))
+		}
+	} else if _, _, err := net.SplitHostPort(s.Address); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(Address), flag.ErrInvalidVal))
+	}
+	for _, tag := range s.Tags {
+		if tag == "" || strings.ContainsAny(tag, ",|#\n") {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(Tags), flag.ErrInvalidVal))
+			break
+		}
+	}
+	if s.FlushInterval <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(FlushInterval), flag.ErrInvalidVal))
+	}
+	if s.MaxPacketSize < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(MaxPacketSize), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() error {
+	s.init()
+
+	network, addr, size := "udp", s.Address, defaultUDPPacketSize
+	if path, ok := strings.CutPrefix(s.Address, "unix://"); ok {
+		network, addr, size = "unixgram", path, defaultUDSPacketSize
+	}
+	if s.MaxPacketSize == 0 {
+		s.MaxPacketSize = size
+	}
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	s.conn = conn
+	s.mtx.Unlock()
+
+	if !s.DisableGlobalSink {
+		telemetry.SetGlobalMetricSink(s.sink)
+	}
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. It flushes the buffered
+// metrics on each interval and on shutdown.
+func (s *Service) ServeContext(ctx context.Context) error {
+	ticker := time.NewTicker(s.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-ctx.Done():
+			s.Flush()
+			s.mtx.Lock()
+			defer s.mtx.Unlock()
+			err := s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+}
+
+func hasTag(tags []string, name string) bool {
+	for _, t := range tags {
+		if strings.HasPrefix(t, name+":") {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	_ run.Initializer    = (*Service)(nil)
+	_ run.Config         = (*Service)(nil)
+	_ run.PreRunner      = (*Service)(nil)
+	_ run.ServiceContext = (*Service)(nil)
+)
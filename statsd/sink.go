@@ -0,0 +1,183 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"context"
+
+	"github.com/basvanbeek/telemetry"
+)
+
+type labelOp int
+
+const (
+	opInsert labelOp = iota
+	opUpdate
+	opUpsert
+	opDelete
+)
+
+// label implements telemetry.Label.
+type label string
+
+// labelValue implements telemetry.LabelValue.
+type labelValue struct {
+	name  string
+	value string
+	op    labelOp
+}
+
+func (l label) Insert(value string) telemetry.LabelValue {
+	return labelValue{name: string(l), value: value, op: opInsert}
+}
+
+func (l label) Update(value string) telemetry.LabelValue {
+	return labelValue{name: string(l), value: value, op: opUpdate}
+}
+
+func (l label) Upsert(value string) telemetry.LabelValue {
+	return labelValue{name: string(l), value: value, op: opUpsert}
+}
+
+func (l label) Delete() telemetry.LabelValue {
+	return labelValue{name: string(l), op: opDelete}
+}
+
+// apply applies the label values to the provided set, following the
+// semantics of the telemetry.Label operations.
+func apply(set map[string]string, values []telemetry.LabelValue) map[string]string {
+	res := make(map[string]string, len(set)+len(values))
+	for k, v := range set {
+		res[k] = v
+	}
+	for _, lv := range values {
+		v, ok := lv.(labelValue)
+		if !ok {
+			continue
+		}
+		_, exists := res[v.name]
+		switch {
+		case v.op == opDelete:
+			delete(res, v.name)
+		case v.op == opInsert && exists, v.op == opUpdate && !exists:
+		default:
+			res[v.name] = v.value
+		}
+	}
+	return res
+}
+
+type ctxLabels struct{}
+
+// sink implements telemetry.MetricSink emitting into StatsD. Sums map onto
+// counters, gauges onto gauges and distributions onto histograms.
+type sink struct {
+	s *Service
+}
+
+func (s *sink) NewSum(name, _ string, opts ...telemetry.MetricOption) telemetry.Metric {
+	return s.newMetric(name, typeCount, opts)
+}
+
+func (s *sink) NewGauge(name, _ string, opts ...telemetry.MetricOption) telemetry.Metric {
+	return s.newMetric(name, typeGauge, opts)
+}
+
+func (s *sink) NewDistribution(name, _ string, _ []float64, opts ...telemetry.MetricOption) telemetry.Metric {
+	return s.newMetric(name, typeHistogram, opts)
+}
+
+func (s *sink) NewLabel(name string) telemetry.Label {
+	return label(sanitize(name))
+}
+
+func (s *sink) ContextWithLabels(ctx context.Context, values ...telemetry.LabelValue) (context.Context, error) {
+	set, _ := ctx.Value(ctxLabels{}).(map[string]string)
+	return context.WithValue(ctx, ctxLabels{}, apply(set, values)), nil
+}
+
+func (s *sink) newMetric(name, typ string, opts []telemetry.MetricOption) *metric {
+	var o telemetry.MetricOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &metric{s: s.s, name: name, typ: typ, enabled: o.EnabledCondition}
+}
+
+// metric implements telemetry.Metric.
+type metric struct {
+	s       *Service
+	name    string
+	typ     string
+	enabled func() bool
+	bound   map[string]string
+}
+
+func (m *metric) Name() string { return m.name }
+
+func (m *metric) Increment() { m.add(m.bound, 1) }
+
+func (m *metric) Decrement() { m.add(m.bound, -1) }
+
+func (m *metric) Record(value float64) { m.record(m.bound, value) }
+
+func (m *metric) RecordContext(ctx context.Context, value float64) {
+	set, _ := ctx.Value(ctxLabels{}).(map[string]string)
+	if len(m.bound) > 0 {
+		merged := apply(set, nil)
+		for k, v := range m.bound {
+			merged[k] = v
+		}
+		set = merged
+	}
+	m.record(set, value)
+}
+
+func (m *metric) With(labelValues ...telemetry.LabelValue) telemetry.Metric {
+	c := *m
+	c.bound = apply(m.bound, labelValues)
+	return &c
+}
+
+func (m *metric) add(set map[string]string, delta float64) {
+	if m.enabled != nil && !m.enabled() {
+		return
+	}
+	switch m.typ {
+	case typeGauge:
+		m.s.gauge(m.name, delta, true, tags(set))
+	case typeCount:
+		m.s.emit(m.name, delta, m.typ, tags(set))
+	default:
+		m.s.emit(m.name, 1, m.typ, tags(set))
+	}
+}
+
+func (m *metric) record(set map[string]string, value float64) {
+	if m.enabled != nil && !m.enabled() {
+		return
+	}
+	if m.typ == typeGauge {
+		m.s.gauge(m.name, value, false, tags(set))
+		return
+	}
+	m.s.emit(m.name, value, m.typ, tags(set))
+}
+
+var (
+	_ telemetry.MetricSink = (*sink)(nil)
+	_ telemetry.Metric     = (*metric)(nil)
+	_ telemetry.Label      = label("")
+)
@@ -0,0 +1,403 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tcp provides a run handler serving custom line or binary protocols
+// over TCP with optional TLS, connection limits and graceful drain.
+package tcp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("tcp", "TCP server")
+
+// package flags.
+const (
+	defaultShutdownTimeout = 10 * time.Second
+
+	ListenAddress   = "tcp-listen-address"
+	TLSCertFile     = "tcp-tls-cert-file"
+	TLSKeyFile      = "tcp-tls-key-file"
+	TLSClientCAFile = "tcp-tls-client-ca-file"
+	MaxConns        = "tcp-max-conns"
+	IdleTimeout     = "tcp-idle-timeout"
+	ShutdownTimeout = "tcp-shutdown-timeout"
+)
+
+// Handler handles a connection. The connection is closed once the Handler
+// returns. The context is canceled when the Service starts shutting down,
+// after which the Handler should finish its current exchange and return.
+type Handler func(ctx context.Context, conn net.Conn)
+
+// Service implements run.Config and run.ServiceContext for a TCP server
+// handing accepted connections to its Handler.
+type Service struct {
+	Prefix string
+
+	Address     string
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile holds the CA bundle to verify client certificates with.
+	// If set, clients are required to present a valid certificate.
+	TLSClientCAFile string
+	// MaxConns holds the max. number of concurrent connections. Once reached,
+	// new connections wait in the listen backlog. 0 means unlimited.
+	MaxConns int
+	// IdleTimeout closes connections without reads or writes for the given
+	// duration. 0 disables the timeout. As it is implemented using the
+	// connection deadlines, Handlers should not set their own deadlines.
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+
+	Handler Handler
+	// TLSConfig optionally holds the TLS configuration to use instead of the
+	// one derived from the TLS files.
+	TLSConfig *tls.Config
+
+	listener net.Listener
+	active   atomic.Int64
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+func (s *Service) prefix(v string) string {
+	if s.Prefix != "" {
+		return s.Prefix + "-" + v
+	}
+	return v
+}
+
+// Name implements run.Unit.
+func (s *Service) Name() string {
+	return s.prefix("tcp")
+}
+
+// Initialize implements run.Initializer.
+func (s *Service) Initialize() {
+	if s.ShutdownTimeout == 0 {
+		s.ShutdownTimeout = defaultShutdownTimeout
+	}
+}
+
+// FlagSet implements run.Config.
+func (s *Service) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("TCP server options")
+
+	flags.StringVar(&s.Address, s.prefix(ListenAddress),
+		s.Address, "listen address of the TCP server")
+
+	flags.StringVar(&s.TLSCertFile, s.prefix(TLSCertFile),
+		s.TLSCertFile, "TLS certificate file (enables TLS)")
+
+	flags.StringVar(&s.TLSKeyFile, s.prefix(TLSKeyFile),
+		s.TLSKeyFile, "TLS certificate key file")
+
+	flags.StringVar(&s.TLSClientCAFile, s.prefix(TLSClientCAFile),
+		s.TLSClientCAFile, "CA bundle to verify required client certificates with")
+
+	flags.IntVar(&s.MaxConns, s.prefix(MaxConns),
+		s.MaxConns, "max. number of concurrent connections (0 is unlimited)")
+
+	flags.DurationVar(&s.IdleTimeout, s.prefix(IdleTimeout),
+		s.IdleTimeout, "close connections idle for this duration (0 disables)")
+
+	flags.DurationVar(&s.ShutdownTimeout, s.prefix(ShutdownTimeout),
+		s.ShutdownTimeout, "max. time to wait for connections to drain on shutdown")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	if s.Address == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(ListenAddress), flag.ErrRequired))
+	} else if _, _, err := net.SplitHostPort(s.Address); err != nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(ListenAddress), flag.ErrInvalidVal))
+	}
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(TLSKeyFile),
+				flag.ValidationError("certificate and key must be provided together")))
+	}
+	for _, f := range []struct {
+		flag string
+		path string
+	}{
+		{TLSCertFile, s.TLSCertFile},
+		{TLSKeyFile, s.TLSKeyFile},
+		{TLSClientCAFile, s.TLSClientCAFile},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(f.flag), flag.ErrInvalidPath))
+		}
+	}
+	if s.TLSClientCAFile != "" && s.TLSCertFile == "" && s.TLSConfig == nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(TLSClientCAFile),
+				flag.ValidationError("client certificates require TLS")))
+	}
+	if s.MaxConns < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(MaxConns), flag.ErrInvalidVal))
+	}
+	if s.IdleTimeout < 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(IdleTimeout), flag.ErrInvalidVal))
+	}
+	if s.Handler == nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(ListenAddress),
+				flag.ValidationError("no connection handler registered")))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() error {
+	cfg, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if s.listener, err = net.Listen("tcp", s.Address); err != nil {
+		return err
+	}
+	if s.IdleTimeout > 0 {
+		s.listener = &idleListener{Listener: s.listener, timeout: s.IdleTimeout}
+	}
+	if cfg != nil {
+		s.listener = tls.NewListener(s.listener, cfg)
+	}
+	s.conns = make(map[net.Conn]struct{})
+	log.Info("TCP server listening", "address", s.listener.Addr().String(), "tls", cfg != nil)
+	return nil
+}
+
+// ServeContext implements run.ServiceContext. On shutdown it stops accepting
+// connections, cancels the Handler context and waits for the connections to
+// drain until the shutdown timeout expires, after which the remaining
+// connections are closed.
+func (s *Service) ServeContext(ctx context.Context) error {
+	connCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var sem chan struct{}
+	if s.MaxConns > 0 {
+		sem = make(chan struct{}, s.MaxConns)
+	}
+
+	go func() {
+		var delay time.Duration
+		for {
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			conn, err := s.listener.Accept()
+			if err != nil {
+				if sem != nil {
+					<-sem
+				}
+				if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+					return
+				}
+				// back off on errors like running out of file descriptors,
+				// similar to net/http
+				delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+				log.Error("accept error", err, "retry_in", delay)
+				time.Sleep(delay)
+				continue
+			}
+			delay = 0
+			s.serve(connCtx, conn, sem)
+		}
+	}()
+
+	<-ctx.Done()
+	_ = s.listener.Close()
+	cancel()
+	s.drain()
+	return nil
+}
+
+// serve tracks the connection and runs the Handler in its own goroutine.
+func (s *Service) serve(ctx context.Context, conn net.Conn, sem chan struct{}) {
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	s.active.Add(1)
+	s.wg.Add(1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("connection handler panic", fmt.Errorf("%v", r),
+					"remote", conn.RemoteAddr().String(), "stack", string(debug.Stack()))
+			}
+			_ = conn.Close()
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			s.active.Add(-1)
+			if sem != nil {
+				<-sem
+			}
+			s.wg.Done()
+		}()
+		s.Handler(ctx, conn)
+	}()
+}
+
+// drain waits for the active connections to finish until the shutdown
+// timeout expires and closes the remaining ones.
+func (s *Service) drain() {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	select {
+	case <-done:
+		log.Info("graceful shutdown completed")
+		return
+	case <-time.After(timeout):
+	}
+
+	s.mu.Lock()
+	log.Error("graceful shutdown did not complete", context.DeadlineExceeded,
+		"timeout", timeout, "active_connections", len(s.conns))
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	<-done
+}
+
+// ActiveConns returns the number of connections currently being handled.
+func (s *Service) ActiveConns() int64 {
+	return s.active.Load()
+}
+
+// Addr returns the address the Service listens on. It is available after
+// PreRun.
+func (s *Service) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+func (s *Service) tlsConfig() (*tls.Config, error) {
+	if s.TLSConfig != nil {
+		return s.TLSConfig, nil
+	}
+	if s.TLSCertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(s.TLSCertFile, s.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if s.TLSClientCAFile != "" {
+		b, err := os.ReadFile(s.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.New("no valid certificates found in client CA bundle")
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// idleListener wraps accepted connections in an idleConn.
+type idleListener struct {
+	net.Listener
+	timeout time.Duration
+}
+
+func (l *idleListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &idleConn{Conn: conn, timeout: l.timeout}, nil
+}
+
+// idleConn extends the connection deadline on each read and write, so
+// deadlines set by the Handler are overridden.
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+var (
+	_ run.Initializer    = (*Service)(nil)
+	_ run.Config         = (*Service)(nil)
+	_ run.PreRunner      = (*Service)(nil)
+	_ run.ServiceContext = (*Service)(nil)
+)
@@ -0,0 +1,82 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package templates
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// templateSet holds the parsed templates, each page holding a clone of the
+// layouts and partials.
+type templateSet struct {
+	base  *template.Template
+	pages map[string]*template.Template
+	files []string
+}
+
+// parse parses the templates found in fsys.
+func (s *Service) parse(fsys fs.FS) (*templateSet, error) {
+	set := &templateSet{
+		base:  template.New("").Funcs(s.Funcs),
+		pages: make(map[string]*template.Template),
+	}
+
+	var pages []string
+	err := fs.WalkDir(fsys, ".", func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(file) != s.Extension {
+			return err
+		}
+		set.files = append(set.files, file)
+		if strings.HasPrefix(file, LayoutsDir+"/") || strings.HasPrefix(file, PartialsDir+"/") {
+			return parseFile(fsys, set.base, file)
+		}
+		pages = append(pages, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range pages {
+		t, err := set.base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err = parseFile(fsys, t, file); err != nil {
+			return nil, err
+		}
+		set.pages[strings.TrimSuffix(file, s.Extension)] = t
+	}
+
+	return set, nil
+}
+
+// parseFile parses the file as a template named by its path without
+// extension.
+func parseFile(fsys fs.FS, t *template.Template, file string) error {
+	b, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(file, path.Ext(file))
+	if _, err = t.New(name).Parse(string(b)); err != nil {
+		return fmt.Errorf("unable to parse template %s: %w", file, err)
+	}
+	return nil
+}
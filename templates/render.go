@@ -0,0 +1,80 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package templates
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+)
+
+var bufPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// Render renders the named page through the layout, or the named layout or
+// partial by itself, e.g. for rendering fragments. The output is buffered so
+// nothing is written if rendering fails. If w is an http.ResponseWriter
+// without Content-Type, it is set to HTML.
+func (s *Service) Render(w io.Writer, name string, data any) error {
+	set := s.set.Load()
+	if set == nil {
+		return ErrNotFound
+	}
+	var t *template.Template
+	if page, ok := set.pages[name]; ok {
+		if t = page.Lookup(path.Join(LayoutsDir, s.Layout)); t == nil {
+			// page without layout
+			t = page.Lookup(name)
+		}
+	} else if t = set.base.Lookup(name); t == nil {
+		return ErrNotFound
+	}
+
+	buf := bufPool.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		bufPool.Put(buf)
+	}()
+	if err := t.Execute(buf, data); err != nil {
+		return err
+	}
+
+	if rw, ok := w.(http.ResponseWriter); ok && rw.Header().Get("Content-Type") == "" {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// Handler returns a handler rendering the named page with the data returned
+// by fn, which may be nil. Render errors are logged and result in a 500
+// response.
+func (s *Service) Handler(name string, fn func(r *http.Request) any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data any
+		if fn != nil {
+			data = fn(r)
+		}
+		if err := s.Render(w, name, data); err != nil {
+			log.Error("unable to render template", err, "name", name)
+			http.Error(w, http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package templates provides an HTML template renderer for use with the run
+// package, supporting layouts, partials and hot reload during development.
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("templates", "HTML template renderer")
+
+// package flags.
+const (
+	defaultExtension = ".html"
+	defaultLayout    = "base"
+
+	Dir       = "templates-dir"
+	Extension = "templates-extension"
+	Layout    = "templates-layout"
+	Reload    = "templates-reload"
+)
+
+// Template directories.
+const (
+	LayoutsDir  = "layouts"
+	PartialsDir = "partials"
+)
+
+// ErrNotFound is returned when rendering an unknown template.
+var ErrNotFound = errors.New("template not found")
+
+// FileWatcher is implemented by the filewatcher.Service and provides
+// notifications of file changes.
+type FileWatcher interface {
+	AddWatcher(name, fqn string) (<-chan []byte, error)
+}
+
+// Service implements run.Config and run.PreRunner for an HTML template
+// renderer. Templates are parsed from Dir or, if not set, from FS (e.g. an
+// embed.FS).
+//
+// Templates in the layouts directory are layouts and templates in the
+// partials directory are partials, available to all pages by their path
+// without extension, e.g. {{template "partials/nav" .}}. All other templates
+// are pages, named by their path without extension, e.g. "users/show". A
+// page is rendered through the layout named by Layout if it exists, with the
+// page defining the blocks used by the layout, e.g. {{define "content"}}.
+type Service struct {
+	Prefix    string
+	Dir       string
+	FS        fs.FS
+	Extension string
+	Layout    string
+	// Reload re-parses the templates in Dir once they change, for use during
+	// development. It requires Watcher to be set.
+	Reload bool
+	// Watcher optionally holds the file watcher (e.g. filewatcher.Service)
+	// used to reload the templates.
+	Watcher FileWatcher
+	// Funcs holds the functions available to the templates.
+	Funcs template.FuncMap
+
+	set atomic.Pointer[templateSet]
+}
+
+func (s *Service) prefix(v string) string {
+	if s.Prefix != "" {
+		return s.Prefix + "-" + v
+	}
+	return v
+}
+
+// Name implements run.Unit.
+func (s *Service) Name() string {
+	return s.prefix("templates")
+}
+
+// Initialize implements run.Initializer.
+func (s *Service) Initialize() {
+	if s.Extension == "" {
+		s.Extension = defaultExtension
+	}
+	if s.Layout == "" {
+		s.Layout = defaultLayout
+	}
+}
+
+// FlagSet implements run.Config.
+func (s *Service) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Template options")
+
+	flags.StringVar(&s.Dir, s.prefix(Dir), s.Dir,
+		"template directory (overrides the embedded templates)")
+
+	flags.StringVar(&s.Extension, s.prefix(Extension), s.Extension,
+		"file extension of the templates")
+
+	flags.StringVar(&s.Layout, s.prefix(Layout), s.Layout,
+		"layout used for rendering pages")
+
+	flags.BoolVar(&s.Reload, s.prefix(Reload), s.Reload,
+		"re-parse the templates on change (development)")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (s *Service) Validate() error {
+	var mErr error
+
+	if s.Dir != "" {
+		if fi, err := os.Stat(s.Dir); err != nil || !fi.IsDir() {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(s.prefix(Dir), flag.ErrInvalidPath))
+		}
+	} else if s.FS == nil {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(s.prefix(Dir), flag.ErrRequired))
+	}
+	if s.Reload {
+		switch {
+		case s.Dir == "":
+			mErr = multierror.Append(mErr, flag.NewValidationError(s.prefix(Reload),
+				flag.ValidationError("reload requires a template directory")))
+		case s.Watcher == nil:
+			mErr = multierror.Append(mErr, flag.NewValidationError(s.prefix(Reload),
+				flag.ValidationError("reload requires a file watcher")))
+		}
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (s *Service) PreRun() error {
+	fsys := s.FS
+	if s.Dir != "" {
+		fsys = os.DirFS(s.Dir)
+	}
+
+	set, err := s.parse(fsys)
+	if err != nil {
+		return err
+	}
+	s.set.Store(set)
+	log.Info("parsed templates", "pages", len(set.pages))
+
+	if !s.Reload {
+		return nil
+	}
+	for _, file := range set.files {
+		ch, err := s.Watcher.AddWatcher(s.prefix("templates-"+file), filepath.Join(s.Dir, filepath.FromSlash(file)))
+		if err != nil {
+			return fmt.Errorf("unable to watch template %s: %w", file, err)
+		}
+		go func() {
+			// the channel is closed once the file watcher stops; failing
+			// templates keep the previous templates in use
+			for range ch {
+				set, err := s.parse(fsys)
+				if err != nil {
+					log.Error("unable to reload templates", err, "file", file)
+					continue
+				}
+				s.set.Store(set)
+				log.Info("reloaded templates", "file", file)
+			}
+		}()
+	}
+
+	return nil
+}
+
+var (
+	_ run.Initializer = (*Service)(nil)
+	_ run.Config      = (*Service)(nil)
+	_ run.PreRunner   = (*Service)(nil)
+)
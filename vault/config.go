@@ -0,0 +1,339 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vault provides a run handler integrating HashiCorp Vault secrets.
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("vault", "Vault secrets")
+
+// Supported authentication methods.
+const (
+	AuthToken      = "token"
+	AuthAppRole    = "approle"
+	AuthKubernetes = "kubernetes"
+)
+
+// package flags.
+const (
+	defaultTimeout             = 30 * time.Second
+	defaultRefreshInterval     = 5 * time.Minute
+	defaultKubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	Address             = "vault-address"
+	Namespace           = "vault-namespace"
+	CAFile              = "vault-ca-file"
+	AuthMethod          = "vault-auth-method"
+	AuthMount           = "vault-auth-mount"
+	Token               = "vault-token"
+	RoleID              = "vault-role-id"
+	SecretID            = "vault-secret-id"
+	KubernetesRole      = "vault-kubernetes-role"
+	KubernetesTokenFile = "vault-kubernetes-token-file"
+	Paths               = "vault-secret-path"
+	RefreshInterval     = "vault-refresh-interval"
+	Timeout             = "vault-timeout"
+)
+
+var (
+	// ErrSecretNotFound is returned if a secret path holds no secret.
+	ErrSecretNotFound = errors.New("vault secret not found")
+	// ErrTokenExpired is logged if a configured token expires and can not be
+	// renewed.
+	ErrTokenExpired = errors.New("vault token expires and can not be renewed")
+)
+
+// Config implements run.Config to allow configuration of a Vault client. It
+// authenticates and fetches the configured secret paths in PreRun. The
+// ServeContext renews the token and secret leases and refreshes secrets
+// when their lease expires, notifying the registered rotation callbacks.
+//
+// Other handlers can consume secrets by registering their configuration
+// strings with Expand before PreRun, e.g. to fill a database DSN.
+type Config struct {
+	Prefix string
+
+	Address         string
+	Namespace       string
+	CAFile          string
+	AuthMethod      string
+	AuthMount       string
+	Token           string
+	RoleID          string
+	SecretID        string
+	KubernetesRole  string
+	KubernetesToken string
+	// Paths holds the secret paths fetched in PreRun.
+	Paths           []string
+	RefreshInterval time.Duration
+	Timeout         time.Duration
+
+	client *api.Client
+	auth   *api.Secret
+
+	mtx      sync.RWMutex
+	secrets  map[string]*api.Secret
+	bindings []binding
+	rotate   map[string][]func(data map[string]any)
+}
+
+func (c *Config) prefix(s string) string {
+	if c.Prefix != "" {
+		return c.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (c *Config) Name() string {
+	return c.prefix("vault")
+}
+
+// FlagSet implements run.Config.
+func (c *Config) FlagSet() *run.FlagSet {
+	if envAddress := os.Getenv("VAULT_ADDR"); envAddress != "" && c.Address == "" {
+		c.Address = envAddress
+	}
+	if envToken := os.Getenv("VAULT_TOKEN"); envToken != "" && c.Token == "" {
+		c.Token = envToken
+	}
+	if envSecretID := os.Getenv("VAULT_SECRET_ID"); envSecretID != "" && c.SecretID == "" {
+		c.SecretID = envSecretID
+	}
+	if c.AuthMethod == "" {
+		c.AuthMethod = AuthToken
+	}
+	if c.KubernetesToken == "" {
+		c.KubernetesToken = defaultKubernetesTokenFile
+	}
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = defaultRefreshInterval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultTimeout
+	}
+
+	flags := run.NewFlagSet("Vault options")
+
+	flags.StringVar(&c.Address, c.prefix(Address),
+		c.Address, "Vault server address")
+
+	flags.StringVar(&c.Namespace, c.prefix(Namespace),
+		c.Namespace, "Vault namespace")
+
+	flags.StringVar(&c.CAFile, c.prefix(CAFile),
+		c.CAFile, "path to CA file for verifying the Vault server")
+
+	flags.StringVar(&c.AuthMethod, c.prefix(AuthMethod),
+		c.AuthMethod, "authentication method (token, approle or kubernetes)")
+
+	flags.StringVar(&c.AuthMount, c.prefix(AuthMount),
+		c.AuthMount, "mount path of the auth method (defaults to the method name)")
+
+	flags.SensitiveStringVar(&c.Token, c.prefix(Token),
+		c.Token, "Vault token for token authentication")
+
+	flags.StringVar(&c.RoleID, c.prefix(RoleID),
+		c.RoleID, "role ID for approle authentication")
+
+	flags.SensitiveStringVar(&c.SecretID, c.prefix(SecretID),
+		c.SecretID, "secret ID for approle authentication")
+
+	flags.StringVar(&c.KubernetesRole, c.prefix(KubernetesRole),
+		c.KubernetesRole, "role for kubernetes authentication")
+
+	flags.StringVar(&c.KubernetesToken, c.prefix(KubernetesTokenFile),
+		c.KubernetesToken, "path to the service account token for kubernetes authentication")
+
+	flags.StringArrayVar(&c.Paths, c.prefix(Paths),
+		c.Paths, "secret path to fetch (can be repeated)")
+
+	flags.DurationVar(&c.RefreshInterval, c.prefix(RefreshInterval),
+		c.RefreshInterval, "refresh interval for secrets without a lease")
+
+	flags.DurationVar(&c.Timeout, c.prefix(Timeout),
+		c.Timeout, "timeout for Vault requests")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (c *Config) Validate() error {
+	var mErr error
+
+	if c.Address == "" {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(Address), flag.ErrRequired))
+	}
+	if c.CAFile != "" {
+		if _, err := os.Stat(c.CAFile); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(CAFile), flag.ErrInvalidPath))
+		}
+	}
+
+	switch c.AuthMethod {
+	case AuthToken:
+		if c.Token == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(Token), flag.ErrRequired))
+		}
+	case AuthAppRole:
+		if c.RoleID == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(RoleID), flag.ErrRequired))
+		}
+	case AuthKubernetes:
+		if c.KubernetesRole == "" {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(c.prefix(KubernetesRole), flag.ErrRequired))
+		}
+	default:
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(AuthMethod), flag.ErrInvalidVal))
+	}
+
+	if c.RefreshInterval <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(c.prefix(RefreshInterval), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// PreRun implements run.PreRunner.
+func (c *Config) PreRun() error {
+	cfg := api.DefaultConfig()
+	cfg.Address = c.Address
+	cfg.Timeout = c.Timeout
+	if c.CAFile != "" {
+		if err := cfg.ConfigureTLS(&api.TLSConfig{CACert: c.CAFile}); err != nil {
+			return fmt.Errorf("unable to configure vault TLS: %w", err)
+		}
+	}
+
+	var err error
+	if c.client, err = api.NewClient(cfg); err != nil {
+		return fmt.Errorf("unable to create vault client: %w", err)
+	}
+	if c.Namespace != "" {
+		c.client.SetNamespace(c.Namespace)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	if err = c.login(ctx); err != nil {
+		return err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.secrets == nil {
+		c.secrets = make(map[string]*api.Secret)
+	}
+	var mErr error
+	for _, path := range c.Paths {
+		s, err := c.read(ctx, path)
+		if err != nil {
+			mErr = multierror.Append(mErr, err)
+			continue
+		}
+		c.secrets[path] = s
+	}
+	if mErr != nil {
+		return mErr
+	}
+	for _, b := range c.bindings {
+		if err = c.expand(b); err != nil {
+			mErr = multierror.Append(mErr, err)
+		}
+	}
+	return mErr
+}
+
+// login authenticates with the configured auth method.
+func (c *Config) login(ctx context.Context) error {
+	if c.AuthMethod == AuthToken {
+		c.client.SetToken(c.Token)
+		s, err := c.client.Auth().Token().LookupSelfWithContext(ctx)
+		if err != nil {
+			return fmt.Errorf("vault token lookup failed: %w", err)
+		}
+		renewable, _ := s.TokenIsRenewable()
+		ttl, _ := s.TokenTTL()
+		c.auth = &api.Secret{Auth: &api.SecretAuth{
+			ClientToken:   c.Token,
+			Renewable:     renewable,
+			LeaseDuration: int(ttl.Seconds()),
+		}}
+		return nil
+	}
+
+	mount := c.AuthMount
+	if mount == "" {
+		mount = c.AuthMethod
+	}
+	data := map[string]any{}
+	switch c.AuthMethod {
+	case AuthAppRole:
+		data["role_id"] = c.RoleID
+		data["secret_id"] = c.SecretID
+	case AuthKubernetes:
+		jwt, err := os.ReadFile(c.KubernetesToken)
+		if err != nil {
+			return fmt.Errorf("unable to read kubernetes token: %w", err)
+		}
+		data["role"] = c.KubernetesRole
+		data["jwt"] = strings.TrimSpace(string(jwt))
+	}
+
+	s, err := c.client.Logical().WriteWithContext(ctx, "auth/"+mount+"/login", data)
+	if err != nil {
+		return fmt.Errorf("vault %s login failed: %w", c.AuthMethod, err)
+	}
+	if s == nil || s.Auth == nil {
+		return fmt.Errorf("vault %s login returned no token", c.AuthMethod)
+	}
+	c.client.SetToken(s.Auth.ClientToken)
+	c.auth = s
+	return nil
+}
+
+// Client returns the authenticated Vault client.
+func (c *Config) Client() *api.Client {
+	return c.client
+}
+
+var (
+	_ run.Config         = (*Config)(nil)
+	_ run.PreRunner      = (*Config)(nil)
+	_ run.ServiceContext = (*Config)(nil)
+)
@@ -0,0 +1,152 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// ServeContext implements run.ServiceContext.
+func (c *Config) ServeContext(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.watchToken(ctx)
+	}()
+	for _, path := range c.Paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.watchSecret(ctx, path)
+		}()
+	}
+
+	wg.Wait()
+	return nil
+}
+
+// watch renews the secret until it can no longer be renewed. It returns
+// false if the context is canceled.
+func (c *Config) watch(ctx context.Context, s *api.Secret) bool {
+	w, err := c.client.NewLifetimeWatcher(&api.LifetimeWatcherInput{Secret: s})
+	if err != nil {
+		log.Error("unable to watch lease", err)
+		return c.sleep(ctx, c.RefreshInterval)
+	}
+	go w.Start()
+	defer w.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case err = <-w.DoneCh():
+			if err != nil {
+				log.Error("lease renewal failed", err)
+			}
+			return true
+		case <-w.RenewCh():
+		}
+	}
+}
+
+// watchToken renews the token and logs in again once the token can no
+// longer be renewed.
+func (c *Config) watchToken(ctx context.Context) {
+	for {
+		switch auth := c.auth.Auth; {
+		case auth.Renewable:
+			if !c.watch(ctx, c.auth) {
+				return
+			}
+		case auth.LeaseDuration > 0:
+			if !c.sleep(ctx, time.Duration(auth.LeaseDuration)*time.Second*2/3) {
+				return
+			}
+		default:
+			// token without expiry
+			return
+		}
+		if c.AuthMethod == AuthToken {
+			log.Error("unable to keep vault token alive", ErrTokenExpired)
+			return
+		}
+
+		for {
+			rctx, cancel := context.WithTimeout(ctx, c.Timeout)
+			err := c.login(rctx)
+			cancel()
+			if err == nil {
+				break
+			}
+			log.Error("vault login failed", err)
+			if !c.sleep(ctx, c.RefreshInterval) {
+				return
+			}
+		}
+		log.Info("vault token renewed by login")
+	}
+}
+
+// watchSecret keeps the secret at path current. Leased secrets are renewed
+// and fetched again once their lease expires, other secrets are refreshed
+// every RefreshInterval.
+func (c *Config) watchSecret(ctx context.Context, path string) {
+	for {
+		c.mtx.RLock()
+		s := c.secrets[path]
+		c.mtx.RUnlock()
+
+		if s.LeaseID != "" {
+			if !c.watch(ctx, s) {
+				return
+			}
+		} else if !c.sleep(ctx, c.RefreshInterval) {
+			return
+		}
+
+		for {
+			rctx, cancel := context.WithTimeout(ctx, c.Timeout)
+			s, err := c.read(rctx, path)
+			cancel()
+			if err == nil {
+				c.update(path, s)
+				break
+			}
+			log.Error("unable to refresh secret", err, "path", path)
+			if !c.sleep(ctx, c.RefreshInterval) {
+				return
+			}
+		}
+	}
+}
+
+// sleep waits for d and returns false if the context is canceled first.
+func (c *Config) sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
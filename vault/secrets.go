@@ -0,0 +1,165 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// reference matches secret references of the form ${vault:<path>#<key>}.
+var reference = regexp.MustCompile(`\$\{vault:([^#}]+)#([^}]+)\}`)
+
+type binding struct {
+	dst      *string
+	template string
+}
+
+// read fetches the secret at path.
+func (c *Config) read(ctx context.Context, path string) (*api.Secret, error) {
+	s, err := c.client.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read vault secret %s: %w", path, err)
+	}
+	if s == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, path)
+	}
+	return s, nil
+}
+
+// data returns the secret data, unwrapping KV version 2 secrets.
+func data(s *api.Secret) map[string]any {
+	if s == nil {
+		return nil
+	}
+	if d, ok := s.Data["data"].(map[string]any); ok {
+		if _, ok = s.Data["metadata"]; ok {
+			return d
+		}
+	}
+	return s.Data
+}
+
+// Secret returns the data of the secret at path. Only paths configured in
+// Paths are available.
+func (c *Config) Secret(path string) (map[string]any, bool) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	s, ok := c.secrets[path]
+	return data(s), ok
+}
+
+// Value returns the value of key in the secret at path as a string.
+func (c *Config) Value(path, key string) (string, bool) {
+	d, ok := c.Secret(path)
+	if !ok {
+		return "", false
+	}
+	v, ok := d[key]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+// Expand registers configuration strings containing secret references of
+// the form ${vault:<path>#<key>}. The references are replaced with the secret
+// values in PreRun and again whenever the secret rotates. The referenced
+// paths need to be configured in Paths.
+//
+// Expand needs to be called before the PreRun of the handler consuming the
+// strings, e.g.:
+//
+//	pg := &postgresql.Config{}
+//	v := &vault.Config{Paths: []string{"database/creds/app"}}
+//	v.Expand(&pg.DSN)
+//
+// with the DSN flag set to
+// postgres://${vault:database/creds/app#username}:${vault:database/creds/app#password}@db/app.
+func (c *Config) Expand(dst ...*string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, d := range dst {
+		b := binding{dst: d, template: *d}
+		c.bindings = append(c.bindings, b)
+		if c.secrets != nil {
+			if err := c.expand(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expand replaces the secret references of the binding. The caller needs to
+// hold the lock.
+func (c *Config) expand(b binding) error {
+	var err error
+	*b.dst = reference.ReplaceAllStringFunc(b.template, func(ref string) string {
+		m := reference.FindStringSubmatch(ref)
+		s, ok := c.secrets[m[1]]
+		if !ok {
+			err = fmt.Errorf("%w: %s", ErrSecretNotFound, m[1])
+			return ref
+		}
+		v, ok := data(s)[m[2]]
+		if !ok {
+			err = fmt.Errorf("%w: %s#%s", ErrSecretNotFound, m[1], m[2])
+			return ref
+		}
+		return fmt.Sprint(v)
+	})
+	return err
+}
+
+// OnRotate registers a callback which is called with the new secret data
+// whenever the secret at path changes.
+func (c *Config) OnRotate(path string, fn func(data map[string]any)) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.rotate == nil {
+		c.rotate = make(map[string][]func(map[string]any))
+	}
+	c.rotate[path] = append(c.rotate[path], fn)
+}
+
+// update stores the refreshed secret and notifies the rotation callbacks and
+// bindings if the secret data changed.
+func (c *Config) update(path string, s *api.Secret) {
+	c.mtx.Lock()
+	changed := !reflect.DeepEqual(data(c.secrets[path]), data(s))
+	c.secrets[path] = s
+	var callbacks []func(map[string]any)
+	if changed {
+		for _, b := range c.bindings {
+			if err := c.expand(b); err != nil {
+				log.Error("unable to expand secret references", err)
+			}
+		}
+		callbacks = c.rotate[path]
+	}
+	c.mtx.Unlock()
+
+	if changed {
+		log.Info("secret rotated", "path", path)
+	}
+	for _, fn := range callbacks {
+		fn(data(s))
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Delivery holds a pending delivery of an event to an endpoint.
+type Delivery struct {
+	ID          string    `json:"id"`
+	Endpoint    string    `json:"endpoint"`
+	EventID     string    `json:"eventId"`
+	EventType   string    `json:"eventType"`
+	Payload     []byte    `json:"payload"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Attempt     int       `json:"attempt"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// endpoint holds a registered endpoint and its circuit breaker state.
+type endpoint struct {
+	Endpoint
+
+	mtx       sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// paused returns the time until which deliveries to the endpoint are paused.
+func (e *endpoint) paused(now time.Time) (time.Time, bool) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.openUntil, now.Before(e.openUntil)
+}
+
+// record records the outcome of a delivery, pausing the endpoint once the
+// threshold of consecutive failures is reached. A failure after the pause
+// pauses it again.
+func (e *endpoint) record(success bool, threshold int, cooldown time.Duration) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if success {
+		e.failures = 0
+		e.openUntil = time.Time{}
+		return
+	}
+	e.failures++
+	if threshold > 0 && e.failures >= threshold {
+		if e.failures == threshold {
+			log.Info("pausing webhook deliveries", "endpoint", e.Name, "cooldown", cooldown.String())
+		}
+		e.openUntil = time.Now().Add(cooldown)
+	}
+}
+
+// deliver attempts the delivery, rescheduling it on failure.
+func (d *Dispatcher) deliver(ctx context.Context, dl *Delivery) {
+	// store updates must succeed even if the delivery was canceled
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	e := d.endpoint(dl.Endpoint)
+	if e == nil {
+		d.drop(storeCtx, dl, ErrUnknownEndpoint)
+		return
+	}
+	if until, ok := e.paused(time.Now()); ok {
+		dl.NextAttempt = until
+		if err := d.store.update(storeCtx, dl); err != nil {
+			log.Error("unable to reschedule webhook delivery", err, "id", dl.ID)
+		}
+		return
+	}
+
+	dl.Attempt++
+	err := d.post(ctx, e, dl)
+	if err == nil {
+		e.record(true, d.BreakerThreshold, d.BreakerCooldown)
+		if err = d.store.remove(storeCtx, dl.ID); err != nil {
+			log.Error("unable to remove webhook delivery", err, "id", dl.ID)
+		}
+		log.Debug("delivered webhook", "id", dl.ID, "endpoint", dl.Endpoint, "attempt", dl.Attempt)
+		return
+	}
+
+	if ctx.Err() != nil {
+		// canceled on shutdown, retry without counting the attempt
+		dl.Attempt--
+		dl.NextAttempt = time.Now()
+		if err = d.store.update(storeCtx, dl); err != nil {
+			log.Error("unable to reschedule webhook delivery", err, "id", dl.ID)
+		}
+		return
+	}
+
+	e.record(false, d.BreakerThreshold, d.BreakerCooldown)
+	dl.LastError = err.Error()
+	if dl.Attempt >= d.MaxAttempts {
+		d.drop(storeCtx, dl, err)
+		return
+	}
+	dl.NextAttempt = time.Now().Add(d.backoff(dl.Attempt))
+	log.Debug("webhook delivery failed", "id", dl.ID, "endpoint", dl.Endpoint,
+		"attempt", dl.Attempt, "error", err.Error())
+	if err = d.store.update(storeCtx, dl); err != nil {
+		log.Error("unable to reschedule webhook delivery", err, "id", dl.ID)
+	}
+}
+
+// drop removes the delivery which can't be delivered.
+func (d *Dispatcher) drop(ctx context.Context, dl *Delivery, err error) {
+	log.Error("dropping webhook delivery", err, "id", dl.ID, "endpoint", dl.Endpoint,
+		"event", dl.EventType, "attempts", dl.Attempt)
+	if rErr := d.store.remove(ctx, dl.ID); rErr != nil {
+		log.Error("unable to remove webhook delivery", rErr, "id", dl.ID)
+	}
+	if d.OnFailure != nil {
+		d.OnFailure(dl, err)
+	}
+}
+
+// post sends the signed payload to the endpoint. Responses other than 2xx
+// are failures.
+func (d *Dispatcher) post(ctx context.Context, e *endpoint, dl *Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return err
+	}
+	ts := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, dl.ID)
+	req.Header.Set(HeaderEvent, dl.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+	req.Header.Set(HeaderAttempt, strconv.Itoa(dl.Attempt))
+	secret := e.Secret
+	if secret == "" {
+		secret = d.Secret
+	}
+	if secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, dl.ID, ts, dl.Payload))
+	}
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// drain the response to reuse the connection
+		_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%w: %s", ErrDeliveryFailed, res.Status)
+	}
+	return nil
+}
+
+// backoff returns the time to wait before the next attempt.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	b := d.RetryBackoff
+	for i := 1; i < attempt && b < d.RetryMaxBackoff; i++ {
+		b *= 2
+	}
+	b = min(b, d.RetryMaxBackoff)
+	// add up to 20% jitter to spread retries of deliveries failing together
+	return b + time.Duration(mrand.Int64N(int64(b)/5+1))
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,547 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webhooks provides a webhook dispatcher delivering signed events to
+// registered endpoints with retries and per-endpoint circuit breaking.
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/basvanbeek/multierror"
+	"github.com/basvanbeek/run"
+	"github.com/basvanbeek/run/pkg/flag"
+	"github.com/basvanbeek/telemetry/scope"
+)
+
+var log = scope.Register("webhooks", "webhook dispatcher")
+
+// package flags.
+const (
+	defaultStore            = StoreMemory
+	defaultKeyPrefix        = "{webhooks}:"
+	defaultTable            = "webhook_deliveries"
+	defaultWorkers          = 10
+	defaultPollInterval     = time.Second
+	defaultTimeout          = 10 * time.Second
+	defaultMaxAttempts      = 10
+	defaultRetryBackoff     = 5 * time.Second
+	defaultRetryMaxBackoff  = time.Hour
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = time.Minute
+	defaultShutdownTimeout  = 30 * time.Second
+
+	Endpoints        = "webhooks-endpoints"
+	Secret           = "webhooks-secret"
+	Store            = "webhooks-store"
+	KeyPrefix        = "webhooks-key-prefix"
+	Table            = "webhooks-table"
+	CreateTables     = "webhooks-create-tables"
+	Workers          = "webhooks-workers"
+	PollInterval     = "webhooks-poll-interval"
+	Timeout          = "webhooks-timeout"
+	MaxAttempts      = "webhooks-max-attempts"
+	RetryBackoff     = "webhooks-retry-backoff"
+	RetryMaxBackoff  = "webhooks-retry-max-backoff"
+	BreakerThreshold = "webhooks-breaker-threshold"
+	BreakerCooldown  = "webhooks-breaker-cooldown"
+	ShutdownTimeout  = "webhooks-shutdown-timeout"
+)
+
+// Supported stores of pending deliveries.
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+	StoreSQL    = "sql"
+)
+
+// Dispatcher errors.
+var (
+	ErrUnknownEndpoint = errors.New("unknown webhook endpoint")
+	ErrDeliveryFailed  = errors.New("webhook delivery failed")
+)
+
+var tableName = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)?$`)
+
+// Endpoint holds a webhook endpoint.
+type Endpoint struct {
+	Name string
+	URL  string
+	// Secret optionally overrides the secret used to sign the payloads.
+	Secret string
+	// Events optionally holds the event types delivered to the endpoint. A
+	// trailing "*" matches event types by prefix, e.g. "order.*". If empty,
+	// all events are delivered.
+	Events []string
+}
+
+func (e *Endpoint) accepts(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, t := range e.Events {
+		if t == eventType || strings.HasSuffix(t, "*") && strings.HasPrefix(eventType, t[:len(t)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatcher implements run.Config and run.ServiceContext for a webhook
+// dispatcher. Events passed to Send are delivered to the endpoints accepting
+// them, signed with HMAC-SHA256 (see Sign). Failed deliveries are retried
+// with exponential backoff until MaxAttempts is reached, after which they
+// are dropped and passed to OnFailure. Endpoints failing BreakerThreshold
+// consecutive deliveries are paused for BreakerCooldown.
+//
+// Pending deliveries are held in memory or, to survive restarts and share
+// them between instances, in Redis or a SQL (MySQL) database, e.g. the
+// dbpool handler. Deliveries are at least once, receivers should use the
+// Webhook-Id header to detect duplicates. On shutdown due deliveries are
+// drained until ShutdownTimeout expires.
+//
+// Initialize sets the zero-valued fields to their defaults. Set
+// BreakerThreshold to a negative value, or to 0 through its flag, to disable
+// the circuit breaker.
+type Dispatcher struct {
+	Prefix string
+
+	// Endpoints holds the "name=url" endpoints configured by flag. Use
+	// AddEndpoint to register endpoints with their own secret or events.
+	Endpoints map[string]string
+	// Secret holds the default secret used to sign the payloads.
+	Secret string
+
+	Store string
+	// Redis returns the client of the redis store, e.g. the Pool method of
+	// the redis handler.
+	Redis     func() redis.UniversalClient
+	KeyPrefix string
+	// DB returns the database of the sql store, e.g. the Pool method of the
+	// dbpool handler.
+	DB           func() *sql.DB
+	Table        string
+	CreateTables bool
+
+	Workers          int
+	PollInterval     time.Duration
+	Timeout          time.Duration
+	MaxAttempts      int
+	RetryBackoff     time.Duration
+	RetryMaxBackoff  time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	ShutdownTimeout  time.Duration
+
+	// Client optionally holds the http client used for the deliveries.
+	Client *http.Client
+	// OnFailure is optionally called for deliveries which are dropped.
+	OnFailure func(d *Delivery, err error)
+
+	mtx       sync.RWMutex
+	endpoints map[string]*endpoint
+	store     store
+	wake      chan struct{}
+}
+
+func (d *Dispatcher) prefix(s string) string {
+	if d.Prefix != "" {
+		return d.Prefix + "-" + s
+	}
+	return s
+}
+
+// Name implements run.Unit.
+func (d *Dispatcher) Name() string {
+	return d.prefix("webhooks")
+}
+
+// Initialize implements run.Initializer.
+func (d *Dispatcher) Initialize() {
+	if d.Store == "" {
+		d.Store = defaultStore
+	}
+	if d.KeyPrefix == "" {
+		d.KeyPrefix = defaultKeyPrefix
+	}
+	if d.Table == "" {
+		d.Table = defaultTable
+	}
+	if d.Workers == 0 {
+		d.Workers = defaultWorkers
+	}
+	if d.PollInterval == 0 {
+		d.PollInterval = defaultPollInterval
+	}
+	if d.Timeout == 0 {
+		d.Timeout = defaultTimeout
+	}
+	if d.MaxAttempts == 0 {
+		d.MaxAttempts = defaultMaxAttempts
+	}
+	if d.RetryBackoff == 0 {
+		d.RetryBackoff = defaultRetryBackoff
+	}
+	if d.RetryMaxBackoff == 0 {
+		d.RetryMaxBackoff = defaultRetryMaxBackoff
+	}
+	if d.BreakerThreshold == 0 {
+		d.BreakerThreshold = defaultBreakerThreshold
+	}
+	if d.BreakerCooldown == 0 {
+		d.BreakerCooldown = defaultBreakerCooldown
+	}
+	if d.ShutdownTimeout == 0 {
+		d.ShutdownTimeout = defaultShutdownTimeout
+	}
+}
+
+// FlagSet implements run.Config.
+func (d *Dispatcher) FlagSet() *run.FlagSet {
+	flags := run.NewFlagSet("Webhook options")
+
+	flags.StringToStringVar(&d.Endpoints, d.prefix(Endpoints),
+		d.Endpoints, `webhook endpoints as "name=url" pairs`)
+
+	flags.StringVar(&d.Secret, d.prefix(Secret),
+		d.Secret, "secret used to sign the webhook payloads")
+
+	flags.StringVar(&d.Store, d.prefix(Store),
+		d.Store, "store of pending deliveries ("+StoreMemory+", "+StoreRedis+" or "+StoreSQL+")")
+
+	flags.StringVar(&d.KeyPrefix, d.prefix(KeyPrefix),
+		d.KeyPrefix, "prefix of the Redis keys")
+
+	flags.StringVar(&d.Table, d.prefix(Table),
+		d.Table, "database table holding the pending deliveries")
+
+	flags.BoolVar(&d.CreateTables, d.prefix(CreateTables),
+		d.CreateTables, "create the database table if it doesn't exist")
+
+	flags.IntVar(&d.Workers, d.prefix(Workers),
+		d.Workers, "number of concurrent deliveries")
+
+	flags.DurationVar(&d.PollInterval, d.prefix(PollInterval),
+		d.PollInterval, "interval for polling due deliveries")
+
+	flags.DurationVar(&d.Timeout, d.prefix(Timeout),
+		d.Timeout, "timeout of a delivery attempt")
+
+	flags.IntVar(&d.MaxAttempts, d.prefix(MaxAttempts),
+		d.MaxAttempts, "max. number of delivery attempts")
+
+	flags.DurationVar(&d.RetryBackoff, d.prefix(RetryBackoff),
+		d.RetryBackoff, "backoff before the first retry, doubled for each next retry")
+
+	flags.DurationVar(&d.RetryMaxBackoff, d.prefix(RetryMaxBackoff),
+		d.RetryMaxBackoff, "max. backoff between retries")
+
+	flags.IntVar(&d.BreakerThreshold, d.prefix(BreakerThreshold),
+		d.BreakerThreshold, "consecutive failures pausing deliveries to an endpoint (0 or negative disables)")
+
+	flags.DurationVar(&d.BreakerCooldown, d.prefix(BreakerCooldown),
+		d.BreakerCooldown, "time deliveries to a failing endpoint are paused")
+
+	flags.DurationVar(&d.ShutdownTimeout, d.prefix(ShutdownTimeout),
+		d.ShutdownTimeout, "max. time to drain due deliveries on shutdown")
+
+	return flags
+}
+
+// Validate implements run.Config.
+func (d *Dispatcher) Validate() error {
+	var mErr error
+
+	for name, u := range d.Endpoints {
+		if err := d.AddEndpoint(Endpoint{Name: name, URL: u}); err != nil {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(d.prefix(Endpoints), fmt.Errorf("%s: %w", name, err)))
+		}
+	}
+	switch d.Store {
+	case StoreMemory:
+	case StoreRedis:
+		if d.Redis == nil {
+			mErr = multierror.Append(mErr, flag.NewValidationError(d.prefix(Store),
+				flag.ValidationError("redis store requires a redis client")))
+		}
+	case StoreSQL:
+		if d.DB == nil {
+			mErr = multierror.Append(mErr, flag.NewValidationError(d.prefix(Store),
+				flag.ValidationError("sql store requires a database")))
+		}
+		if !tableName.MatchString(d.Table) {
+			mErr = multierror.Append(mErr,
+				flag.NewValidationError(d.prefix(Table), flag.ErrInvalidVal))
+		}
+	default:
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(d.prefix(Store), flag.ErrInvalidVal))
+	}
+	if d.Workers < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(d.prefix(Workers), flag.ErrInvalidVal))
+	}
+	if d.PollInterval <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(d.prefix(PollInterval), flag.ErrInvalidVal))
+	}
+	if d.Timeout <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(d.prefix(Timeout), flag.ErrInvalidVal))
+	}
+	if d.MaxAttempts < 1 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(d.prefix(MaxAttempts), flag.ErrInvalidVal))
+	}
+	if d.RetryBackoff <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(d.prefix(RetryBackoff), flag.ErrInvalidVal))
+	}
+	if d.RetryMaxBackoff < d.RetryBackoff {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(d.prefix(RetryMaxBackoff), flag.ErrInvalidVal))
+	}
+	if d.BreakerThreshold > 0 && d.BreakerCooldown <= 0 {
+		mErr = multierror.Append(mErr,
+			flag.NewValidationError(d.prefix(BreakerCooldown), flag.ErrInvalidVal))
+	}
+
+	return mErr
+}
+
+// AddEndpoint registers the endpoint, replacing an endpoint of the same
+// name.
+func (d *Dispatcher) AddEndpoint(e Endpoint) error {
+	if e.Name == "" {
+		return errors.New("endpoint name is required")
+	}
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint url %q", e.URL)
+	}
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if d.endpoints == nil {
+		d.endpoints = make(map[string]*endpoint)
+	}
+	d.endpoints[e.Name] = &endpoint{Endpoint: e}
+	return nil
+}
+
+// RemoveEndpoint removes the named endpoint. Its pending deliveries are
+// dropped.
+func (d *Dispatcher) RemoveEndpoint(name string) {
+	d.mtx.Lock()
+	delete(d.endpoints, name)
+	d.mtx.Unlock()
+}
+
+func (d *Dispatcher) endpoint(name string) *endpoint {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	return d.endpoints[name]
+}
+
+// PreRun implements run.PreRunner.
+func (d *Dispatcher) PreRun() error {
+	switch d.Store {
+	case StoreRedis:
+		d.store = &redisStore{rdb: d.Redis(), prefix: d.KeyPrefix}
+	case StoreSQL:
+		d.store = &sqlStore{db: d.DB(), table: d.Table}
+	default:
+		d.store = &memoryStore{}
+	}
+	if d.CreateTables {
+		if s, ok := d.store.(*sqlStore); ok {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := s.createTable(ctx); err != nil {
+				return fmt.Errorf("unable to create webhooks table: %w", err)
+			}
+		}
+	}
+	if d.Client == nil {
+		d.Client = &http.Client{}
+	}
+	d.wake = make(chan struct{}, 1)
+	return nil
+}
+
+// Send queues the event for delivery to the endpoints accepting its type and
+// returns the event ID. The payload is delivered as is if it is a []byte or
+// json.RawMessage and marshaled to JSON otherwise.
+func (d *Dispatcher) Send(ctx context.Context, eventType string, payload any) (string, error) {
+	var body []byte
+	switch p := payload.(type) {
+	case []byte:
+		body = p
+	case json.RawMessage:
+		body = p
+	default:
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return "", fmt.Errorf("unable to marshal webhook payload: %w", err)
+		}
+	}
+
+	now := time.Now()
+	eventID := newID()
+	var deliveries []*Delivery
+	d.mtx.RLock()
+	for _, e := range d.endpoints {
+		if !e.accepts(eventType) {
+			continue
+		}
+		deliveries = append(deliveries, &Delivery{
+			ID:          newID(),
+			Endpoint:    e.Name,
+			EventID:     eventID,
+			EventType:   eventType,
+			Payload:     body,
+			CreatedAt:   now,
+			NextAttempt: now,
+		})
+	}
+	d.mtx.RUnlock()
+	if len(deliveries) == 0 {
+		return eventID, nil
+	}
+
+	if err := d.store.add(ctx, deliveries...); err != nil {
+		return "", fmt.Errorf("unable to queue webhook deliveries: %w", err)
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return eventID, nil
+}
+
+// Pending returns the number of pending deliveries.
+func (d *Dispatcher) Pending(ctx context.Context) (int, error) {
+	return d.store.pending(ctx)
+}
+
+// ServeContext implements run.ServiceContext. It delivers the due deliveries
+// until the context is canceled and drains them on shutdown.
+func (d *Dispatcher) ServeContext(ctx context.Context) error {
+	// deliveries continue during the drain, until its deadline
+	drainCtx, cancelDrain := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelDrain()
+
+	var (
+		wg    sync.WaitGroup
+		slots = make(chan struct{}, d.Workers)
+		done  = make(chan struct{}, 1)
+		t     = time.NewTicker(d.PollInterval)
+	)
+	defer t.Stop()
+
+	// dispatch claims due deliveries for the free workers, returning the
+	// number of claimed deliveries.
+	dispatch := func() int {
+		n := cap(slots) - len(slots)
+		if n == 0 {
+			return 0
+		}
+		deliveries, err := d.store.claim(drainCtx, time.Now(), d.lease(), n)
+		if err != nil {
+			log.Error("unable to claim webhook deliveries", err)
+			return 0
+		}
+		for _, dl := range deliveries {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-slots
+					wg.Done()
+					select {
+					case done <- struct{}{}:
+					default:
+					}
+				}()
+				d.deliver(drainCtx, dl)
+			}()
+		}
+		return len(deliveries)
+	}
+
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-t.C:
+		case <-d.wake:
+		case <-done:
+		}
+		if running {
+			dispatch()
+		}
+	}
+
+	log.Info("draining webhook deliveries")
+	deadline := time.NewTimer(d.ShutdownTimeout)
+	defer deadline.Stop()
+drain:
+	for {
+		if dispatch() == 0 && len(slots) == 0 {
+			break
+		}
+		select {
+		case <-deadline.C:
+			break drain
+		case <-done:
+		case <-t.C:
+		}
+	}
+	cancelDrain()
+	wg.Wait()
+
+	if n, err := d.store.pending(context.Background()); err == nil && n > 0 {
+		if d.Store == StoreMemory {
+			log.Info("dropping undelivered webhooks", "count", n)
+		} else {
+			log.Info("undelivered webhooks remain pending", "count", n)
+		}
+	}
+	return nil
+}
+
+// lease returns the time a claimed delivery is reserved for this instance.
+// Deliveries of crashed instances are retried once it expires.
+func (d *Dispatcher) lease() time.Duration {
+	return d.Timeout + time.Minute
+}
+
+var (
+	_ run.Initializer    = (*Dispatcher)(nil)
+	_ run.Config         = (*Dispatcher)(nil)
+	_ run.PreRunner      = (*Dispatcher)(nil)
+	_ run.ServiceContext = (*Dispatcher)(nil)
+)
@@ -0,0 +1,75 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook request headers.
+const (
+	HeaderID        = "Webhook-Id"
+	HeaderEvent     = "Webhook-Event"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderAttempt   = "Webhook-Attempt"
+	HeaderSignature = "Webhook-Signature"
+)
+
+// ErrInvalidSignature is returned by Verify for requests without valid
+// signature.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the signature of the payload: "v1=" followed by the hex
+// encoded HMAC-SHA256 of "<id>.<unix timestamp>.<payload>". Including the ID
+// and timestamp allows receivers to reject replayed requests.
+func Sign(secret, id string, ts time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id + "." + strconv.FormatInt(ts.Unix(), 10) + "."))
+	mac.Write(payload)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify verifies the signature of a webhook request as received by an
+// endpoint and returns its payload. Requests with a timestamp deviating more
+// than tolerance from the current time are rejected.
+func Verify(r *http.Request, secret string, tolerance time.Duration) ([]byte, error) {
+	sec, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	ts := time.Unix(sec, 0)
+	if d := time.Since(ts); d > tolerance || d < -tolerance {
+		return nil, ErrInvalidSignature
+	}
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	expected := Sign(secret, r.Header.Get(HeaderID), ts, payload)
+	for _, sig := range strings.Split(r.Header.Get(HeaderSignature), " ") {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return payload, nil
+		}
+	}
+	return nil, ErrInvalidSignature
+}
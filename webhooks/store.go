@@ -0,0 +1,287 @@
+// Copyright (c) Bas van Beek 2025.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// store holds the pending deliveries.
+type store interface {
+	add(ctx context.Context, deliveries ...*Delivery) error
+	// claim returns up to n deliveries due at now, reserving them for the
+	// lease by moving their next attempt.
+	claim(ctx context.Context, now time.Time, lease time.Duration, n int) ([]*Delivery, error)
+	update(ctx context.Context, d *Delivery) error
+	remove(ctx context.Context, id string) error
+	pending(ctx context.Context) (int, error)
+}
+
+// memoryStore holds the pending deliveries in memory.
+type memoryStore struct {
+	mtx        sync.Mutex
+	deliveries map[string]Delivery
+}
+
+func (m *memoryStore) add(_ context.Context, deliveries ...*Delivery) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.deliveries == nil {
+		m.deliveries = make(map[string]Delivery)
+	}
+	for _, d := range deliveries {
+		m.deliveries[d.ID] = *d
+	}
+	return nil
+}
+
+func (m *memoryStore) claim(_ context.Context, now time.Time, lease time.Duration, n int) ([]*Delivery, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	var due []*Delivery
+	for _, d := range m.deliveries {
+		if !d.NextAttempt.After(now) {
+			due = append(due, &d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttempt.Before(due[j].NextAttempt) })
+	if len(due) > n {
+		due = due[:n]
+	}
+	for _, d := range due {
+		d.NextAttempt = now.Add(lease)
+		m.deliveries[d.ID] = *d
+	}
+	return due, nil
+}
+
+func (m *memoryStore) update(ctx context.Context, d *Delivery) error {
+	return m.add(ctx, d)
+}
+
+func (m *memoryStore) remove(_ context.Context, id string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.deliveries, id)
+	return nil
+}
+
+func (m *memoryStore) pending(context.Context) (int, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return len(m.deliveries), nil
+}
+
+// redisStore holds the deliveries in a hash and their next attempts in a
+// sorted set. The keys share a hash tag to support Redis Cluster.
+type redisStore struct {
+	rdb    redis.UniversalClient
+	prefix string
+}
+
+func (r *redisStore) deliveriesKey() string { return r.prefix + "deliveries" }
+func (r *redisStore) dueKey() string        { return r.prefix + "due" }
+
+// claimScript reserves the due deliveries and returns them. Sorted set
+// entries without delivery (removed concurrently) are cleaned up.
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+local deliveries = {}
+for _, id in ipairs(ids) do
+	local d = redis.call('HGET', KEYS[2], id)
+	if d then
+		redis.call('ZADD', KEYS[1], ARGV[2], id)
+		table.insert(deliveries, d)
+	else
+		redis.call('ZREM', KEYS[1], id)
+	end
+end
+return deliveries
+`)
+
+func (r *redisStore) add(ctx context.Context, deliveries ...*Delivery) error {
+	_, err := r.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for _, d := range deliveries {
+			b, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			p.HSet(ctx, r.deliveriesKey(), d.ID, b)
+			p.ZAdd(ctx, r.dueKey(), redis.Z{Score: float64(d.NextAttempt.UnixMilli()), Member: d.ID})
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *redisStore) claim(ctx context.Context, now time.Time, lease time.Duration, n int) ([]*Delivery, error) {
+	res, err := claimScript.Run(ctx, r.rdb, []string{r.dueKey(), r.deliveriesKey()},
+		now.UnixMilli(), now.Add(lease).UnixMilli(), n).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]*Delivery, 0, len(res))
+	for _, v := range res {
+		d := &Delivery{}
+		if err = json.Unmarshal([]byte(v), d); err != nil {
+			log.Error("unable to decode webhook delivery", err)
+			continue
+		}
+		d.NextAttempt = now.Add(lease)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func (r *redisStore) update(ctx context.Context, d *Delivery) error {
+	return r.add(ctx, d)
+}
+
+func (r *redisStore) remove(ctx context.Context, id string) error {
+	_, err := r.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HDel(ctx, r.deliveriesKey(), id)
+		p.ZRem(ctx, r.dueKey(), id)
+		return nil
+	})
+	return err
+}
+
+func (r *redisStore) pending(ctx context.Context) (int, error) {
+	n, err := r.rdb.ZCard(ctx, r.dueKey()).Result()
+	return int(n), err
+}
+
+// sqlStore holds the deliveries in a MySQL table. Times are stored as unix
+// milliseconds so no driver time parsing is required.
+type sqlStore struct {
+	db    *sql.DB
+	table string
+}
+
+func (s *sqlStore) quoted() string {
+	return "`" + strings.ReplaceAll(s.table, ".", "`.`") + "`"
+}
+
+func (s *sqlStore) createTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS `+s.quoted()+` (
+	id           varchar(32) NOT NULL PRIMARY KEY,
+	endpoint     varchar(255) NOT NULL,
+	event_id     varchar(32) NOT NULL,
+	event_type   varchar(255) NOT NULL,
+	payload      longblob,
+	created_at   bigint NOT NULL,
+	attempt      int NOT NULL DEFAULT 0,
+	next_attempt bigint NOT NULL,
+	last_error   text,
+	INDEX next_attempt_idx (next_attempt)
+)`)
+	return err
+}
+
+func (s *sqlStore) add(ctx context.Context, deliveries ...*Delivery) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, d := range deliveries {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO `+s.quoted()+`
+(id, endpoint, event_id, event_type, payload, created_at, attempt, next_attempt, last_error)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			d.ID, d.Endpoint, d.EventID, d.EventType, d.Payload, d.CreatedAt.UnixMilli(),
+			d.Attempt, d.NextAttempt.UnixMilli(), d.LastError); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) claim(ctx context.Context, now time.Time, lease time.Duration, n int) ([]*Delivery, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// SKIP LOCKED lets instances claim deliveries concurrently
+	rows, err := tx.QueryContext(ctx, `SELECT
+id, endpoint, event_id, event_type, payload, created_at, attempt, last_error
+FROM `+s.quoted()+` WHERE next_attempt <= ? ORDER BY next_attempt LIMIT ?
+FOR UPDATE SKIP LOCKED`, now.UnixMilli(), n)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		deliveries []*Delivery
+		ids        []any
+	)
+	for rows.Next() {
+		var (
+			d         = &Delivery{NextAttempt: now.Add(lease)}
+			createdAt int64
+			lastError sql.NullString
+		)
+		if err = rows.Scan(&d.ID, &d.Endpoint, &d.EventID, &d.EventType, &d.Payload,
+			&createdAt, &d.Attempt, &lastError); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		d.CreatedAt = time.UnixMilli(createdAt)
+		d.LastError = lastError.String
+		deliveries = append(deliveries, d)
+		ids = append(ids, d.ID)
+	}
+	if err = errors.Join(rows.Err(), rows.Close()); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE `+s.quoted()+` SET next_attempt = ?
+WHERE id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`,
+		append([]any{now.Add(lease).UnixMilli()}, ids...)...); err != nil {
+		return nil, err
+	}
+	return deliveries, tx.Commit()
+}
+
+func (s *sqlStore) update(ctx context.Context, d *Delivery) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE `+s.quoted()+`
+SET attempt = ?, next_attempt = ?, last_error = ? WHERE id = ?`,
+		d.Attempt, d.NextAttempt.UnixMilli(), d.LastError, d.ID)
+	return err
+}
+
+func (s *sqlStore) remove(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM `+s.quoted()+` WHERE id = ?`, id)
+	return err
+}
+
+func (s *sqlStore) pending(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+s.quoted()).Scan(&n)
+	return n, err
+}